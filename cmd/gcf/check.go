@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gocf/fcheck"
+	"log"
+	"os"
+)
+
+// exit code when any rule failed (gcf check), the junit/sarif report has errors or the Parquet metadata has issues (gcf meta) (1 is used for errors, 2 for invalid arguments)
+const EXIT_RULES_FAILED = 3
+
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	pRules := fs.String("rules", "", "YAML file with the rules (required)")
+	pOutput := fs.String("o", "text", "output format: text, json, junit or sarif")
+	rf := addReaderFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Check data quality rules, exit code is 0 if all rules passed, 3 if any failed, 1 on errors.")
+		fmt.Fprintln(fs.Output(), "usage: gcf check -rules <rules.yaml> [options] <file_name>")
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || *pRules == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	rules, err := fcheck.LoadRules(*pRules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := rules.Check(rf.open(fs.Arg(0)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *pOutput {
+	case "text":
+		res.WriteText(os.Stdout)
+	case "json":
+		err = res.WriteJSON(os.Stdout)
+	case "junit":
+		err = res.WriteJUnit(os.Stdout)
+	case "sarif":
+		err = res.WriteSARIF(os.Stdout)
+	default:
+		log.Fatalf("unknown output format: %s", *pOutput)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if res.Failed > 0 {
+		os.Exit(EXIT_RULES_FAILED)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"gocf/fcheck"
+	"io"
+	"log"
+	"os"
+)
+
+// convertToAvro writes the Avro file to stdout, the schema is read from avscFile (if set) and rejected
+// rows go to rejectsFile (if set)
+func convertToAvro(reader fcheck.FileReader, opts fcheck.AvroOptions, avscFile, rejectsFile string) {
+	if avscFile != "" {
+		avsc, err := os.ReadFile(avscFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Schema = string(avsc)
+	}
+	opts.Rejects = createRejects(rejectsFile)
+	out := bufio.NewWriter(os.Stdout)
+	res, err := fcheck.ToAvro(reader, out, opts)
+	finishConversion(out, res, err)
+}
+
+// convertToParquet writes the Parquet file to stdout, rejected rows go to rejectsFile (if set)
+func convertToParquet(reader fcheck.FileReader, opts fcheck.ParquetOptions, rejectsFile string) {
+	opts.Rejects = createRejects(rejectsFile)
+	out := bufio.NewWriter(os.Stdout)
+	res, err := fcheck.ToParquet(reader, out, opts)
+	finishConversion(out, res, err)
+}
+
+// convertToArrow writes the Arrow IPC file (or stream) to stdout, rejected rows go to rejectsFile (if set)
+func convertToArrow(reader fcheck.FileReader, opts fcheck.ArrowOptions, rejectsFile string) {
+	opts.Rejects = createRejects(rejectsFile)
+	out := bufio.NewWriter(os.Stdout)
+	res, err := fcheck.ToArrow(reader, out, opts)
+	finishConversion(out, res, err)
+}
+
+// createRejects creates the file for rejected rows, nil if not set (the file is closed on exit)
+func createRejects(fileName string) io.Writer {
+	if fileName == "" {
+		return nil
+	}
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f
+}
+
+func finishConversion(out *bufio.Writer, res fcheck.ConvertResult, err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if res.Rejected > 0 {
+		log.Printf("%d rows written, %d rejected", res.Rows, res.Rejected)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"gocf/fcheck"
 	"log"
+	"os"
+	"strings"
 )
 
 
@@ -14,31 +16,152 @@ func main() {
 
 	var pNoOfSamples = flag.Int("m", 5, "number of sample values to include in the report (default 5)")
 	
-	//var pToJson = flag.Bool("j", false, "convert to JSON (instead of generating coverage report")
-	//var pToCsv = flag.Bool("c", false, "convert to CSV (instead of generating coverage report")
+	var pToJson = flag.Bool("j", false, "convert to JSON lines (instead of generating coverage report")
+	var pToCsv = flag.Bool("c", false, "convert to CSV (instead of generating coverage report")
 	var pQuoteCsv = flag.Bool("q", false, "enable quoting strings (only if -c was specified, this may slow things down)")
-	var pCsvDelimiter = flag.String("d", "", "CSV delimiter (if not specified ftest will try to guess)")
-	//var pNumOfRows = flag.Int("n", -1, "number of rows in CSV or JSON output (all by default")
-	// TODO: add error handling, add -f option 
+	var pToAvro = flag.Bool("a", false, "convert to Avro object container file (instead of generating coverage report")
+	var pAvsc = flag.String("avsc", "", "Avro schema (.avsc file) for -a, fields are matched by name (default: derived from the input)")
+	var pToParquet = flag.Bool("p", false, "convert to Parquet (instead of generating coverage report")
+	var pToArrow = flag.Bool("arrow", false, "convert to Arrow IPC file (Feather v2) (instead of generating coverage report")
+	var pArrowStream = flag.Bool("arrow-stream", false, "write the Arrow IPC stream format instead of the file format (with -arrow)")
+	var pBatchRows = flag.Int("batch-rows", 0, "rows per Arrow record batch (default 65536)")
+	var pCodec = flag.String("codec", "", "compression codec, for -a: "+strings.Join(fcheck.AVRO_CODECS, ", ")+" (default null), for -p: "+strings.Join(fcheck.PARQUET_CODECS, ", ")+" (default snappy), for -arrow: "+strings.Join(fcheck.ARROW_CODECS, ", ")+" (default uncompressed)")
+	var pLevel = flag.Int("level", 0, "compression level (deflate 1-9, zstd 1-22, default: codec default)")
+	var pSyncInterval = flag.Int("sync-interval", fcheck.AVRO_SYNC_INTERVAL, "approx. Avro block size in bytes (before compression)")
+	var pBlockRows = flag.Int("block-rows", 0, "max rows per Avro block (default: no limit)")
+	var pRowGroupSize = flag.Int("row-group-size", 0, "approx. Parquet row group size in bytes (default 64MB)")
+	var pPageSize = flag.Int("page-size", 0, "approx. Parquet page size in bytes (default 1MB)")
+	var pDictLimit = flag.Int("dict-limit", 0, "max distinct values of dictionary encoded Parquet string columns (default 10000, -1 disables dictionaries)")
+	var pRejects = flag.String("rejects", "", "write rows that can't be converted to this csv file (with an error column)")
+	var pKeys = flag.String("keys", "", "comma separated key fields, report duplicate keys (implies -dups)")
+	var pDups = flag.Bool("dups", false, "report duplicate rows")
+	var pApprox = flag.Bool("approx", false, "approximate duplicate detection with Bloom filters (for files too big for exact counting)")
+	var pCorr = flag.Float64("corr", 0, "report correlations (|r| or Cramér's V >= the given threshold, e.g. 0.5), functional dependencies and redundant fields")
+	var pPatterns = flag.Bool("patterns", false, "report value patterns (AB-1234 -> AA-9999) and semantic types (email, url, ip, uuid, phone, country code, credit card)")
+	var pPII = flag.Bool("pii", false, "report fields with likely PII (emails, phones, IBANs, card numbers, PESELs, IPs)")
+	var pMask = flag.String("mask", "", "scrub fields: 'fields=action;...', actions: mask, hash (HMAC, key in $GCF_MASK_KEY), token, drop, e.g. 'email,phone_*=hash;card=mask'")
+	var pOutput = flag.String("o", "text", "report format: text, html, markdown, junit or sarif (for CI, exit code is 3 if there are errors: empty fields, coverage below -min-coverage, duplicate keys)")
+	var pMinCoverage = flag.Float64("min-coverage", 0, "minimum coverage (in %) of every field, lower is an error in junit and sarif reports")
+	var rf = addReaderFlags(flag.CommandLine)
+	// TODO: add error handling
 	var usage = func () {
 		fmt.Fprintln(flag.CommandLine.Output(), "Generate coverage and data validity report.")
 		fmt.Fprintln(flag.CommandLine.Output(), "usage: gcf [options] <file_name>")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gcf check -rules <rules.yaml> [options] <file_name> (see gcf check -h)")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gcf schema [options] <file_name> (see gcf schema -h)")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gcf meta <file_name>... (Parquet footer or Avro blocks, see gcf meta -h)")
 		fmt.Fprintln(flag.CommandLine.Output(), "Options:")
 		flag.PrintDefaults()
 	}	
 	flag.Usage = usage
 
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		runCheck(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		runSchema(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "meta" {
+		runMeta(os.Args[2:])
+		return
+	}
 	flag.Parse()
 
 	if flag.NArg() > 0 {
 		var inputFileName string = flag.Arg(0)
 		//fmt.Println(inputFileName)
 		//fmt.Println("#### args:", *pNoSort, *pLeastFreq, *pNoOfSamples, *pToJson, *pToCsv, *pQuoteCsv, *pCsvDelimiter, *pNumOfRows)
-		reader, err := fcheck.NewFileReader(inputFileName, *pNoSort, *pLeastFreq, *pNoOfSamples, *pQuoteCsv, *pCsvDelimiter)
+		reader := rf.open(inputFileName)
+		var err error
+		if *pMask != "" {
+			rules, err := fcheck.ParseMaskRules(*pMask)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if reader, err = fcheck.NewMaskedReader(reader, rules, []byte(os.Getenv("GCF_MASK_KEY"))); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *pToAvro {
+			convertToAvro(reader, fcheck.AvroOptions{Codec: *pCodec, Level: *pLevel, SyncInterval: *pSyncInterval, BlockRows: *pBlockRows}, *pAvsc, *pRejects)
+			return
+		}
+		if *pToParquet {
+			convertToParquet(reader, fcheck.ParquetOptions{Codec: *pCodec, Level: *pLevel, RowGroupSize: *pRowGroupSize, PageSize: *pPageSize, DictionaryLimit: *pDictLimit}, *pRejects)
+			return
+		}
+		if *pToArrow {
+			convertToArrow(reader, fcheck.ArrowOptions{Stream: *pArrowStream, Codec: *pCodec, Level: *pLevel, BatchRows: *pBatchRows}, *pRejects)
+			return
+		}
+		if *pToCsv || *pToJson {
+			if *pToCsv {
+				delimiter := ','
+				if len(*rf.delimiter) > 0 {
+					delimiter = rune((*rf.delimiter)[0])
+				}
+				err = fcheck.ToCsv(reader, os.Stdout, delimiter, *pQuoteCsv)
+			} else {
+				err = fcheck.ToJson(reader, os.Stdout)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		opts := fcheck.ReportOptions{Sorted: !*pNoSort, NoOfSamples: *pNoOfSamples, LeastFrequent: *pLeastFreq}
+		if *pDups || *pKeys != "" {
+			opts.Duplicates = &fcheck.DuplicateOptions{Approximate: *pApprox}
+			if *pKeys != "" {
+				opts.Duplicates.Keys = strings.Split(*pKeys, ",")
+			}
+		}
+		opts.Collectors = fcheck.CL_default
+		if *pPatterns {
+			opts.Collectors |= fcheck.CL_patterns
+		}
+		if *pPII {
+			opts.Collectors |= fcheck.CL_pii
+		}
+		if *pOutput == "html" {
+			opts.Collectors |= fcheck.CL_histogram | fcheck.CL_nullmap
+		}
+		if *pCorr > 0 {
+			opts.Correlations = &fcheck.CorrelationOptions{Threshold: *pCorr, Seed: *rf.seed}
+		}
+		report, err := fcheck.NewReport(reader, opts)
 		if err != nil {
 			log.Fatal(err)
 		}
-		fcheck.TestFile(reader, !*pNoSort, *pNoOfSamples, *pLeastFreq)
+		switch *pOutput {
+		case "text":
+			report.WriteText(os.Stdout)
+			return
+		case "html":
+			if err = report.WriteHTML(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "markdown", "md":
+			report.WriteMarkdown(os.Stdout)
+			return
+		case "junit":
+			err = report.WriteJUnit(os.Stdout, *pMinCoverage)
+		case "sarif":
+			err = report.WriteSARIF(os.Stdout, *pMinCoverage)
+		default:
+			log.Fatalf("unknown report format: %s", *pOutput)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, f := range report.Findings(*pMinCoverage) {
+			if f.Level == fcheck.FL_error {
+				os.Exit(EXIT_RULES_FAILED)
+			}
+		}
 	} else {
 		usage()
 	}
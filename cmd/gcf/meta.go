@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gocf/fcheck"
+	"log"
+	"os"
+)
+
+func runMeta(args []string) {
+	fs := flag.NewFlagSet("meta", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Print the Parquet footer (schema, key-value metadata, row groups, per column compression, encodings, sizes and statistics)")
+		fmt.Fprintln(fs.Output(), "or the Avro header and blocks (metadata, codec, records per block, sync markers) without profiling the data.")
+		fmt.Fprintln(fs.Output(), "Exit code is 3 if the statistics or row counts are missing or inconsistent or if there are corrupted Avro blocks.")
+		fmt.Fprintln(fs.Output(), "usage: gcf meta <file_name>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	issues := 0
+	for i, fileName := range fs.Args() {
+		fm, err := fcheck.ReadFileMeta(fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fm.WriteText(os.Stdout)
+		issues += fm.IssueCount()
+	}
+	if issues > 0 {
+		os.Exit(EXIT_RULES_FAILED)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"gocf/fcheck"
+	"log"
+	"strings"
+)
+
+// readerFlags are the options shared by gcf commands: file format and row/field selection
+type readerFlags struct {
+	delimiter *string
+	format    *string
+	where     *string
+	numOfRows *int
+	sample    *string
+	seed      *int64
+	fields    *string
+	salvage   *bool
+	lenient   *bool
+	repair    *bool
+	maxErrors *int
+	badLines  *string
+	header    *string
+	skip      *int
+	names     *string
+	types     *string
+	layout    *string
+	trim      *string
+	sheet     *string
+	headerRow *int
+}
+
+func addReaderFlags(fs *flag.FlagSet) *readerFlags {
+	return &readerFlags{
+		delimiter: fs.String("d", "", "CSV delimiter (if not specified ftest will try to guess)"),
+		format:    fs.String("t", "", "file format: "+strings.Join(fcheck.Formats(), ", ")+" (if not specified ftest will try to guess)"),
+		where:     fs.String("where", "", "process only rows matching the expression, e.g. 'country == \"PL\" && amount > 0'"),
+		numOfRows: fs.Int("n", -1, "process only the first n rows (all by default), same as -sample head:n"),
+		sample:    fs.String("sample", "", "process only a sample of rows: head:N, tail:N, reservoir:N, fraction:F (e.g. 0.01 or 1%) or every:N"),
+		seed:      fs.Int64("seed", 0, "seed for random sampling (default: random, the seed used is shown in the report)"),
+		salvage:   fs.Bool("salvage", false, "Avro: skip corrupted blocks (resynchronizing on the sync marker) instead of failing, bytes and records lost are shown in the report"),
+		lenient:   fs.Bool("lenient", false, "CSV: skip malformed records (wrong number of fields, bare or missing quotes) instead of failing, they are counted in the report"),
+		repair:    fs.Bool("repair", false, "CSV: pad records with missing fields with empty values and drop extra fields (implies -lenient)"),
+		maxErrors: fs.Int("max-errors", 0, "CSV: fail if there are more malformed records (with -lenient, 0 - no limit)"),
+		badLines:  fs.String("bad-lines", "", "CSV: write the raw lines of the skipped records to this file (with -lenient)"),
+		header:    fs.String("header", "auto", "CSV: auto, yes or no - is the first line (after -skip) a header with the column names"),
+		skip:      fs.Int("skip", 0, "CSV: skip the first n lines (banners, comments)"),
+		names:     fs.String("names", "", "CSV: comma separated column names, replace the header or the default c_0, c_1 ..."),
+		types:     fs.String("types", "", "CSV: override column types, e.g. zip=string,amount=float (string, int or float)"),
+		layout:    fs.String("layout", "", "fixed-width: copybook with the record layout (PIC X(n), 9(n), S9(n)V9(n) ...), fixed-width files are read only with a layout"),
+		trim:      fs.String("trim", "both", "fixed-width: spaces removed from string fields: both, right, left or none"),
+		sheet:     fs.String("sheet", "", "xlsx: sheet name or 1-based index (the first sheet by default)"),
+		headerRow: fs.Int("header-row", 0, "xlsx: row number of the header, rows above it are skipped (0 - the first non-empty row, -1 - no header, columns are named A, B, C ...)"),
+		fields:    fs.String("f", "", "comma separated list of fields to process: names, globs (amount_*) or regexps (/^dt_\\d+$/), prefix with ! to exclude"),
+	}
+}
+
+// open creates the reader for the file, wrapped with the filter, projection and sampling
+func (rf *readerFlags) open(inputFileName string) fcheck.FileReader {
+	readerOpts := fcheck.ReaderOptions{}
+	csvOpts := fcheck.CsvOptions{Lenient: *rf.lenient, Repair: *rf.repair, MaxErrors: *rf.maxErrors, Rejects: createRejects(*rf.badLines)}
+	if len(*rf.delimiter) > 0 {
+		csvOpts.Delimiter = rune((*rf.delimiter)[0])
+	}
+	var err error
+	if csvOpts.Header, err = fcheck.ParseCsvHeader(*rf.header); err != nil {
+		log.Fatal(err)
+	}
+	csvOpts.SkipLines = *rf.skip
+	if *rf.names != "" {
+		csvOpts.Names = strings.Split(*rf.names, ",")
+	}
+	if *rf.types != "" {
+		csvOpts.Types = map[string]fcheck.DataType{}
+		for _, nt := range strings.Split(*rf.types, ",") {
+			name, typ, ok := strings.Cut(nt, "=")
+			if !ok {
+				log.Fatalf("invalid type override: %s (expected name=type)", nt)
+			}
+			if csvOpts.Types[name], err = fcheck.ParseDataType(typ); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	readerOpts["csv"] = csvOpts
+	if *rf.layout != "" {
+		layout, err := fcheck.LoadFixedLayout(*rf.layout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		trim, err := fcheck.ParseFixedTrim(*rf.trim)
+		if err != nil {
+			log.Fatal(err)
+		}
+		readerOpts["fixed"] = fcheck.FixedWidthOptions{Layout: layout, Trim: trim}
+	}
+	readerOpts["xlsx"] = fcheck.XlsxOptions{Sheet: *rf.sheet, HeaderRow: *rf.headerRow}
+	if *rf.salvage {
+		readerOpts["avro"] = fcheck.AvroReaderOptions{Salvage: true}
+	}
+	var reader fcheck.FileReader
+	if *rf.format != "" {
+		reader, err = fcheck.NewFileReaderOf(*rf.format, inputFileName, readerOpts)
+	} else {
+		reader, err = fcheck.NewFileReader(inputFileName, readerOpts)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rf.where != "" {
+		if reader, err = fcheck.NewFilteredReader(reader, *rf.where); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *rf.fields != "" {
+		if reader, err = fcheck.NewProjectedReader(reader, *rf.fields); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *rf.sample != "" || *rf.numOfRows >= 0 {
+		sampling := fcheck.Sampling{Mode: fcheck.SM_head, N: *rf.numOfRows}
+		if *rf.sample != "" {
+			if sampling, err = fcheck.ParseSampling(*rf.sample); err != nil {
+				log.Fatal(err)
+			}
+		}
+		sampling.Seed = *rf.seed
+		reader = fcheck.NewSampledReader(reader, sampling)
+	}
+	return reader
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gocf/fcheck"
+	"log"
+	"os"
+	"strings"
+)
+
+func runSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	pOutput := fs.String("o", "avsc", "schema format: "+strings.Join(fcheck.SchemaFormats(), ", "))
+	pName := fs.String("name", "", "table/record name (default: the file name without extension)")
+	rf := addReaderFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Print the schema inferred from the data: types, nullability (from observed nulls) and max string lengths.")
+		fmt.Fprintln(fs.Output(), "usage: gcf schema [options] <file_name>")
+		fmt.Fprintln(fs.Output(), "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	report, err := fcheck.NewReport(rf.open(fs.Arg(0)), fcheck.ReportOptions{Collectors: fcheck.CL_default})
+	if err != nil {
+		log.Fatal(err)
+	}
+	schema := fcheck.NewSchema(report)
+	if *pName != "" {
+		schema.Name = *pName
+	}
+	if err := schema.Write(os.Stdout, *pOutput); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,307 @@
+package fcheck
+
+import (
+	"bytes"
+	"fmt"
+	"gocf/fcheck/arrow"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// continuation marker of the messages of the Arrow stream format
+var arrowContinuation = []byte{0xff, 0xff, 0xff, 0xff}
+
+// ArrowReader reads Arrow IPC files (and Feather v2) and streams, record batches are decoded column by column
+// into typed vectors (see ReadBatches), unselected columns (Project) are not decoded
+type ArrowReader struct {
+	fileName string
+	file     *os.File
+	reader   *arrow.Reader
+	fields   []string
+	types    []DataType
+	selected []int // positions of the selected fields in the schema
+	rows     int
+	readStop
+}
+
+func NewArrowReader(fileName string) *ArrowReader {
+	return &ArrowReader{fileName: fileName}
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "arrow",
+		Type:       FT_arrow,
+		Magic:      arrow.MAGIC,
+		Extensions: []string{".arrow", ".feather", ".arrows", ".ipc"},
+		// the stream format starts with a message (continuation marker and the metadata length)
+		Sniff: func(fileName string, head []byte, opts any) bool {
+			return bytes.HasPrefix(head, arrowContinuation) && len(head) >= 8 && bytes.Count(head[4:8], []byte{0}) < 4
+		},
+		New: func(fileName string, opts any) (FileReader, error) {
+			return NewArrowReader(fileName), nil
+		},
+	})
+}
+
+func (ar *ArrowReader) FileName() string {
+	return ar.fileName
+}
+
+// arrowDataType maps the kind of the column values to a DataType
+func arrowDataType(f *arrow.Field) DataType {
+	kind := f.Kind()
+	switch {
+	case kind == arrow.K_int:
+		return DT_int
+	case kind == arrow.K_float:
+		return DT_float
+	}
+	return DT_string
+}
+
+// open opens the file and reads the schema, the reader is positioned at the first batch
+func (ar *ArrowReader) open() error {
+	if ar.file != nil {
+		ar.file.Close()
+	}
+	f, err := os.Open(ar.fileName)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r, err := arrow.NewReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", ar.fileName, err)
+	}
+	ar.file, ar.reader = f, r
+	return nil
+}
+
+func (ar *ArrowReader) Init() {
+	if err := ar.open(); err != nil {
+		log.Fatal(err)
+	}
+	ar.fields, ar.types, ar.selected = nil, nil, nil
+	for i, f := range ar.reader.Schema.Fields {
+		ar.fields = append(ar.fields, f.Name)
+		ar.types = append(ar.types, arrowDataType(f))
+		ar.selected = append(ar.selected, i)
+	}
+	ar.reader.Project(ar.selected)
+}
+
+// Project implements Projector
+func (ar *ArrowReader) Project(fields []string) error {
+	selected := make([]int, len(fields))
+	types := make([]DataType, len(fields))
+	for i, name := range fields {
+		k := indexof(ar.fields, name)
+		if k < 0 {
+			return fmt.Errorf("unknown field: %s", name)
+		}
+		selected[i] = ar.selected[k]
+		types[i] = ar.types[k]
+	}
+	ar.selected = selected
+	ar.fields = fields
+	ar.types = types
+	ar.reader.Project(selected)
+	return nil
+}
+
+func (ar *ArrowReader) GetFields() []string {
+	return ar.fields
+}
+
+func (ar *ArrowReader) GetTypes() []DataType {
+	return ar.types
+}
+
+func (ar *ArrowReader) GetFileInfo() string {
+	r := ar.reader
+	info := fmt.Sprintf("arrow %s, %d record batches, %d rows", r.Format, r.Batches(), ar.rows)
+	if r.Compression != "" {
+		info += ", " + r.Compression
+	}
+	var nested []string
+	for _, f := range r.Schema.Fields {
+		if len(f.Children) > 0 {
+			nested = append(nested, f.Name+": "+f.String())
+		}
+	}
+	if len(nested) > 0 {
+		info += ", nested fields read as JSON (" + strings.Join(nested, ", ") + ")"
+	}
+	return info
+}
+
+// ReadBatches implements BatchReader, the record batches of the file are sent as they are (size is ignored),
+// the vectors share the decoded column buffers
+func (ar *ArrowReader) ReadBatches(size int) chan *ColumnBatch {
+	return ar.readBatches(ar.begin())
+}
+
+// readBatches sends the record batches till the end of the file or till done is closed
+func (ar *ArrowReader) readBatches(done chan struct{}) chan *ColumnBatch {
+	out := make(chan *ColumnBatch)
+	go func() {
+		if ar.reader.Batches() > 0 {
+			// read again
+			if err := ar.open(); err != nil {
+				log.Fatal(err)
+			}
+			ar.reader.Project(ar.selected)
+		}
+		defer ar.file.Close()
+		ar.rows = 0
+		for {
+			b, err := ar.reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatalf("%s: %v", ar.fileName, err)
+			}
+			batch := &ColumnBatch{Rows: b.Rows, Columns: make([]*Vector, len(ar.selected))}
+			for i, k := range ar.selected {
+				c := b.Columns[k]
+				batch.Columns[i] = &Vector{Type: ar.types[i], Valid: c.Valid, Ints: c.Ints, Floats: c.Floats, Strings: c.Strings}
+			}
+			ar.rows += b.Rows
+			select {
+			case out <- batch:
+			case <-done:
+				close(out)
+				return
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// Read sends the rows of the batches, see ReadBatches
+func (ar *ArrowReader) Read() chan []any {
+	out := make(chan []any)
+	done := ar.begin()
+	batches := ar.readBatches(done)
+	go func() {
+		for b := range batches {
+			for r := 0; r < b.Rows; r++ {
+				select {
+				case out <- b.Row(r, nil):
+				case <-done:
+					// the batches are stopped too
+					for range batches {
+					}
+					close(out)
+					return
+				}
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// ARROW_CODECS compression codecs supported by ToArrow
+var ARROW_CODECS = []string{"uncompressed", "zstd"}
+
+// ArrowOptions controls ToArrow
+type ArrowOptions struct {
+	// Stream writes the stream format instead of the file format
+	Stream bool
+	Codec  string // one of ARROW_CODECS, uncompressed if empty
+	Level  int    // zstd level (1-22)
+	// BatchRows is the number of rows of a record batch, arrow.BATCH_ROWS if 0
+	BatchRows int
+	// Rejects gets the rows that can't be converted to the column types as csv, with an error column, dropped if nil
+	Rejects io.Writer
+}
+
+// ToArrow writes all rows (Init() is called here) as an Arrow IPC file, the schema is derived from GetTypes:
+// int -> int64, float -> float64, string -> utf8, all fields nullable
+func ToArrow(fr FileReader, w io.Writer, opts ArrowOptions) (ConvertResult, error) {
+	var res ConvertResult
+	fr.Init()
+	fields := fr.GetFields()
+	types := fr.GetTypes()
+	if opts.Codec == "uncompressed" {
+		opts.Codec = ""
+	}
+	afields := make([]*arrow.Field, len(fields))
+	for i, f := range fields {
+		afields[i] = &arrow.Field{Name: f, Nullable: true, Type: arrow.T_utf8}
+		switch types[i] {
+		case DT_int:
+			afields[i].Type, afields[i].BitWidth, afields[i].Signed = arrow.T_int, 64, true
+		case DT_float:
+			afields[i].Type, afields[i].Precision = arrow.T_floating_point, arrow.P_double
+		}
+	}
+	base := filepath.Base(fr.FileName())
+	aw, err := arrow.NewWriter(w, afields, arrow.WriterOptions{Stream: opts.Stream, Codec: opts.Codec, Level: opts.Level,
+		BatchRows: opts.BatchRows, Metadata: []arrow.KeyValue{{Key: "source", Value: base}}})
+	if err != nil {
+		return res, err
+	}
+	rejects := newRejectWriter(opts.Rejects, fields)
+	values := make([]any, len(fields))
+	rows := fr.Read()
+	for row := range rows {
+		var err error
+		for i, v := range row {
+			if values[i], err = arrowValue(afields[i], v); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = aw.Write(values)
+		}
+		if err != nil {
+			res.Rejected++
+			if err = rejects.write(row, err); err != nil {
+				drain(rows)
+				return res, err
+			}
+			continue
+		}
+		res.Rows++
+	}
+	if err := rejects.flush(); err != nil {
+		return res, err
+	}
+	return res, aw.Close()
+}
+
+// arrowValue converts the value to the field type, empty strings are nulls for numeric fields
+func arrowValue(f *arrow.Field, value any) (any, error) {
+	if s, ok := value.(string); value == nil || ok && s == "" && f.Type != arrow.T_utf8 {
+		return nil, nil
+	}
+	switch f.Type {
+	case arrow.T_int:
+		v, err := toInt64(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		return v, nil
+	case arrow.T_floating_point:
+		v, err := toFloat64(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		return v, nil
+	}
+	s, _ := formatValue(value)
+	return s, nil
+}
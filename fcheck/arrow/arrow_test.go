@@ -0,0 +1,293 @@
+package arrow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func testFields() []*Field {
+	return []*Field{
+		{Name: "id", Type: T_int, BitWidth: 64, Signed: true},
+		{Name: "amount", Nullable: true, Type: T_floating_point, Precision: P_double},
+		{Name: "name", Nullable: true, Type: T_utf8},
+		{Name: "ok", Nullable: true, Type: T_bool},
+	}
+}
+
+func writeRows(t *testing.T, opts WriterOptions, rows [][]any) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testFields(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readAll returns the values of all batches formatted with fmt
+func readAll(r *Reader) ([]string, int, error) {
+	var rows []string
+	batches := 0
+	for {
+		b, err := r.Next()
+		if err == io.EOF {
+			return rows, batches, nil
+		}
+		if err != nil {
+			return nil, batches, err
+		}
+		batches++
+		for i := 0; i < b.Rows; i++ {
+			var row []string
+			for _, c := range b.Columns {
+				if c == nil {
+					row = append(row, "-")
+				} else {
+					row = append(row, fmt.Sprint(c.Value(i)))
+				}
+			}
+			rows = append(rows, strings.Join(row, " "))
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	rows := [][]any{
+		{int64(1), 10.5, "a", true},
+		{int64(-2), nil, "", false},
+		{int64(3), math.Inf(1), nil, nil},
+		{int64(math.MaxInt64), -0.25, "zażółć", true},
+		{int64(5), 1e300, "e", nil},
+	}
+	expected := []string{"1 10.5 a true", "-2 <nil>  false", "3 +Inf <nil> <nil>",
+		"9223372036854775807 -0.25 zażółć true", "5 1e+300 e <nil>"}
+	for _, opts := range []WriterOptions{
+		{},
+		{Stream: true},
+		{Codec: "zstd", BatchRows: 2},
+		{Stream: true, Codec: "zstd", Level: 19, Metadata: []KeyValue{{Key: "source", Value: "test"}}},
+	} {
+		b := writeRows(t, opts, rows)
+		r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if format := map[bool]string{false: "file", true: "stream"}[opts.Stream]; r.Format != format {
+			t.Errorf("%+v: expected the %s format, got %s", opts, format, r.Format)
+		}
+		if len(r.Schema.Fields) != 4 || r.Schema.Fields[0].String() != "int64" || r.Schema.Fields[1].String() != "float64" ||
+			r.Schema.Fields[2].Name != "name" || !r.Schema.Fields[2].Nullable || r.Schema.Fields[0].Nullable {
+			t.Errorf("%+v: unexpected schema: %+v", opts, r.Schema.Fields)
+		}
+		if len(opts.Metadata) > 0 && (len(r.Schema.Metadata) != 1 || r.Schema.Metadata[0] != opts.Metadata[0]) {
+			t.Errorf("%+v: unexpected metadata: %v", opts, r.Schema.Metadata)
+		}
+		values, batches, err := readAll(r)
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if fmt.Sprint(values) != fmt.Sprint(expected) {
+			t.Errorf("%+v: unexpected rows:\n%q", opts, values)
+		}
+		if opts.BatchRows == 2 && (batches != 3 || r.Batches() != 3) {
+			t.Errorf("%+v: expected 3 batches, got %d", opts, batches)
+		}
+		if opts.Codec != "" && r.Compression != "zstd" {
+			t.Errorf("%+v: unexpected compression %q", opts, r.Compression)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	b := writeRows(t, WriterOptions{}, [][]any{{int64(1), 2.0, "a", true}, {int64(2), nil, "b", false}})
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Project([]int{2, 0})
+	values, _, err := readAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%q", values) != `["1 - a -" "2 - b -"]` {
+		t.Errorf("unexpected rows: %q", values)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, testFields(), WriterOptions{Codec: "lz4"}); err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+	if _, err := NewWriter(&buf, []*Field{{Name: "d", Type: T_date}}, WriterOptions{}); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+	w, err := NewWriter(&buf, testFields(), WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]any{
+		{int64(1), 2.0, "a"},
+		{nil, 2.0, "a", true},
+		{int64(1), "2", "a", true},
+		{1, 2.0, "a", true},
+	} {
+		if err := w.Write(row); err == nil {
+			t.Errorf("expected an error for %v", row)
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	b := writeRows(t, WriterOptions{}, [][]any{{int64(1), 2.0, "a", true}})
+	stream := writeRows(t, WriterOptions{Stream: true}, [][]any{{int64(1), 2.0, "a", true}})
+	corrupted := append([]byte{}, b...)
+	// the footer length
+	corrupted[len(b)-10] = 0xf0
+	for name, data := range map[string][]byte{
+		"empty":             nil,
+		"truncated file":    b[:len(b)-3],
+		"corrupted footer":  corrupted,
+		"truncated stream":  stream[:12],
+		"not a schema":      {0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0},
+		"text":              []byte("id,name\n1,a\n2,b\n"),
+		"huge metadata len": {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err == nil {
+			_, _, err = readAll(r)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	// the stream without its last batch and end of stream marker
+	r, err := NewReader(bytes.NewReader(stream[:len(stream)-20]), int64(len(stream)-20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readAll(r); err == nil {
+		t.Error("expected an error for a truncated batch")
+	}
+}
+
+// TestReadLz4 reads a file written by Arrow Go (arrow/ipc with the lz4 frame codec), two batches of the same 5 rows
+func TestReadLz4(t *testing.T) {
+	b, err := os.ReadFile("../../test/data/arrow_lz4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, batches, err := readAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batches != 2 || len(values) != 10 || r.Compression != "lz4_frame" {
+		t.Fatalf("unexpected file: %d batches, %d rows, compression %q", batches, len(values), r.Compression)
+	}
+	expected := []string{
+		`0 4000000000 0.5 1.5 true 2022-01-08 2023-01-01T00:00:00Z 01:00:00 -123.45 PL [] {"x":0,"y":"y"} large0 0`,
+		`-2 4000000002 <nil> 1.5 <nil> 2022-01-10 2023-01-01T00:00:03Z 01:00:00.002 -123.43 <nil> <nil> {"x":2,"y":"y"} <nil> 120`,
+		`-4 4000000004 4.5 1.5 true 2022-01-12 2023-01-01T00:00:06Z 01:00:00.004 -123.41 PL [0,1,2,3] {"x":4,"y":"y"} large4 240`,
+	}
+	for i, row := range []int{5, 7, 9} {
+		if values[row] != expected[i] {
+			t.Errorf("row %d: expected\n%s, got\n%s", row, expected[i], values[row])
+		}
+	}
+}
+
+// TestReaderCorrupted reads the files with each byte flipped and truncated at each length, corrupted lengths
+// must be errors, not panics or huge allocations
+func TestReaderCorrupted(t *testing.T) {
+	rows := [][]any{{int64(1), 2.0, "a", true}, {int64(2), nil, "bb", nil}, {int64(3), 4.5, nil, false}}
+	lz4, err := os.ReadFile("../../test/data/arrow_lz4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats runtime.MemStats
+	for name, b := range map[string][]byte{
+		"file":        writeRows(t, WriterOptions{}, rows),
+		"zstd stream": writeRows(t, WriterOptions{Stream: true, Codec: "zstd", BatchRows: 2}, rows),
+		"lz4 file":    lz4,
+	} {
+		read := func(what string, data []byte) {
+			runtime.ReadMemStats(&stats)
+			before := stats.TotalAlloc
+			if r, err := NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+				readAll(r)
+			}
+			runtime.ReadMemStats(&stats)
+			if allocated := stats.TotalAlloc - before; allocated > 16<<20 {
+				t.Errorf("%s, %s: allocated %d bytes", name, what, allocated)
+			}
+		}
+		for i := range b {
+			data := append([]byte{}, b...)
+			data[i] ^= 0xff
+			read(fmt.Sprintf("byte %d flipped", i), data)
+			read(fmt.Sprintf("truncated at %d", i), b[:i])
+		}
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	for _, c := range []struct {
+		b        []byte
+		scale    int
+		expected string
+	}{
+		{[]byte{0x39, 0x30, 0, 0}, 2, "123.45"},
+		{[]byte{0xc7, 0xcf, 0xff, 0xff}, 2, "-123.45"},
+		{[]byte{5, 0}, 3, "0.005"},
+		{[]byte{0xfb, 0xff}, 3, "-0.005"},
+		{[]byte{7, 0}, -2, "700"},
+		{[]byte{7, 0}, 0, "7"},
+	} {
+		if s := decimal(c.b, c.scale); s != c.expected {
+			t.Errorf("decimal(%v, %d): expected %s, got %s", c.b, c.scale, c.expected, s)
+		}
+	}
+	for h, expected := range map[uint16]float64{0x3c00: 1, 0xc000: -2, 0x3555: 0.333251953125, 0x0001: math.Pow(2, -24),
+		0x7c00: math.Inf(1), 0x0000: 0} {
+		if v := half(h); v != expected {
+			t.Errorf("half(%#x): expected %v, got %v", h, expected, v)
+		}
+	}
+	if v := half(0x7e00); !math.IsNaN(v) {
+		t.Errorf("half(0x7e00): expected NaN, got %v", v)
+	}
+	for _, c := range []struct {
+		f        *Field
+		expected string
+	}{
+		{&Field{Type: T_int, BitWidth: 16}, "uint16"},
+		{&Field{Type: T_timestamp, Unit: U_millisecond, Timezone: "UTC"}, "timestamp[ms, UTC]"},
+		{&Field{Type: T_time, BitWidth: 64, Unit: U_nanosecond}, "time64[ns]"},
+		{&Field{Type: T_decimal, BitWidth: 128, Precision: 10, Scale: 2}, "decimal128(10, 2)"},
+		{&Field{Type: T_date, Unit: U_day}, "date32"},
+		{&Field{Type: T_list, Children: []*Field{{Name: "item", Type: T_utf8}}}, "list<item: utf8>"},
+		{&Field{Type: T_utf8, Dictionary: &DictionaryEncoding{IndexType: &Field{Type: T_int, BitWidth: 8, Signed: true}}},
+			"dictionary<values=utf8, indices=int8>"},
+	} {
+		if s := c.f.String(); s != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, s)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Flatbuffers, just enough for the Arrow IPC metadata (Message.fbs, Schema.fbs, File.fbs).
+// Tables are read in place, out of range offsets of corrupted metadata panic and are recovered by parse functions.
+
+var le = binary.LittleEndian
+
+// table is a flatbuffers table at pos in b
+type table struct {
+	b   []byte
+	pos int
+}
+
+// rootTable returns the root table of the buffer
+func rootTable(b []byte) table {
+	return table{b, int(le.Uint32(b))}
+}
+
+// offset returns the offset of the field (slot) in the table, 0 if it's not set
+func (t table) offset(slot int) int {
+	vt := t.pos - int(int32(le.Uint32(t.b[t.pos:])))
+	o := 4 + 2*slot
+	if o+2 > int(le.Uint16(t.b[vt:])) {
+		return 0
+	}
+	return int(le.Uint16(t.b[vt+o:]))
+}
+
+func (t table) uint8(slot int, def uint8) uint8 {
+	if o := t.offset(slot); o != 0 {
+		return t.b[t.pos+o]
+	}
+	return def
+}
+
+func (t table) bool(slot int) bool {
+	return t.uint8(slot, 0) != 0
+}
+
+func (t table) int16(slot int, def int16) int16 {
+	if o := t.offset(slot); o != 0 {
+		return int16(le.Uint16(t.b[t.pos+o:]))
+	}
+	return def
+}
+
+func (t table) int32(slot int, def int32) int32 {
+	if o := t.offset(slot); o != 0 {
+		return int32(le.Uint32(t.b[t.pos+o:]))
+	}
+	return def
+}
+
+func (t table) int64(slot int, def int64) int64 {
+	if o := t.offset(slot); o != 0 {
+		return int64(le.Uint64(t.b[t.pos+o:]))
+	}
+	return def
+}
+
+// indirect follows the uoffset at p
+func (t table) indirect(p int) int {
+	return p + int(le.Uint32(t.b[p:]))
+}
+
+func (t table) table(slot int) (table, bool) {
+	o := t.offset(slot)
+	if o == 0 {
+		return table{}, false
+	}
+	return table{t.b, t.indirect(t.pos + o)}, true
+}
+
+func (t table) string(slot int) string {
+	o := t.offset(slot)
+	if o == 0 {
+		return ""
+	}
+	p := t.indirect(t.pos + o)
+	n := int(le.Uint32(t.b[p:]))
+	return string(t.b[p+4 : p+4+n])
+}
+
+// vector returns the position of the first element and the length of a vector field of elements of the given size,
+// it panics if the vector doesn't fit in the buffer (a corrupted length would allocate too much)
+func (t table) vector(slot int, size int) (int, int) {
+	o := t.offset(slot)
+	if o == 0 {
+		return 0, 0
+	}
+	p := t.indirect(t.pos + o)
+	n := int(le.Uint32(t.b[p:]))
+	if n > (len(t.b)-p-4)/size {
+		panic(fmt.Sprintf("vector of %d elements at %d out of range", n, p))
+	}
+	return p + 4, n
+}
+
+// tables returns the tables of a vector of tables
+func (t table) tables(slot int) []table {
+	p, n := t.vector(slot, 4)
+	tables := make([]table, n)
+	for i := range tables {
+		tables[i] = table{t.b, t.indirect(p + 4*i)}
+	}
+	return tables
+}
+
+// structs returns the bytes of a vector of structs of the given size
+func (t table) structs(slot int, size int) []byte {
+	p, n := t.vector(slot, size)
+	return t.b[p : p+n*size]
+}
+
+// recoverParse turns a panic on corrupted metadata into an error
+func recoverParse(what string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("corrupted %s: %v", what, r)
+	}
+}
+
+// builder objects: *fbTable, string, fbVector (of tables or strings) and fbStructs
+type fbField struct {
+	size int    // 1, 2, 4 or 8 for scalars, 0 for references
+	val  uint64 // scalar value
+	ref  any    // referenced object
+}
+
+type fbTable []*fbField // by slot, nil fields are not set
+
+type fbVector []any
+
+type fbStructs struct {
+	data  []byte
+	n     int
+	align int
+}
+
+func fbScalar(size int, v uint64) *fbField {
+	return &fbField{size: size, val: v}
+}
+
+func fbBool(v bool) *fbField {
+	if v {
+		return fbScalar(1, 1)
+	}
+	return fbScalar(1, 0)
+}
+
+func fbRef(obj any) *fbField {
+	return &fbField{ref: obj}
+}
+
+// fbBuilder writes flatbuffers front to back: a table is followed by the objects it references (uoffsets point forward),
+// the vtable is written just before its table
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbBuild returns the flatbuffer with the root table, padded to 8 bytes
+func fbBuild(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	pos := b.object(root)
+	le.PutUint32(b.buf, uint32(pos))
+	b.pad(8)
+	return b.buf
+}
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) put(p int, size int, v uint64) {
+	switch size {
+	case 1:
+		b.buf[p] = byte(v)
+	case 2:
+		le.PutUint16(b.buf[p:], uint16(v))
+	case 4:
+		le.PutUint32(b.buf[p:], uint32(v))
+	case 8:
+		le.PutUint64(b.buf[p:], v)
+	}
+}
+
+func (b *fbBuilder) grow(n int) int {
+	p := len(b.buf)
+	b.buf = append(b.buf, make([]byte, n)...)
+	return p
+}
+
+// object writes the object and returns its position
+func (b *fbBuilder) object(obj any) int {
+	switch o := obj.(type) {
+	case fbTable:
+		return b.table(o)
+	case string:
+		b.pad(4)
+		p := b.grow(4 + len(o) + 1)
+		le.PutUint32(b.buf[p:], uint32(len(o)))
+		copy(b.buf[p+4:], o)
+		return p
+	case fbVector:
+		b.pad(4)
+		p := b.grow(4 + 4*len(o))
+		le.PutUint32(b.buf[p:], uint32(len(o)))
+		for i, e := range o {
+			ep := p + 4 + 4*i
+			// b.buf grows while the object is written
+			op := b.object(e)
+			le.PutUint32(b.buf[ep:], uint32(op-ep))
+		}
+		return p
+	case fbStructs:
+		for (len(b.buf)+4)%o.align != 0 {
+			b.buf = append(b.buf, 0)
+		}
+		p := b.grow(4)
+		le.PutUint32(b.buf[p:], uint32(o.n))
+		b.buf = append(b.buf, o.data...)
+		return p
+	}
+	panic(fmt.Sprintf("flatbuffers: unexpected object %T", obj))
+}
+
+func (b *fbBuilder) table(t fbTable) int {
+	// field offsets in the table, each aligned to its size, the table starts at an 8 byte boundary
+	offsets := make([]int, len(t))
+	size := 4
+	for i, f := range t {
+		if f == nil {
+			continue
+		}
+		fs := f.size
+		if fs == 0 {
+			fs = 4
+		}
+		for size%fs != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += fs
+	}
+	b.pad(2)
+	vt := b.grow(4 + 2*len(t))
+	le.PutUint16(b.buf[vt:], uint16(4+2*len(t)))
+	le.PutUint16(b.buf[vt+2:], uint16(size))
+	for i, o := range offsets {
+		le.PutUint16(b.buf[vt+4+2*i:], uint16(o))
+	}
+	b.pad(8)
+	p := b.grow(size)
+	le.PutUint32(b.buf[p:], uint32(p-vt))
+	for i, f := range t {
+		if f != nil && f.size > 0 {
+			b.put(p+offsets[i], f.size, f.val)
+		}
+	}
+	for i, f := range t {
+		if f != nil && f.size == 0 {
+			fp := p + offsets[i]
+			op := b.object(f.ref)
+			le.PutUint32(b.buf[fp:], uint32(op-fp))
+		}
+	}
+	return p
+}
@@ -0,0 +1,937 @@
+package arrow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// MAGIC of the IPC file format, the file starts with it (padded to 8 bytes) and ends with it
+var MAGIC = []byte("ARROW1")
+
+// message header types (the MessageHeader union of Message.fbs)
+const (
+	MH_schema           = 1
+	MH_dictionary_batch = 2
+	MH_record_batch     = 3
+)
+
+// body compression codecs
+const (
+	C_lz4_frame = 0
+	C_zstd      = 1
+)
+
+// max ratios of uncompressed to compressed size of lz4 and zstd buffers
+const (
+	LZ4_MAX_RATIO  = 256
+	ZSTD_MAX_RATIO = 32 << 10
+)
+
+// max size of the message metadata, protects from corrupted lengths
+const MAX_METADATA_SIZE = 64 << 20
+
+// Column holds the values of a field in a batch, by the field Kind. Valid is the validity bitmap
+// (bit i set if the value i is not null), nil if there are no nulls.
+type Column struct {
+	Kind    Kind
+	Len     int
+	Valid   []byte
+	Ints    []int64
+	Floats  []float64
+	Strings []string
+}
+
+// IsNull tells if the i-th value is null
+func (c *Column) IsNull(i int) bool {
+	return c.Valid != nil && c.Valid[i/8]&(1<<(i%8)) == 0
+}
+
+// Value returns the i-th value: nil, int64, float64 or string
+func (c *Column) Value(i int) any {
+	if c.IsNull(i) {
+		return nil
+	}
+	switch c.Kind {
+	case K_int:
+		return c.Ints[i]
+	case K_float:
+		return c.Floats[i]
+	}
+	return c.Strings[i]
+}
+
+// Batch is a decoded record batch, a column for each field of the schema (nil if not selected, see Reader.Project)
+type Batch struct {
+	Rows    int
+	Columns []*Column
+}
+
+// block is the position of a message in the file format
+type block struct {
+	offset     int64
+	metaLength int32
+	bodyLength int64
+}
+
+// Reader reads record batches of the file or stream format, dictionary batches are applied to the dictionary
+// encoded fields
+type Reader struct {
+	Schema *Schema
+	// Format is "file" or "stream"
+	Format string
+	// Compression of the batch bodies read so far: "", "zstd" or "lz4_frame"
+	Compression string
+	r           *io.SectionReader // stream format
+	ra          io.ReaderAt
+	size        int64
+	blocks      []block // file format: dictionaries then record batches
+	next        int
+	dicts       map[int64]*array
+	dictFields  map[int64]*Field
+	zstd        *zstd.Decoder
+	lz4         *lz4.Reader
+	batches     int
+	selected    []bool // decoded columns, all if nil
+}
+
+// NewReader reads the schema of the file or stream, the file format is recognized by the magic bytes
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	ar := &Reader{size: size, dicts: map[int64]*array{}, dictFields: map[int64]*Field{}}
+	head := make([]byte, 8)
+	tail := make([]byte, 10)
+	if size >= 8+10 {
+		if _, err := r.ReadAt(head, 0); err != nil {
+			return nil, err
+		}
+		if _, err := r.ReadAt(tail, size-10); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.HasPrefix(head, MAGIC) && bytes.Equal(tail[4:], MAGIC) {
+		ar.Format = "file"
+		ar.ra = r
+		if err := ar.readFooter(size, int64(le.Uint32(tail))); err != nil {
+			return nil, err
+		}
+		return ar, nil
+	}
+	if bytes.HasPrefix(head, MAGIC) {
+		return nil, errors.New("truncated arrow file (no footer)")
+	}
+	ar.Format = "stream"
+	ar.r = io.NewSectionReader(r, 0, size)
+	msg, _, err := ar.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil || msg.headerType != MH_schema {
+		return nil, errors.New("arrow stream doesn't start with a schema")
+	}
+	return ar, ar.setSchema(msg.header)
+}
+
+type message struct {
+	headerType uint8
+	header     table
+	bodyLength int64
+}
+
+func parseMessage(b []byte) (msg *message, err error) {
+	defer recoverParse("message", &err)
+	t := rootTable(b)
+	msg = &message{headerType: t.uint8(1, 0), bodyLength: t.int64(3, 0)}
+	if h, ok := t.table(2); ok {
+		msg.header = h
+	} else {
+		return nil, errors.New("message without a header")
+	}
+	if msg.bodyLength < 0 {
+		return nil, fmt.Errorf("invalid body length %d", msg.bodyLength)
+	}
+	return msg, nil
+}
+
+func (ar *Reader) setSchema(t table) (err error) {
+	defer recoverParse("schema", &err)
+	ar.Schema = parseSchema(t)
+	var walk func(fields []*Field)
+	walk = func(fields []*Field) {
+		for _, f := range fields {
+			if f.Dictionary != nil {
+				ar.dictFields[f.Dictionary.ID] = f
+			}
+			walk(f.Children)
+		}
+	}
+	walk(ar.Schema.Fields)
+	return nil
+}
+
+func (ar *Reader) readFooter(size, footerSize int64) (err error) {
+	if footerSize <= 0 || footerSize > size-18 {
+		return fmt.Errorf("invalid arrow footer size %d", footerSize)
+	}
+	b := make([]byte, footerSize)
+	if _, err := ar.ra.ReadAt(b, size-10-footerSize); err != nil {
+		return err
+	}
+	defer recoverParse("footer", &err)
+	t := rootTable(b)
+	st, ok := t.table(1)
+	if !ok {
+		return errors.New("arrow footer without a schema")
+	}
+	if err := ar.setSchema(st); err != nil {
+		return err
+	}
+	for _, slot := range []int{2, 3} {
+		// Block structs: offset, metaDataLength (padded to 8), bodyLength
+		s := t.structs(slot, 24)
+		for i := 0; i < len(s); i += 24 {
+			ar.blocks = append(ar.blocks, block{int64(le.Uint64(s[i:])), int32(le.Uint32(s[i+8:])), int64(le.Uint64(s[i+16:]))})
+		}
+	}
+	return nil
+}
+
+// readMessage reads the next message of the stream and its body, nil at the end of the stream
+func (ar *Reader) readMessage() (*message, []byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(ar.r, prefix[:]); err != nil {
+		if err == io.EOF {
+			// no end of stream marker
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	n := le.Uint32(prefix[:])
+	if n == 0xFFFFFFFF {
+		if _, err := io.ReadFull(ar.r, prefix[:]); err != nil {
+			return nil, nil, err
+		}
+		n = le.Uint32(prefix[:])
+	}
+	if n == 0 {
+		return nil, nil, nil
+	}
+	pos, _ := ar.r.Seek(0, io.SeekCurrent)
+	if n > MAX_METADATA_SIZE || int64(n) > ar.r.Size()-pos {
+		return nil, nil, fmt.Errorf("invalid arrow message length %d", n)
+	}
+	meta := make([]byte, n)
+	if _, err := io.ReadFull(ar.r, meta); err != nil {
+		return nil, nil, err
+	}
+	msg, err := parseMessage(meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the body can't be longer than the rest of the stream
+	pos, _ = ar.r.Seek(0, io.SeekCurrent)
+	if msg.bodyLength > ar.r.Size()-pos {
+		return nil, nil, fmt.Errorf("truncated arrow message body: %d bytes, %d left", msg.bodyLength, ar.r.Size()-pos)
+	}
+	body := make([]byte, msg.bodyLength)
+	if _, err := io.ReadFull(ar.r, body); err != nil {
+		return nil, nil, fmt.Errorf("truncated arrow message body: %v", err)
+	}
+	return msg, body, nil
+}
+
+// readBlock reads the message at the block of the file
+func (ar *Reader) readBlock(b block) (*message, []byte, error) {
+	if b.metaLength <= 8 || b.metaLength > MAX_METADATA_SIZE || b.bodyLength < 0 || b.offset < 0 ||
+		b.offset+int64(b.metaLength) > ar.size || b.bodyLength > ar.size-b.offset-int64(b.metaLength) {
+		return nil, nil, fmt.Errorf("invalid arrow block at %d", b.offset)
+	}
+	meta := make([]byte, b.metaLength)
+	if _, err := ar.ra.ReadAt(meta, b.offset); err != nil {
+		return nil, nil, err
+	}
+	start := 4
+	if le.Uint32(meta) == 0xFFFFFFFF {
+		start = 8
+	}
+	msg, err := parseMessage(meta[start:])
+	if err != nil {
+		return nil, nil, err
+	}
+	body := make([]byte, b.bodyLength)
+	if _, err := ar.ra.ReadAt(body, b.offset+int64(b.metaLength)); err != nil {
+		return nil, nil, fmt.Errorf("truncated arrow message body: %v", err)
+	}
+	return msg, body, nil
+}
+
+// Next returns the next record batch, io.EOF at the end
+func (ar *Reader) Next() (*Batch, error) {
+	for {
+		var msg *message
+		var body []byte
+		var err error
+		if ar.ra != nil {
+			if ar.next >= len(ar.blocks) {
+				return nil, io.EOF
+			}
+			msg, body, err = ar.readBlock(ar.blocks[ar.next])
+			ar.next++
+		} else {
+			msg, body, err = ar.readMessage()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			return nil, io.EOF
+		}
+		switch msg.headerType {
+		case MH_record_batch:
+			ar.batches++
+			return ar.readBatch(msg.header, body)
+		case MH_dictionary_batch:
+			if err := ar.readDictionary(msg.header, body); err != nil {
+				return nil, err
+			}
+		case MH_schema:
+			return nil, errors.New("unexpected schema message")
+		default:
+			return nil, fmt.Errorf("unsupported arrow message type %d", msg.headerType)
+		}
+	}
+}
+
+// Project selects the fields (indexes in the schema) decoded by Next, the columns of other fields are nil
+func (ar *Reader) Project(fields []int) {
+	ar.selected = make([]bool, len(ar.Schema.Fields))
+	for _, i := range fields {
+		ar.selected[i] = true
+	}
+}
+
+// Batches returns the number of record batches read so far
+func (ar *Reader) Batches() int {
+	return ar.batches
+}
+
+// batchReader walks the field nodes and buffers of a record batch body
+type batchReader struct {
+	ar      *Reader
+	body    []byte
+	length  int64
+	nodes   []byte
+	buffers []byte
+	codec   int // -1 if not compressed
+}
+
+func (ar *Reader) newBatchReader(t table, body []byte) (br *batchReader, err error) {
+	defer recoverParse("record batch", &err)
+	br = &batchReader{ar: ar, body: body, length: t.int64(0, 0), nodes: t.structs(1, 16), buffers: t.structs(2, 16), codec: -1}
+	if _, n := t.vector(4, 8); n > 0 {
+		return nil, errors.New("variadic buffers (view types) are not supported")
+	}
+	if ct, ok := t.table(3); ok {
+		br.codec = int(ct.uint8(0, C_lz4_frame))
+		if br.codec > C_zstd {
+			return nil, fmt.Errorf("unknown compression codec %d", br.codec)
+		}
+		ar.Compression = []string{"lz4_frame", "zstd"}[br.codec]
+	}
+	return br, nil
+}
+
+func (br *batchReader) node() (length, nulls int, err error) {
+	if len(br.nodes) < 16 {
+		return 0, 0, errors.New("missing field node")
+	}
+	length, nulls = int(le.Uint64(br.nodes)), int(le.Uint64(br.nodes[8:]))
+	br.nodes = br.nodes[16:]
+	if length < 0 || nulls < 0 || nulls > length {
+		return 0, 0, fmt.Errorf("invalid field node: length %d, nulls %d", length, nulls)
+	}
+	return length, nulls, nil
+}
+
+func (br *batchReader) buffer() ([]byte, error) {
+	if len(br.buffers) < 16 {
+		return nil, errors.New("missing buffer")
+	}
+	offset, length := int64(le.Uint64(br.buffers)), int64(le.Uint64(br.buffers[8:]))
+	br.buffers = br.buffers[16:]
+	if offset < 0 || length < 0 || offset+length > int64(len(br.body)) {
+		return nil, fmt.Errorf("buffer outside of the body: offset %d, length %d", offset, length)
+	}
+	b := br.body[offset : offset+length]
+	if br.codec < 0 || len(b) == 0 {
+		return b, nil
+	}
+	// compressed buffers start with the uncompressed length, -1 if the buffer is not compressed
+	if len(b) < 8 {
+		return nil, errors.New("invalid compressed buffer")
+	}
+	n := int64(le.Uint64(b))
+	if n == -1 {
+		return b[8:], nil
+	}
+	// a zstd block of 4 bytes (an RLE block) decompresses to 128 KiB at most, an lz4 sequence adds 255 bytes
+	// for each byte of its match length, a larger length is corrupted
+	if n < 0 || n/[]int64{LZ4_MAX_RATIO, ZSTD_MAX_RATIO}[br.codec] > int64(len(b)-8) {
+		return nil, fmt.Errorf("invalid uncompressed buffer length %d", n)
+	}
+	var out []byte
+	var err error
+	if br.codec == C_lz4_frame {
+		out, err = br.ar.lz4Decode(b[8:], n)
+	} else {
+		out, err = br.ar.zstdDecode(b[8:], n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) != n {
+		return nil, fmt.Errorf("decompressed buffer has %d bytes, expected %d", len(out), n)
+	}
+	return out, nil
+}
+
+func (ar *Reader) zstdDecode(b []byte, n int64) ([]byte, error) {
+	if ar.zstd == nil {
+		var err error
+		// DecodeAll doesn't grow the output above n, whatever the frame header says
+		if ar.zstd, err = zstd.NewReader(nil, zstd.WithDecodeAllCapLimit(true)); err != nil {
+			return nil, err
+		}
+	}
+	return ar.zstd.DecodeAll(b, make([]byte, 0, n))
+}
+
+// lz4Decode decodes the lz4 frame, n is the expected length, the frame must not decode to more
+func (ar *Reader) lz4Decode(b []byte, n int64) ([]byte, error) {
+	if ar.lz4 == nil {
+		ar.lz4 = lz4.NewReader(nil)
+	}
+	ar.lz4.Reset(bytes.NewReader(b))
+	out := make([]byte, n+1)
+	k, err := io.ReadFull(ar.lz4, out)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return out[:k], err
+}
+
+// array is the raw data of a field in a batch: validity, values (or offsets and data) and children
+type array struct {
+	f        *Field
+	n        int
+	valid    []byte // nil if there are no nulls
+	values   []byte // fixed width values, dictionary indexes or offsets
+	data     []byte // variable width data
+	children []*array
+	dict     *array
+}
+
+func (a *array) isNull(i int) bool {
+	return a.valid != nil && a.valid[i/8]&(1<<(i%8)) == 0
+}
+
+// read reads the nodes and buffers of the field (and its children)
+func (br *batchReader) read(f *Field) (*array, error) {
+	n, nulls, err := br.node()
+	if err != nil {
+		return nil, err
+	}
+	a := &array{f: f, n: n}
+	nbuf := 0
+	switch f.Type {
+	case T_null:
+		// no buffers, all values are null
+		a.valid = make([]byte, (n+7)/8)
+		return a, nil
+	case T_int, T_floating_point, T_bool, T_decimal, T_date, T_time, T_timestamp, T_duration, T_fixed_size_binary,
+		T_list, T_large_list, T_map:
+		nbuf = 2
+	case T_binary, T_utf8, T_large_binary, T_large_utf8:
+		nbuf = 3
+	case T_struct, T_fixed_size_list:
+		nbuf = 1
+	default:
+		return nil, fmt.Errorf("%s: %s is not supported", f.Name, f.Type)
+	}
+	if f.Dictionary != nil {
+		nbuf = 2
+	}
+	bufs := make([][]byte, nbuf)
+	for i := range bufs {
+		if bufs[i], err = br.buffer(); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	if nulls > 0 {
+		if len(bufs[0]) < (n+7)/8 {
+			return nil, fmt.Errorf("%s: validity bitmap too short", f.Name)
+		}
+		a.valid = bufs[0]
+	}
+	if nbuf > 1 {
+		a.values = bufs[1]
+	}
+	if nbuf > 2 {
+		a.data = bufs[2]
+	}
+	if f.Dictionary != nil {
+		if a.dict = br.ar.dicts[f.Dictionary.ID]; a.dict == nil {
+			return nil, fmt.Errorf("%s: dictionary %d not found", f.Name, f.Dictionary.ID)
+		}
+		return a, a.check(f.Dictionary.IndexType)
+	}
+	for _, c := range f.Children {
+		ca, err := br.read(c)
+		if err != nil {
+			return nil, err
+		}
+		a.children = append(a.children, ca)
+	}
+	return a, a.check(f)
+}
+
+// width returns the size of a fixed width value in bytes (0 for bits, -1 for other types)
+func width(f *Field) int {
+	switch f.Type {
+	case T_int, T_time, T_decimal:
+		return f.BitWidth / 8
+	case T_floating_point:
+		return []int{2, 4, 8}[f.Precision%3]
+	case T_date:
+		return []int{4, 8}[f.Unit%2]
+	case T_timestamp, T_duration:
+		return 8
+	case T_fixed_size_binary:
+		return f.ByteWidth
+	case T_bool:
+		return 0
+	case T_binary, T_utf8, T_list, T_map:
+		return 4 // offsets
+	case T_large_binary, T_large_utf8, T_large_list:
+		return 8
+	}
+	return -1
+}
+
+// check checks the buffer sizes against the length (t is the type of the values, the index type of dictionaries)
+func (a *array) check(t *Field) error {
+	w := width(t)
+	switch {
+	case w == 0:
+		if a.n > 8*len(a.values) {
+			return fmt.Errorf("%s: values buffer too short", a.f.Name)
+		}
+	case w > 0:
+		n := a.n
+		if a.data != nil || t.Type == T_list || t.Type == T_large_list || t.Type == T_map {
+			n++
+		}
+		if a.n > 0 && (n < 0 || len(a.values)/w < n) {
+			return fmt.Errorf("%s: values buffer too short", a.f.Name)
+		}
+	}
+	if a.n > 0 && a.dict == nil {
+		switch t.Type {
+		case T_binary, T_utf8, T_large_binary, T_large_utf8, T_list, T_large_list, T_map:
+			end := len(a.data)
+			if a.data == nil {
+				end = a.children[0].n
+			}
+			for i := 0; i < a.n; i++ {
+				if o1, o2 := a.offset(i), a.offset(i+1); o1 < 0 || o2 < o1 || o2 > end {
+					return fmt.Errorf("%s: invalid offsets", a.f.Name)
+				}
+			}
+		}
+	}
+	if t.Type == T_fixed_size_list && len(a.children) == 1 && t.ByteWidth > 0 && a.children[0].n/t.ByteWidth < a.n {
+		return fmt.Errorf("%s: list child too short", a.f.Name)
+	}
+	return nil
+}
+
+// offset returns the i-th offset of a variable width array
+func (a *array) offset(i int) int {
+	if a.f.Type == T_large_binary || a.f.Type == T_large_utf8 || a.f.Type == T_large_list {
+		return int(le.Uint64(a.values[8*i:]))
+	}
+	return int(int32(le.Uint32(a.values[4*i:])))
+}
+
+// int returns the i-th value of a fixed width integer type
+func intAt(b []byte, i int, w int, signed bool) int64 {
+	switch w {
+	case 1:
+		if signed {
+			return int64(int8(b[i]))
+		}
+		return int64(b[i])
+	case 2:
+		v := le.Uint16(b[2*i:])
+		if signed {
+			return int64(int16(v))
+		}
+		return int64(v)
+	case 4:
+		v := le.Uint32(b[4*i:])
+		if signed {
+			return int64(int32(v))
+		}
+		return int64(v)
+	}
+	// uint64 above math.MaxInt64 wrap
+	return int64(le.Uint64(b[8*i:]))
+}
+
+// half converts an IEEE 754 half precision float
+func half(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * frac * math.Pow(2, -24)
+	case 0x1f:
+		if frac != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+	return sign * (1 + frac/1024) * math.Pow(2, float64(exp-15))
+}
+
+var timeUnits = []time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond}
+
+func toTime(v int64, unit int) time.Time {
+	switch unit {
+	case U_second:
+		return time.Unix(v, 0).UTC()
+	case U_millisecond:
+		return time.UnixMilli(v).UTC()
+	case U_microsecond:
+		return time.UnixMicro(v).UTC()
+	}
+	return time.Unix(0, v).UTC()
+}
+
+// decimal formats the little endian two's complement integer with the scale
+func decimal(b []byte, scale int) string {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	v := new(big.Int).SetBytes(be)
+	if b[len(b)-1]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	s := v.String()
+	if scale <= 0 {
+		return s + string(bytes.Repeat([]byte{'0'}, -scale))
+	}
+	neg := s[0] == '-'
+	if neg {
+		s = s[1:]
+	}
+	for len(s) <= scale {
+		s = "0" + s
+	}
+	s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// value returns the i-th value as int64, float64, string (or bool, []any and map[string]any of nested types), nil if null
+func (a *array) value(i int) any {
+	if a.isNull(i) {
+		return nil
+	}
+	f := a.f
+	if a.dict != nil {
+		k := int(intAt(a.values, i, f.Dictionary.IndexType.BitWidth/8, f.Dictionary.IndexType.Signed))
+		if k < 0 || k >= a.dict.n {
+			return nil
+		}
+		return a.dict.value(k)
+	}
+	switch f.Type {
+	case T_int:
+		return intAt(a.values, i, f.BitWidth/8, f.Signed)
+	case T_duration:
+		return int64(le.Uint64(a.values[8*i:]))
+	case T_floating_point:
+		switch f.Precision {
+		case P_half:
+			return half(le.Uint16(a.values[2*i:]))
+		case P_single:
+			return float64(math.Float32frombits(le.Uint32(a.values[4*i:])))
+		}
+		return math.Float64frombits(le.Uint64(a.values[8*i:]))
+	case T_bool:
+		return a.values[i/8]&(1<<(i%8)) != 0
+	case T_binary, T_utf8, T_large_binary, T_large_utf8:
+		return string(a.data[a.offset(i):a.offset(i+1)])
+	case T_fixed_size_binary:
+		return string(a.values[i*f.ByteWidth : (i+1)*f.ByteWidth])
+	case T_decimal:
+		w := f.BitWidth / 8
+		return decimal(a.values[i*w:(i+1)*w], f.Scale)
+	case T_date:
+		if f.Unit == U_day {
+			return toTime(86400*int64(int32(le.Uint32(a.values[4*i:]))), U_second).Format("2006-01-02")
+		}
+		return toTime(int64(le.Uint64(a.values[8*i:])), U_millisecond).Format("2006-01-02")
+	case T_timestamp:
+		t := toTime(int64(le.Uint64(a.values[8*i:])), f.Unit)
+		if f.Timezone != "" {
+			return t.Format(time.RFC3339Nano)
+		}
+		return t.Format("2006-01-02 15:04:05.999999999")
+	case T_time:
+		d := time.Duration(intAt(a.values, i, f.BitWidth/8, true)) * timeUnits[f.Unit%4]
+		return time.Unix(0, 0).UTC().Add(d).Format("15:04:05.999999999")
+	case T_list, T_large_list, T_map:
+		l := []any{}
+		for k := a.offset(i); k < a.offset(i+1); k++ {
+			l = append(l, a.children[0].value(k))
+		}
+		if f.Type == T_map {
+			// entries are structs of the key and the value
+			m := map[string]any{}
+			for _, e := range l {
+				if kv, ok := e.(map[string]any); ok && len(a.children[0].children) == 2 {
+					m[fmt.Sprint(kv[a.children[0].children[0].f.Name])] = kv[a.children[0].children[1].f.Name]
+				}
+			}
+			return m
+		}
+		return l
+	case T_fixed_size_list:
+		l := []any{}
+		for k := i * f.ByteWidth; k < (i+1)*f.ByteWidth; k++ {
+			l = append(l, a.children[0].value(k))
+		}
+		return l
+	case T_struct:
+		m := map[string]any{}
+		for _, c := range a.children {
+			m[c.f.Name] = c.value(i)
+		}
+		return m
+	}
+	return nil
+}
+
+// column converts the array to a column of its kind: numbers are kept as is, other values are formatted as text
+// (nested values as JSON)
+func (a *array) column() *Column {
+	c := &Column{Kind: a.f.Kind(), Len: a.n, Valid: a.valid}
+	f := a.f
+	if a.dict == nil {
+		// plain ints, floats and strings without boxing
+		switch {
+		case f.Type == T_int:
+			c.Ints = make([]int64, a.n)
+			for i := range c.Ints {
+				c.Ints[i] = intAt(a.values, i, f.BitWidth/8, f.Signed)
+			}
+			return c
+		case f.Type == T_floating_point && f.Precision == P_double:
+			c.Floats = make([]float64, a.n)
+			for i := range c.Floats {
+				c.Floats[i] = math.Float64frombits(le.Uint64(a.values[8*i:]))
+			}
+			return c
+		case f.Type == T_utf8 || f.Type == T_large_utf8:
+			c.Strings = make([]string, a.n)
+			for i := range c.Strings {
+				if !a.isNull(i) {
+					c.Strings[i] = string(a.data[a.offset(i):a.offset(i+1)])
+				}
+			}
+			return c
+		}
+	}
+	if a.dict != nil {
+		c.Kind = a.dict.f.Kind()
+	}
+	switch c.Kind {
+	case K_int:
+		c.Ints = make([]int64, a.n)
+		for i := range c.Ints {
+			if v, ok := a.value(i).(int64); ok {
+				c.Ints[i] = v
+			}
+		}
+	case K_float:
+		c.Floats = make([]float64, a.n)
+		for i := range c.Floats {
+			if v, ok := a.value(i).(float64); ok {
+				c.Floats[i] = v
+			}
+		}
+	default:
+		c.Strings = make([]string, a.n)
+		for i := range c.Strings {
+			switch v := a.value(i).(type) {
+			case string:
+				c.Strings[i] = v
+			case bool:
+				c.Strings[i] = strconv.FormatBool(v)
+			case nil:
+			default:
+				b, err := json.Marshal(v)
+				if err != nil {
+					b = []byte(fmt.Sprint(v))
+				}
+				c.Strings[i] = string(b)
+			}
+		}
+	}
+	if a.dict != nil && c.Valid == nil {
+		// indexes out of the dictionary are nulls
+		for i := 0; i < a.n; i++ {
+			if a.value(i) == nil {
+				c.Valid = make([]byte, (a.n+7)/8)
+				for k := 0; k < a.n; k++ {
+					if a.value(k) != nil {
+						c.Valid[k/8] |= 1 << (k % 8)
+					}
+				}
+				break
+			}
+		}
+	}
+	return c
+}
+
+func (ar *Reader) readBatch(t table, body []byte) (*Batch, error) {
+	br, err := ar.newBatchReader(t, body)
+	if err != nil {
+		return nil, err
+	}
+	b := &Batch{Rows: int(br.length), Columns: make([]*Column, len(ar.Schema.Fields))}
+	for i, f := range ar.Schema.Fields {
+		// unselected fields are read to get to the buffers of the next ones
+		a, err := br.read(f)
+		if err != nil {
+			return nil, err
+		}
+		if a.n != b.Rows {
+			return nil, fmt.Errorf("%s: %d values in a batch of %d rows", f.Name, a.n, b.Rows)
+		}
+		if ar.selected == nil || ar.selected[i] {
+			b.Columns[i] = a.column()
+		}
+	}
+	return b, nil
+}
+
+func (ar *Reader) readDictionary(t table, body []byte) (err error) {
+	var id int64
+	var data table
+	var delta, ok bool
+	func() {
+		defer recoverParse("dictionary batch", &err)
+		id, delta = t.int64(0, 0), t.bool(2)
+		data, ok = t.table(1)
+	}()
+	if err != nil {
+		return err
+	}
+	f := ar.dictFields[id]
+	if f == nil || !ok {
+		return fmt.Errorf("unknown dictionary %d", id)
+	}
+	br, err := ar.newBatchReader(data, body)
+	if err != nil {
+		return err
+	}
+	// the dictionary values have the type of the field
+	vf := *f
+	vf.Dictionary = nil
+	a, err := br.read(&vf)
+	if err != nil {
+		return err
+	}
+	if old := ar.dicts[id]; delta && old != nil {
+		a = concat(old, a)
+	}
+	ar.dicts[id] = a
+	return nil
+}
+
+// concat appends a delta dictionary, it's materialized as a utf8 or a float array
+func concat(a, b *array) *array {
+	f := &Field{Name: a.f.Name, Nullable: true, Type: T_utf8}
+	if a.f.Kind() == K_float || a.f.Kind() == K_int {
+		f = &Field{Name: a.f.Name, Nullable: true, Type: T_floating_point, Precision: P_double}
+		if a.f.Kind() == K_int {
+			f = &Field{Name: a.f.Name, Nullable: true, Type: T_int, BitWidth: 64, Signed: true}
+		}
+	}
+	out := &array{f: f, n: a.n + b.n, valid: make([]byte, (a.n+b.n+7)/8)}
+	var offsets []byte
+	if f.Type == T_utf8 {
+		offsets = make([]byte, 4, 4*(out.n+1))
+	}
+	k := 0
+	for _, src := range []*array{a, b} {
+		for i := 0; i < src.n; i++ {
+			v := src.value(i)
+			if v != nil {
+				out.valid[k/8] |= 1 << (k % 8)
+			}
+			switch f.Type {
+			case T_utf8:
+				s := fmt.Sprint(v)
+				if v == nil {
+					s = ""
+				} else if b, ok := v.(bool); ok {
+					s = strconv.FormatBool(b)
+				}
+				out.data = append(out.data, s...)
+				offsets = appendUint32(offsets, uint32(len(out.data)))
+			case T_int:
+				x, _ := v.(int64)
+				out.values = appendUint64(out.values, uint64(x))
+			default:
+				x, _ := v.(float64)
+				out.values = appendUint64(out.values, math.Float64bits(x))
+			}
+			k++
+		}
+	}
+	if f.Type == T_utf8 {
+		out.values = offsets
+		if out.data == nil {
+			out.data = []byte{}
+		}
+	}
+	return out
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
@@ -0,0 +1,295 @@
+package arrow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TypeID is the type of a field (the Type union of Schema.fbs)
+type TypeID uint8
+
+const (
+	T_none TypeID = iota
+	T_null
+	T_int
+	T_floating_point
+	T_binary
+	T_utf8
+	T_bool
+	T_decimal
+	T_date
+	T_time
+	T_timestamp
+	T_interval
+	T_list
+	T_struct
+	T_union
+	T_fixed_size_binary
+	T_fixed_size_list
+	T_map
+	T_duration
+	T_large_binary
+	T_large_utf8
+	T_large_list
+	T_run_end_encoded
+	T_binary_view
+	T_utf8_view
+	T_list_view
+	T_large_list_view
+)
+
+var typeNames = []string{"none", "null", "int", "floating_point", "binary", "utf8", "bool", "decimal", "date", "time",
+	"timestamp", "interval", "list", "struct", "union", "fixed_size_binary", "fixed_size_list", "map", "duration",
+	"large_binary", "large_utf8", "large_list", "run_end_encoded", "binary_view", "utf8_view", "list_view", "large_list_view"}
+
+func (t TypeID) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("type(%d)", t)
+}
+
+// units of dates (U_day, U_millisecond) and of times, timestamps and durations
+const (
+	U_second = iota
+	U_millisecond
+	U_microsecond
+	U_nanosecond
+	U_day = 0
+)
+
+// precisions of floating point numbers
+const (
+	P_half = iota
+	P_single
+	P_double
+)
+
+// Kind is how the values of a column are kept in a Batch
+type Kind uint8
+
+const (
+	K_string Kind = iota // Column.Strings: text, binary, bools, dates, times, decimals and nested values (as JSON)
+	K_int                // Column.Ints: integers and durations
+	K_float              // Column.Floats: floating point numbers
+)
+
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// Field is a field of the schema with its type parameters
+type Field struct {
+	Name     string
+	Nullable bool
+	Type     TypeID
+	// BitWidth of ints, times and decimals
+	BitWidth int
+	Signed   bool
+	// Precision of floating point numbers (P_half, P_single, P_double) or decimals
+	Precision int
+	Scale     int
+	// Unit of dates, times, timestamps and durations
+	Unit     int
+	Timezone string
+	// ByteWidth of fixed size binaries or the size of fixed size lists
+	ByteWidth int
+	// Dictionary is set for dictionary encoded fields, the type is the type of the dictionary values
+	Dictionary *DictionaryEncoding
+	Children   []*Field
+	Metadata   []KeyValue
+}
+
+// DictionaryEncoding of a field, the values are int indexes into the dictionary batch with the ID
+type DictionaryEncoding struct {
+	ID        int64
+	IndexType *Field
+	Ordered   bool
+}
+
+type Schema struct {
+	Fields   []*Field
+	Metadata []KeyValue
+}
+
+// Kind returns how the field values are read
+func (f *Field) Kind() Kind {
+	switch f.Type {
+	case T_int, T_duration:
+		return K_int
+	case T_floating_point:
+		return K_float
+	}
+	return K_string
+}
+
+// String returns the type, e.g. int64, timestamp[ms, UTC], list<item: utf8>
+func (f *Field) String() string {
+	var s string
+	switch f.Type {
+	case T_int:
+		s = fmt.Sprintf("int%d", f.BitWidth)
+		if !f.Signed {
+			s = "u" + s
+		}
+	case T_floating_point:
+		s = []string{"float16", "float32", "float64"}[f.Precision%3]
+	case T_decimal:
+		s = fmt.Sprintf("decimal%d(%d, %d)", f.BitWidth, f.Precision, f.Scale)
+	case T_date:
+		s = []string{"date32", "date64"}[f.Unit%2]
+	case T_time, T_timestamp, T_duration:
+		s = f.Type.String()
+		if f.Type == T_time {
+			s += strconv.Itoa(f.BitWidth)
+		}
+		s += "[" + []string{"s", "ms", "us", "ns"}[f.Unit%4]
+		if f.Timezone != "" {
+			s += ", " + f.Timezone
+		}
+		s += "]"
+	case T_fixed_size_binary:
+		s = fmt.Sprintf("fixed_size_binary[%d]", f.ByteWidth)
+	case T_list, T_large_list, T_fixed_size_list, T_struct, T_map:
+		children := make([]string, len(f.Children))
+		for i, c := range f.Children {
+			children[i] = c.Name + ": " + c.String()
+		}
+		s = f.Type.String() + "<" + strings.Join(children, ", ") + ">"
+	default:
+		s = f.Type.String()
+	}
+	if f.Dictionary != nil {
+		s = "dictionary<values=" + s + ", indices=" + f.Dictionary.IndexType.String() + ">"
+	}
+	return s
+}
+
+func parseKeyValues(tables []table) []KeyValue {
+	var kvs []KeyValue
+	for _, t := range tables {
+		kvs = append(kvs, KeyValue{t.string(0), t.string(1)})
+	}
+	return kvs
+}
+
+func parseInt(t table) *Field {
+	return &Field{Type: T_int, BitWidth: int(t.int32(0, 0)), Signed: t.bool(1)}
+}
+
+// validType tells if the type parameters are in range and lists have their child, the width and String functions
+// index tables by them
+func (f *Field) validType() bool {
+	switch f.Type {
+	case T_int:
+		return f.BitWidth == 8 || f.BitWidth == 16 || f.BitWidth == 32 || f.BitWidth == 64
+	case T_floating_point:
+		return f.Precision >= P_half && f.Precision <= P_double
+	case T_decimal:
+		// 76 digits fit in 256 bits
+		return (f.BitWidth == 32 || f.BitWidth == 64 || f.BitWidth == 128 || f.BitWidth == 256) &&
+			f.Precision >= 0 && f.Precision <= 76 && f.Scale >= -76 && f.Scale <= 76
+	case T_date:
+		return f.Unit == U_day || f.Unit == U_millisecond
+	case T_time:
+		return f.Unit >= U_second && f.Unit <= U_nanosecond && (f.BitWidth == 32 || f.BitWidth == 64)
+	case T_timestamp, T_duration:
+		return f.Unit >= U_second && f.Unit <= U_nanosecond
+	case T_fixed_size_binary:
+		return f.ByteWidth >= 0
+	case T_fixed_size_list:
+		return f.ByteWidth >= 0 && len(f.Children) == 1
+	case T_list, T_large_list, T_map:
+		return len(f.Children) == 1
+	}
+	return true
+}
+
+func parseField(t table) *Field {
+	f := &Field{Name: t.string(0), Nullable: t.bool(1), Type: TypeID(t.uint8(2, 0))}
+	if tt, ok := t.table(3); ok {
+		switch f.Type {
+		case T_int:
+			f.BitWidth, f.Signed = int(tt.int32(0, 0)), tt.bool(1)
+		case T_floating_point:
+			f.Precision = int(tt.int16(0, P_half))
+		case T_decimal:
+			f.Precision, f.Scale, f.BitWidth = int(tt.int32(0, 0)), int(tt.int32(1, 0)), int(tt.int32(2, 128))
+		case T_date:
+			f.Unit = int(tt.int16(0, U_millisecond))
+		case T_time:
+			f.Unit, f.BitWidth = int(tt.int16(0, U_millisecond)), int(tt.int32(1, 32))
+		case T_timestamp:
+			f.Unit, f.Timezone = int(tt.int16(0, U_second)), tt.string(1)
+		case T_duration:
+			f.Unit = int(tt.int16(0, U_millisecond))
+		case T_fixed_size_binary, T_fixed_size_list:
+			f.ByteWidth = int(tt.int32(0, 0))
+		}
+	}
+	if dt, ok := t.table(4); ok {
+		f.Dictionary = &DictionaryEncoding{ID: dt.int64(0, 0), Ordered: dt.bool(2)}
+		if it, ok := dt.table(1); ok {
+			f.Dictionary.IndexType = parseInt(it)
+		} else {
+			f.Dictionary.IndexType = &Field{Type: T_int, BitWidth: 32, Signed: true}
+		}
+	}
+	for _, c := range t.tables(5) {
+		f.Children = append(f.Children, parseField(c))
+	}
+	f.Metadata = parseKeyValues(t.tables(6))
+	if !f.validType() || f.Dictionary != nil && !f.Dictionary.IndexType.validType() {
+		panic(fmt.Sprintf("%s: invalid %s type", f.Name, f.Type))
+	}
+	return f
+}
+
+func parseSchema(t table) *Schema {
+	s := &Schema{Metadata: parseKeyValues(t.tables(2))}
+	if t.int16(0, 0) != 0 {
+		panic("big endian data is not supported")
+	}
+	for _, ft := range t.tables(1) {
+		s.Fields = append(s.Fields, parseField(ft))
+	}
+	return s
+}
+
+func buildKeyValues(kvs []KeyValue) *fbField {
+	if len(kvs) == 0 {
+		return nil
+	}
+	v := make(fbVector, len(kvs))
+	for i, kv := range kvs {
+		v[i] = fbTable{fbRef(kv.Key), fbRef(kv.Value)}
+	}
+	return fbRef(v)
+}
+
+// buildField writes the field, only the types written by Writer are supported
+func buildField(f *Field) fbTable {
+	var typ fbTable
+	switch f.Type {
+	case T_int:
+		typ = fbTable{fbScalar(4, uint64(f.BitWidth)), fbBool(f.Signed)}
+	case T_floating_point:
+		typ = fbTable{fbScalar(2, uint64(f.Precision))}
+	case T_utf8, T_binary, T_bool, T_null:
+		typ = fbTable{}
+	default:
+		panic("arrow: writing " + f.Type.String() + " is not supported")
+	}
+	return fbTable{fbRef(f.Name), fbBool(f.Nullable), fbScalar(1, uint64(f.Type)), fbRef(typ), nil, fbRef(fbVector{}),
+		buildKeyValues(f.Metadata)}
+}
+
+func buildSchema(s *Schema) fbTable {
+	fields := make(fbVector, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[i] = buildField(f)
+	}
+	return fbTable{fbScalar(2, 0), fbRef(fields), buildKeyValues(s.Metadata)}
+}
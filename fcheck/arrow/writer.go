@@ -0,0 +1,277 @@
+package arrow
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaults of WriterOptions
+const BATCH_ROWS = 64 * 1024
+
+// metadata version V5
+const metadataVersion = 4
+
+type WriterOptions struct {
+	// Stream writes the stream format (no magic and footer, can't be read backwards), the file format by default
+	Stream bool
+	// Codec is "zstd" for zstd compressed buffers, uncompressed if empty (lz4_frame is not supported)
+	Codec string
+	// Level is the zstd level (1-22), 0 for the default
+	Level int
+	// BatchRows is the number of rows of a record batch, BATCH_ROWS if 0
+	BatchRows int
+	Metadata  []KeyValue
+}
+
+// Writer writes rows as record batches of a flat schema. Values passed to Write are nil, int64 (T_int 64 bits),
+// float64 (T_floating_point P_double), bool (T_bool) or string / []byte (T_utf8, T_binary).
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	opts    WriterOptions
+	schema  *Schema
+	columns []*columnBuilder
+	rows    int
+	blocks  []block
+	zstd    *zstd.Encoder
+}
+
+// columnBuilder collects the values of a batch
+type columnBuilder struct {
+	f      *Field
+	valid  []byte
+	nulls  int
+	values []byte // fixed width values, bits or offsets
+	data   []byte // strings
+}
+
+// NewWriter writes the schema (and the magic bytes of the file format), Close must be called to finish the file
+func NewWriter(w io.Writer, fields []*Field, opts WriterOptions) (*Writer, error) {
+	if opts.BatchRows <= 0 {
+		opts.BatchRows = BATCH_ROWS
+	}
+	aw := &Writer{w: w, opts: opts, schema: &Schema{Fields: fields, Metadata: opts.Metadata}}
+	switch opts.Codec {
+	case "", "uncompressed", "none":
+	case "zstd":
+		level := zstd.SpeedDefault
+		if opts.Level > 0 {
+			level = zstd.EncoderLevelFromZstd(opts.Level)
+		}
+		var err error
+		if aw.zstd, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(level)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported arrow codec: %s (supported: uncompressed, zstd)", opts.Codec)
+	}
+	for _, f := range fields {
+		switch {
+		case f.Type == T_int && f.BitWidth == 64 && f.Signed, f.Type == T_floating_point && f.Precision == P_double,
+			f.Type == T_utf8, f.Type == T_binary, f.Type == T_bool:
+		default:
+			return nil, fmt.Errorf("%s: writing %s is not supported", f.Name, f)
+		}
+		aw.columns = append(aw.columns, &columnBuilder{f: f})
+	}
+	if !opts.Stream {
+		if err := aw.write(append(MAGIC, 0, 0)); err != nil {
+			return nil, err
+		}
+	}
+	aw.reset()
+	meta := fbBuild(fbTable{fbScalar(2, metadataVersion), fbScalar(1, MH_schema), fbRef(buildSchema(aw.schema)), fbScalar(8, 0)})
+	if _, err := aw.writeMessage(meta, nil); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (aw *Writer) write(b []byte) error {
+	n, err := aw.w.Write(b)
+	aw.offset += int64(n)
+	return err
+}
+
+func (aw *Writer) reset() {
+	for _, c := range aw.columns {
+		c.valid, c.nulls, c.values, c.data = c.valid[:0], 0, c.values[:0], c.data[:0]
+		if c.f.Type == T_utf8 || c.f.Type == T_binary {
+			c.values = append(c.values, 0, 0, 0, 0)
+		}
+	}
+	aw.rows = 0
+}
+
+// Write adds a row, the batch is written when it has BatchRows rows
+func (aw *Writer) Write(row []any) error {
+	if len(row) != len(aw.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(aw.columns), len(row))
+	}
+	for i, v := range row {
+		if err := aw.columns[i].check(v); err != nil {
+			return err
+		}
+	}
+	for i, v := range row {
+		aw.columns[i].add(aw.rows, v)
+	}
+	aw.rows++
+	if aw.rows >= aw.opts.BatchRows {
+		return aw.flush()
+	}
+	return nil
+}
+
+func (c *columnBuilder) check(v any) error {
+	ok := false
+	switch v.(type) {
+	case nil:
+		ok = c.f.Nullable
+	case int64:
+		ok = c.f.Type == T_int
+	case float64:
+		ok = c.f.Type == T_floating_point
+	case bool:
+		ok = c.f.Type == T_bool
+	case string, []byte:
+		ok = c.f.Type == T_utf8 || c.f.Type == T_binary
+	}
+	if !ok {
+		return fmt.Errorf("%s: unexpected value %v (%T) for %s", c.f.Name, v, v, c.f)
+	}
+	return nil
+}
+
+func (c *columnBuilder) add(i int, v any) {
+	if i%8 == 0 {
+		c.valid = append(c.valid, 0)
+		if c.f.Type == T_bool {
+			c.values = append(c.values, 0)
+		}
+	}
+	if v == nil {
+		c.nulls++
+	} else {
+		c.valid[i/8] |= 1 << (i % 8)
+	}
+	switch x := v.(type) {
+	case int64:
+		c.values = appendUint64(c.values, uint64(x))
+	case float64:
+		c.values = appendUint64(c.values, math.Float64bits(x))
+	case bool:
+		if x {
+			c.values[i/8] |= 1 << (i % 8)
+		}
+	case string:
+		c.data = append(c.data, x...)
+	case []byte:
+		c.data = append(c.data, x...)
+	}
+	switch c.f.Type {
+	case T_int, T_floating_point:
+		if v == nil {
+			c.values = appendUint64(c.values, 0)
+		}
+	case T_utf8, T_binary:
+		c.values = appendUint32(c.values, uint32(len(c.data)))
+	}
+}
+
+// pad8 returns the number of bytes padding n to a multiple of 8
+func pad8(n int) int {
+	return (8 - n%8) % 8
+}
+
+// flush writes the record batch
+func (aw *Writer) flush() error {
+	if aw.rows == 0 {
+		return nil
+	}
+	var body []byte
+	var nodes, buffers []byte
+	addBuffer := func(b []byte) error {
+		if aw.zstd != nil && len(b) > 0 {
+			// the uncompressed length, then the compressed buffer (or -1 and the buffer if it doesn't get smaller)
+			z := aw.zstd.EncodeAll(b, appendUint64(nil, uint64(len(b))))
+			if len(z) >= len(b)+8 {
+				z = append(appendUint64(nil, math.MaxUint64), b...)
+			}
+			b = z
+		}
+		buffers = appendUint64(appendUint64(buffers, uint64(len(body))), uint64(len(b)))
+		body = append(body, b...)
+		body = append(body, make([]byte, pad8(len(body)))...)
+		return nil
+	}
+	for _, c := range aw.columns {
+		nodes = appendUint64(appendUint64(nodes, uint64(aw.rows)), uint64(c.nulls))
+		if c.nulls > 0 {
+			addBuffer(c.valid)
+		} else {
+			addBuffer(nil)
+		}
+		addBuffer(c.values)
+		if c.f.Type == T_utf8 || c.f.Type == T_binary {
+			addBuffer(c.data)
+		}
+	}
+	batch := fbTable{fbScalar(8, uint64(aw.rows)), fbRef(fbStructs{nodes, len(aw.columns), 8}),
+		fbRef(fbStructs{buffers, len(buffers) / 16, 8})}
+	if aw.zstd != nil {
+		batch = append(batch, fbRef(fbTable{fbScalar(1, C_zstd), fbScalar(1, 0)}))
+	}
+	meta := fbBuild(fbTable{fbScalar(2, metadataVersion), fbScalar(1, MH_record_batch), fbRef(batch), fbScalar(8, uint64(len(body)))})
+	b, err := aw.writeMessage(meta, body)
+	if err != nil {
+		return err
+	}
+	aw.blocks = append(aw.blocks, b)
+	aw.reset()
+	return nil
+}
+
+// writeMessage writes the encapsulated message: continuation marker, metadata length, metadata (padded to 8) and body
+func (aw *Writer) writeMessage(meta, body []byte) (block, error) {
+	b := block{offset: aw.offset}
+	meta = append(meta, make([]byte, pad8(len(meta)))...)
+	prefix := appendUint32(appendUint32(nil, 0xFFFFFFFF), uint32(len(meta)))
+	b.metaLength = int32(len(prefix) + len(meta))
+	b.bodyLength = int64(len(body))
+	for _, p := range [][]byte{prefix, meta, body} {
+		if err := aw.write(p); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// Close writes the last batch, the end of stream marker and the footer (file format)
+func (aw *Writer) Close() error {
+	if err := aw.flush(); err != nil {
+		return err
+	}
+	if err := aw.write(appendUint32(appendUint32(nil, 0xFFFFFFFF), 0)); err != nil {
+		return err
+	}
+	if aw.opts.Stream {
+		return nil
+	}
+	var blocks []byte
+	for _, b := range aw.blocks {
+		blocks = appendUint64(appendUint32(appendUint32(appendUint64(blocks, uint64(b.offset)), uint32(b.metaLength)), 0), uint64(b.bodyLength))
+	}
+	footer := fbBuild(fbTable{fbScalar(2, metadataVersion), fbRef(buildSchema(aw.schema)), fbRef(fbStructs{nil, 0, 8}),
+		fbRef(fbStructs{blocks, len(aw.blocks), 8})})
+	if err := aw.write(footer); err != nil {
+		return err
+	}
+	if err := aw.write(appendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return aw.write(MAGIC)
+}
@@ -0,0 +1,121 @@
+package fcheck
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToArrow(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"id", "amount", "name"},
+		types:  []DataType{DT_int, DT_float, DT_string},
+		rows: [][]any{
+			{int64(1), 10.5, "a"},
+			{"2", "", nil},
+			{"x", 1.0, "c"},
+			{int64(4), "y", "d"},
+			{int64(5), int64(3), int64(7)},
+		},
+	}
+	dir := t.TempDir()
+	for _, opts := range []ArrowOptions{{}, {Stream: true, Codec: "zstd", BatchRows: 2}} {
+		var buf, rejects bytes.Buffer
+		opts.Rejects = &rejects
+		res, err := ToArrow(sr, &buf, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Rows != 3 || res.Rejected != 2 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		if !strings.Contains(rejects.String(), `x,1,c,"id: ""x"" is not an integer"`) {
+			t.Errorf("unexpected rejects:\n%s", rejects.String())
+		}
+		// no extension, the format is detected by the magic bytes or the stream prefix
+		fileName := filepath.Join(dir, "data")
+		if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fr, err := NewFileReader(fileName, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fr.(*ArrowReader); !ok {
+			t.Fatalf("expected an arrow reader, got %T", fr)
+		}
+		r := newTestReport(t, fr, ReportOptions{})
+		if r.RowCount != 3 {
+			t.Errorf("expected 3 rows, got %d", r.RowCount)
+		}
+		if types := fr.GetTypes(); types[0] != DT_int || types[1] != DT_float || types[2] != DT_string {
+			t.Errorf("unexpected types: %v", types)
+		}
+		if s := r.Field("amount").Numeric; s == nil || s.Min != 3 || s.Max != 10.5 || r.Field("amount").Nulls != 1 {
+			t.Errorf("unexpected amount stats: %+v", s)
+		}
+		if s := r.Field("id").Numeric; s == nil || s.Min != 1 || s.Max != 5 {
+			t.Errorf("unexpected id stats: %+v", s)
+		}
+		if s := r.Field("name").Strings; s == nil || r.Field("name").Count != 2 || r.Field("name").Nulls != 1 {
+			t.Errorf("unexpected name stats: %+v", s)
+		}
+		expected := "arrow file, 1 record batches, 3 rows"
+		if opts.Stream {
+			expected = "arrow stream, 2 record batches, 3 rows, zstd"
+		}
+		if info := fr.GetFileInfo(); info != expected {
+			t.Errorf("unexpected info: %s", info)
+		}
+	}
+	if _, err := ToArrow(sr, &bytes.Buffer{}, ArrowOptions{Codec: "lz4"}); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+func TestArrowProject(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := ToArrow(testRows(), &buf, ArrowOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "rows.arrow")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ar := NewArrowReader(fileName)
+	ar.Init()
+	if err := ar.Project([]string{"name", "country"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.Project([]string{"amount"}); err == nil {
+		t.Error("expected an error for a field that is not selected")
+	}
+	var rows [][]any
+	for row := range ar.Read() {
+		rows = append(rows, row)
+	}
+	if fmt.Sprint(rows) != "[[a PL] [b PL] [<nil> DE] [c PL] [<nil> PL]]" {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+}
+
+func TestToArrowDrainsReader(t *testing.T) {
+	sr := &sliceReader{fields: []string{"i"}, types: []DataType{DT_int}}
+	// more rejects than the rejects writer buffers
+	for i := 0; i < 10000; i++ {
+		sr.rows = append(sr.rows, []any{"x"})
+	}
+	fr := &finishedReader{sr, make(chan bool)}
+	if _, err := ToArrow(fr, &bytes.Buffer{}, ArrowOptions{Rejects: failingWriter{}}); err == nil {
+		t.Error("expected a rejects write error")
+	}
+	select {
+	case <-fr.done:
+	case <-time.After(time.Second):
+		t.Error("the reader was not read to the end")
+	}
+}
@@ -2,37 +2,84 @@ package fcheck
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"reflect"
 	"github.com/hamba/avro"
-	"github.com/hamba/avro/ocf"
 )
 
 type AvroReader struct {
 	fileName string
 	file *os.File
-	decoder *ocf.Decoder
+	ocf *ocfReader
 	schema *avro.RecordSchema
 	compression string
 	fields []string
 	types []DataType
-	tmpRow []any
+	recType reflect.Type // struct with the (selected) fields, the decoder skips all other fields
+	salvage bool
+	salvaged AvroSalvage
+	readStop
 }
+
+// AvroReaderOptions are the Avro reader options (ReaderOptions["avro"])
+type AvroReaderOptions struct {
+	// Salvage skips corrupted blocks (resynchronizing on the sync marker) instead of failing, see AvroReader.Salvaged
+	Salvage bool
+}
+
+// AvroSalvage counts what was skipped in the salvage mode, RecordsLost are the record counts of the corrupted blocks
+// (unknown for blocks with an invalid header or sync marker, so it's a lower bound)
+type AvroSalvage struct {
+	CorruptBlocks int
+	BytesLost     int64
+	RecordsLost   int64
+}
+
 func NewAvroReader(fileName string) AvroReader {
 	return AvroReader{fileName:fileName}
 }
-func (ar *AvroReader) toList(record any) []any {
-	rec := record.(map[string]any)
-	for i,name := range ar.fields {
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "avro",
+		Type:       FT_avro,
+		Magic:      MAGIC_AVRO,
+		Extensions: []string{".avro"},
+		New: func(fileName string, opts any) (FileReader, error) {
+			c := NewAvroReader(fileName)
+			if o, ok := opts.(AvroReaderOptions); ok {
+				c.salvage = o.Salvage
+			}
+			return &c, nil
+		},
+	})
+}
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+// recordType creates a struct type with an "any" field for each of the given Avro fields
+func recordType(fields []string) reflect.Type {
+	sfs := make([]reflect.StructField, len(fields))
+	for i, name := range fields {
+		sfs[i] = reflect.StructField{Name: fmt.Sprintf("F%d", i), Type: anyType, Tag: reflect.StructTag(`avro:"` + name + `"`)}
+	}
+	return reflect.StructOf(sfs)
+}
+
+func (ar *AvroReader) toList(rec reflect.Value) []any {
+	row := make([]any, len(ar.fields))
+	for i := range ar.fields {
+		value := rec.Field(i).Interface()
 		switch ar.types[i] {
 		case DT_float, DT_int, DT_string:
-			ar.tmpRow[i] = rec[name]
+			row[i] = value
 		default:
-			ar.tmpRow[i] = fmt.Sprintf("%v", rec[name])
+			row[i] = fmt.Sprintf("%v", value)
 		}
 
 	}
-	return ar.tmpRow
+	return row
 }
 func (ar *AvroReader) FileName() string {
 	return ar.fileName;
@@ -44,11 +91,15 @@ func (ar *AvroReader) Init() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	dec, err := ocf.NewDecoder(f)
+	fi, err := f.Stat()
 	if err != nil {
 		log.Fatal(err)
 	}
-	var meta map[string][]byte = dec.Metadata()
+	or, err := newOcfReader(f, fi.Size())
+	if err != nil {
+		log.Fatalf("%s: %v", ar.fileName, err)
+	}
+	var meta map[string][]byte = or.meta
 	schemaString := string(meta["avro.schema"])
 	ar.compression = string(meta["avro.codec"])
 	schema, err := avro.Parse(schemaString)
@@ -75,12 +126,12 @@ func (ar *AvroReader) Init() {
 			// find not null type
 			for _, subSchema := range unionSchema.Types() {
 				if subSchema.Type() != avro.Null {
-					schema = subSchema
+					fieldSchema = subSchema
 				}
 			}
 		}
 		var typ DataType
-		switch schema.Type() {
+		switch fieldSchema.Type() {
 		case avro.Int, avro.Long:
 			typ = DT_int
 		case avro.Float, avro.Double:
@@ -91,8 +142,24 @@ func (ar *AvroReader) Init() {
 		ar.types[i] = typ
 	}
 	ar.file = f
-	ar.decoder = dec
-	ar.tmpRow = make([]any, nFields)
+	ar.ocf = or
+	ar.recType = recordType(ar.fields)
+}
+
+// Project implements Projector, unselected fields are skipped by the decoder
+func (ar *AvroReader) Project(fields []string) error {
+	types := make([]DataType, len(fields))
+	for i, name := range fields {
+		j := indexof(ar.fields, name)
+		if j < 0 {
+			return fmt.Errorf("unknown field: %s", name)
+		}
+		types[i] = ar.types[j]
+	}
+	ar.fields = fields
+	ar.types = types
+	ar.recType = recordType(fields)
+	return nil
 }
 
 func (ar *AvroReader) GetFields() []string {
@@ -102,22 +169,91 @@ func (ar *AvroReader) GetTypes() []DataType {
 	return ar.types
 }
 func (ar *AvroReader) GetFileInfo() string {
-	return fmt.Sprintf("Avro, %d fields, %s compression", len(ar.fields), ar.compression)
+	info := fmt.Sprintf("Avro, %d fields, %s compression", len(ar.schema.Fields()), ar.compression)
+	if ar.salvage {
+		s := ar.salvaged
+		info += fmt.Sprintf(", salvage: %d corrupted blocks skipped, %d bytes and at least %d records lost", s.CorruptBlocks, s.BytesLost, s.RecordsLost)
+	}
+	return info
+}
+
+// Salvaged returns what was skipped in the salvage mode (after reading all rows)
+func (ar *AvroReader) Salvaged() AvroSalvage {
+	return ar.salvaged
 }
+
+// readRecord decodes the next record of the block, panics on corrupted data (e.g. invalid lengths) are returned as errors
+func readRecord(rd *avro.Reader, schema avro.Schema, rec any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	rd.ReadVal(schema, rec)
+	return rd.Error
+}
+
 func (ar *AvroReader) Read() chan []any {
 	out := make(chan []any)
-	go func(decoder *ocf.Decoder) { 
-		for decoder.HasNext() {
-			//var rec any
-			var rec map[string]any
-			err := decoder.Decode(&rec)
-			if err != nil {
-				log.Panic(err)
+	done := ar.begin()
+	go func() {
+		rec := reflect.New(ar.recType)
+		zero := reflect.Zero(ar.recType)
+		rd := avro.NewReader(nil, 0)
+		ar.readBlocks(func(data []byte, count int64) (int64, error) {
+			rd.Reset(data)
+			rd.Error = nil
+			// lengths are checked before decoding, the decoder allocates them
+			valid, checkErr := checkRecords(data, count, ar.schema)
+			for i := int64(0); i < valid; i++ {
+				rec.Elem().Set(zero)
+				if err := readRecord(rd, ar.schema, rec.Interface()); err != nil {
+					return i, err
+				}
+				select {
+				case out <- ar.toList(rec.Elem()):
+				case <-done:
+					return i + 1, errStopped
+				}
 			}
-			out <- ar.toList(rec)
-		}
-		ar.file.Close()
+			return valid, checkErr
+		})
 		close(out)
-	} (ar.decoder)
+	} ()
 	return out
 }
+
+// readBlocks passes the data of the blocks to decode, which returns the number of records decoded
+// and the error of the next one (errStopped stops reading). Corrupted blocks are skipped in the salvage mode,
+// otherwise they are fatal.
+func (ar *AvroReader) readBlocks(decode func(data []byte, count int64) (int64, error)) {
+	or := ar.ocf
+	for {
+		block, err := or.next()
+		if err == io.EOF {
+			break
+		}
+		lost := int64(0)
+		if err == nil {
+			var n int64
+			if n, err = decode(block.Data, block.Count); err == errStopped {
+				break
+			} else if err != nil {
+				err = fmt.Errorf("record %d: %v", n, err)
+				lost = block.Count - n
+			}
+		} else if block.Count > 0 {
+			lost = block.Count
+		}
+		if err != nil {
+			if !ar.salvage {
+				log.Fatalf("%s: block at offset %d: %v (use -salvage to skip corrupted blocks)", ar.fileName, block.Offset, err)
+			}
+			skipped, _ := or.resync(block.Offset)
+			ar.salvaged.CorruptBlocks++
+			ar.salvaged.BytesLost += skipped
+			ar.salvaged.RecordsLost += lost
+		}
+	}
+	ar.file.Close()
+}
@@ -2,11 +2,15 @@ package fcheck
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/hamba/avro/ocf"
 )
 const (
-	AVRO_NULL_PATH = "..\\test\\data\\avro_null_codec"
-	AVRO_SNAPPY_PATH = "..\\test\\data\\avro_snappy"
+	AVRO_NULL_PATH = "../test/data/avro_null_codec"
+	AVRO_SNAPPY_PATH = "../test/data/avro_snappy"
 	AVRO_NULL_ROWS = 1000
 	AVRO_SNAPPY_ROWS = 1000
 )
@@ -60,3 +64,119 @@ func TestAvroTFSnappy(t *testing.T) {
 	fr := NewAvroReader(AVRO_SNAPPY_PATH)
 	TestFile(&fr, true, 10, false)
 }
+
+func TestAvroTypes(t *testing.T) {
+	// plain fields after nullable ones are typed by their own schema, not by the last union
+	schema := `{"type": "record", "name": "r", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "amount", "type": ["null", "double"]},
+		{"name": "name", "type": "string"},
+		{"name": "count", "type": "int"},
+		{"name": "rate", "type": ["float", "null"]}]}`
+	fileName := filepath.Join(t.TempDir(), "types.avro")
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := ocf.NewEncoder(schema, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := map[string]any{"id": int64(1), "amount": nil, "name": "a", "count": 2, "rate": float32(0.5)}
+	if err := enc.Encode(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fr := NewAvroReader(fileName)
+	fr.Init()
+	expected := []DataType{DT_int, DT_float, DT_string, DT_int, DT_float}
+	if fmt.Sprint(fr.GetTypes()) != fmt.Sprint(expected) {
+		t.Errorf("expected types %v, got %v", expected, fr.GetTypes())
+	}
+}
+
+// avroRows returns the rows of the batches (of 7 rows) of the file with the fields selected, read by ReadBatches
+// or collected from the rows of Read
+func avroRows(t *testing.T, fileName string, fields []string, native bool) []string {
+	fr := NewAvroReader(fileName)
+	fr.Init()
+	if fields != nil {
+		if err := fr.Project(fields); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var batches chan *ColumnBatch
+	if native {
+		batches = fr.ReadBatches(7)
+	} else {
+		batches = rowBatches(&fr, 7)
+	}
+	var rows []string
+	for b := range batches {
+		for k := 0; k < b.Rows; k++ {
+			rows = append(rows, fmt.Sprint(b.Row(k, nil)))
+		}
+	}
+	return rows
+}
+
+func TestAvroBatches(t *testing.T) {
+	schema := `{"type": "record", "name": "r", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "amount", "type": ["null", "double"]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "name", "type": "string"},
+		{"name": "count", "type": "int"},
+		{"name": "rate", "type": ["float", "null"]},
+		{"name": "ok", "type": "boolean"}]}`
+	fileName := filepath.Join(t.TempDir(), "batches.avro")
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := ocf.NewEncoder(schema, f, ocf.WithBlockLength(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		row := map[string]any{"id": int64(i), "amount": float64(i) / 4, "tags": []string{"x", fmt.Sprint(i)},
+			"name": fmt.Sprint("n", i), "count": -i, "rate": float32(i) / 2, "ok": i%3 == 0}
+		if i%5 == 1 {
+			row["amount"], row["rate"] = nil, nil
+		}
+		if err := enc.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fr := NewAvroReader(fileName)
+	fr.Init()
+	// the array field is decoded by the generic decoder, the others straight into the vectors
+	if newAvroVectors(fr.schema, fr.fields) != nil || newAvroVectors(fr.schema, []string{"amount", "ok"}) == nil {
+		t.Error("expected the vector decoder for primitive fields only")
+	}
+	for _, fields := range [][]string{nil, {"rate", "ok", "id", "amount", "count", "name"}, {"amount"}} {
+		rows := avroRows(t, fileName, fields, false)
+		if len(rows) != 20 {
+			t.Fatalf("%v: expected 20 rows, got %d", fields, len(rows))
+		}
+		if batchRows := avroRows(t, fileName, fields, true); fmt.Sprint(batchRows) != fmt.Sprint(rows) {
+			t.Errorf("%v: rows of batches differ:\n%v\n%v", fields, batchRows, rows)
+		}
+	}
+	if rows := avroRows(t, fileName, []string{"rate", "ok", "id", "amount", "count", "name"}, true); rows[1] != "[<nil> false 1 <nil> -1 n1]" ||
+		rows[7] != "[3.5 false 7 1.75 -7 n7]" {
+		t.Errorf("unexpected rows: %v", rows[:8])
+	}
+	for _, fileName := range []string{AVRO_NULL_PATH, AVRO_SNAPPY_PATH} {
+		if rows, batchRows := avroRows(t, fileName, nil, false), avroRows(t, fileName, nil, true); fmt.Sprint(batchRows) != fmt.Sprint(rows) {
+			t.Errorf("%s: rows of batches differ", fileName)
+		}
+	}
+}
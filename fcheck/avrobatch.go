@@ -0,0 +1,196 @@
+package fcheck
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/hamba/avro"
+)
+
+// ReadBatches implements BatchReader. Records are decoded straight into the vectors when the selected fields are
+// primitive types or unions of null and a primitive type, other records are decoded like in Read.
+func (ar *AvroReader) ReadBatches(size int) chan *ColumnBatch {
+	if size <= 0 {
+		size = BATCH_SIZE
+	}
+	out := make(chan *ColumnBatch)
+	done := ar.begin()
+	go func() {
+		b := newColumnBatch(ar.types, size)
+		// added sends the batch if it's full, false if the reader was stopped
+		added := func() bool {
+			if b.Rows == size {
+				select {
+				case out <- b:
+				case <-done:
+					return false
+				}
+				b = newColumnBatch(ar.types, size)
+			}
+			return true
+		}
+		if dec := newAvroVectors(ar.schema, ar.fields); dec != nil {
+			ar.readBlocks(func(data []byte, count int64) (int64, error) {
+				// the records that are decoded are checked first, so decoding doesn't stop halfway through a record
+				valid, checkErr := checkRecords(data, count, ar.schema)
+				dec.b, dec.pos = data, 0
+				for i := int64(0); i < valid; i++ {
+					if err := dec.record(b); err != nil {
+						b.truncate()
+						return i, err
+					}
+					if !added() {
+						return i + 1, errStopped
+					}
+				}
+				return valid, checkErr
+			})
+		} else {
+			rec := reflect.New(ar.recType)
+			zero := reflect.Zero(ar.recType)
+			rd := avro.NewReader(nil, 0)
+			ar.readBlocks(func(data []byte, count int64) (int64, error) {
+				rd.Reset(data)
+				rd.Error = nil
+				valid, checkErr := checkRecords(data, count, ar.schema)
+				for i := int64(0); i < valid; i++ {
+					rec.Elem().Set(zero)
+					if err := readRecord(rd, ar.schema, rec.Interface()); err != nil {
+						return i, err
+					}
+					b.add(ar.toList(rec.Elem()))
+					if !added() {
+						return i + 1, errStopped
+					}
+				}
+				return valid, checkErr
+			})
+		}
+		if b.Rows > 0 {
+			out <- b
+		}
+		close(out)
+	}()
+	return out
+}
+
+// avroVectors decodes records into the vectors of a batch, unselected fields are skipped
+type avroVectors struct {
+	avroChecker
+	fields []avroVectorField // by the fields of the schema
+}
+
+type avroVectorField struct {
+	schema avro.Schema
+	column int       // index of the vector, -1 if the field is not selected
+	typ    avro.Type // the primitive type (of the not null branch of a union)
+	null   int64     // union index of null, -1 if the field is not a union
+}
+
+// newAvroVectors returns the decoder of the selected fields of the schema, nil if one of them isn't
+// a primitive type (without a logical type, the decoder returns e.g. time.Time for them) or a union of null and one
+func newAvroVectors(schema *avro.RecordSchema, selected []string) *avroVectors {
+	dec := &avroVectors{}
+	for _, f := range schema.Fields() {
+		vf := avroVectorField{schema: f.Type(), column: indexof(selected, f.Name()), null: -1}
+		if vf.column >= 0 {
+			s := f.Type()
+			if u, ok := s.(*avro.UnionSchema); ok {
+				types := u.Types()
+				if len(types) != 2 || types[0].Type() != avro.Null && types[1].Type() != avro.Null {
+					return nil
+				}
+				vf.null = 0
+				s = types[1]
+				if types[1].Type() == avro.Null {
+					vf.null, s = 1, types[0]
+				}
+			}
+			p, ok := s.(*avro.PrimitiveSchema)
+			if !ok || p.Logical() != nil {
+				return nil
+			}
+			switch p.Type() {
+			case avro.Int, avro.Long, avro.Float, avro.Double, avro.String, avro.Boolean:
+			default:
+				return nil
+			}
+			vf.typ = p.Type()
+		}
+		dec.fields = append(dec.fields, vf)
+	}
+	return dec
+}
+
+// record decodes the next record, appending its values to the vectors of the batch
+func (dec *avroVectors) record(b *ColumnBatch) error {
+	for _, f := range dec.fields {
+		if f.column < 0 {
+			if err := dec.datum(f.schema); err != nil {
+				return err
+			}
+			continue
+		}
+		v := b.Columns[f.column]
+		if f.null >= 0 {
+			i, err := dec.long()
+			if err != nil {
+				return err
+			}
+			if i == f.null {
+				v.appendNull(b.Rows)
+				continue
+			}
+		}
+		if err := dec.value(f.typ, v); err != nil {
+			return err
+		}
+		v.appendValid(b.Rows, true)
+	}
+	b.Rows++
+	return nil
+}
+
+// value appends the value of the primitive type to the vector, ints and floats are converted to the vector type
+// (the field types of Init are DT_int for ints and longs, DT_float for floats and doubles and DT_string for others)
+func (dec *avroVectors) value(typ avro.Type, v *Vector) error {
+	switch typ {
+	case avro.Int, avro.Long:
+		x, err := dec.long()
+		if err != nil {
+			return err
+		}
+		v.Ints = append(v.Ints, x)
+	case avro.Float:
+		start := dec.pos
+		if err := dec.skip(4); err != nil {
+			return err
+		}
+		v.Floats = append(v.Floats, float64(math.Float32frombits(binary.LittleEndian.Uint32(dec.b[start:]))))
+	case avro.Double:
+		start := dec.pos
+		if err := dec.skip(8); err != nil {
+			return err
+		}
+		v.Floats = append(v.Floats, math.Float64frombits(binary.LittleEndian.Uint64(dec.b[start:])))
+	case avro.String:
+		n, err := dec.long()
+		if err != nil {
+			return err
+		}
+		start := dec.pos
+		if err := dec.skip(n); err != nil {
+			return err
+		}
+		v.Strings = append(v.Strings, string(dec.b[start:dec.pos]))
+	case avro.Boolean:
+		start := dec.pos
+		if err := dec.skip(1); err != nil {
+			return err
+		}
+		v.Strings = append(v.Strings, strconv.FormatBool(dec.b[start] != 0))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package fcheck
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// MAX_AVRO_BLOCKS_SHOWN is the max number of blocks listed by AvroMeta.WriteText, corrupted blocks are always listed
+const MAX_AVRO_BLOCKS_SHOWN = 20
+
+// AvroBlock is a block of an Avro object container file
+type AvroBlock struct {
+	Offset       int64
+	Records      int64 // -1 if the block header or the sync marker is invalid
+	Size         int64 // compressed, -1 if the block header is invalid
+	Uncompressed int64
+	Error        string // why the block is corrupted, "" if it's valid
+	Skipped      int64  // bytes skipped to the next sync marker if the block is corrupted
+}
+
+// AvroMeta is the structure of an Avro object container file: header metadata and blocks, records are not decoded
+type AvroMeta struct {
+	FileName      string
+	Size          int64
+	HeaderSize    int64
+	Codec         string
+	MetadataKeys  []string // in the file order
+	Metadata      map[string][]byte
+	Blocks        []AvroBlock
+	Records       int64 // in valid blocks
+	CorruptBlocks int
+}
+
+// ReadAvroMeta walks the blocks of the file, corrupted blocks are skipped by searching for the next sync marker
+func ReadAvroMeta(fileName string) (*AvroMeta, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	or, err := newOcfReader(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	am := &AvroMeta{FileName: fileName, Size: fi.Size(), HeaderSize: or.headerSize, Codec: or.codec.name,
+		MetadataKeys: or.metaKeys, Metadata: or.meta}
+	for {
+		b, err := or.next()
+		if err == io.EOF {
+			break
+		}
+		block := AvroBlock{Offset: b.Offset, Records: b.Count, Size: b.Size, Uncompressed: int64(len(b.Data))}
+		if err != nil {
+			block.Error = err.Error()
+			if block.Skipped, err = or.resync(b.Offset); err != nil && err != io.EOF {
+				return nil, err
+			}
+			am.CorruptBlocks++
+		} else {
+			am.Records += b.Count
+		}
+		am.Blocks = append(am.Blocks, block)
+	}
+	return am, nil
+}
+
+// IssueCount returns the number of corrupted blocks
+func (am *AvroMeta) IssueCount() int {
+	return am.CorruptBlocks
+}
+
+func metadataValue(key string, v []byte) string {
+	if key == "avro.schema" || !utf8.Valid(v) || len(v) > 200 {
+		return fmt.Sprintf("(%d bytes)", len(v))
+	}
+	return strconv.Quote(string(v))
+}
+
+// WriteText writes the header metadata, block statistics and the blocks (all of them up to MAX_AVRO_BLOCKS_SHOWN)
+func (am *AvroMeta) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "File:", am.FileName)
+	fmt.Fprintf(w, "Info: Avro object container, %s, %s compression\n", formatSize(am.Size), am.Codec)
+	fmt.Fprintf(w, "Rows: %d in %d blocks", am.Records, len(am.Blocks)-am.CorruptBlocks)
+	if am.CorruptBlocks > 0 {
+		fmt.Fprintf(w, ", %d corrupted blocks", am.CorruptBlocks)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Header: %d bytes\n", am.HeaderSize)
+	for _, key := range am.MetadataKeys {
+		fmt.Fprintf(w, "  %s: %s\n", key, metadataValue(key, am.Metadata[key]))
+	}
+
+	var min, max, lost, skipped int64 = -1, 0, 0, 0
+	for _, b := range am.Blocks {
+		if b.Error != "" {
+			skipped += b.Skipped
+			if b.Records > 0 {
+				lost += b.Records
+			}
+			continue
+		}
+		if min < 0 || b.Records < min {
+			min = b.Records
+		}
+		if b.Records > max {
+			max = b.Records
+		}
+	}
+	if valid := int64(len(am.Blocks) - am.CorruptBlocks); valid > 0 {
+		fmt.Fprintf(w, "Records per block: min %d, avg %.1f, max %d\n", min, float64(am.Records)/float64(valid), max)
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  block\toffset\trecords\tcompressed\tuncompressed\tstatus")
+	for i, b := range am.Blocks {
+		if i >= MAX_AVRO_BLOCKS_SHOWN && b.Error == "" {
+			continue
+		}
+		status, size, uncompressed, records := "ok", formatSize(b.Size), formatSize(b.Uncompressed), strconv.FormatInt(b.Records, 10)
+		if b.Error != "" {
+			status = fmt.Sprintf("! %s, %d bytes skipped", b.Error, b.Skipped)
+			uncompressed = "-"
+		}
+		if b.Records < 0 {
+			records = "-"
+		}
+		if b.Size < 0 {
+			size = "-"
+		}
+		fmt.Fprintf(tw, "  %d\t%d\t%s\t%s\t%s\t%s\n", i, b.Offset, records, size, uncompressed, status)
+	}
+	tw.Flush()
+	if len(am.Blocks) > MAX_AVRO_BLOCKS_SHOWN {
+		fmt.Fprintf(w, "  (only the first %d blocks and the corrupted ones are listed)\n", MAX_AVRO_BLOCKS_SHOWN)
+	}
+
+	fmt.Fprintln(w)
+	if am.CorruptBlocks > 0 {
+		fmt.Fprintf(w, "%d corrupted blocks, %d bytes and at least %d records lost (use -salvage to profile the rest)\n", am.CorruptBlocks, skipped, lost)
+	} else {
+		fmt.Fprintln(w, "0 issues found")
+	}
+}
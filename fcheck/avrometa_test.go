@@ -0,0 +1,152 @@
+package fcheck
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeAvroFile writes testRows as an OCF file with 2 records per block, modify can corrupt it
+func writeAvroFile(t *testing.T, codec string, modify func(b []byte) []byte) string {
+	var buf bytes.Buffer
+	if _, err := ToAvro(testRows(), &buf, AvroOptions{Codec: codec, BlockRows: 2}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if modify != nil {
+		b = modify(b)
+	}
+	fileName := filepath.Join(t.TempDir(), "test.avro")
+	if err := os.WriteFile(fileName, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// readAvroRows returns the number of rows and the salvage counts, read by Read and by ReadBatches (they must agree)
+func readAvroRows(t *testing.T, fileName string, salvage bool) (int, AvroSalvage) {
+	var counts [2]int
+	var salvaged [2]AvroSalvage
+	for i := range counts {
+		fr, err := NewFileReader(fileName, ReaderOptions{"avro": AvroReaderOptions{Salvage: salvage}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.Init()
+		if i == 0 {
+			for range fr.Read() {
+				counts[i]++
+			}
+		} else {
+			for b := range ReadBatches(fr, 3) {
+				counts[i] += b.Rows
+			}
+		}
+		salvaged[i] = fr.(*AvroReader).Salvaged()
+	}
+	if counts[0] != counts[1] || salvaged[0] != salvaged[1] {
+		t.Errorf("%s: read %d rows, %+v, in batches %d rows, %+v", fileName, counts[0], salvaged[0], counts[1], salvaged[1])
+	}
+	return counts[0], salvaged[0]
+}
+
+func TestAvroMeta(t *testing.T) {
+	for _, codec := range AVRO_CODECS {
+		fileName := writeAvroFile(t, codec, nil)
+		am, err := ReadAvroMeta(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(am.Blocks) != 3 || am.Records != 5 || am.CorruptBlocks != 0 || am.Blocks[2].Records != 1 || am.MetadataKeys[0] != "avro.schema" {
+			t.Errorf("%s: unexpected meta: %+v", codec, am)
+		}
+		if n, _ := readAvroRows(t, fileName, false); n != 5 {
+			t.Errorf("%s: expected 5 rows, got %d", codec, n)
+		}
+		var out bytes.Buffer
+		am.WriteText(&out)
+		if !strings.Contains(out.String(), "Rows: 5 in 3 blocks") || !strings.Contains(out.String(), "0 issues found") {
+			t.Errorf("%s: unexpected output:\n%s", codec, out.String())
+		}
+	}
+	if _, err := ReadAvroMeta("csv_test.go"); err == nil {
+		t.Error("expected an error for a non avro file")
+	}
+}
+
+func TestAvroSalvage(t *testing.T) {
+	valid, _ := ReadAvroMeta(writeAvroFile(t, "snappy", nil))
+	second := valid.Blocks[1]
+	for _, tt := range []struct {
+		name    string
+		modify  func(b []byte) []byte
+		rows    int
+		records int64
+		message string
+	}{
+		{"corrupted data", func(b []byte) []byte {
+			b[second.Offset+4] ^= 0xff
+			return b
+		}, 3, 2, "snappy"},
+		{"invalid sync marker", func(b []byte) []byte {
+			b[valid.Blocks[2].Offset-1] ^= 0xff
+			return b
+		}, 3, 0, "invalid sync marker"},
+		{"invalid block header", func(b []byte) []byte {
+			b[second.Offset] = 0x01 // -1 records
+			return b
+		}, 3, 0, "invalid block header"},
+		{"truncated", func(b []byte) []byte {
+			return b[:len(b)-5]
+		}, 4, 1, "truncated file"},
+	} {
+		fileName := writeAvroFile(t, "snappy", tt.modify)
+		am, err := ReadAvroMeta(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if am.CorruptBlocks != 1 || am.Records != int64(tt.rows) || am.IssueCount() != 1 {
+			t.Errorf("%s: unexpected meta: %+v", tt.name, am)
+		}
+		var out bytes.Buffer
+		am.WriteText(&out)
+		if !strings.Contains(out.String(), tt.message) {
+			t.Errorf("%s: expected %q in\n%s", tt.name, tt.message, out.String())
+		}
+		n, salvaged := readAvroRows(t, fileName, true)
+		if n != tt.rows || salvaged.CorruptBlocks != 1 || salvaged.RecordsLost != tt.records || salvaged.BytesLost == 0 {
+			t.Errorf("%s: expected %d rows, got %d, %+v", tt.name, tt.rows, n, salvaged)
+		}
+	}
+}
+
+func TestAvroSalvageRecordLength(t *testing.T) {
+	// a valid block with a string length of 1 TB in its second record, then a valid block
+	var buf bytes.Buffer
+	codec, _ := newAvroCodec("null", 0)
+	ow, err := newOcfWriter(&buf, `{"type": "record", "name": "r", "fields": [{"name": "s", "type": "string"}]}`, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ow.block = appendBytes(ow.block, "a")
+	ow.block = appendLong(ow.block, 1<<40)
+	ow.block = append(ow.block, "bc"...)
+	ow.count = 2
+	if err := ow.flush(); err != nil {
+		t.Fatal(err)
+	}
+	ow.block, ow.count = appendBytes(ow.block, "d"), 1
+	if err := ow.flush(); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "length.avro")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, salvaged := readAvroRows(t, fileName, true)
+	if n != 2 || salvaged.CorruptBlocks != 1 || salvaged.RecordsLost != 1 {
+		t.Errorf("expected 2 rows, got %d, %+v", n, salvaged)
+	}
+}
@@ -0,0 +1,513 @@
+package fcheck
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/hamba/avro"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Avro object container file (OCF) framing: header (magic, metadata, sync marker) followed by blocks
+// (record count, size, data, sync marker), see https://avro.apache.org/docs/current/specification/#object-container-files
+
+// AVRO_CODECS block compression codecs supported by the OCF writer
+var AVRO_CODECS = []string{"null", "deflate", "snappy", "zstd"}
+
+// AVRO_SYNC_INTERVAL default approx. size of a block (before compression), same as in the Java implementation
+const AVRO_SYNC_INTERVAL = 64000
+
+const AVRO_SYNC_SIZE = 16
+
+// AVRO_MAX_BLOCK_SIZE max size of a decompressed block, larger blocks are read as corrupted
+const AVRO_MAX_BLOCK_SIZE = 256 << 20
+
+// avroCodec compresses and decompresses OCF blocks, decompress fails if the block is larger than max bytes
+type avroCodec struct {
+	name       string
+	compress   func(b []byte) ([]byte, error)
+	decompress func(b []byte, max int) ([]byte, error)
+}
+
+// readAllMax reads r till EOF, it fails if there are more than max bytes
+func readAllMax(r io.Reader, max int) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err == nil && len(b) > max {
+		err = fmt.Errorf("block larger than %d bytes decompressed", max)
+	}
+	return b, err
+}
+
+// newAvroCodec returns the codec, level is the deflate (1-9) or zstd (1-22) level, 0 for the default
+func newAvroCodec(name string, level int) (*avroCodec, error) {
+	switch name {
+	case "", "null":
+		return &avroCodec{
+			name:       "null",
+			compress:   func(b []byte) ([]byte, error) { return b, nil },
+			decompress: func(b []byte, max int) ([]byte, error) { return b, nil },
+		}, nil
+	case "deflate":
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return nil, fmt.Errorf("invalid deflate level: %d", level)
+		}
+		return &avroCodec{
+			name: name,
+			compress: func(b []byte) ([]byte, error) {
+				var buf bytes.Buffer
+				fw, err := flate.NewWriter(&buf, level)
+				if err != nil {
+					return nil, err
+				}
+				if _, err = fw.Write(b); err != nil {
+					return nil, err
+				}
+				err = fw.Close()
+				return buf.Bytes(), err
+			},
+			decompress: func(b []byte, max int) ([]byte, error) {
+				return readAllMax(flate.NewReader(bytes.NewReader(b)), max)
+			},
+		}, nil
+	case "snappy":
+		// snappy blocks are followed by the big-endian CRC32 of the uncompressed data
+		return &avroCodec{
+			name: name,
+			compress: func(b []byte) ([]byte, error) {
+				var crc [4]byte
+				binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(b))
+				return append(snappy.Encode(nil, b), crc[:]...), nil
+			},
+			decompress: func(b []byte, max int) ([]byte, error) {
+				if len(b) < 4 {
+					return nil, errors.New("snappy block too short")
+				}
+				if n, err := snappy.DecodedLen(b[:len(b)-4]); err == nil && n > max {
+					return nil, fmt.Errorf("block larger than %d bytes decompressed", max)
+				}
+				out, err := snappy.Decode(nil, b[:len(b)-4])
+				if err != nil {
+					return nil, err
+				}
+				if crc32.ChecksumIEEE(out) != binary.BigEndian.Uint32(b[len(b)-4:]) {
+					return nil, errors.New("snappy block checksum mismatch")
+				}
+				return out, nil
+			},
+		}, nil
+	case "zstd", "zstandard":
+		zlevel := zstd.SpeedDefault
+		if level != 0 {
+			zlevel = zstd.EncoderLevelFromZstd(level)
+		}
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zlevel))
+		if err != nil {
+			return nil, err
+		}
+		// DecodeAll fails for blocks larger than AVRO_MAX_BLOCK_SIZE before allocating them
+		// (also if it's the content size declared in the frame header), smaller max sizes are checked after decoding
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(AVRO_MAX_BLOCK_SIZE))
+		if err != nil {
+			return nil, err
+		}
+		return &avroCodec{
+			name:     "zstandard", // the name used in avro.codec
+			compress: func(b []byte) ([]byte, error) { return enc.EncodeAll(b, nil), nil },
+			decompress: func(b []byte, max int) ([]byte, error) {
+				out, err := dec.DecodeAll(b, nil)
+				if err == nil && len(out) > max {
+					err = fmt.Errorf("block larger than %d bytes decompressed", max)
+				}
+				return out, err
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown avro codec: %s (supported: null, deflate, snappy, zstd)", name)
+}
+
+// appendLong appends the zig-zag varint encoding of Avro int and long
+func appendLong(b []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+// appendBytes appends Avro bytes and string: the length followed by the data
+func appendBytes(b []byte, s string) []byte {
+	return append(appendLong(b, int64(len(s))), s...)
+}
+
+// ocfWriter writes the header and blocks of encoded records. The hamba ocf.Encoder is not used: it has no
+// zstd codec, and a record that fails to encode can't be taken back out of its block (rejected rows are cut
+// off the block here).
+type ocfWriter struct {
+	w     io.Writer
+	codec *avroCodec
+	sync  [AVRO_SYNC_SIZE]byte
+	block []byte // encoded records of the current block
+	count int    // records in the current block
+}
+
+func newOcfWriter(w io.Writer, schema string, codec *avroCodec) (*ocfWriter, error) {
+	ow := &ocfWriter{w: w, codec: codec}
+	if _, err := rand.Read(ow.sync[:]); err != nil {
+		return nil, err
+	}
+	header := append([]byte(nil), MAGIC_AVRO...)
+	// metadata is an Avro map of bytes: a block of 2 entries and the end of map (0)
+	header = appendLong(header, 2)
+	header = appendBytes(header, "avro.schema")
+	header = appendBytes(header, schema)
+	header = appendBytes(header, "avro.codec")
+	header = appendBytes(header, codec.name)
+	header = appendLong(header, 0)
+	header = append(header, ow.sync[:]...)
+	_, err := w.Write(header)
+	return ow, err
+}
+
+// flush writes the current block (if not empty)
+func (ow *ocfWriter) flush() error {
+	if ow.count == 0 {
+		return nil
+	}
+	data, err := ow.codec.compress(ow.block)
+	if err != nil {
+		return err
+	}
+	b := appendLong(nil, int64(ow.count))
+	b = appendLong(b, int64(len(data)))
+	if _, err = ow.w.Write(b); err != nil {
+		return err
+	}
+	if _, err = ow.w.Write(data); err != nil {
+		return err
+	}
+	_, err = ow.w.Write(ow.sync[:])
+	ow.block, ow.count = ow.block[:0], 0
+	return err
+}
+
+// ocfBlock is a block of an OCF file, Data is decompressed. Count and Size are -1 if the block header is invalid,
+// Count is also -1 if the sync marker is invalid (the count can't be trusted).
+type ocfBlock struct {
+	Offset int64
+	Count  int64
+	Size   int64 // compressed size
+	Data   []byte
+}
+
+// ocfReader reads the blocks of an OCF file, corrupted blocks can be skipped by searching for the next sync marker
+type ocfReader struct {
+	r          io.ReaderAt
+	size       int64
+	offset     int64 // of the next block
+	end        int64 // declared end of the last block read by next, 0 if its header is invalid
+	headerSize int64
+	meta       map[string][]byte
+	metaKeys   []string // in the file order
+	codec      *avroCodec
+	sync       [AVRO_SYNC_SIZE]byte
+}
+
+// countingReader counts bytes read from the header
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := io.ReadFull(cr.r, p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// readString reads Avro bytes or string, max is the max length
+func readString(cr *countingReader, max int64) (string, error) {
+	n, err := binary.ReadVarint(cr)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > max {
+		return "", fmt.Errorf("invalid length: %d", n)
+	}
+	b := make([]byte, n)
+	_, err = cr.Read(b)
+	return string(b), err
+}
+
+// newOcfReader reads the header: magic, metadata and the sync marker
+func newOcfReader(r io.ReaderAt, size int64) (*ocfReader, error) {
+	or := &ocfReader{r: r, size: size, meta: map[string][]byte{}}
+	cr := &countingReader{r: bufio.NewReader(io.NewSectionReader(r, 0, size))}
+	magic := make([]byte, len(MAGIC_AVRO))
+	if _, err := cr.Read(magic); err != nil || !bytes.Equal(magic, MAGIC_AVRO) {
+		return nil, errors.New("not an Avro object container file")
+	}
+	for {
+		// metadata map blocks: count (negative if followed by the block size), key/value pairs, 0 at the end
+		n, err := binary.ReadVarint(cr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Avro header: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		if n < 0 {
+			n = -n
+			if _, err := binary.ReadVarint(cr); err != nil {
+				return nil, fmt.Errorf("invalid Avro header: %v", err)
+			}
+		}
+		for ; n > 0; n-- {
+			key, err := readString(cr, size)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Avro header: %v", err)
+			}
+			value, err := readString(cr, size)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Avro header: %s: %v", key, err)
+			}
+			if _, ok := or.meta[key]; !ok {
+				or.metaKeys = append(or.metaKeys, key)
+			}
+			or.meta[key] = []byte(value)
+		}
+	}
+	if _, err := cr.Read(or.sync[:]); err != nil {
+		return nil, fmt.Errorf("invalid Avro header: no sync marker")
+	}
+	codec, err := newAvroCodec(string(or.meta["avro.codec"]), 0)
+	if err != nil {
+		return nil, err
+	}
+	or.codec = codec
+	or.headerSize = cr.n
+	or.offset = cr.n
+	return or, nil
+}
+
+// readBlock reads the block at the offset, Data is not decompressed. end is the offset after the sync marker,
+// it's set if the block header is valid (also for an invalid sync marker)
+func (or *ocfReader) readBlock(offset int64) (b *ocfBlock, end int64, err error) {
+	b = &ocfBlock{Offset: offset, Count: -1, Size: -1}
+	var head [2 * binary.MaxVarintLen64]byte
+	n, err := or.r.ReadAt(head[:], offset)
+	if err != nil && err != io.EOF {
+		return b, 0, err
+	}
+	count, k1 := binary.Varint(head[:n])
+	if k1 <= 0 {
+		return b, 0, errors.New("invalid block header")
+	}
+	size, k2 := binary.Varint(head[k1:n])
+	if k2 <= 0 || count < 0 || size < 0 {
+		return b, 0, errors.New("invalid block header")
+	}
+	b.Count, b.Size = count, size
+	start := offset + int64(k1+k2)
+	if size > or.size-start-AVRO_SYNC_SIZE {
+		return b, 0, fmt.Errorf("block of %d bytes goes past the end of the file (truncated file)", size)
+	}
+	data := make([]byte, size+AVRO_SYNC_SIZE)
+	if _, err := or.r.ReadAt(data, start); err != nil {
+		return b, 0, err
+	}
+	end = start + size + AVRO_SYNC_SIZE
+	if !bytes.Equal(data[size:], or.sync[:]) {
+		b.Count = -1 // the header may be corrupted as well
+		return b, end, errors.New("invalid sync marker")
+	}
+	b.Data = data[:size]
+	return b, end, nil
+}
+
+// next reads the block at the current offset, io.EOF at the end of the file.
+// On other errors the offset is not moved, use resync to skip the block.
+func (or *ocfReader) next() (*ocfBlock, error) {
+	if or.offset >= or.size {
+		return nil, io.EOF
+	}
+	b, end, err := or.readBlock(or.offset)
+	or.end = end
+	if err != nil {
+		return b, err
+	}
+	if b.Data, err = or.codec.decompress(b.Data, AVRO_MAX_BLOCK_SIZE); err != nil {
+		return b, fmt.Errorf("%s: %v", or.codec.name, err)
+	}
+	or.offset = end
+	return b, nil
+}
+
+// resync skips the corrupted block at from (the last one returned by next): it moves to the declared end of the block
+// if there's a valid block (or the end of the file), otherwise after the first sync marker found after from.
+// It returns the number of bytes skipped, io.EOF if there's no sync marker (the rest of the file is skipped).
+func (or *ocfReader) resync(from int64) (int64, error) {
+	if or.end > from {
+		if _, _, err := or.readBlock(or.end); err == nil || or.end == or.size {
+			or.offset = or.end
+			return or.end - from, nil
+		}
+	}
+	buf := make([]byte, AVRO_SYNC_INTERVAL)
+	for pos := from; ; pos += int64(len(buf) - AVRO_SYNC_SIZE + 1) {
+		n, err := or.r.ReadAt(buf, pos)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.Index(buf[:n], or.sync[:]); i >= 0 {
+			or.offset = pos + int64(i+AVRO_SYNC_SIZE)
+			return or.offset - from, nil
+		}
+		if pos+int64(n) >= or.size {
+			or.offset = or.size
+			return or.size - from, io.EOF
+		}
+	}
+}
+
+// avroChecker walks encoded records of a block without decoding them. Lengths and counts are checked
+// against the bytes left in the block, so a corrupted block can't make the decoder allocate more than that.
+type avroChecker struct {
+	b   []byte
+	pos int
+}
+
+var errAvroRecord = errors.New("invalid length or truncated record")
+
+// checkRecords returns the number of well formed records at the start of the block data,
+// and the error of the next one if it's less than count
+func checkRecords(data []byte, count int64, schema avro.Schema) (int64, error) {
+	c := &avroChecker{b: data}
+	for i := int64(0); i < count; i++ {
+		if err := c.datum(schema); err != nil {
+			return i, err
+		}
+	}
+	return count, nil
+}
+
+func (c *avroChecker) long() (int64, error) {
+	v, n := binary.Varint(c.b[c.pos:])
+	if n <= 0 {
+		return 0, errAvroRecord
+	}
+	c.pos += n
+	return v, nil
+}
+
+// skip moves past n bytes
+func (c *avroChecker) skip(n int64) error {
+	if n < 0 || n > int64(len(c.b)-c.pos) {
+		return errAvroRecord
+	}
+	c.pos += int(n)
+	return nil
+}
+
+// items checks the blocks of an array or a map, each item is checked by item
+func (c *avroChecker) items(item func() error) error {
+	for {
+		count, err := c.long()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// the block size follows a negative count
+			count = -count
+			if _, err := c.long(); err != nil {
+				return err
+			}
+		}
+		// items take at least a byte (longer arrays of nulls are rejected too)
+		if count > int64(len(c.b)-c.pos) {
+			return errAvroRecord
+		}
+		for ; count > 0; count-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *avroChecker) datum(schema avro.Schema) error {
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		for _, f := range s.Fields() {
+			if err := c.datum(f.Type()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *avro.RefSchema:
+		return c.datum(s.Schema())
+	case *avro.UnionSchema:
+		i, err := c.long()
+		if err != nil {
+			return err
+		}
+		if i < 0 || i >= int64(len(s.Types())) {
+			return fmt.Errorf("invalid union index %d", i)
+		}
+		return c.datum(s.Types()[i])
+	case *avro.ArraySchema:
+		return c.items(func() error { return c.datum(s.Items()) })
+	case *avro.MapSchema:
+		return c.items(func() error {
+			n, err := c.long()
+			if err == nil {
+				err = c.skip(n)
+			}
+			if err == nil {
+				err = c.datum(s.Values())
+			}
+			return err
+		})
+	case *avro.FixedSchema:
+		return c.skip(int64(s.Size()))
+	}
+	switch schema.Type() {
+	case avro.Null:
+		return nil
+	case avro.Boolean:
+		return c.skip(1)
+	case avro.Int, avro.Long, avro.Enum:
+		_, err := c.long()
+		return err
+	case avro.Float:
+		return c.skip(4)
+	case avro.Double:
+		return c.skip(8)
+	case avro.String, avro.Bytes:
+		n, err := c.long()
+		if err == nil {
+			err = c.skip(n)
+		}
+		return err
+	}
+	return fmt.Errorf("unsupported type %s", schema.Type())
+}
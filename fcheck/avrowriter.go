@@ -0,0 +1,252 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hamba/avro"
+)
+
+// AvroOptions controls ToAvro
+type AvroOptions struct {
+	// Schema is the Avro record schema (.avsc), fields are matched with the reader fields by name. If empty
+	// it's derived from the reader fields and types, all fields nullable.
+	Schema string
+	// Codec is one of AVRO_CODECS, null if empty
+	Codec string
+	// Level is the deflate (1-9) or zstd (1-22) compression level, 0 for the codec default
+	Level int
+	// SyncInterval is the approx. size of a block (before compression), AVRO_SYNC_INTERVAL if 0
+	SyncInterval int
+	// BlockRows limits the number of records in a block, no limit if 0
+	BlockRows int
+	// Rejects gets the rows that can't be coerced to the schema as csv, with an error column, dropped if nil
+	Rejects io.Writer
+}
+
+// avroColumn encodes a record field from a reader field (or its default)
+type avroColumn struct {
+	name     string
+	src      int       // index of the reader field, -1 if missing (def is used)
+	typ      avro.Type // primitive type or avro.Enum
+	symbols  map[string]int
+	nullable bool
+	nullIdx  int // union branches of nullable fields
+	typIdx   int
+	def      any
+}
+
+// avroSchemaOf derives the record schema from the reader fields and types
+func avroSchemaOf(fr FileReader) string {
+	base := filepath.Base(fr.FileName())
+	s := &Schema{Name: strings.TrimSuffix(base, filepath.Ext(base))}
+	types := fr.GetTypes()
+	for i, f := range fr.GetFields() {
+		t := types[i]
+		if t == DT_unknown {
+			t = DT_string
+		}
+		s.Fields = append(s.Fields, SchemaField{Name: f, Type: t, Nullable: true})
+	}
+	var buf bytes.Buffer
+	s.writeAvro(&buf)
+	return buf.String()
+}
+
+// newAvroColumns matches the schema fields with the reader fields, by name or by the Avro identifier
+// of the reader field name
+func newAvroColumns(schema *avro.RecordSchema, fields []string) ([]avroColumn, error) {
+	var cols []avroColumn
+	for _, f := range schema.Fields() {
+		col := avroColumn{name: f.Name(), src: indexof(fields, f.Name())}
+		if col.src < 0 {
+			for i, name := range fields {
+				if identifier(name) == f.Name() {
+					col.src = i
+					break
+				}
+			}
+		}
+		t := f.Type()
+		if u, ok := t.(*avro.UnionSchema); ok {
+			if !u.Nullable() {
+				return nil, fmt.Errorf("field %s: only nullable unions are supported", f.Name())
+			}
+			col.nullable = true
+			col.nullIdx, col.typIdx = u.Indices()
+			t = u.Types()[col.typIdx]
+		}
+		switch s := t.(type) {
+		case *avro.PrimitiveSchema:
+			if s.Type() == avro.Null {
+				return nil, fmt.Errorf("field %s: null type is not supported", f.Name())
+			}
+			col.typ = s.Type()
+		case *avro.EnumSchema:
+			col.typ = avro.Enum
+			col.symbols = map[string]int{}
+			for i, sym := range s.Symbols() {
+				col.symbols[sym] = i
+			}
+		default:
+			return nil, fmt.Errorf("field %s: type %s is not supported", f.Name(), t.Type())
+		}
+		if col.src < 0 {
+			if !f.HasDefault() && !col.nullable {
+				return nil, fmt.Errorf("field %s: not found in the file and has no default", f.Name())
+			}
+			col.def = f.Default()
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// append encodes the value, empty strings are nulls for non string types
+func (c *avroColumn) append(b []byte, value any) ([]byte, error) {
+	if s, ok := value.(string); ok && s == "" && c.typ != avro.String && c.typ != avro.Bytes {
+		value = nil
+	}
+	if value == nil {
+		if !c.nullable {
+			return b, fmt.Errorf("%s: null value", c.name)
+		}
+		return appendLong(b, int64(c.nullIdx)), nil
+	}
+	if c.nullable {
+		b = appendLong(b, int64(c.typIdx))
+	}
+	switch c.typ {
+	case avro.String, avro.Bytes:
+		if v, ok := value.([]byte); ok {
+			return appendBytes(b, string(v)), nil
+		}
+		s, _ := formatValue(value)
+		return appendBytes(b, s), nil
+	case avro.Int, avro.Long:
+		v, err := toInt64(value)
+		if err != nil {
+			return b, fmt.Errorf("%s: %v", c.name, err)
+		}
+		if c.typ == avro.Int && (v < math.MinInt32 || v > math.MaxInt32) {
+			return b, fmt.Errorf("%s: %d out of int range", c.name, v)
+		}
+		return appendLong(b, v), nil
+	case avro.Float, avro.Double:
+		v, err := toFloat64(value)
+		if err != nil {
+			return b, fmt.Errorf("%s: %v", c.name, err)
+		}
+		if c.typ == avro.Float {
+			var tmp [4]byte
+			binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(float32(v)))
+			return append(b, tmp[:]...), nil
+		}
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+		return append(b, tmp[:]...), nil
+	case avro.Boolean:
+		var v bool
+		switch x := value.(type) {
+		case bool:
+			v = x
+		case int64:
+			v = x != 0
+		default:
+			s, _ := formatValue(value)
+			var err error
+			if v, err = strconv.ParseBool(strings.TrimSpace(s)); err != nil {
+				return b, fmt.Errorf("%s: invalid boolean %q", c.name, s)
+			}
+		}
+		if v {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case avro.Enum:
+		s, _ := formatValue(value)
+		i, ok := c.symbols[s]
+		if !ok {
+			return b, fmt.Errorf("%s: %q is not an enum symbol", c.name, s)
+		}
+		return appendLong(b, int64(i)), nil
+	}
+	return b, fmt.Errorf("%s: unsupported type %s", c.name, c.typ)
+}
+
+// ToAvro writes all rows (Init() is called here) as an Avro object container file
+func ToAvro(fr FileReader, w io.Writer, opts AvroOptions) (ConvertResult, error) {
+	var res ConvertResult
+	fr.Init()
+	fields := fr.GetFields()
+	schemaText := opts.Schema
+	if schemaText == "" {
+		schemaText = avroSchemaOf(fr)
+	}
+	schema, err := avro.Parse(schemaText)
+	if err != nil {
+		return res, err
+	}
+	record, ok := schema.(*avro.RecordSchema)
+	if !ok {
+		return res, fmt.Errorf("avro schema must be a record, got %s", schema.Type())
+	}
+	cols, err := newAvroColumns(record, fields)
+	if err != nil {
+		return res, err
+	}
+	codec, err := newAvroCodec(opts.Codec, opts.Level)
+	if err != nil {
+		return res, err
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = AVRO_SYNC_INTERVAL
+	}
+	// the schema as given, the canonical form drops defaults and docs
+	ow, err := newOcfWriter(w, strings.TrimSpace(schemaText), codec)
+	if err != nil {
+		return res, err
+	}
+	rejects := newRejectWriter(opts.Rejects, fields)
+	rows := fr.Read()
+	for row := range rows {
+		start := len(ow.block)
+		var err error
+		for i := range cols {
+			value := cols[i].def
+			if cols[i].src >= 0 {
+				value = row[cols[i].src]
+			}
+			if ow.block, err = cols[i].append(ow.block, value); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			ow.block = ow.block[:start]
+			res.Rejected++
+			if err = rejects.write(row, err); err != nil {
+				drain(rows)
+				return res, err
+			}
+			continue
+		}
+		res.Rows++
+		ow.count++
+		if len(ow.block) >= opts.SyncInterval || opts.BlockRows > 0 && ow.count >= opts.BlockRows {
+			if err := ow.flush(); err != nil {
+				drain(rows)
+				return res, err
+			}
+		}
+	}
+	if err := rejects.flush(); err != nil {
+		return res, err
+	}
+	return res, ow.flush()
+}
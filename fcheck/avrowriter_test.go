@@ -0,0 +1,167 @@
+package fcheck
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/ocf"
+)
+
+// readOcf decodes all records with the hamba decoder (null, deflate and snappy codecs)
+func readOcf(t *testing.T, b []byte) ([]map[string]any, map[string][]byte) {
+	dec, err := ocf.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []map[string]any
+	for dec.HasNext() {
+		rec := map[string]any{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if dec.Error() != nil {
+		t.Fatal(dec.Error())
+	}
+	return recs, dec.Metadata()
+}
+
+func TestToAvroDerivedSchema(t *testing.T) {
+	for _, codec := range []string{"null", "deflate", "snappy"} {
+		var buf bytes.Buffer
+		res, err := ToAvro(testRows(), &buf, AvroOptions{Codec: codec, BlockRows: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs, meta := readOcf(t, buf.Bytes())
+		if res.Rows != 5 || res.Rejected != 0 || len(recs) != 5 || string(meta["avro.codec"]) != codec {
+			t.Fatalf("%s: unexpected result %+v, %d records", codec, res, len(recs))
+		}
+		if recs[0]["country"] != "PL" || recs[0]["amount"] != 10.5 || recs[2]["name"] != nil {
+			t.Errorf("%s: unexpected records: %v", codec, recs)
+		}
+	}
+}
+
+func TestToAvroSchemaAndRejects(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"id", "amount", "status", "note"},
+		types:  []DataType{DT_string, DT_string, DT_string, DT_string},
+		rows: [][]any{
+			{"1", "10.5", "NEW", "a"},
+			{"x", "1", "NEW", "b"},
+			{"3", "", "DONE", nil},
+			{"4", "2", "LOST", "d"},
+			{"", "3", "NEW", "e"},
+		},
+	}
+	schema := `{"type": "record", "name": "r", "fields": [
+		{"name": "id", "type": "int"},
+		{"name": "amount", "type": ["null", "float"]},
+		{"name": "status", "type": {"type": "enum", "name": "S", "symbols": ["NEW", "DONE"]}},
+		{"name": "source", "type": "string", "default": "csv"}
+	]}`
+	var buf, rejects bytes.Buffer
+	res, err := ToAvro(sr, &buf, AvroOptions{Schema: schema, Rejects: &rejects})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 2 || res.Rejected != 3 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	recs, meta := readOcf(t, buf.Bytes())
+	if len(recs) != 2 || recs[0]["id"] != 1 || recs[0]["amount"] != float32(10.5) || recs[0]["status"] != "NEW" ||
+		recs[0]["source"] != "csv" || recs[1]["amount"] != nil {
+		t.Errorf("unexpected records: %v", recs)
+	}
+	if !strings.Contains(string(meta["avro.schema"]), `"default": "csv"`) {
+		t.Errorf("schema not preserved: %s", meta["avro.schema"])
+	}
+	expected := `id,amount,status,note,error
+x,1,NEW,b,"id: ""x"" is not an integer"
+4,2,LOST,d,"status: ""LOST"" is not an enum symbol"
+,3,NEW,e,id: null value
+`
+	if rejects.String() != expected {
+		t.Errorf("unexpected rejects:\n%s", rejects.String())
+	}
+}
+
+func TestToAvroSchemaErrors(t *testing.T) {
+	for _, schema := range []string{
+		`"string"`,
+		`{"type": "record", "name": "r", "fields": [{"name": "missing", "type": "long"}]}`,
+		`{"type": "record", "name": "r", "fields": [{"name": "amount", "type": {"type": "array", "items": "long"}}]}`,
+		`{"type": "record", "name": "r", "fields": [{"name": "amount", "type": ["long", "string"]}]}`,
+	} {
+		if _, err := ToAvro(testRows(), &bytes.Buffer{}, AvroOptions{Schema: schema}); err == nil {
+			t.Errorf("expected an error for %s", schema)
+		}
+	}
+	if _, err := ToAvro(testRows(), &bytes.Buffer{}, AvroOptions{Codec: "lz4"}); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+func TestAvroCodecs(t *testing.T) {
+	data := bytes.Repeat([]byte("gcf avro block "), 1000)
+	for _, name := range AVRO_CODECS {
+		codec, err := newAvroCodec(name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := codec.compress(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != "null" && len(c) >= len(data) {
+			t.Errorf("%s: not compressed, %d bytes", name, len(c))
+		}
+		d, err := codec.decompress(c, len(data))
+		if err != nil || !bytes.Equal(d, data) {
+			t.Errorf("%s: round trip failed: %v", name, err)
+		}
+		if _, err := codec.decompress(c, len(data)-1); err == nil && name != "null" {
+			t.Errorf("%s: expected an error for a block larger than the max size", name)
+		}
+	}
+	// a zstd frame declaring 1 TB of content
+	codec, _ := newAvroCodec("zstd", 0)
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0xe0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 0, 0}
+	if _, err := codec.decompress(frame, AVRO_MAX_BLOCK_SIZE); err == nil {
+		t.Error("zstd: expected an error for a frame larger than the max size")
+	}
+	var buf bytes.Buffer
+	if _, err := ToAvro(testRows(), &buf, AvroOptions{Codec: "zstd", Level: 19}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("\x14avro.codec\x12zstandard")) {
+		t.Error("zstandard codec not in the header")
+	}
+}
+
+// headerOnlyWriter fails after the first write (the OCF header)
+type headerOnlyWriter struct{ writes int }
+
+func (w *headerOnlyWriter) Write(p []byte) (int, error) {
+	if w.writes++; w.writes > 1 {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestToAvroDrainsReader(t *testing.T) {
+	fr := &finishedReader{numberedRows(100), make(chan bool)}
+	if _, err := ToAvro(fr, &headerOnlyWriter{}, AvroOptions{BlockRows: 1}); err == nil {
+		t.Error("expected a write error")
+	}
+	select {
+	case <-fr.done:
+	case <-time.After(time.Second):
+		t.Error("the reader was not read to the end")
+	}
+}
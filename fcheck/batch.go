@@ -0,0 +1,283 @@
+package fcheck
+
+import (
+	"fmt"
+	"gocf/fcheck/stats"
+	"math"
+)
+
+// BATCH_SIZE default number of rows of a ColumnBatch
+const BATCH_SIZE = 4096
+
+// Vector holds the values of a field in a ColumnBatch as a typed slice, by the field type: Ints (DT_int),
+// Floats (DT_float) or Strings (other types). Valid is the validity bitmap (bit i is set if the i-th value
+// is not null, see stats.IsValid), nil if there are no nulls.
+type Vector struct {
+	Type    DataType
+	Valid   []byte
+	Ints    []int64
+	Floats  []float64
+	Strings []string
+}
+
+// IsNull tells if the i-th value is null
+func (v *Vector) IsNull(i int) bool {
+	return !stats.IsValid(v.Valid, i)
+}
+
+// Value returns the i-th value: nil, int64, float64 or string
+func (v *Vector) Value(i int) any {
+	if v.IsNull(i) {
+		return nil
+	}
+	switch v.Type {
+	case DT_int:
+		return v.Ints[i]
+	case DT_float:
+		return v.Floats[i]
+	}
+	return v.Strings[i]
+}
+
+// Len returns the number of values
+func (v *Vector) Len() int {
+	switch v.Type {
+	case DT_int:
+		return len(v.Ints)
+	case DT_float:
+		return len(v.Floats)
+	}
+	return len(v.Strings)
+}
+
+// push pushes all values of the vector to the collector, one by one if it's not a stats.BatchCollector
+func (v *Vector) push(s stats.StatCollector) {
+	bc, ok := s.(stats.BatchCollector)
+	if !ok {
+		for i := 0; i < v.Len(); i++ {
+			s.Push(v.Value(i))
+		}
+		return
+	}
+	switch v.Type {
+	case DT_int:
+		bc.PushInts(v.Ints, v.Valid)
+	case DT_float:
+		bc.PushFloats(v.Floats, v.Valid)
+	default:
+		bc.PushStrings(v.Strings, v.Valid)
+	}
+}
+
+// ColumnBatch is a chunk of rows stored column by column, a vector for each field (in the GetFields order).
+// A batch is owned by the receiver, readers don't reuse it.
+type ColumnBatch struct {
+	Rows    int
+	Columns []*Vector
+}
+
+// Row fills the row (allocated if it's too short) with the values of the i-th row and returns it
+func (b *ColumnBatch) Row(i int, row []any) []any {
+	if len(row) < len(b.Columns) {
+		row = make([]any, len(b.Columns))
+	}
+	for k, c := range b.Columns {
+		row[k] = c.Value(i)
+	}
+	return row
+}
+
+// BatchReader is implemented by readers that decode whole column vectors, without an []any per row.
+// ReadBatches is called after Init() instead of Read(), size is the preferred number of rows of a batch
+// (readers of files stored in batches, e.g. Arrow, keep the batches of the file).
+type BatchReader interface {
+	ReadBatches(size int) chan *ColumnBatch
+}
+
+// ReadBatches returns the rows of the reader as column batches, natively if it's a BatchReader,
+// otherwise collected from the rows of Read() in batches of size rows (BATCH_SIZE if 0). Values of int and float
+// fields that are not numbers (e.g. CSV cells that don't parse) are nulls, values of other fields are formatted with %v.
+func ReadBatches(fr FileReader, size int) chan *ColumnBatch {
+	if br, ok := fr.(BatchReader); ok {
+		return br.ReadBatches(size)
+	}
+	return rowBatches(fr, size)
+}
+
+// nativeBatches tells if the reader decodes column batches itself, not from the rows of Read()
+func nativeBatches(fr FileReader) bool {
+	if pr, ok := fr.(*ProjectedReader); ok {
+		return nativeBatches(pr.FileReader)
+	}
+	_, ok := fr.(BatchReader)
+	return ok
+}
+
+// rowBatches collects the rows of Read() into batches
+func rowBatches(fr FileReader, size int) chan *ColumnBatch {
+	if size <= 0 {
+		size = BATCH_SIZE
+	}
+	out := make(chan *ColumnBatch)
+	go func(in chan []any) {
+		types := fr.GetTypes()
+		b := newColumnBatch(types, size)
+		for row := range in {
+			b.add(row)
+			if b.Rows == size {
+				out <- b
+				b = newColumnBatch(types, size)
+			}
+		}
+		if b.Rows > 0 {
+			out <- b
+		}
+		close(out)
+	}(fr.Read())
+	return out
+}
+
+func newColumnBatch(types []DataType, size int) *ColumnBatch {
+	b := &ColumnBatch{Columns: make([]*Vector, len(types))}
+	for i, t := range types {
+		v := &Vector{Type: t}
+		switch t {
+		case DT_int:
+			v.Ints = make([]int64, 0, size)
+		case DT_float:
+			v.Floats = make([]float64, 0, size)
+		default:
+			v.Strings = make([]string, 0, size)
+		}
+		b.Columns[i] = v
+	}
+	return b
+}
+
+// add appends the row to the vectors
+func (b *ColumnBatch) add(row []any) {
+	for i, v := range b.Columns {
+		value := row[i]
+		ok := value != nil
+		switch v.Type {
+		case DT_int:
+			var x int64
+			x, ok = intValue(value)
+			v.Ints = append(v.Ints, x)
+		case DT_float:
+			var x float64
+			x, ok = floatValue(value)
+			v.Floats = append(v.Floats, x)
+		default:
+			s, isString := value.(string)
+			if ok && !isString {
+				s = fmt.Sprintf("%v", value)
+			}
+			v.Strings = append(v.Strings, s)
+		}
+		v.appendValid(b.Rows, ok)
+	}
+	b.Rows++
+}
+
+// appendNull appends a null (a zero value) as the i-th value
+func (v *Vector) appendNull(i int) {
+	switch v.Type {
+	case DT_int:
+		v.Ints = append(v.Ints, 0)
+	case DT_float:
+		v.Floats = append(v.Floats, 0)
+	default:
+		v.Strings = append(v.Strings, "")
+	}
+	v.appendValid(i, false)
+}
+
+// truncate drops the values of the vectors after the last row, of a row that failed to decode
+func (b *ColumnBatch) truncate() {
+	n := b.Rows
+	for _, v := range b.Columns {
+		switch v.Type {
+		case DT_int:
+			v.Ints = v.Ints[:n]
+		case DT_float:
+			v.Floats = v.Floats[:n]
+		default:
+			v.Strings = v.Strings[:n]
+		}
+		if v.Valid != nil {
+			v.Valid = v.Valid[:(n+7)/8]
+			if n%8 != 0 {
+				v.Valid[n/8] &= 1<<(n%8) - 1
+			}
+		}
+	}
+}
+
+// appendValid sets the validity of the i-th (the last appended) value, the bitmap is allocated at the first null
+func (v *Vector) appendValid(i int, ok bool) {
+	if !ok && v.Valid == nil {
+		// the first null, all previous values are valid
+		v.Valid = make([]byte, (i+7)/8)
+		for k := 0; k < i; k++ {
+			v.Valid[k/8] |= 1 << (k % 8)
+		}
+	}
+	if v.Valid != nil {
+		if i%8 == 0 {
+			v.Valid = append(v.Valid, 0)
+		}
+		if ok {
+			v.Valid[i/8] |= 1 << (i % 8)
+		}
+	}
+}
+
+// intValue converts integers (and floats without a fraction) to int64
+func intValue(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case float64, float32:
+		f, _ := floatValue(v)
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), true
+		}
+	}
+	return 0, false
+}
+
+// floatValue converts numbers to float64
+func floatValue(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	if i, ok := intValue(value); ok {
+		return float64(i), true
+	}
+	if v, ok := value.(uint64); ok {
+		return float64(v), true
+	}
+	return 0, false
+}
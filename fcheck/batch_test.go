@@ -0,0 +1,133 @@
+package fcheck
+
+import (
+	"bytes"
+	"fmt"
+	"gocf/fcheck/stats"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadBatches(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"id", "amount", "name"},
+		types:  []DataType{DT_int, DT_float, DT_string},
+	}
+	for i := 0; i < 21; i++ {
+		row := []any{int64(i), float64(i) / 2, fmt.Sprint("n", i)}
+		switch {
+		case i == 3:
+			row = []any{int32(3), "x", nil}
+		case i == 12:
+			row = []any{"", int64(6), true}
+		case i == 20:
+			row = []any{float64(20), float32(10), []byte("n20")}
+		}
+		sr.rows = append(sr.rows, row)
+	}
+	var batches []*ColumnBatch
+	for b := range ReadBatches(sr, 8) {
+		batches = append(batches, b)
+	}
+	if len(batches) != 3 || batches[0].Rows != 8 || batches[2].Rows != 5 {
+		t.Fatalf("unexpected batches: %d", len(batches))
+	}
+	if b := batches[0]; b.Columns[0].Valid != nil || b.Columns[1].Valid == nil || b.Columns[2].IsNull(2) || !b.Columns[2].IsNull(3) {
+		t.Errorf("unexpected validity: %+v %+v", b.Columns[0], b.Columns[1])
+	}
+	expected := [][]string{
+		{"0 0 n0", "1 0.5 n1", "2 1 n2", "3 <nil> <nil>", "4 2 n4", "5 2.5 n5", "6 3 n6", "7 3.5 n7"},
+		{"8 4 n8", "9 4.5 n9", "10 5 n10", "11 5.5 n11", "<nil> 6 true", "13 6.5 n13", "14 7 n14", "15 7.5 n15"},
+		{"16 8 n16", "17 8.5 n17", "18 9 n18", "19 9.5 n19", "20 10 [110 50 48]"},
+	}
+	for i, b := range batches {
+		var row []any
+		for k := 0; k < b.Rows; k++ {
+			row = b.Row(k, row)
+			if s := fmt.Sprintf("%v %v %v", row...); s != expected[i][k] {
+				t.Errorf("batch %d row %d: expected %s, got %s", i, k, expected[i][k], s)
+			}
+		}
+	}
+}
+
+func TestArrowBatches(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := ToArrow(testRows(), &buf, ArrowOptions{BatchRows: 3}); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "rows.arrow")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pr, err := NewProjectedReader(NewArrowReader(fileName), "amount,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nativeBatches(pr) || nativeBatches(testRows()) {
+		t.Error("expected native batches of the arrow reader only")
+	}
+	r := newTestReport(t, pr, ReportOptions{Collectors: CL_default | CL_nullmap})
+	if r.RowCount != 5 || len(r.Fields) != 2 {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	if s := r.Field("amount").Numeric; s == nil || s.Min != -1 || s.Max != 10.5 || r.Field("amount").Nulls != 1 {
+		t.Errorf("unexpected amount stats: %+v", s)
+	}
+	if f := r.Field("name"); f.Nulls != 2 || f.Count != 3 || len(f.NullMap) == 0 {
+		t.Errorf("unexpected name stats: %+v", f)
+	}
+	var rows []string
+	for b := range ReadBatches(pr, 0) {
+		for k := 0; k < b.Rows; k++ {
+			rows = append(rows, fmt.Sprint(b.Row(k, nil)))
+		}
+	}
+	if fmt.Sprint(rows) != "[[10.5 a] [-1 b] [3 <nil>] [<nil> c] [7 <nil>]]" {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+}
+
+// valueCollector collects the values one by one, it isn't a stats.BatchCollector
+type valueCollector struct {
+	values []any
+}
+
+func (c *valueCollector) Push(value any)                           { c.values = append(c.values, value) }
+func (c *valueCollector) Info() string                             { return "" }
+func (c *valueCollector) Count() int                               { return len(c.values) }
+func (c *valueCollector) Freq(n int, least bool) ([]string, []int) { return nil, nil }
+
+func TestVectorPush(t *testing.T) {
+	b := newColumnBatch([]DataType{DT_int, DT_float, DT_string}, 3)
+	b.add([]any{int64(1), nil, "a"})
+	b.add([]any{nil, 2.5, nil})
+	for i, expected := range []string{"[1 <nil>]", "[<nil> 2.5]", "[a <nil>]"} {
+		c := &valueCollector{}
+		b.Columns[i].push(c)
+		if s := fmt.Sprint(c.values); s != expected {
+			t.Errorf("column %d: expected %s, got %s", i, expected, s)
+		}
+	}
+	// strings pushed to numeric stats are invalid, not a panic
+	s := &stats.RunningStats{}
+	b.Columns[2].push(s)
+	if s.Invalid() != 1 || s.Nulls() != 1 {
+		t.Errorf("unexpected stats: %s", s.Info())
+	}
+}
+
+func TestBatchTruncate(t *testing.T) {
+	b := newColumnBatch([]DataType{DT_int, DT_string}, 16)
+	for i := 0; i < 9; i++ {
+		b.add([]any{int64(i), "a"})
+	}
+	// a row that failed to decode after its first value
+	b.Columns[0].appendNull(b.Rows)
+	b.truncate()
+	b.add([]any{int64(9), nil})
+	if b.Rows != 10 || b.Columns[0].Valid[1] != 3 || fmt.Sprint(b.Row(9, nil)) != "[9 <nil>]" {
+		t.Errorf("unexpected batch: %+v %v", b.Columns[0], b.Row(9, nil))
+	}
+}
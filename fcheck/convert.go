@@ -0,0 +1,199 @@
+package fcheck
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatValue returns the value as text, ok is false for nulls
+func formatValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case []byte:
+		return string(v), true
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+// ToCsv writes all rows (Init() is called here) as csv with a header. Values are quoted
+// only when needed, unless quoteStrings is set (then all string fields are quoted). Nulls are empty.
+func ToCsv(fr FileReader, w io.Writer, delimiter rune, quoteStrings bool) error {
+	fr.Init()
+	bw := bufio.NewWriter(w)
+	d := string(delimiter)
+	quote := func(s string, force bool) string {
+		if force || strings.ContainsAny(s, d+"\"\r\n") || strings.HasPrefix(s, " ") {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		}
+		return s
+	}
+	types := fr.GetTypes()
+	for i, f := range fr.GetFields() {
+		if i > 0 {
+			bw.WriteString(d)
+		}
+		bw.WriteString(quote(f, false))
+	}
+	bw.WriteString("\n")
+	rows := fr.Read()
+	for row := range rows {
+		for i, v := range row {
+			if i > 0 {
+				bw.WriteString(d)
+			}
+			if s, ok := formatValue(v); ok {
+				bw.WriteString(quote(s, quoteStrings && types[i] == DT_string))
+			}
+		}
+		if _, err := bw.WriteString("\n"); err != nil {
+			drain(rows)
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ToJson writes all rows (Init() is called here) as JSON lines, one object per row
+// with the fields in the file order. NaN and infinite floats are written as null.
+func ToJson(fr FileReader, w io.Writer) error {
+	fr.Init()
+	bw := bufio.NewWriter(w)
+	var keys [][]byte
+	for _, f := range fr.GetFields() {
+		k, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	rows := fr.Read()
+	for row := range rows {
+		bw.WriteByte('{')
+		for i, v := range row {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(keys[i])
+			bw.WriteByte(':')
+			if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				v = nil
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				drain(rows)
+				return err
+			}
+			bw.Write(b)
+		}
+		if _, err := bw.WriteString("}\n"); err != nil {
+			drain(rows)
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// drain reads the rest of the rows after a conversion error, so that the reader can finish and close the file
+func drain(rows chan []any) {
+	for range rows {
+	}
+}
+
+// ConvertResult counts rows written and rejected by a conversion
+type ConvertResult struct {
+	Rows     int
+	Rejected int
+}
+
+// rejectWriter writes rows that can't be converted as csv: the values and the error
+type rejectWriter struct {
+	w *csv.Writer // nil if rejects are dropped
+}
+
+func newRejectWriter(w io.Writer, fields []string) *rejectWriter {
+	if w == nil {
+		return &rejectWriter{}
+	}
+	rw := &rejectWriter{csv.NewWriter(w)}
+	rw.w.Write(append(append([]string(nil), fields...), "error"))
+	return rw
+}
+
+func (rw *rejectWriter) write(row []any, reason error) error {
+	if rw.w == nil {
+		return nil
+	}
+	rec := make([]string, 0, len(row)+1)
+	for _, v := range row {
+		s, _ := formatValue(v)
+		rec = append(rec, s)
+	}
+	return rw.w.Write(append(rec, reason.Error()))
+}
+
+func (rw *rejectWriter) flush() error {
+	if rw.w == nil {
+		return nil
+	}
+	rw.w.Flush()
+	return rw.w.Error()
+}
+
+func toInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	s, _ := formatValue(value)
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an integer", s)
+	}
+	return v, nil
+}
+
+func toFloat64(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	}
+	s, _ := formatValue(value)
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return v, nil
+}
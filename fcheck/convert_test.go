@@ -0,0 +1,95 @@
+package fcheck
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestToCsv(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"name", "n", "x"},
+		types:  []DataType{DT_string, DT_int, DT_float},
+		rows: [][]any{
+			{"plain", int64(1), 1.5},
+			{`a "quoted", value`, nil, math.NaN()},
+			{nil, int64(-2), 1e21},
+		},
+	}
+	var buf bytes.Buffer
+	if err := ToCsv(sr, &buf, ',', false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,n,x\nplain,1,1.5\n\"a \"\"quoted\"\", value\",,NaN\n,-2,1e+21\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+	buf.Reset()
+	if err := ToCsv(sr, &buf, ';', true); err != nil {
+		t.Fatal(err)
+	}
+	expected = "name;n;x\n\"plain\";1;1.5\n\"a \"\"quoted\"\", value\";;NaN\n;-2;1e+21\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestToJson(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"name", "n", "x"},
+		types:  []DataType{DT_string, DT_int, DT_float},
+		rows: [][]any{
+			{"plain \"q\"", int64(1), 1.5},
+			{nil, int64(2), math.Inf(1)},
+		},
+	}
+	var buf bytes.Buffer
+	if err := ToJson(sr, &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\"name\":\"plain \\\"q\\\"\",\"n\":1,\"x\":1.5}\n{\"name\":null,\"n\":2,\"x\":null}\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestConvertDrainsReader(t *testing.T) {
+	convert := map[string]func(fr FileReader) error{
+		"csv":  func(fr FileReader) error { return ToCsv(fr, failingWriter{}, ',', false) },
+		"json": func(fr FileReader) error { return ToJson(fr, failingWriter{}) },
+	}
+	for name, f := range convert {
+		fr := &finishedReader{numberedRows(10000), make(chan bool)}
+		if err := f(fr); err == nil {
+			t.Errorf("%s: expected a write error", name)
+		}
+		select {
+		case <-fr.done:
+		case <-time.After(time.Second):
+			t.Errorf("%s: the reader was not read to the end", name)
+		}
+	}
+}
+
+func TestToInt64(t *testing.T) {
+	for _, v := range []any{int64(5), 5, int32(5), 5.0, "5", " 5 "} {
+		if n, err := toInt64(v); err != nil || n != 5 {
+			t.Errorf("%#v: expected 5, got %d, %v", v, n, err)
+		}
+	}
+	if n, err := toInt64(float64(math.MinInt64)); err != nil || n != math.MinInt64 {
+		t.Errorf("-2^63: expected %d, got %d, %v", int64(math.MinInt64), n, err)
+	}
+	// 2^63 (float64(math.MaxInt64) is rounded up to it) overflows
+	for _, v := range []any{float64(math.MaxInt64), 1e19, 5.5, math.NaN(), "x"} {
+		if _, err := toInt64(v); err == nil {
+			t.Errorf("%#v: expected an error", v)
+		}
+	}
+}
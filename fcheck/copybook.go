@@ -0,0 +1,176 @@
+package fcheck
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A subset of COBOL copybooks describing fixed-width text (USAGE DISPLAY) records, e.g.
+//
+//	01 CUSTOMER.
+//	   05 CUST-ID     PIC 9(6).
+//	   05 NAME        PIC X(20).
+//	   05 BALANCE     PIC S9(7)V99.
+//	   05 FILLER      PIC X(4).
+//	   05 PHONE       PIC X(12) OCCURS 2 TIMES.
+//
+// Group items only group, FILLER is skipped, level 66 and 88 items are ignored, elementary OCCURS items are
+// repeated as NAME_1, NAME_2 ... Binary and packed usages (COMP, COMP-3), REDEFINES and group OCCURS are not supported.
+
+var (
+	copybookEnd    = regexp.MustCompile(`\.(\s|$)`)
+	copybookRepeat = regexp.MustCompile(`(.)\((\d+)\)`)
+)
+
+// LoadFixedLayout reads the layout of fixed-width records from a copybook file
+func LoadFixedLayout(fileName string) (FixedLayout, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return FixedLayout{}, err
+	}
+	defer f.Close()
+	layout, err := ParseCopybook(f)
+	if err != nil {
+		return layout, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return layout, nil
+}
+
+// ParseCopybook parses the copybook of a single record (one 01 level), see LoadFixedLayout
+func ParseCopybook(r io.Reader) (FixedLayout, error) {
+	var text strings.Builder
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) > 6 && strings.TrimSpace(line[:6]) != "" && strings.Trim(line[:6], "0123456789") == "" {
+			// sequence numbers in columns 1-6
+			line = " " + line[6:]
+		}
+		// comments: * or / in the indicator area (column 7) or as the first character
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "/") {
+			continue
+		}
+		text.WriteString(line)
+		text.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return FixedLayout{}, err
+	}
+	var layout FixedLayout
+	pos := 1
+	records := 0
+	for _, stmt := range copybookEnd.Split(text.String(), -1) {
+		tokens := strings.Fields(stmt)
+		if len(tokens) == 0 {
+			continue
+		}
+		if len(tokens) < 2 {
+			return layout, fmt.Errorf("invalid entry: %s", strings.TrimSpace(stmt))
+		}
+		level, err := strconv.Atoi(tokens[0])
+		if err != nil || level < 1 || level > 88 {
+			return layout, fmt.Errorf("invalid level number: %s", tokens[0])
+		}
+		if level == 66 || level == 88 {
+			continue
+		}
+		if level == 1 {
+			if records++; records > 1 {
+				return layout, fmt.Errorf("more than one record (01 level) is not supported")
+			}
+		}
+		name := tokens[1]
+		var pic string
+		occurs := 1
+		for i := 2; i < len(tokens); i++ {
+			switch strings.ToUpper(tokens[i]) {
+			case "PIC", "PICTURE":
+				if i+1 < len(tokens) && strings.ToUpper(tokens[i+1]) == "IS" {
+					i++
+				}
+				if i+1 >= len(tokens) {
+					return layout, fmt.Errorf("%s: missing picture", name)
+				}
+				pic = strings.ToUpper(tokens[i+1])
+				i++
+			case "OCCURS":
+				if i+1 >= len(tokens) {
+					return layout, fmt.Errorf("%s: missing OCCURS count", name)
+				}
+				if occurs, err = strconv.Atoi(tokens[i+1]); err != nil || occurs < 1 {
+					return layout, fmt.Errorf("%s: invalid OCCURS count: %s", name, tokens[i+1])
+				}
+				i++
+			case "TIMES", "USAGE", "IS", "DISPLAY":
+			case "REDEFINES", "SIGN", "COMP", "COMP-1", "COMP-2", "COMP-3", "COMP-4", "COMP-5", "COMPUTATIONAL",
+				"COMPUTATIONAL-3", "BINARY", "PACKED-DECIMAL":
+				return layout, fmt.Errorf("%s: %s is not supported", name, tokens[i])
+			}
+		}
+		if pic == "" {
+			if occurs > 1 {
+				return layout, fmt.Errorf("%s: OCCURS of a group is not supported", name)
+			}
+			continue
+		}
+		field, err := parsePicture(pic)
+		if err != nil {
+			return layout, fmt.Errorf("%s: %v", name, err)
+		}
+		for k := 1; k <= occurs; k++ {
+			if !strings.EqualFold(name, "FILLER") {
+				field.Name = name
+				if occurs > 1 {
+					field.Name = fmt.Sprintf("%s_%d", name, k)
+				}
+				field.Start = pos
+				layout.Fields = append(layout.Fields, field)
+			}
+			pos += field.Length
+		}
+	}
+	layout.RecordLength = pos - 1
+	return layout, layout.Validate()
+}
+
+// parsePicture returns the length and type of a PIC clause: X and A are strings, 9 with optional S (overpunch)
+// and V (implied decimal point) are numbers, edited pictures (Z, ., -, ...) are strings
+func parsePicture(pic string) (FixedField, error) {
+	var f FixedField
+	expanded := copybookRepeat.ReplaceAllStringFunc(pic, func(s string) string {
+		m := copybookRepeat.FindStringSubmatch(s)
+		n, _ := strconv.Atoi(m[2])
+		return strings.Repeat(m[1], n)
+	})
+	if strings.ContainsAny(expanded, "()") {
+		return f, fmt.Errorf("invalid picture: %s", pic)
+	}
+	numeric := strings.Trim(expanded, "S9V") == "" && strings.Contains(expanded, "9") &&
+		strings.Count(expanded, "V") <= 1 && strings.LastIndex(expanded, "S") <= 0
+	if numeric {
+		f.Type = DT_int
+		f.Overpunch = strings.HasPrefix(expanded, "S")
+		if i := strings.Index(expanded, "V"); i >= 0 {
+			f.Decimals = len(expanded) - i - 1
+			if f.Decimals > 0 {
+				f.Type = DT_float
+			}
+		}
+		f.Length = strings.Count(expanded, "9")
+	} else {
+		if strings.ContainsAny(expanded, "SVP") {
+			return f, fmt.Errorf("unsupported picture: %s", pic)
+		}
+		f.Type = DT_string
+		f.Length = len(expanded)
+	}
+	if f.Length == 0 {
+		return f, fmt.Errorf("invalid picture: %s", pic)
+	}
+	return f, nil
+}
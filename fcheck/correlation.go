@@ -0,0 +1,525 @@
+package fcheck
+
+import (
+	"fmt"
+	"gocf/fcheck/stats"
+	"hash/maphash"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// FD_SAMPLE_ROWS rows checked for all pairs of fields, only the dependencies and duplicate fields
+// that hold in these rows are checked in the rest of the file
+const FD_SAMPLE_ROWS = 1000
+
+// FD_MAX_VALUES total number of value ids kept for the candidate dependencies (4 bytes each),
+// candidates that need more are dropped
+const FD_MAX_VALUES = 1 << 24
+
+// CorrelationOptions enables the cross-field report in NewReport
+type CorrelationOptions struct {
+	Threshold     float64 // report correlations and associations with an absolute value >= Threshold (0.5 if 0)
+	MaxCategories int     // string fields with more distinct values are skipped in Cramér's V (50 if 0)
+	SampleSize    int     // rows kept for Spearman correlations, a reservoir sample above that (100000 if 0)
+	MaxDistinct   int     // fields with more distinct values are not checked as dependency sources (10000 if 0)
+	MaxPairs      int     // candidate dependencies and duplicate fields checked after FD_SAMPLE_ROWS rows (10000 if 0)
+	Seed          int64   // seed of the Spearman sample
+}
+
+// CorrelationReport holds relationships between fields.
+// Pairs with an undefined coefficient (e.g. a constant field) are skipped.
+type CorrelationReport struct {
+	Threshold    float64       `json:"threshold"`
+	SampledRows  int           `json:"sampled_rows"` // rows used for Spearman correlations
+	Numeric      []Correlation `json:"numeric"`      // sorted by the strongest coefficient
+	Categorical  []Association `json:"categorical"`  // sorted by Cramér's V
+	Dependencies []Dependency  `json:"dependencies"` // From determines To (but not the other way round)
+	Redundant    []Redundancy  `json:"redundant"`
+	SkippedPairs int           `json:"skipped_pairs,omitempty"` // candidates not checked because of MaxPairs or FD_MAX_VALUES
+}
+
+type Correlation struct {
+	A        string  `json:"a"`
+	B        string  `json:"b"`
+	Count    int     `json:"count"` // rows where both are not null
+	Pearson  float64 `json:"pearson"`
+	Spearman float64 `json:"spearman"`
+}
+
+type Association struct {
+	A        string  `json:"a"`
+	B        string  `json:"b"`
+	Count    int     `json:"count"`
+	CramersV float64 `json:"cramers_v"`
+}
+
+// Dependency is a candidate functional dependency: rows with the same (not null) From value
+// have the same To value. Trivial ones (From is unique or To is constant) are not reported.
+type Dependency struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Distinct int    `json:"distinct"` // distinct From values
+}
+
+// Enum Redundancy reasons, from the strongest
+const (
+	RD_duplicate = "duplicate"  // same values in every row
+	RD_linear    = "linear"     // perfectly correlated numeric fields
+	RD_oneToOne  = "one-to-one" // functional dependency both ways (e.g. a code and its name)
+)
+
+type Redundancy struct {
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Reason string `json:"reason"`
+}
+
+// fieldValues maps distinct values of a field to ids (0 is null)
+type fieldValues struct {
+	ids      map[string]int32
+	nonNull  int
+	overflow bool // more than MaxDistinct values, ids are not assigned anymore
+}
+
+// depPair is a candidate functional dependency a -> b
+type depPair struct {
+	a, b int
+	dep  []int32 // dep[id of a - 1] = id of b + 1 (0 - not seen yet)
+}
+
+type correlationCollector struct {
+	opts    CorrelationOptions
+	fields  []string
+	numeric []int // indexes of numeric fields
+	strs    []int // indexes of string fields
+	cov     [][]stats.Covariance
+	sample  [][]float64 // reservoir of numeric values (NaN for null)
+	rows    int
+	seen    int
+	rnd     *rand.Rand
+	cats    [][]*stats.Contingency // nil if any of the fields has too many categories
+	catOver []bool
+	values  []fieldValues
+	// all pairs are checked on the ids (and hashes of the values) of the first FD_SAMPLE_ROWS rows,
+	// then only the pairs that held
+	sampled   bool
+	sampleIds [][]int32
+	hashes    []maphash.Hash
+	pairs     []depPair
+	equal     [][2]int
+	depValues int // ids in the dep slices of pairs
+	skipped   int
+	scratch   []int32
+	keys      []string
+	ids       []int32
+	buf       []byte
+	x         []float64
+}
+
+func newCorrelationCollector(opts CorrelationOptions, fields []string, types []DataType) *correlationCollector {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 0.5
+	}
+	if opts.MaxCategories <= 0 {
+		opts.MaxCategories = 50
+	}
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = 100000
+	}
+	if opts.MaxDistinct <= 0 {
+		opts.MaxDistinct = 10000
+	}
+	if opts.MaxPairs <= 0 {
+		opts.MaxPairs = 10000
+	}
+	n := len(fields)
+	c := &correlationCollector{opts: opts, fields: fields, rnd: rand.New(rand.NewSource(opts.Seed))}
+	for i, t := range types {
+		if t == DT_int || t == DT_float {
+			c.numeric = append(c.numeric, i)
+		} else if t == DT_string {
+			c.strs = append(c.strs, i)
+		}
+	}
+	c.cov = make([][]stats.Covariance, len(c.numeric))
+	for i := range c.cov {
+		c.cov[i] = make([]stats.Covariance, len(c.numeric))
+	}
+	c.cats = make([][]*stats.Contingency, len(c.strs))
+	for i := range c.cats {
+		c.cats[i] = make([]*stats.Contingency, len(c.strs))
+		for j := i + 1; j < len(c.strs); j++ {
+			c.cats[i][j] = stats.NewContingency()
+		}
+	}
+	c.catOver = make([]bool, len(c.strs))
+	c.values = make([]fieldValues, n)
+	for a := 0; a < n; a++ {
+		c.values[a].ids = map[string]int32{}
+	}
+	seed := maphash.MakeSeed()
+	c.hashes = make([]maphash.Hash, n)
+	for a := range c.hashes {
+		c.hashes[a].SetSeed(seed)
+	}
+	c.keys = make([]string, n)
+	c.ids = make([]int32, n)
+	c.x = make([]float64, len(c.numeric))
+	return c
+}
+
+// id of the value, -1 if the field has too many distinct values
+func (c *correlationCollector) id(f int, key string, null bool) int32 {
+	if null {
+		return 0
+	}
+	v := &c.values[f]
+	v.nonNull++
+	if v.overflow {
+		return -1
+	}
+	id, ok := v.ids[key]
+	if !ok {
+		if len(v.ids) >= c.opts.MaxDistinct {
+			v.overflow = true
+			v.ids = nil
+			return -1
+		}
+		id = int32(len(v.ids) + 1)
+		v.ids[key] = id
+	}
+	return id
+}
+
+func (c *correlationCollector) Push(row []any) {
+	c.rows++
+	for f, v := range row {
+		c.buf = appendValue(c.buf[:0], v)
+		c.keys[f] = string(c.buf)
+		c.ids[f] = c.id(f, c.keys[f], v == nil)
+	}
+
+	// numeric
+	for i, f := range c.numeric {
+		x, ok := stats.ToFloat(row[f])
+		if !ok {
+			x = math.NaN()
+		}
+		c.x[i] = x
+	}
+	for i := range c.numeric {
+		if math.IsNaN(c.x[i]) {
+			continue
+		}
+		for j := i + 1; j < len(c.numeric); j++ {
+			if !math.IsNaN(c.x[j]) {
+				c.cov[i][j].Push(c.x[i], c.x[j])
+			}
+		}
+	}
+	if len(c.numeric) > 1 {
+		c.seen++
+		if len(c.sample) < c.opts.SampleSize {
+			c.sample = append(c.sample, append([]float64(nil), c.x...))
+		} else if k := c.rnd.Intn(c.seen); k < c.opts.SampleSize {
+			copy(c.sample[k], c.x)
+		}
+	}
+
+	// categorical
+	for i, f := range c.strs {
+		if !c.catOver[i] && (c.values[f].overflow || len(c.values[f].ids) > c.opts.MaxCategories) {
+			c.catOver[i] = true
+			for j := range c.cats {
+				c.cats[i][j], c.cats[j][i] = nil, nil
+			}
+		}
+	}
+	for i, f := range c.strs {
+		if c.catOver[i] || row[f] == nil {
+			continue
+		}
+		for j := i + 1; j < len(c.strs); j++ {
+			g := c.strs[j]
+			if c.catOver[j] || row[g] == nil {
+				continue
+			}
+			c.cats[i][j].Push(c.keys[f], c.keys[g])
+		}
+	}
+
+	// dependencies and duplicate fields
+	if !c.sampled {
+		c.sampleIds = append(c.sampleIds, append([]int32(nil), c.ids...))
+		for f := range row {
+			c.hashes[f].WriteString(c.keys[f])
+		}
+		if len(c.sampleIds) == FD_SAMPLE_ROWS {
+			c.selectPairs()
+		}
+		return
+	}
+	k := 0
+	for _, p := range c.equal {
+		if c.keys[p[0]] == c.keys[p[1]] {
+			c.equal[k] = p
+			k++
+		}
+	}
+	c.equal = c.equal[:k]
+	k = 0
+	for _, p := range c.pairs {
+		if c.pushPair(&p) {
+			c.pairs[k] = p
+			k++
+		} else {
+			c.depValues -= len(p.dep)
+		}
+	}
+	c.pairs = c.pairs[:k]
+}
+
+// pushPair checks the dependency in the current row, false if it doesn't hold (or is dropped)
+func (c *correlationCollector) pushPair(p *depPair) bool {
+	ida, idb := c.ids[p.a], c.ids[p.b]
+	if ida == 0 {
+		return true
+	}
+	if ida < 0 || idb < 0 {
+		// too many values: a is skipped or b has more values than a, so a -> b can't hold
+		// (may miss a dependency if b has many values in rows where a is null)
+		return false
+	}
+	for int(ida) > len(p.dep) {
+		if c.depValues >= FD_MAX_VALUES {
+			c.skipped++
+			return false
+		}
+		p.dep = append(p.dep, 0)
+		c.depValues++
+	}
+	if p.dep[ida-1] == 0 {
+		p.dep[ida-1] = idb + 1
+	} else if p.dep[ida-1] != idb+1 {
+		return false
+	}
+	return true
+}
+
+// selectPairs keeps the dependencies and duplicate fields that hold in the sample rows, at most MaxPairs.
+// Dependencies from fields with fewer distinct values are taken first.
+func (c *correlationCollector) selectPairs() {
+	c.sampled = true
+	n := len(c.fields)
+	var from []int
+	for a := 0; a < n; a++ {
+		if !c.values[a].overflow && c.values[a].nonNull > 0 {
+			from = append(from, a)
+		}
+	}
+	sort.SliceStable(from, func(i, j int) bool { return len(c.values[from[i]].ids) < len(c.values[from[j]].ids) })
+	for _, a := range from {
+		for b := 0; b < n; b++ {
+			if a == b || !c.sampleDependency(a, b) {
+				continue
+			}
+			if len(c.pairs) >= c.opts.MaxPairs || c.depValues+len(c.scratch) > FD_MAX_VALUES {
+				c.skipped++
+				continue
+			}
+			c.pairs = append(c.pairs, depPair{a, b, append([]int32(nil), c.scratch...)})
+			c.depValues += len(c.scratch)
+		}
+	}
+	// fields with the same values hash to the same sum
+	byHash := map[uint64][]int{}
+	for a := range c.hashes {
+		h := c.hashes[a].Sum64()
+		byHash[h] = append(byHash[h], a)
+	}
+	for a := range c.hashes {
+		for _, b := range byHash[c.hashes[a].Sum64()] {
+			if b <= a {
+				continue
+			}
+			if len(c.pairs)+len(c.equal) >= c.opts.MaxPairs {
+				c.skipped++
+				continue
+			}
+			c.equal = append(c.equal, [2]int{a, b})
+		}
+	}
+	c.sampleIds = nil
+	c.hashes = nil
+	c.scratch = nil
+}
+
+// sampleDependency tells if a -> b holds in the sample rows, the ids of b by the ids of a are left in scratch
+func (c *correlationCollector) sampleDependency(a, b int) bool {
+	n := len(c.values[a].ids)
+	if cap(c.scratch) < n {
+		c.scratch = make([]int32, n)
+	}
+	dep := c.scratch[:n]
+	for i := range dep {
+		dep[i] = 0
+	}
+	c.scratch = dep
+	for _, ids := range c.sampleIds {
+		ida, idb := ids[a], ids[b]
+		if ida == 0 {
+			continue
+		}
+		if ida < 0 || idb < 0 {
+			return false
+		}
+		if dep[ida-1] == 0 {
+			dep[ida-1] = idb + 1
+		} else if dep[ida-1] != idb+1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *correlationCollector) Report() *CorrelationReport {
+	if !c.sampled {
+		c.selectPairs()
+	}
+	r := &CorrelationReport{Threshold: c.opts.Threshold, SampledRows: len(c.sample), SkippedPairs: c.skipped}
+	redundant := map[[2]int]string{}
+
+	for i, f := range c.numeric {
+		for j := i + 1; j < len(c.numeric); j++ {
+			g := c.numeric[j]
+			cov := &c.cov[i][j]
+			p := cov.Pearson()
+			xs := make([]float64, len(c.sample))
+			ys := make([]float64, len(c.sample))
+			for k, s := range c.sample {
+				xs[k], ys[k] = s[i], s[j]
+			}
+			s := stats.Spearman(xs, ys)
+			if math.IsNaN(p) || math.IsNaN(s) {
+				continue
+			}
+			if math.Abs(p) > 1-1e-9 {
+				redundant[[2]int{f, g}] = RD_linear
+			}
+			if math.Abs(p) >= c.opts.Threshold || math.Abs(s) >= c.opts.Threshold {
+				r.Numeric = append(r.Numeric, Correlation{A: c.fields[f], B: c.fields[g], Count: cov.Count(), Pearson: p, Spearman: s})
+			}
+		}
+	}
+	strongest := func(c Correlation) float64 { return math.Max(math.Abs(c.Pearson), math.Abs(c.Spearman)) }
+	sort.SliceStable(r.Numeric, func(i, j int) bool { return strongest(r.Numeric[i]) > strongest(r.Numeric[j]) })
+
+	for i, f := range c.strs {
+		for j := i + 1; j < len(c.strs); j++ {
+			if c.catOver[i] || c.catOver[j] {
+				continue
+			}
+			t := c.cats[i][j]
+			if v := t.CramersV(); !math.IsNaN(v) && v >= c.opts.Threshold {
+				r.Categorical = append(r.Categorical, Association{A: c.fields[f], B: c.fields[c.strs[j]], Count: t.Count(), CramersV: v})
+			}
+		}
+	}
+	sort.SliceStable(r.Categorical, func(i, j int) bool { return r.Categorical[i].CramersV > r.Categorical[j].CramersV })
+
+	// distinct values including null
+	distinct := func(f int) int {
+		v := c.values[f]
+		if v.overflow {
+			return c.opts.MaxDistinct + 1
+		}
+		n := len(v.ids)
+		if v.nonNull < c.rows {
+			n++
+		}
+		return n
+	}
+	held := map[[2]int]bool{}
+	for _, p := range c.pairs {
+		held[[2]int{p.a, p.b}] = true
+	}
+	holds := func(a, b int) bool {
+		v := c.values[a]
+		return held[[2]int{a, b}] && !v.overflow && v.nonNull > 0 &&
+			len(v.ids) < v.nonNull && distinct(b) > 1 // not unique and not constant
+	}
+	pairs := append([]depPair(nil), c.pairs...)
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].a < pairs[j].a || pairs[i].a == pairs[j].a && pairs[i].b < pairs[j].b
+	})
+	for _, p := range pairs {
+		a, b := p.a, p.b
+		if !holds(a, b) {
+			continue
+		}
+		if holds(b, a) {
+			if a < b {
+				if _, ok := redundant[[2]int{a, b}]; !ok {
+					redundant[[2]int{a, b}] = RD_oneToOne
+				}
+			}
+			continue
+		}
+		r.Dependencies = append(r.Dependencies, Dependency{From: c.fields[a], To: c.fields[b], Distinct: len(c.values[a].ids)})
+	}
+	for _, p := range c.equal {
+		redundant[p] = RD_duplicate
+	}
+	n := len(c.fields)
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			if reason, ok := redundant[[2]int{a, b}]; ok {
+				r.Redundant = append(r.Redundant, Redundancy{A: c.fields[a], B: c.fields[b], Reason: reason})
+			}
+		}
+	}
+	return r
+}
+
+func (r *CorrelationReport) writeText(w io.Writer) {
+	title := "correlations"
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, strings.Repeat("=", len(title)))
+	if len(r.Numeric) > 0 {
+		fmt.Fprintf(w, "numeric (|r| >= %.2f, Spearman on %d rows)\n", r.Threshold, r.SampledRows)
+		fmt.Fprintf(w, "%-8s : %-8s : %-8s : %s\n", "pearson", "spearman", "count", "fields")
+		for _, c := range r.Numeric {
+			fmt.Fprintf(w, "%-8.4f : %-8.4f : %-8d : %s, %s\n", c.Pearson, c.Spearman, c.Count, c.A, c.B)
+		}
+	}
+	if len(r.Categorical) > 0 {
+		fmt.Fprintf(w, "categorical (Cramér's V >= %.2f)\n", r.Threshold)
+		fmt.Fprintf(w, "%-8s : %-8s : %s\n", "V", "count", "fields")
+		for _, a := range r.Categorical {
+			fmt.Fprintf(w, "%-8.4f : %-8d : %s, %s\n", a.CramersV, a.Count, a.A, a.B)
+		}
+	}
+	if len(r.Dependencies) > 0 {
+		fmt.Fprintln(w, "functional dependencies")
+		for _, d := range r.Dependencies {
+			fmt.Fprintf(w, "%s -> %s (%d distinct values)\n", d.From, d.To, d.Distinct)
+		}
+	}
+	if len(r.Redundant) > 0 {
+		fmt.Fprintln(w, "redundant fields")
+		for _, d := range r.Redundant {
+			fmt.Fprintf(w, "%s, %s (%s)\n", d.A, d.B, d.Reason)
+		}
+	}
+	if r.SkippedPairs > 0 {
+		fmt.Fprintf(w, "%d candidate dependencies or duplicate fields not checked (too many)\n", r.SkippedPairs)
+	}
+	if len(r.Numeric)+len(r.Categorical)+len(r.Dependencies)+len(r.Redundant) == 0 {
+		fmt.Fprintln(w, "--- NONE FOUND ---")
+	}
+	fmt.Fprintln(w)
+}
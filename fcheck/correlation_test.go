@@ -0,0 +1,129 @@
+package fcheck
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func correlationRows() *sliceReader {
+	sr := &sliceReader{
+		fields: []string{"zip", "city", "code", "country", "x", "y", "x_copy", "z"},
+		types:  []DataType{DT_string, DT_string, DT_string, DT_string, DT_int, DT_float, DT_int, DT_int},
+	}
+	zips := []string{"00-001", "00-002", "30-001", "30-002", "10115"}
+	cities := []string{"Warszawa", "Warszawa", "Kraków", "Kraków", "Berlin"}
+	codes := []string{"PL", "PL", "PL", "PL", "DE"}
+	countries := []string{"Poland", "Poland", "Poland", "Poland", "Germany"}
+	for i := 0; i < 100; i++ {
+		k := i % 5
+		x := int64(i)
+		sr.rows = append(sr.rows, []any{zips[k], cities[k], codes[k], countries[k], x, 3*float64(x) + 1, x, int64(i * i % 7)})
+	}
+	return sr
+}
+
+func TestCorrelations(t *testing.T) {
+	r := newTestReport(t, correlationRows(), ReportOptions{Correlations: &CorrelationOptions{}}).Correlations
+	if r == nil {
+		t.Fatal("no correlation report")
+	}
+	if len(r.Numeric) != 3 || r.Numeric[0].Pearson < 0.9999 || r.Numeric[0].Spearman < 0.9999 || r.Numeric[0].Count != 100 {
+		t.Errorf("unexpected numeric correlations: %+v", r.Numeric)
+	}
+	for _, c := range r.Numeric {
+		if c.A == "z" || c.B == "z" {
+			t.Errorf("z is not correlated: %+v", c)
+		}
+	}
+	if r.SampledRows != 100 {
+		t.Errorf("expected 100 sampled rows, got %d", r.SampledRows)
+	}
+	if len(r.Categorical) == 0 || r.Categorical[0].CramersV < 0.9999 {
+		t.Errorf("unexpected categorical associations: %+v", r.Categorical)
+	}
+
+	deps := map[string]bool{}
+	for _, d := range r.Dependencies {
+		deps[d.From+" -> "+d.To] = true
+	}
+	for _, d := range []string{"zip -> city", "zip -> code", "city -> country", "zip -> country"} {
+		if !deps[d] {
+			t.Errorf("missing dependency %s in %v", d, r.Dependencies)
+		}
+	}
+	for _, d := range []string{"city -> zip", "code -> city", "x -> y", "z -> zip"} {
+		if deps[d] {
+			t.Errorf("unexpected dependency %s", d)
+		}
+	}
+
+	redundant := map[string]string{}
+	for _, d := range r.Redundant {
+		redundant[d.A+", "+d.B] = d.Reason
+	}
+	expected := map[string]string{"code, country": RD_oneToOne, "x, y": RD_linear, "x, x_copy": RD_duplicate, "y, x_copy": RD_linear}
+	if len(redundant) != len(expected) {
+		t.Errorf("unexpected redundant fields: %v", redundant)
+	}
+	for k, v := range expected {
+		if redundant[k] != v {
+			t.Errorf("%s: expected %s, got %s", k, v, redundant[k])
+		}
+	}
+}
+
+func TestCorrelationLimits(t *testing.T) {
+	// too many categories / distinct values, nothing reported except duplicates
+	opts := &CorrelationOptions{MaxCategories: 2, MaxDistinct: 2}
+	rep := newTestReport(t, correlationRows(), ReportOptions{Correlations: opts})
+	r := rep.Correlations
+	if len(r.Dependencies) != 0 {
+		t.Errorf("unexpected dependencies: %v", r.Dependencies)
+	}
+	for _, a := range r.Categorical {
+		if a.A != "code" || a.B != "country" {
+			t.Errorf("unexpected association: %+v", a)
+		}
+	}
+	var buf bytes.Buffer
+	rep.WriteText(&buf)
+	if !strings.Contains(buf.String(), "x, x_copy (duplicate)") {
+		t.Errorf("redundant fields not in the report:\n%s", buf.String())
+	}
+}
+
+func TestCorrelationCandidates(t *testing.T) {
+	// code -> name and a == b hold in the first FD_SAMPLE_ROWS rows only
+	sr := &sliceReader{
+		fields: []string{"code", "name", "group", "a", "b"},
+		types:  []DataType{DT_string, DT_string, DT_string, DT_int, DT_int},
+	}
+	for i := 0; i < 2*FD_SAMPLE_ROWS; i++ {
+		code := fmt.Sprintf("c%d", i%10)
+		name, b := "n"+code, int64(i)
+		if i == FD_SAMPLE_ROWS+500 {
+			name, b = "other", -1
+		}
+		sr.rows = append(sr.rows, []any{code, name, fmt.Sprintf("g%d", i%10/5), int64(i), b})
+	}
+	r := newTestReport(t, sr, ReportOptions{Correlations: &CorrelationOptions{}}).Correlations
+	deps := map[string]bool{}
+	for _, d := range r.Dependencies {
+		deps[d.From+" -> "+d.To] = true
+	}
+	if !deps["code -> group"] || deps["code -> name"] || len(r.Redundant) != 0 || r.SkippedPairs != 0 {
+		t.Errorf("unexpected dependencies %v or redundant fields %v", r.Dependencies, r.Redundant)
+	}
+
+	rep := newTestReport(t, correlationRows(), ReportOptions{Correlations: &CorrelationOptions{MaxPairs: 3}})
+	if r := rep.Correlations; len(r.Dependencies) > 3 || r.SkippedPairs == 0 {
+		t.Errorf("expected at most 3 pairs checked: %+v", r)
+	}
+	var buf bytes.Buffer
+	rep.WriteText(&buf)
+	if !strings.Contains(buf.String(), "not checked (too many)") {
+		t.Errorf("skipped pairs not in the report:\n%s", buf.String())
+	}
+}
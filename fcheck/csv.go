@@ -24,108 +24,245 @@ type CsvReader struct {
 	hasHeader bool
 	fields []string
 	types []DataType
-	tmpRow []any
+	selected []int // positions of the fields in the record, set by Project()
+	nColumns int   // number of columns in the file
+	lenient bool
+	repair bool
+	maxErrors int
+	rejects io.Writer
+	errors CsvErrors
+	header CsvHeader
+	skipLines int
+	start int64 // offset of the first record (or the header) after skipLines
+	names []string
+	typeOverrides map[string]DataType
+	badNumbers int // values of int and float fields that can't be converted (read as nulls)
+	readStop
 }
 func NewCsvReader(fileName string, delimiter rune) CsvReader {
 	return CsvReader{fileName:fileName, delimiter:delimiter, hasHeader:true}
 }
+
+// CsvOptions are CSV specific options for NewFileReader (ReaderOptions{"csv": CsvOptions{...}})
+type CsvOptions struct {
+	Delimiter rune // ',' if not set
+	// Lenient skips malformed records (wrong number of fields, bare or missing quotes) instead of failing,
+	// they are counted by the kind of error, see CsvReader.Errors
+	Lenient bool
+	// Repair pads records with missing fields with empty values and drops extra fields instead of skipping them (lenient mode)
+	Repair bool
+	// MaxErrors is the max number of malformed records in the lenient mode, reading fails when it's exceeded (0 - no limit)
+	MaxErrors int
+	// Rejects gets the raw lines of the skipped records (lenient mode), dropped if nil
+	Rejects io.Writer
+	// Header forces or disables the header line, by default it's detected (see sniffCsvSample)
+	Header CsvHeader
+	// SkipLines is the number of lines skipped before the header or the first record (banners, comments)
+	SkipLines int
+	// Names of the columns, they replace the header or the default c_0, c_1 ... names
+	Names []string
+	// Types override the detected types of the columns by name (after Names are applied),
+	// e.g. DT_string keeps the leading zeros of codes like "012"
+	Types map[string]DataType
+}
+
+// CsvHeader tells if the first line (after CsvOptions.SkipLines) has the column names
+type CsvHeader uint
+
+const (
+	CH_auto CsvHeader = iota // detect
+	CH_yes
+	CH_no
+)
+
+// ParseCsvHeader parses auto, yes or no
+func ParseCsvHeader(s string) (CsvHeader, error) {
+	switch s {
+	case "", "auto":
+		return CH_auto, nil
+	case "yes", "true":
+		return CH_yes, nil
+	case "no", "false":
+		return CH_no, nil
+	}
+	return CH_auto, fmt.Errorf("invalid csv header option: %s (expected auto, yes or no)", s)
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "csv",
+		Type:       FT_csv,
+		Extensions: []string{".csv"},
+		// if delimiter is specified assume CSV
+		Sniff: func(fileName string, head []byte, opts any) bool {
+			o, ok := opts.(CsvOptions)
+			return ok && o.Delimiter != 0
+		},
+		New: func(fileName string, opts any) (FileReader, error) {
+			o, ok := opts.(CsvOptions)
+			if !ok && opts != nil {
+				return nil, fmt.Errorf("invalid csv options: %T", opts)
+			}
+			if o.Delimiter == 0 {
+				o.Delimiter = ','
+			}
+			c := NewCsvReader(fileName, o.Delimiter)
+			c.lenient = o.Lenient || o.Repair
+			c.repair = o.Repair
+			c.maxErrors = o.MaxErrors
+			c.rejects = o.Rejects
+			c.header = o.Header
+			c.skipLines = o.SkipLines
+			c.names = o.Names
+			c.typeOverrides = o.Types
+			return &c, nil
+		},
+	})
+}
 func (cr *CsvReader) FileName() string {
 	return cr.fileName
 }
 
 func (cr *CsvReader) toList(values []string) []any {
+	row := make([]any, len(cr.fields))
+	if cr.selected != nil {
+		// convert only the selected columns
+		for i,j := range cr.selected {
+			row[i] = cr.convert(i, values[j])
+		}
+		return row
+	}
 	for i,sv := range values {
-		switch cr.types[i] {
-		case DT_float:
-			if v,err := strconv.ParseFloat(sv, 64); err == nil {
-				cr.tmpRow[i] = v
-				continue
-			}
-		case DT_int:
-			if v,err := strconv.ParseInt(sv, 10, 64); err == nil {
-				cr.tmpRow[i] = v
-				continue
-			}
+		row[i] = cr.convert(i, sv)
+	}
+	return row
+}
+
+// converts the value of the i-th field to its type, empty numbers and numbers that can't be converted
+// (e.g. with a type override) are nulls
+func (cr *CsvReader) convert(i int, sv string) any {
+	switch cr.types[i] {
+	case DT_float:
+		if v,err := strconv.ParseFloat(sv, 64); err == nil {
+			return v
 		}
-		cr.tmpRow[i] = sv
+	case DT_int:
+		if v,err := strconv.ParseInt(sv, 10, 64); err == nil {
+			return v
+		}
+	default:
+		return sv
+	}
+	if sv != "" {
+		cr.badNumbers++
 	}
-	return cr.tmpRow
+	return nil
 }
 
-func sniffCsvSample(sample [][]string) (hasHeader bool, fields []string, types []DataType){
-	hasHeader = true
-	nRows := len(sample)
-	nFields := len(sample[0])
-	var sTypes[][]DataType = make([][]DataType, nRows)
-	for r:=0; r< nRows; r++ {
-		row := sample[r]
-		if r == 0 { // check header
-			for _,s := range row {
-				if len(s)==0 { // definetely not a header
-					hasHeader = false  // hasHeader is initialized to true by default
-					break
-				}
-			}
+// cellType returns the type of a value, DT_unknown if it's empty
+func cellType(s string) DataType {
+	if len(s) == 0 {
+		return DT_unknown
+	}
+	if FloatPattern.MatchString(s) {
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return DT_float
 		}
-		// sniff types
-		sTypes[r] = make([]DataType, nFields)
-		for c,s := range row {
-			thisType := DT_string
-			if len(s) > 0 {
-				if FloatPattern.MatchString(s) {
-					if _,err := strconv.ParseFloat(s, 64); err == nil {
-							thisType = DT_float
-					}
-				} else if IntPattern.MatchString(s) {
-						if _,err := strconv.ParseInt(s, 10, 64); err == nil {
-							thisType = DT_int
-						}
-				}
-				sTypes[r][c] = thisType
-			}
+	} else if IntPattern.MatchString(s) {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return DT_int
 		}
 	}
-	types = make([]DataType, nFields)
-	// for each column, check if types are consistent
-	for c:=0; c<nFields; c++ {
-		headerType := sTypes[0][c]
-		valType := sTypes[1][c]
-		for r:=2; r<nRows; r++ {
-			thisType := sTypes[r][c] 
-			if thisType != DT_unknown {
-				if sTypes[r][c] != valType {
-					// mixed types
-					if((valType == DT_float || valType == DT_int) &&  (thisType == DT_float || thisType == DT_int)) {
-						// for mixed ints and floats stay with the floats
-						valType = DT_float
-					} else {
-						// otherwise set to string and leave
-						valType = DT_string
-						break
-					}
-				} else if valType == DT_unknown { 
-					// in case row no.1 was empty
-					valType = thisType
-				}
+	return DT_string
+}
+
+// sniffCsvTypes returns the types of the columns: mixed ints and floats are floats, other mixes and empty columns are strings
+func sniffCsvTypes(rows [][]string, nFields int) []DataType {
+	types := make([]DataType, nFields)
+	for c := range types {
+		valType := DT_unknown
+		for _, row := range rows {
+			if c >= len(row) {
+				continue
+			}
+			thisType := cellType(row[c])
+			switch {
+			case thisType == DT_unknown || thisType == valType:
+			case valType == DT_unknown:
+				// in case the previous rows were empty
+				valType = thisType
+			case (valType == DT_float || valType == DT_int) && (thisType == DT_float || thisType == DT_int):
+				// for mixed ints and floats stay with the floats
+				valType = DT_float
+			default:
+				// otherwise set to string and leave
+				valType = DT_string
+			}
+			if valType == DT_string {
+				break
 			}
 		}
-		if valType == DT_unknown { valType = DT_string }
-		if valType != DT_string && valType == headerType {
-			hasHeader = false
+		if valType == DT_unknown {
+			valType = DT_string
 		}
 		types[c] = valType
 	}
+	return types
+}
+
+// csvColumnNames returns the default names of the columns: c_0, c_1 ...
+func csvColumnNames(n int) []string {
+	fields := make([]string, n)
+	for i := range fields {
+		fields[i] = fmt.Sprintf("c_%d", i)
+	}
+	return fields
+}
+
+// sniffCsvSample detects the header: the first row is not a header if it has an empty value
+// or a value of the type of its (non-string) column
+func sniffCsvSample(sample [][]string) (hasHeader bool, fields []string, types []DataType) {
+	nFields := len(sample[0])
+	types = sniffCsvTypes(sample[1:], nFields)
+	hasHeader = true
+	for c, s := range sample[0] {
+		if len(s) == 0 || types[c] != DT_string && cellType(s) == types[c] {
+			hasHeader = false
+			break
+		}
+	}
 	if hasHeader {
 		fields = sample[0]
 	} else {
-		fields = make([]string, nFields)
-		for i:=0; i<nFields; i++ {
-			fields[i] = fmt.Sprintf("c_%d", i)
-		}
+		fields = csvColumnNames(nFields)
+		types = sniffCsvTypes(sample, nFields)
 	}
 	return
 }
 
+// skipLines returns the offset after the first n lines of the file
+func skipLines(f *os.File, n int) int64 {
+	if n <= 0 {
+		return 0
+	}
+	buf := make([]byte, 4096)
+	offset := int64(0)
+	for {
+		k, err := f.ReadAt(buf, offset)
+		for i, c := range buf[:k] {
+			if c == '\n' {
+				if n--; n == 0 {
+					return offset + int64(i) + 1
+				}
+			}
+		}
+		offset += int64(k)
+		if err != nil {
+			return offset
+		}
+	}
+}
+
 func (cr *CsvReader) Init() {
 	// read first few lines of the csv to get the fields and types
 	f, err := os.Open(cr.fileName)
@@ -133,21 +270,77 @@ func (cr *CsvReader) Init() {
 		log.Fatal(err)
 	}
 	defer f.Close()
-	csvReader := csv.NewReader(f)
+	fi, err := f.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cr.start = skipLines(f, cr.skipLines)
+	csvReader := csv.NewReader(io.NewSectionReader(f, cr.start, fi.Size()-cr.start))
 	csvReader.Comma = cr.delimiter
 
 	// sniff sample, take N_SAMPLE_ROWS first lines
 	var sample [][]string
-	for i:=0; i< N_SAMPLE_ROWS; i++ {
+	for len(sample) < N_SAMPLE_ROWS {
 		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*csv.ParseError); ok && cr.lenient {
+			// malformed records are counted when all rows are read
+			continue
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
 		sample = append(sample, row)
 	}
+	if len(sample) == 0 {
+		log.Fatalf("%s: no records found", cr.fileName)
+	}
 	cr.hasHeader, cr.fields, cr.types = sniffCsvSample(sample)
-	// init reusable row
-	cr.tmpRow = make([]any, len(cr.fields))
+	nFields := len(cr.fields)
+	if cr.header == CH_yes && !cr.hasHeader {
+		cr.hasHeader, cr.fields, cr.types = true, sample[0], sniffCsvTypes(sample[1:], nFields)
+	} else if cr.header == CH_no && cr.hasHeader {
+		cr.hasHeader, cr.fields, cr.types = false, csvColumnNames(nFields), sniffCsvTypes(sample, nFields)
+	}
+	if cr.names != nil {
+		if len(cr.names) != nFields {
+			log.Fatalf("%s: %d column names given, the file has %d columns", cr.fileName, len(cr.names), nFields)
+		}
+		cr.fields = cr.names
+	}
+	for name, t := range cr.typeOverrides {
+		i := indexof(cr.fields, name)
+		if i < 0 {
+			log.Fatalf("%s: unknown column in type overrides: %s", cr.fileName, name)
+		}
+		cr.types[i] = t
+	}
+	cr.nColumns = len(cr.fields)
+}
+
+// Project implements Projector, unselected columns are not converted
+func (cr *CsvReader) Project(fields []string) error {
+	var selected []int
+	var types []DataType
+	for _, name := range fields {
+		i := indexof(cr.fields, name)
+		if i < 0 {
+			return fmt.Errorf("unknown field: %s", name)
+		}
+		if cr.selected != nil {
+			// projected already
+			selected = append(selected, cr.selected[i])
+		} else {
+			selected = append(selected, i)
+		}
+		types = append(types, cr.types[i])
+	}
+	cr.selected = selected
+	cr.fields = fields
+	cr.types = types
+	return nil
 }
 
 func (cr *CsvReader) GetFields() []string {
@@ -157,41 +350,220 @@ func (cr *CsvReader) GetTypes() []DataType {
 	return cr.types
 }
 func (cr *CsvReader) GetFileInfo() string {
-	return fmt.Sprintf("CSV, %d columns, delimited with '%c'", len(cr.fields), cr.delimiter)
+	nColumns := len(cr.fields)
+	if cr.selected != nil {
+		nColumns = cr.nColumns
+	}
+	info := fmt.Sprintf("CSV, %d columns, delimited with '%c'", nColumns, cr.delimiter)
+	if cr.lenient {
+		info += ", " + cr.errors.String()
+	}
+	if cr.badNumbers > 0 {
+		info += fmt.Sprintf(", %d invalid numbers", cr.badNumbers)
+	}
+	return info
 }
-func (cr *CsvReader) Read() chan []any {
-	out := make(chan []any)
-	go func(inFile string) { // equivalent to python's generator
-		f, err := os.Open(inFile)
-		if err != nil {
+
+// Errors returns the malformed records found in the lenient mode (after reading all rows)
+func (cr *CsvReader) Errors() CsvErrors {
+	return cr.errors
+}
+
+// malformed counts the malformed record (lenient mode), writes its raw lines from the file to the rejects
+// and fails if there are more than maxErrors
+func (cr *CsvReader) malformed(f *os.File, err error, line int, start, end int64, repaired bool) {
+	cr.errors.add(csvErrorKind(err), line)
+	if repaired {
+		cr.errors.Repaired++
+	} else if cr.rejects != nil {
+		raw := make([]byte, end-start)
+		if _, err := f.ReadAt(raw, start); err != nil && err != io.EOF {
+			log.Fatal(err)
+		}
+		if len(raw) > 0 && raw[len(raw)-1] != '\n' {
+			raw = append(raw, '\n')
+		}
+		if _, err := cr.rejects.Write(raw); err != nil {
 			log.Fatal(err)
 		}
-		defer f.Close()
-		
-		// TODO:
-		//fields, types, delimiter := inferCsvFormat(f)
+	}
+	if cr.maxErrors > 0 && cr.errors.Total() > cr.maxErrors {
+		log.Fatalf("%s: more than %d malformed records, the last one on line %d: %v", cr.fileName, cr.maxErrors, line, err)
+	}
+}
 
-		csvReader := csv.NewReader(f)
-		csvReader.Comma = cr.delimiter
-		csvReader.ReuseRecord = true
-		if cr.hasHeader {
-			// skip header
-			_, err := csvReader.Read()
-			if err == io.EOF {
-				log.Fatal(err)
+// lineEnd returns the offset after the end of the line at the offset, empty lines (skipped by csv.Reader) are skipped first
+func lineEnd(f *os.File, offset int64) int64 {
+	buf := make([]byte, 4096)
+	empty := true
+	for {
+		n, err := f.ReadAt(buf, offset)
+		for i, c := range buf[:n] {
+			if c == '\n' && !empty {
+				return offset + int64(i) + 1
+			}
+			if c != '\n' && c != '\r' {
+				empty = false
 			}
 		}
-		for {
-			rec, err := csvReader.Read()
-			if err == io.EOF {
-				break
+		offset += int64(n)
+		if err != nil {
+			return offset
+		}
+	}
+}
+
+// repaired pads the record with empty values or drops extra values
+func (cr *CsvReader) repaired(rec []string) []string {
+	for len(rec) < cr.nColumns {
+		rec = append(rec, "")
+	}
+	return rec[:cr.nColumns]
+}
+func (cr *CsvReader) Read() chan []any {
+	out := make(chan []any)
+	done := cr.begin()
+	go func() { // equivalent to python's generator
+		cr.readRecords(func(rec []string) bool {
+			select {
+			case out <- cr.toList(rec):
+				return true
+			case <-done:
+				return false
 			}
-			if err != nil {
-				log.Fatal(err)
+		})
+		close(out)
+	}()
+	return out
+}
+
+// ReadBatches implements BatchReader, the records are parsed straight into the vectors of the batches
+func (cr *CsvReader) ReadBatches(size int) chan *ColumnBatch {
+	if size <= 0 {
+		size = BATCH_SIZE
+	}
+	out := make(chan *ColumnBatch)
+	done := cr.begin()
+	go func() {
+		b := newColumnBatch(cr.types, size)
+		cr.readRecords(func(rec []string) bool {
+			cr.addRecord(b, rec)
+			if b.Rows == size {
+				select {
+				case out <- b:
+				case <-done:
+					return false
+				}
+				b = newColumnBatch(cr.types, size)
 			}
-			out <- cr.toList(rec)
+			return true
+		})
+		if b.Rows > 0 {
+			out <- b
 		}
 		close(out)
-	} (cr.fileName)
+	}()
 	return out
+}
+
+// addRecord appends the values of the record to the vectors of the batch, converted like in convert
+func (cr *CsvReader) addRecord(b *ColumnBatch, rec []string) {
+	for i, v := range b.Columns {
+		j := i
+		if cr.selected != nil {
+			j = cr.selected[i]
+		}
+		var sv string
+		if j < len(rec) {
+			sv = rec[j]
+		}
+		ok := true
+		switch v.Type {
+		case DT_int:
+			x, err := strconv.ParseInt(sv, 10, 64)
+			ok = err == nil
+			v.Ints = append(v.Ints, x)
+		case DT_float:
+			x, err := strconv.ParseFloat(sv, 64)
+			ok = err == nil
+			v.Floats = append(v.Floats, x)
+		default:
+			v.Strings = append(v.Strings, sv)
+		}
+		if !ok && sv != "" {
+			cr.badNumbers++
+		}
+		v.appendValid(b.Rows, ok)
+	}
+	b.Rows++
+}
+
+// readRecords reads the records of the file (skipping or repairing malformed ones in the lenient mode) and
+// passes them to emit, the record slice is reused after emit returns. Reading stops if emit returns false.
+// The counts of malformed records and invalid numbers are of the last read.
+func (cr *CsvReader) readRecords(emit func(rec []string) bool) {
+	cr.errors = CsvErrors{}
+	cr.badNumbers = 0
+	f, err := os.Open(cr.fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	
+	// TODO:
+	//fields, types, delimiter := inferCsvFormat(f)
+
+	fi, err := f.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var csvReader *csv.Reader
+	base, lineBase := int64(0), cr.skipLines // offset and line number where csvReader starts
+	newReader := func(offset int64) {
+		csvReader = csv.NewReader(io.NewSectionReader(f, offset, fi.Size()-offset))
+		csvReader.Comma = cr.delimiter
+		// the record slice is reused, its strings are not (converted values are owned by the rows)
+		csvReader.ReuseRecord = true
+		base = offset
+	}
+	newReader(cr.start)
+	if cr.hasHeader {
+		// skip header
+		_, err := csvReader.Read()
+		if err == io.EOF {
+			log.Fatal(err)
+		}
+	}
+	for {
+		if cr.lenient {
+			csvReader.FieldsPerRecord = cr.nColumns
+		}
+		start := base + csvReader.InputOffset()
+		rec, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if pe, ok := err.(*csv.ParseError); ok && cr.lenient {
+			line := lineBase + pe.StartLine
+			if pe.Err == csv.ErrQuote && pe.Line > pe.StartLine {
+				// most likely a stray quote that swallowed the following lines: skip only the first line
+				end := lineEnd(f, start)
+				cr.malformed(f, pe.Err, line, start, end, false)
+				lineBase = line
+				newReader(end)
+				continue
+			}
+			repair := cr.repair && pe.Err == csv.ErrFieldCount
+			cr.malformed(f, pe.Err, line, start, base+csvReader.InputOffset(), repair)
+			if !repair {
+				continue
+			}
+			rec = cr.repaired(rec)
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		if !emit(rec) {
+			break
+		}
+	}
 }
\ No newline at end of file
@@ -2,13 +2,17 @@ package fcheck
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 const (
 	CSV_SIMPLE_ROWS = 1000
 )
 func TestCsvReader(t *testing.T) {
-	cr := NewCsvReader("..\\test\\data\\simple.csv", ',')
+	cr := NewCsvReader("../test/data/simple.csv", ',')
 	cr.Init()
 	fmt.Println("fields:", cr.GetFields())
 	fmt.Println("types:", cr.GetTypes())
@@ -85,3 +89,105 @@ func TestCsvSnifferNeg(t *testing.T) {
 	fmt.Println("fields   :", fields)
 	fmt.Println("types    :", types)
 }
+
+func TestCsvOptions(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "zip.csv")
+	data := "# export\n# 3 rows\n01234,Warsaw,1.5\n00950,Krakow,2\n12345,Gdansk,3\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		opts   CsvOptions
+		fields []string
+		types  []DataType
+		rows   int
+	}{
+		{CsvOptions{SkipLines: 2}, []string{"c_0", "c_1", "c_2"}, []DataType{DT_int, DT_string, DT_float}, 3},
+		{CsvOptions{SkipLines: 2, Header: CH_yes}, []string{"01234", "Warsaw", "1.5"}, []DataType{DT_int, DT_string, DT_int}, 2},
+		{CsvOptions{SkipLines: 2, Names: []string{"zip", "city", "amount"}, Types: map[string]DataType{"zip": DT_string}},
+			[]string{"zip", "city", "amount"}, []DataType{DT_string, DT_string, DT_float}, 3},
+	} {
+		fr, err := NewFileReader(fileName, ReaderOptions{"csv": tt.opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.Init()
+		if !reflect.DeepEqual(fr.GetFields(), tt.fields) || !reflect.DeepEqual(fr.GetTypes(), tt.types) {
+			t.Errorf("%+v: unexpected fields %v %v", tt.opts, fr.GetFields(), fr.GetTypes())
+		}
+		n := 0
+		for range fr.Read() {
+			n++
+		}
+		if n != tt.rows {
+			t.Errorf("%+v: expected %d rows, got %d", tt.opts, tt.rows, n)
+		}
+	}
+	// a small file (less than N_SAMPLE_ROWS), all rows are used for the types without a header
+	if err := os.WriteFile(fileName, []byte("a,b\n1,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fr, _ := NewFileReader(fileName, ReaderOptions{"csv": CsvOptions{Header: CH_no}})
+	fr.Init()
+	if !reflect.DeepEqual(fr.GetTypes(), []DataType{DT_string, DT_string}) || fr.GetFields()[0] != "c_0" {
+		t.Errorf("unexpected fields %v %v", fr.GetFields(), fr.GetTypes())
+	}
+}
+
+func TestCsvInvalidNumbers(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "zip.csv")
+	if err := os.WriteFile(fileName, []byte("id,zip,amount\n1,012,1.5\n2,abc,\n3,034,x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFileReader(fileName, ReaderOptions{"csv": CsvOptions{Types: map[string]DataType{"zip": DT_int, "amount": DT_float}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReport(t, fr, ReportOptions{})
+	if f := r.Field("zip"); f.Count != 2 || f.Nulls != 1 || f.Numeric == nil || f.Numeric.Max != 34 {
+		t.Errorf("unexpected zip stats: %+v", f)
+	}
+	if f := r.Field("amount"); f.Count != 1 || f.Nulls != 2 {
+		t.Errorf("unexpected amount stats: %+v", f)
+	}
+	if info := fr.GetFileInfo(); !strings.HasSuffix(info, ", 2 invalid numbers") {
+		t.Errorf("unexpected info: %s", info)
+	}
+}
+
+func TestCsvBatches(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "batches.csv")
+	data := "id,name,amount,code\n1,a,1.5,x\n2,b,,y\n3,c\n4,d,x,z\n5,,2,w\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFileReader(fileName, ReaderOptions{"csv": CsvOptions{Repair: true, Types: map[string]DataType{"amount": DT_float}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.Init()
+	if err := fr.(Projector).Project([]string{"amount", "id", "name"}); err != nil {
+		t.Fatal(err)
+	}
+	var rows, batchRows []string
+	for row := range fr.Read() {
+		rows = append(rows, fmt.Sprint(row))
+	}
+	batches := 0
+	for b := range ReadBatches(fr, 2) {
+		batches++
+		for k := 0; k < b.Rows; k++ {
+			batchRows = append(batchRows, fmt.Sprint(b.Row(k, nil)))
+		}
+	}
+	if fmt.Sprint(batchRows) != fmt.Sprint(rows) || batches != 3 {
+		t.Errorf("%d batches, rows differ:\n%v\n%v", batches, batchRows, rows)
+	}
+	if fmt.Sprint(rows) != "[[1.5 1 a] [<nil> 2 b] [<nil> 3 c] [<nil> 4 d] [2 5 ]]" {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+	// the counts are of the last read, not of both
+	if info := fr.GetFileInfo(); !strings.HasSuffix(info, "1 malformed records (wrong number of fields: 1 at line 4), 1 repaired, 1 invalid numbers") {
+		t.Errorf("unexpected info: %s", info)
+	}
+}
@@ -0,0 +1,107 @@
+package fcheck
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CSV_ERROR_LINES max number of line numbers kept for each kind of CSV error
+const CSV_ERROR_LINES = 10
+
+// CsvErrorKind is the kind of a malformed CSV record
+type CsvErrorKind uint
+
+const (
+	CE_field_count CsvErrorKind = iota // wrong number of fields
+	CE_bare_quote                      // " in an unquoted field
+	CE_quote                           // extraneous or missing " in a quoted field
+	CE_other
+)
+
+var csvErrorKinds = []CsvErrorKind{CE_field_count, CE_bare_quote, CE_quote, CE_other}
+
+func (k CsvErrorKind) String() string {
+	switch k {
+	case CE_field_count:
+		return "wrong number of fields"
+	case CE_bare_quote:
+		return "bare quote"
+	case CE_quote:
+		return "extraneous or missing quote"
+	}
+	return "other"
+}
+
+func csvErrorKind(err error) CsvErrorKind {
+	switch {
+	case errors.Is(err, csv.ErrFieldCount):
+		return CE_field_count
+	case errors.Is(err, csv.ErrBareQuote):
+		return CE_bare_quote
+	case errors.Is(err, csv.ErrQuote):
+		return CE_quote
+	}
+	return CE_other
+}
+
+// CsvErrors counts malformed CSV records (lenient mode) by kind, with the first CSV_ERROR_LINES line numbers
+type CsvErrors struct {
+	Counts   map[CsvErrorKind]int
+	Lines    map[CsvErrorKind][]int
+	Repaired int // records with a wrong number of fields that were padded or truncated (included in Counts)
+}
+
+func (e *CsvErrors) add(kind CsvErrorKind, line int) {
+	if e.Counts == nil {
+		e.Counts = map[CsvErrorKind]int{}
+		e.Lines = map[CsvErrorKind][]int{}
+	}
+	e.Counts[kind]++
+	if len(e.Lines[kind]) < CSV_ERROR_LINES {
+		e.Lines[kind] = append(e.Lines[kind], line)
+	}
+}
+
+// Total returns the number of malformed records
+func (e *CsvErrors) Total() int {
+	n := 0
+	for _, c := range e.Counts {
+		n += c
+	}
+	return n
+}
+
+// String returns e.g. "3 malformed records (wrong number of fields: 2 at lines 5, 9; bare quote: 1 at line 12), 2 repaired"
+func (e *CsvErrors) String() string {
+	var kinds []string
+	for _, kind := range csvErrorKinds {
+		n := e.Counts[kind]
+		if n == 0 {
+			continue
+		}
+		lines := make([]string, len(e.Lines[kind]))
+		for i, l := range e.Lines[kind] {
+			lines[i] = strconv.Itoa(l)
+		}
+		at := "at line "
+		if len(lines) > 1 {
+			at = "at lines "
+		}
+		s := fmt.Sprintf("%s: %d %s%s", kind, n, at, strings.Join(lines, ", "))
+		if n > len(lines) {
+			s += ", ..."
+		}
+		kinds = append(kinds, s)
+	}
+	s := fmt.Sprintf("%d malformed records", e.Total())
+	if len(kinds) > 0 {
+		s += " (" + strings.Join(kinds, "; ") + ")"
+	}
+	if e.Repaired > 0 {
+		s += fmt.Sprintf(", %d repaired", e.Repaired)
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package fcheck
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeBadCsv(t *testing.T) string {
+	lines := []string{"id,name,amount"}
+	for i := 1; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("%d,name %d,%d.5", i, i, i))
+	}
+	lines[5] = `5,bad "quote,1.0`
+	lines[8] = "8,too,many,fields"
+	lines[12] = "12,short"
+	lines[20] = `20,"unterminated,2.0`
+	fileName := filepath.Join(t.TempDir(), "bad.csv")
+	if err := os.WriteFile(fileName, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestCsvLenient(t *testing.T) {
+	fileName := writeBadCsv(t)
+	for _, tt := range []struct {
+		opts     CsvOptions
+		rows     int
+		repaired int
+		rejects  string
+	}{
+		{CsvOptions{Lenient: true}, 25, 0, "5,bad \"quote,1.0\n8,too,many,fields\n12,short\n20,\"unterminated,2.0\n"},
+		{CsvOptions{Repair: true}, 27, 2, "5,bad \"quote,1.0\n20,\"unterminated,2.0\n"},
+	} {
+		var rejects bytes.Buffer
+		tt.opts.Rejects = &rejects
+		fr, err := NewFileReader(fileName, ReaderOptions{"csv": tt.opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.Init()
+		n := 0
+		for range fr.Read() {
+			n++
+		}
+		errs := fr.(*CsvReader).Errors()
+		if n != tt.rows || errs.Total() != 4 || errs.Repaired != tt.repaired || errs.Counts[CE_field_count] != 2 ||
+			errs.Lines[CE_quote][0] != 21 || errs.Lines[CE_bare_quote][0] != 6 {
+			t.Errorf("%+v: expected %d rows, got %d, %+v", tt.opts, tt.rows, n, errs)
+		}
+		if rejects.String() != tt.rejects {
+			t.Errorf("%+v: unexpected rejects:\n%s", tt.opts, rejects.String())
+		}
+		if info := fr.GetFileInfo(); !strings.Contains(info, "wrong number of fields: 2 at lines 9, 13") {
+			t.Errorf("unexpected info: %s", info)
+		}
+	}
+}
+
+func TestCsvErrorsString(t *testing.T) {
+	var e CsvErrors
+	if s := e.String(); s != "0 malformed records" {
+		t.Errorf("unexpected string: %s", s)
+	}
+	for i := 1; i <= CSV_ERROR_LINES+1; i++ {
+		e.add(CE_bare_quote, i)
+	}
+	e.add(CE_other, 100)
+	expected := "12 malformed records (bare quote: 11 at lines 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, ...; other: 1 at line 100)"
+	if s := e.String(); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
@@ -0,0 +1,404 @@
+package fcheck
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"gocf/fcheck/stats"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DuplicateOptions enables duplicate detection in NewReport
+type DuplicateOptions struct {
+	Keys        []string // key fields, duplicate keys are reported if not empty
+	Approximate bool     // use Bloom filters instead of exact counting
+	MaxExamples int      // number of example duplicate keys, 5 if 0
+	// exact mode: number of distinct values kept in memory, above that they are spilled to disk (1000000 if 0)
+	MemoryLimit int
+	TempDir     string // directory for spill files, os.TempDir() if empty
+	// approximate mode: expected number of rows (10000000 if 0) and false positive rate (0.001 if 0)
+	ExpectedRows      int
+	FalsePositiveRate float64
+}
+
+// DuplicateReport holds results of the duplicate detection.
+// In the approximate mode duplicate counts may be overestimated (never underestimated)
+// and DuplicatedKeys/DuplicatedRows are not available.
+type DuplicateReport struct {
+	Approximate    bool           `json:"approximate"`
+	Keys           []string       `json:"keys,omitempty"`
+	NullKeys       int            `json:"null_keys"`       // rows with a null in any of the key fields, not checked
+	KeyDuplicates  int            `json:"key_duplicates"`  // rows with a key that occurred in an earlier row
+	DuplicatedKeys int            `json:"duplicated_keys"` // distinct keys occurring more than once
+	Examples       []DuplicateKey `json:"examples,omitempty"`
+	RowDuplicates  int            `json:"row_duplicates"`  // rows identical to an earlier row
+	DuplicatedRows int            `json:"duplicated_rows"` // distinct rows occurring more than once
+}
+
+type DuplicateKey struct {
+	Key   []string `json:"key"`
+	Count int      `json:"count"`
+}
+
+// appendValue serializes a value so that different values never give the same bytes:
+// type tag, length and the value as string
+func appendValue(buf []byte, value any) []byte {
+	var s string
+	var tag byte
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0)
+	case string:
+		tag, s = 's', v
+	case int64:
+		tag, s = 'i', strconv.FormatInt(v, 10)
+	case float64:
+		tag, s = 'f', strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		tag, s = 'b', string(v)
+	default:
+		tag, s = 'v', fmt.Sprintf("%v", v)
+	}
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(l[:], uint64(len(s)))
+	buf = append(buf, tag)
+	buf = append(buf, l[:n]...)
+	return append(buf, s...)
+}
+
+// decodeValues is the reverse of appendValue, values are returned as strings ("null" for nil)
+func decodeValues(buf []byte) []string {
+	var values []string
+	for len(buf) > 0 {
+		tag := buf[0]
+		buf = buf[1:]
+		if tag == 0 {
+			values = append(values, Nullstr)
+			continue
+		}
+		l, n := binary.Uvarint(buf)
+		values = append(values, string(buf[n:n+int(l)]))
+		buf = buf[n+int(l):]
+	}
+	return values
+}
+
+// counter counts occurrences of byte strings
+type counter interface {
+	add(item []byte) error
+	result(maxExamples int) (duplicates, duplicated int, examples []DuplicateKey, err error)
+}
+
+// top K items by count
+type itemHeap []DuplicateKey
+
+func (h itemHeap) Len() int           { return len(h) }
+func (h itemHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h itemHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *itemHeap) Push(x any)        { *h = append(*h, x.(DuplicateKey)) }
+func (h *itemHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type topItems struct {
+	k int
+	h itemHeap
+}
+
+func (t *topItems) offer(item string, count int) {
+	if t.k <= 0 {
+		return
+	}
+	if len(t.h) < t.k {
+		heap.Push(&t.h, DuplicateKey{Key: []string{item}, Count: count})
+	} else if t.h[0].Count < count {
+		t.h[0] = DuplicateKey{Key: []string{item}, Count: count}
+		heap.Fix(&t.h, 0)
+	}
+}
+
+// items sorted by count, descending, Key contains the decoded values
+func (t *topItems) sorted() []DuplicateKey {
+	res := make([]DuplicateKey, len(t.h))
+	for i := len(res) - 1; i >= 0; i-- {
+		dk := heap.Pop(&t.h).(DuplicateKey)
+		dk.Key = decodeValues([]byte(dk.Key[0]))
+		res[i] = dk
+	}
+	return res
+}
+
+const SPILL_BUCKETS = 64
+
+// exact counter, keeps counts in memory until the limit of distinct items is reached,
+// then spills them to bucket files (by hash) and counts each bucket separately at the end
+type spillCounter struct {
+	limit   int
+	dir     string
+	counts  map[string]int
+	files   []*os.File
+	writers []*bufio.Writer
+}
+
+func newSpillCounter(limit int, dir string) *spillCounter {
+	return &spillCounter{limit: limit, dir: dir, counts: map[string]int{}}
+}
+
+func (c *spillCounter) add(item []byte) error {
+	if c.files == nil {
+		c.counts[string(item)]++
+		if len(c.counts) > c.limit {
+			return c.spill()
+		}
+		return nil
+	}
+	return c.write(item, 1)
+}
+
+func (c *spillCounter) spill() error {
+	for i := 0; i < SPILL_BUCKETS; i++ {
+		f, err := os.CreateTemp(c.dir, "gcf-dups-*")
+		if err != nil {
+			c.cleanup()
+			return err
+		}
+		c.files = append(c.files, f)
+		c.writers = append(c.writers, bufio.NewWriter(f))
+	}
+	for item, count := range c.counts {
+		if err := c.write([]byte(item), count); err != nil {
+			return err
+		}
+	}
+	c.counts = nil
+	return nil
+}
+
+// bucket record: uvarint count, uvarint length, item
+func (c *spillCounter) write(item []byte, count int) error {
+	h := fnv.New32a()
+	h.Write(item)
+	w := c.writers[h.Sum32()%SPILL_BUCKETS]
+	var hdr [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(count))
+	n += binary.PutUvarint(hdr[n:], uint64(len(item)))
+	if _, err := w.Write(hdr[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(item)
+	return err
+}
+
+func (c *spillCounter) cleanup() {
+	for _, f := range c.files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	c.files, c.writers = nil, nil
+}
+
+func (c *spillCounter) result(maxExamples int) (duplicates, duplicated int, examples []DuplicateKey, err error) {
+	top := &topItems{k: maxExamples}
+	count := func(counts map[string]int) {
+		for item, n := range counts {
+			if n > 1 {
+				duplicates += n - 1
+				duplicated++
+				top.offer(item, n)
+			}
+		}
+	}
+	if c.files == nil {
+		count(c.counts)
+		return duplicates, duplicated, top.sorted(), nil
+	}
+	defer c.cleanup()
+	for i, f := range c.files {
+		if err = c.writers[i].Flush(); err != nil {
+			return
+		}
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		counts := map[string]int{}
+		r := bufio.NewReader(f)
+		for {
+			n, e := binary.ReadUvarint(r)
+			if e == io.EOF {
+				break
+			}
+			l, e2 := binary.ReadUvarint(r)
+			if e != nil || e2 != nil {
+				return 0, 0, nil, fmt.Errorf("corrupted spill file %s", f.Name())
+			}
+			item := make([]byte, l)
+			if _, err = io.ReadFull(r, item); err != nil {
+				return
+			}
+			counts[string(item)] += int(n)
+		}
+		count(counts)
+	}
+	return duplicates, duplicated, top.sorted(), nil
+}
+
+// approximate counter: an item is a duplicate if the Bloom filter has (probably) seen it,
+// counts of example items are kept only for the first candidates
+type bloomCounter struct {
+	bloom      *stats.BloomFilter
+	duplicates int
+	candidates map[string]int
+	maxCand    int
+}
+
+func newBloomCounter(n int, p float64, maxExamples int) *bloomCounter {
+	return &bloomCounter{bloom: stats.NewBloomFilter(n, p), candidates: map[string]int{}, maxCand: 100 * maxExamples}
+}
+
+func (c *bloomCounter) add(item []byte) error {
+	if c.bloom.Add(item) {
+		c.duplicates++
+		if _, ok := c.candidates[string(item)]; ok || len(c.candidates) < c.maxCand {
+			c.candidates[string(item)]++
+		}
+	}
+	return nil
+}
+
+func (c *bloomCounter) result(maxExamples int) (duplicates, duplicated int, examples []DuplicateKey, err error) {
+	top := &topItems{k: maxExamples}
+	for item, n := range c.candidates {
+		top.offer(item, n+1)
+	}
+	return c.duplicates, -1, top.sorted(), nil
+}
+
+// duplicateDetector collects duplicate keys and rows from the row stream
+type duplicateDetector struct {
+	opts   DuplicateOptions
+	keyIdx []int
+	keys   counter
+	rows   counter
+	nulls  int
+	buf    []byte
+	err    error
+}
+
+func newDuplicateDetector(opts DuplicateOptions, fields []string) (*duplicateDetector, error) {
+	if opts.MaxExamples == 0 {
+		opts.MaxExamples = 5
+	}
+	if opts.MemoryLimit <= 0 {
+		opts.MemoryLimit = 1000000
+	}
+	if opts.ExpectedRows <= 0 {
+		opts.ExpectedRows = 10000000
+	}
+	if opts.FalsePositiveRate <= 0 {
+		opts.FalsePositiveRate = 0.001
+	}
+	d := &duplicateDetector{opts: opts}
+	for _, k := range opts.Keys {
+		i := indexof(fields, k)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown key field: %s", k)
+		}
+		d.keyIdx = append(d.keyIdx, i)
+	}
+	newCounter := func(maxExamples int) counter {
+		if opts.Approximate {
+			return newBloomCounter(opts.ExpectedRows, opts.FalsePositiveRate, maxExamples)
+		}
+		return newSpillCounter(opts.MemoryLimit, opts.TempDir)
+	}
+	if len(d.keyIdx) > 0 {
+		d.keys = newCounter(opts.MaxExamples)
+	}
+	d.rows = newCounter(0)
+	return d, nil
+}
+
+func (d *duplicateDetector) Push(row []any) {
+	if d.err != nil {
+		return
+	}
+	if d.keys != nil {
+		d.buf = d.buf[:0]
+		null := false
+		for _, i := range d.keyIdx {
+			if row[i] == nil {
+				null = true
+				break
+			}
+			d.buf = appendValue(d.buf, row[i])
+		}
+		if null {
+			d.nulls++
+		} else if err := d.keys.add(d.buf); err != nil {
+			d.err = err
+			return
+		}
+	}
+	d.buf = d.buf[:0]
+	for _, v := range row {
+		d.buf = appendValue(d.buf, v)
+	}
+	d.err = d.rows.add(d.buf)
+}
+
+func (d *duplicateDetector) Report() (*DuplicateReport, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	r := &DuplicateReport{Approximate: d.opts.Approximate, Keys: d.opts.Keys, NullKeys: d.nulls}
+	var err error
+	if d.keys != nil {
+		if r.KeyDuplicates, r.DuplicatedKeys, r.Examples, err = d.keys.result(d.opts.MaxExamples); err != nil {
+			return nil, err
+		}
+	}
+	if r.RowDuplicates, r.DuplicatedRows, _, err = d.rows.result(0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (d *DuplicateReport) writeText(w io.Writer) {
+	title := "duplicates"
+	if d.Approximate {
+		title += " (approximate)"
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, strings.Repeat("=", len(title)))
+	if len(d.Keys) > 0 {
+		fmt.Fprintf(w, "key (%s): %d duplicate rows", strings.Join(d.Keys, ", "), d.KeyDuplicates)
+		if !d.Approximate {
+			fmt.Fprintf(w, ", %d duplicated keys", d.DuplicatedKeys)
+		}
+		if d.NullKeys > 0 {
+			fmt.Fprintf(w, ", %d rows with null keys", d.NullKeys)
+		}
+		fmt.Fprintln(w)
+		if len(d.Examples) > 0 {
+			fmt.Fprintf(w, "%-8s : %s\n", "count", "key")
+			for _, e := range d.Examples {
+				fmt.Fprintf(w, "%-8d : %s\n", e.Count, strings.Join(e.Key, ", "))
+			}
+		}
+	}
+	fmt.Fprintf(w, "full rows: %d duplicate rows", d.RowDuplicates)
+	if !d.Approximate {
+		fmt.Fprintf(w, ", %d duplicated rows", d.DuplicatedRows)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,92 @@
+package fcheck
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func dupRows() *sliceReader {
+	return &sliceReader{
+		fields: []string{"id", "day", "amount"},
+		types:  []DataType{DT_int, DT_string, DT_float},
+		rows: [][]any{
+			{int64(1), "mon", 1.0},
+			{int64(2), "mon", 2.0},
+			{int64(1), "mon", 1.0}, // duplicate row
+			{int64(1), "tue", 1.0},
+			{int64(3), "tue", 3.0},
+			{int64(1), "mon", 4.0},
+			{nil, "tue", 5.0},
+			{int64(3), "tue", 3.0}, // duplicate row
+			{int64(3), "1", 3.0},
+			{int64(3), "tue", 3.0}, // duplicate row
+		},
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	for _, limit := range []int{0, 2} {
+		dir := t.TempDir()
+		r := newTestReport(t, dupRows(), ReportOptions{Duplicates: &DuplicateOptions{Keys: []string{"id", "day"}, MemoryLimit: limit, TempDir: dir}})
+		d := r.Duplicates
+		if d == nil {
+			t.Fatal("no duplicates report")
+		}
+		// keys: (1,mon) x3, (3,tue) x3
+		if d.KeyDuplicates != 4 || d.DuplicatedKeys != 2 || d.NullKeys != 1 {
+			t.Errorf("limit %d: unexpected key duplicates: %+v", limit, d)
+		}
+		if len(d.Examples) != 2 || d.Examples[0].Count != 3 || len(d.Examples[0].Key) != 2 {
+			t.Errorf("limit %d: unexpected examples: %+v", limit, d.Examples)
+		}
+		if d.RowDuplicates != 3 || d.DuplicatedRows != 2 {
+			t.Errorf("limit %d: unexpected row duplicates: %+v", limit, d)
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("limit %d: spill files not removed: %d", limit, len(files))
+		}
+	}
+}
+
+func TestDuplicatesExamples(t *testing.T) {
+	r := newTestReport(t, dupRows(), ReportOptions{Duplicates: &DuplicateOptions{Keys: []string{"id"}, MaxExamples: 1}})
+	d := r.Duplicates
+	// 1 x4, 3 x4, 2 x1
+	if d.KeyDuplicates != 6 || d.DuplicatedKeys != 2 || len(d.Examples) != 1 || d.Examples[0].Count != 4 {
+		t.Errorf("unexpected key duplicates: %+v", d)
+	}
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	if !strings.Contains(buf.String(), "key (id): 6 duplicate rows, 2 duplicated keys, 1 rows with null keys") {
+		t.Errorf("duplicates not in the report:\n%s", buf.String())
+	}
+}
+
+func TestDuplicatesApproximate(t *testing.T) {
+	r := newTestReport(t, dupRows(), ReportOptions{Duplicates: &DuplicateOptions{Keys: []string{"id", "day"}, Approximate: true, ExpectedRows: 1000}})
+	d := r.Duplicates
+	if !d.Approximate || d.KeyDuplicates < 4 || d.RowDuplicates < 3 {
+		t.Errorf("unexpected duplicates: %+v", d)
+	}
+	if len(d.Examples) == 0 || d.Examples[0].Count < 3 {
+		t.Errorf("unexpected examples: %+v", d.Examples)
+	}
+}
+
+func TestDuplicateKeysNoFalseMatches(t *testing.T) {
+	// values are length prefixed, so ("ab", "c") and ("a", "bc") are different keys
+	sr := &sliceReader{
+		fields: []string{"a", "b"},
+		types:  []DataType{DT_string, DT_string},
+		rows:   [][]any{{"ab", "c"}, {"a", "bc"}, {"1", "x"}, {int64(1), "x"}},
+	}
+	r := newTestReport(t, sr, ReportOptions{Duplicates: &DuplicateOptions{}})
+	if r.Duplicates.RowDuplicates != 0 {
+		t.Errorf("unexpected duplicates: %+v", r.Duplicates)
+	}
+	if _, err := NewReport(sr, ReportOptions{Duplicates: &DuplicateOptions{Keys: []string{"nope"}}}); err == nil {
+		t.Error("unknown key field not reported")
+	}
+}
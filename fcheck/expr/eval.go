@@ -0,0 +1,317 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+type node interface {
+	eval(row []any) any
+	children() []node
+}
+
+func walk(n node, fn func(node)) {
+	fn(n)
+	for _, c := range n.children() {
+		walk(c, fn)
+	}
+}
+
+type literalNode struct {
+	v any
+}
+
+func (n *literalNode) eval(row []any) any { return n.v }
+func (n *literalNode) children() []node   { return nil }
+
+type fieldNode struct {
+	name    string
+	index   int
+	numeric bool
+}
+
+func (n *fieldNode) eval(row []any) any {
+	v := normalize(row[n.index])
+	if n.numeric {
+		if s, ok := v.(string); ok {
+			return parseNumber(s)
+		}
+	}
+	return v
+}
+func (n *fieldNode) children() []node { return nil }
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(row []any) any { return !truthy(n.operand.eval(row)) }
+func (n *notNode) children() []node   { return []node{n.operand} }
+
+type negNode struct {
+	operand node
+}
+
+func (n *negNode) eval(row []any) any {
+	switch v := toNumber(n.operand.eval(row)).(type) {
+	case int64:
+		return -v
+	case float64:
+		return -v
+	}
+	return nil
+}
+func (n *negNode) children() []node { return []node{n.operand} }
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(row []any) any {
+	l := truthy(n.left.eval(row))
+	if n.op == "&&" {
+		return l && truthy(n.right.eval(row))
+	}
+	return l || truthy(n.right.eval(row))
+}
+func (n *logicalNode) children() []node { return []node{n.left, n.right} }
+
+type matchNode struct {
+	negate bool
+	left   node
+	re     *regexp.Regexp
+}
+
+func (n *matchNode) eval(row []any) any {
+	v := n.left.eval(row)
+	if v == nil {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+	return n.re.MatchString(s) != n.negate
+}
+func (n *matchNode) children() []node { return []node{n.left} }
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) children() []node { return []node{n.left, n.right} }
+
+func (n *binaryNode) eval(row []any) any {
+	l := n.left.eval(row)
+	r := n.right.eval(row)
+	switch n.op {
+	case "==", "!=":
+		return equal(l, r) == (n.op == "==")
+	case "<", "<=", ">", ">=":
+		c, ok := compare(l, r)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	}
+	return arithmetic(n.op, l, r)
+}
+
+// normalize converts row values to one of: nil, bool, int64, float64, string
+func normalize(value any) any {
+	switch v := value.(type) {
+	case nil, bool, int64, float64, string:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func parseNumber(s string) any {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return nil
+}
+
+// toNumber returns int64 or float64, strings are parsed, anything else gives nil
+func toNumber(v any) any {
+	switch x := v.(type) {
+	case int64, float64:
+		return x
+	case string:
+		return parseNumber(x)
+	}
+	return nil
+}
+
+func toFloat(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case int64:
+		return x != 0
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return false
+}
+
+func equal(l, r any) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if c, ok := compare(l, r); ok {
+		return c == 0
+	}
+	return l == r
+}
+
+// compare returns -1, 0, 1 and true if the values are comparable.
+// Numbers are compared as numbers, if only one side is a number the other one is parsed.
+func compare(l, r any) (int, bool) {
+	if l == nil || r == nil {
+		return 0, false
+	}
+	if isNumber(l) || isNumber(r) {
+		l, r = toNumber(l), toNumber(r)
+		if l == nil || r == nil {
+			return 0, false
+		}
+		if li, ok := l.(int64); ok {
+			if ri, ok := r.(int64); ok {
+				return cmp(li < ri, li > ri), true
+			}
+		}
+		lf, rf := toFloat(l), toFloat(r)
+		return cmp(lf < rf, lf > rf), true
+	}
+	switch lv := l.(type) {
+	case string:
+		if rv, ok := r.(string); ok {
+			return cmp(lv < rv, lv > rv), true
+		}
+	case bool:
+		if rv, ok := r.(bool); ok {
+			return cmp(!lv && rv, lv && !rv), true
+		}
+	}
+	return 0, false
+}
+
+func cmp(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+func arithmetic(op string, l, r any) any {
+	if l == nil || r == nil {
+		return nil
+	}
+	if op == "+" {
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				return ls + rs
+			}
+		}
+	}
+	l, r = toNumber(l), toNumber(r)
+	if l == nil || r == nil {
+		return nil
+	}
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+	if lInt && rInt && op != "/" {
+		switch op {
+		case "+":
+			return li + ri
+		case "-":
+			return li - ri
+		case "*":
+			return li * ri
+		case "%":
+			if ri == 0 {
+				return nil
+			}
+			return li % ri
+		}
+	}
+	lf, rf := toFloat(l), toFloat(r)
+	switch op {
+	case "+":
+		return lf + rf
+	case "-":
+		return lf - rf
+	case "*":
+		return lf * rf
+	case "/":
+		if rf == 0 {
+			return nil
+		}
+		return lf / rf
+	case "%":
+		if rf == 0 {
+			return nil
+		}
+		return math.Mod(lf, rf)
+	}
+	return nil
+}
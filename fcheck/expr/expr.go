@@ -0,0 +1,272 @@
+// Package expr implements a small expression language used to filter rows, e.g.
+//
+//	country == "PL" && amount > 0
+//	(name =~ "^[A-Z]" || code != null) and not deleted
+//	price * qty >= 100.5
+//
+// Supported are: number, string ('...' or "..."), true, false and null literals,
+// field names (`quoted` if they contain spaces or other special characters),
+// arithmetic + - * / %, comparisons == (or =) != < <= > >=, regex match =~ and !~,
+// boolean && (and) || (or) ! (not) and parentheses.
+// Comparing anything but == or != with null is false, arithmetic with null gives null.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Field describes a field of the rows passed to Eval/Match, values of Numeric fields are
+// converted to numbers (values that can't be converted are treated as null)
+type Field struct {
+	Name    string
+	Numeric bool
+}
+
+// Expr is a parsed expression, it must be bound to fields with Bind before it can be evaluated
+type Expr struct {
+	src   string
+	root  node
+	bound bool
+}
+
+// Parse parses the expression, field names are resolved later by Bind
+func Parse(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tk_eof {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// Compile parses the expression and binds it to the fields
+func Compile(src string, fields []Field) (*Expr, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Bind(fields); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Bind resolves field names used in the expression to positions in the row
+func (e *Expr) Bind(fields []Field) error {
+	var err error
+	walk(e.root, func(n node) {
+		if f, ok := n.(*fieldNode); ok && err == nil {
+			f.index = -1
+			for i, fld := range fields {
+				if fld.Name == f.name {
+					f.index = i
+					f.numeric = fld.Numeric
+					break
+				}
+			}
+			if f.index < 0 {
+				err = fmt.Errorf("unknown field: %s", f.name)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	e.bound = true
+	return nil
+}
+
+// Fields returns names of the fields used in the expression
+func (e *Expr) Fields() []string {
+	var names []string
+	seen := map[string]bool{}
+	walk(e.root, func(n node) {
+		if f, ok := n.(*fieldNode); ok && !seen[f.name] {
+			seen[f.name] = true
+			names = append(names, f.name)
+		}
+	})
+	return names
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression for the row, the result is nil, bool, int64, float64 or string
+func (e *Expr) Eval(row []any) any {
+	if !e.bound {
+		panic("expr: Eval called before Bind")
+	}
+	return e.root.eval(row)
+}
+
+// Match evaluates the expression and returns true if the result is true, a non zero number or a non empty string
+func (e *Expr) Match(row []any) bool {
+	return truthy(e.Eval(row))
+}
+
+//
+// parser (precedence climbing)
+//
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tk_eof {
+		p.pos++
+	}
+	return t
+}
+
+// binary operator of the token (keywords and aliases mapped to their symbols) and its precedence
+func binaryOp(t token) (string, int) {
+	op := t.text
+	if t.kind == tk_ident && !t.quoted {
+		switch strings.ToLower(t.text) {
+		case "or":
+			op = "||"
+		case "and":
+			op = "&&"
+		default:
+			return "", -1
+		}
+	} else if t.kind != tk_op {
+		return "", -1
+	}
+	switch op {
+	case "||":
+		return op, 1
+	case "&&":
+		return op, 2
+	case "==", "=", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		if op == "=" {
+			op = "=="
+		}
+		return op, 3
+	case "+", "-":
+		return op, 4
+	case "*", "/", "%":
+		return op, 5
+	}
+	return "", -1
+}
+
+func (p *parser) parseExpr(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op, prec := binaryOp(t)
+		if prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseExpr(prec)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "&&", "||":
+			left = &logicalNode{op: op, left: left, right: right}
+		case "=~", "!~":
+			lit, ok := right.(*literalNode)
+			if ok {
+				_, ok = lit.v.(string)
+			}
+			if !ok {
+				return nil, fmt.Errorf("%s at %d expects a string literal with a regular expression", op, t.pos)
+			}
+			re, err := regexp.Compile(lit.v.(string))
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression at %d: %w", t.pos, err)
+			}
+			left = &matchNode{negate: op == "!~", left: left, re: re}
+		default:
+			left = &binaryNode{op: op, left: left, right: right}
+		}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if (t.kind == tk_op && (t.text == "!" || t.text == "-")) || (t.kind == tk_ident && !t.quoted && strings.ToLower(t.text) == "not") {
+		p.next()
+		var operand node
+		var err error
+		if t.kind == tk_ident {
+			// SQL like: not a == b is not (a == b), !a == b is (!a) == b
+			operand, err = p.parseExpr(2)
+		} else {
+			operand, err = p.parseUnary()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "-" {
+			return &negNode{operand}, nil
+		}
+		return &notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tk_number:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literalNode{i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return &literalNode{f}, nil
+	case tk_string:
+		return &literalNode{t.text}, nil
+	case tk_ident:
+		if !t.quoted {
+			switch strings.ToLower(t.text) {
+			case "true":
+				return &literalNode{true}, nil
+			case "false":
+				return &literalNode{false}, nil
+			case "null":
+				return &literalNode{nil}, nil
+			}
+		}
+		return &fieldNode{name: t.text}, nil
+	case tk_lparen:
+		n, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tk_rparen {
+			return nil, fmt.Errorf("missing ) at %d", c.pos)
+		}
+		return n, nil
+	case tk_eof:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
@@ -0,0 +1,110 @@
+package expr
+
+import (
+	"testing"
+)
+
+var testFields = []Field{
+	{Name: "country"},
+	{Name: "amount", Numeric: true},
+	{Name: "qty", Numeric: true},
+	{Name: "name"},
+	{Name: "my field"},
+	{Name: "active"},
+}
+
+func TestEval(t *testing.T) {
+	row := []any{"PL", "12.5", int64(3), nil, "x y", true}
+	tests := []struct {
+		src      string
+		expected any
+	}{
+		{`country == "PL" && amount > 0`, true},
+		{`country = 'PL' and amount > 100`, false},
+		{`country != "PL" or qty >= 3`, true},
+		{`amount * qty`, 37.5},
+		{`qty + 2 * 3`, int64(9)},
+		{`(qty + 2) * 3`, int64(15)},
+		{`qty / 2`, 1.5},
+		{`qty % 2`, int64(1)},
+		{`-qty - -1`, int64(-2)},
+		{`qty / 0`, nil},
+		{`name == null`, true},
+		{`name != null`, false},
+		{`name > "a"`, false},
+		{`name + "x"`, nil},
+		{`country + "-" + qty`, nil},
+		{`country + "-"`, "PL-"},
+		{`country =~ "^P"`, true},
+		{`country !~ "^P"`, false},
+		{`name =~ ".*"`, false},
+		{`qty =~ "^3$"`, true},
+		{"`my field` == \"x y\"", true},
+		{`active`, true},
+		{`!active`, false},
+		{`not country == "DE"`, true},
+		{`!qty == 3`, false},
+		{`active == true && 1.5e1 > 1e1`, true},
+		{`country < "XX"`, true},
+		{`country == 1`, false},
+		{`amount == "12.50"`, true},
+	}
+	for _, tc := range tests {
+		e, err := Compile(tc.src, testFields)
+		if err != nil {
+			t.Errorf("%s: %v", tc.src, err)
+			continue
+		}
+		if v := e.Eval(row); v != tc.expected {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", tc.src, tc.expected, tc.expected, v, v)
+		}
+	}
+}
+
+func TestNumericField(t *testing.T) {
+	e, err := Compile(`amount == null`, testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Match([]any{"PL", "", nil, nil, nil, nil}) {
+		t.Error("empty numeric value should be null")
+	}
+	if !e.Match([]any{"PL", "abc", nil, nil, nil, nil}) {
+		t.Error("invalid numeric value should be null")
+	}
+	if e.Match([]any{"PL", float32(1.5), nil, nil, nil, nil}) {
+		t.Error("float32 is not null")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`amount >`,
+		`(amount > 1`,
+		`amount > 1)`,
+		`country == "PL`,
+		`country =~ "["`,
+		`country =~ amount`,
+		`amount # 2`,
+		`1 2`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%q: expected syntax error", src)
+		}
+	}
+	if _, err := Compile(`nope > 1`, testFields); err == nil {
+		t.Error("unknown field not reported")
+	}
+}
+
+func TestFields(t *testing.T) {
+	e, err := Parse("a > 1 && (b == a || `c d` != null)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := e.Fields()
+	if len(fields) != 3 || fields[0] != "a" || fields[1] != "b" || fields[2] != "c d" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
@@ -0,0 +1,131 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind uint
+
+const (
+	tk_eof tokenKind = iota
+	tk_ident
+	tk_number
+	tk_string
+	tk_op
+	tk_lparen
+	tk_rparen
+)
+
+type token struct {
+	kind tokenKind
+	text   string // identifier/operator/number as written, unquoted string value
+	pos    int    // byte offset in the source, used in error messages
+	quoted bool   // `quoted` identifier, never a keyword
+}
+
+// two character operators must be checked before the single character ones
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "+", "-", "*", "/", "%", "!", "="}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	rs := []rune(src)
+	// rune index -> byte offset for error messages
+	offset := func(i int) int { return len(string(rs[:i])) }
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tk_lparen, text: "(", pos: offset(i)})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tk_rparen, text: ")", pos: offset(i)})
+			i++
+		case r == '"' || r == '\'' || r == '`':
+			// strings in double or single quotes, field names with spaces etc. in backquotes
+			start := i
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(rs) {
+				if rs[i] == '\\' && r != '`' && i+1 < len(rs) {
+					switch rs[i+1] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(rs[i+1])
+					}
+					i += 2
+					continue
+				}
+				if rs[i] == r {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(rs[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at %d", offset(start))
+			}
+			kind := tk_string
+			if r == '`' {
+				kind = tk_ident
+			}
+			tokens = append(tokens, token{kind: kind, text: sb.String(), pos: offset(start), quoted: r == '`'})
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			// exponent
+			if i < len(rs) && (rs[i] == 'e' || rs[i] == 'E') {
+				j := i + 1
+				if j < len(rs) && (rs[j] == '+' || rs[j] == '-') {
+					j++
+				}
+				if j < len(rs) && unicode.IsDigit(rs[j]) {
+					i = j
+					for i < len(rs) && unicode.IsDigit(rs[i]) {
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tk_number, text: string(rs[start:i]), pos: offset(start)})
+		case isIdentStart(r):
+			start := i
+			for i < len(rs) && isIdentPart(rs[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tk_ident, text: string(rs[start:i]), pos: offset(start)})
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(rs[i:]), op) {
+					tokens = append(tokens, token{kind: tk_op, text: op, pos: offset(i)})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at %d", r, offset(i))
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tk_eof, pos: len(src)})
+	return tokens, nil
+}
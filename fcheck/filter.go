@@ -0,0 +1,103 @@
+package fcheck
+
+import (
+	"fmt"
+	"gocf/fcheck/expr"
+	"log"
+)
+
+// FilteredReader wraps a FileReader and passes only the rows matching a where expression
+// (see package expr for the syntax). Since it's a FileReader itself, the filter applies
+// to everything downstream: stat collectors, conversion writers etc.
+type FilteredReader struct {
+	FileReader
+	where *expr.Expr
+	proj  *projection // set by Project()
+}
+
+// NewFilteredReader checks the syntax of the expression, field names are checked by Init()
+func NewFilteredReader(fr FileReader, where string) (*FilteredReader, error) {
+	e, err := expr.Parse(where)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression: %w", err)
+	}
+	return &FilteredReader{FileReader: fr, where: e}, nil
+}
+
+func exprFields(fields []string, types []DataType) []expr.Field {
+	ef := make([]expr.Field, len(fields))
+	for i, name := range fields {
+		ef[i] = expr.Field{Name: name, Numeric: types[i] == DT_int || types[i] == DT_float}
+	}
+	return ef
+}
+
+func (fr *FilteredReader) Init() {
+	fr.FileReader.Init()
+	if err := fr.where.Bind(exprFields(fr.FileReader.GetFields(), fr.FileReader.GetTypes())); err != nil {
+		log.Fatal("invalid where expression: ", err)
+	}
+}
+
+// Project selects the fields passed downstream, the wrapped reader still has to deliver
+// the fields used in the expression, so only the union of both is pushed down to it.
+func (fr *FilteredReader) Project(fields []string) error {
+	if p, ok := fr.FileReader.(Projector); ok {
+		var needed []string
+		used := fr.where.Fields()
+		for _, f := range fr.FileReader.GetFields() {
+			if indexof(fields, f) >= 0 || indexof(used, f) >= 0 {
+				needed = append(needed, f)
+			}
+		}
+		if err := p.Project(needed); err != nil {
+			return err
+		}
+		if err := fr.where.Bind(exprFields(fr.FileReader.GetFields(), fr.FileReader.GetTypes())); err != nil {
+			return err
+		}
+	}
+	proj, err := newProjection(fr.FileReader.GetFields(), fr.FileReader.GetTypes(), fields)
+	if err != nil {
+		return err
+	}
+	fr.proj = proj
+	return nil
+}
+
+func (fr *FilteredReader) GetFields() []string {
+	if fr.proj != nil {
+		return fr.proj.fields
+	}
+	return fr.FileReader.GetFields()
+}
+func (fr *FilteredReader) GetTypes() []DataType {
+	if fr.proj != nil {
+		return fr.proj.types
+	}
+	return fr.FileReader.GetTypes()
+}
+
+func (fr *FilteredReader) GetFileInfo() string {
+	return fmt.Sprintf("%s, rows where: %s", fr.FileReader.GetFileInfo(), fr.where)
+}
+
+func (fr *FilteredReader) Stop() {
+	stopReader(fr.FileReader)
+}
+
+func (fr *FilteredReader) Read() chan []any {
+	out := make(chan []any)
+	go func(in chan []any) {
+		for row := range in {
+			if fr.where.Match(row) {
+				if fr.proj != nil {
+					row = fr.proj.apply(row)
+				}
+				out <- row
+			}
+		}
+		close(out)
+	}(fr.FileReader.Read())
+	return out
+}
@@ -0,0 +1,65 @@
+package fcheck
+
+import (
+	"testing"
+)
+
+// in-memory FileReader for tests
+type sliceReader struct {
+	fields []string
+	types  []DataType
+	rows   [][]any
+}
+
+func (sr *sliceReader) FileName() string     { return "memory" }
+func (sr *sliceReader) Init()                {}
+func (sr *sliceReader) GetFields() []string  { return sr.fields }
+func (sr *sliceReader) GetTypes() []DataType { return sr.types }
+func (sr *sliceReader) GetFileInfo() string  { return "in-memory rows" }
+func (sr *sliceReader) Read() chan []any {
+	out := make(chan []any)
+	go func() {
+		for _, row := range sr.rows {
+			out <- row
+		}
+		close(out)
+	}()
+	return out
+}
+
+func testRows() *sliceReader {
+	return &sliceReader{
+		fields: []string{"country", "amount", "name"},
+		types:  []DataType{DT_string, DT_float, DT_string},
+		rows: [][]any{
+			{"PL", 10.5, "a"},
+			{"PL", -1.0, "b"},
+			{"DE", 3.0, nil},
+			{"PL", nil, "c"},
+			{"PL", 7.0, nil},
+		},
+	}
+}
+
+func TestFilteredReader(t *testing.T) {
+	fr, err := NewFilteredReader(testRows(), `country == "PL" && amount > 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReport(t, fr, ReportOptions{NoOfSamples: 5})
+	if r.RowCount != 2 {
+		t.Errorf("Expected 2 rows, got %d", r.RowCount)
+	}
+	if s := r.Field("amount").Numeric; s == nil || s.Min != 7 || s.Max != 10.5 {
+		t.Errorf("unexpected amount stats: %+v", s)
+	}
+	if r.Field("name").Nulls != 1 {
+		t.Errorf("Expected 1 null name, got %d", r.Field("name").Nulls)
+	}
+}
+
+func TestFilteredReaderErrors(t *testing.T) {
+	if _, err := NewFilteredReader(testRows(), `country ==`); err == nil {
+		t.Error("syntax error not reported")
+	}
+}
@@ -0,0 +1,126 @@
+package fcheck
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Enum finding levels, same as in SARIF
+const (
+	FL_error   = "error"
+	FL_warning = "warning"
+	FL_note    = "note"
+)
+
+// Finding is an issue spotted in the report, used by the CI outputs (JUnit, SARIF)
+type Finding struct {
+	Field   string `json:"field,omitempty"` // "" for the whole file
+	Rule    string `json:"rule"`            // see FINDING_RULES
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// FINDING_RULES ids and descriptions of the findings
+var FINDING_RULES = [][2]string{
+	{"no-rows", "The file has no rows"},
+	{"empty-field", "All values of the field are null or empty"},
+	{"low-coverage", "Share of not null and not empty values is below the minimum"},
+	{"nulls", "The field has null or empty values"},
+	{"pii", "The field contains likely PII"},
+	{"duplicate-keys", "Key fields are not unique"},
+	{"duplicate-rows", "Some rows are identical"},
+}
+
+// Findings checks the report: errors for no rows, empty fields, fields with coverage (in %) below
+// minCoverage and duplicate keys, warnings for PII and duplicate rows, notes for fields with nulls
+func (r *Report) Findings(minCoverage float64) []Finding {
+	var f []Finding
+	if r.RowCount == 0 {
+		return append(f, Finding{Rule: "no-rows", Level: FL_error, Message: "no data found"})
+	}
+	for _, field := range r.Fields {
+		stats := fmt.Sprintf("count: %d (%.2f%%), type: %s, %s", field.Count, field.Coverage, field.Type, field.Comment)
+		switch {
+		case field.Count == 0:
+			f = append(f, Finding{Field: field.Name, Rule: "empty-field", Level: FL_error, Message: stats})
+		case field.Coverage < minCoverage:
+			f = append(f, Finding{Field: field.Name, Rule: "low-coverage", Level: FL_error,
+				Message: fmt.Sprintf("coverage %.2f%% < %g%%, %s", field.Coverage, minCoverage, stats)})
+		case field.Count < r.RowCount:
+			f = append(f, Finding{Field: field.Name, Rule: "nulls", Level: FL_note, Message: stats})
+		}
+		if field.PII != "" {
+			f = append(f, Finding{Field: field.Name, Rule: "pii", Level: FL_warning, Message: "likely " + field.PII})
+		}
+	}
+	if d := r.Duplicates; d != nil {
+		if d.KeyDuplicates > 0 {
+			var examples []string
+			for _, e := range d.Examples {
+				examples = append(examples, fmt.Sprintf("(%s) x %d", strings.Join(e.Key, ", "), e.Count))
+			}
+			f = append(f, Finding{Field: strings.Join(d.Keys, ","), Rule: "duplicate-keys", Level: FL_error,
+				Message: fmt.Sprintf("%d duplicate rows, e.g. %s", d.KeyDuplicates, strings.Join(examples, ", "))})
+		}
+		if d.RowDuplicates > 0 {
+			f = append(f, Finding{Rule: "duplicate-rows", Level: FL_warning, Message: fmt.Sprintf("%d duplicate rows", d.RowDuplicates)})
+		}
+	}
+	return f
+}
+
+// WriteJUnit writes a test suite for the file with a test case per field (and for the file itself),
+// error findings are failures, the rest goes to system-out
+func (r *Report) WriteJUnit(w io.Writer, minCoverage float64) error {
+	findings := r.Findings(minCoverage)
+	s := newJUnitSuite(r.FileName, r.Start, r.Duration)
+	testCase := func(name string, match func(f Finding) bool) junitCase {
+		tc := junitCase{Name: name, Classname: r.FileName}
+		var out, failures []string
+		for _, f := range findings {
+			if !match(f) {
+				continue
+			}
+			out = append(out, f.Level+": "+f.Rule+": "+f.Message)
+			if f.Level == FL_error {
+				failures = append(failures, f.Message)
+				if tc.Failure == nil {
+					tc.Failure = &junitFailure{Type: f.Rule, Message: f.Message}
+				}
+			}
+		}
+		if tc.Failure != nil {
+			tc.Failure.Text = strings.Join(failures, "\n")
+		}
+		tc.SystemOut = strings.Join(out, "\n")
+		return tc
+	}
+	file := testCase("rows", func(f Finding) bool { return f.Field == "" })
+	if file.SystemOut == "" {
+		file.SystemOut = fmt.Sprintf("%d rows, %s", r.RowCount, r.FileInfo)
+	}
+	s.add(file)
+	for _, field := range r.Fields {
+		name := field.Name
+		tc := testCase(name, func(f Finding) bool { return f.Field == name && f.Rule != "duplicate-keys" })
+		tc.Classname += "." + field.Name
+		if tc.SystemOut == "" {
+			tc.SystemOut = fmt.Sprintf("count: %d (%.2f%%), type: %s, %s", field.Count, field.Coverage, field.Type, field.Comment)
+		}
+		s.add(tc)
+	}
+	if r.Duplicates != nil && len(r.Duplicates.Keys) > 0 {
+		s.add(testCase("unique "+strings.Join(r.Duplicates.Keys, ","), func(f Finding) bool { return f.Rule == "duplicate-keys" }))
+	}
+	return writeJUnit(w, s)
+}
+
+// WriteSARIF writes all findings as SARIF results
+func (r *Report) WriteSARIF(w io.Writer, minCoverage float64) error {
+	var results []sarifResult
+	for _, f := range r.Findings(minCoverage) {
+		results = append(results, newSarifResult(r.FileName, f.Field, f.Rule, f.Level, f.Message))
+	}
+	return writeSARIF(w, FINDING_RULES, results)
+}
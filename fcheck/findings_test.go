@@ -0,0 +1,89 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func findingsReport(t *testing.T) *Report {
+	sr := &sliceReader{
+		fields: []string{"id", "email", "empty", "note"},
+		types:  []DataType{DT_int, DT_string, DT_string, DT_string},
+		rows: [][]any{
+			{int64(1), "a@example.com", nil, "x"},
+			{int64(1), "b@example.com", "", nil},
+			{int64(2), "c@example.com", nil, "y"},
+			{int64(3), "d@example.com", nil, "z"},
+		},
+	}
+	return newTestReport(t, sr, ReportOptions{Collectors: CL_default | CL_pii, Duplicates: &DuplicateOptions{Keys: []string{"id"}}})
+}
+
+func TestFindings(t *testing.T) {
+	r := findingsReport(t)
+	var got []string
+	for _, f := range r.Findings(80) {
+		got = append(got, f.Level+" "+f.Rule+" "+f.Field)
+	}
+	expected := "warning pii email,error empty-field empty,error low-coverage note,error duplicate-keys id"
+	if strings.Join(got, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(got, ","))
+	}
+	got = nil
+	for _, f := range r.Findings(0) {
+		got = append(got, f.Level+" "+f.Rule+" "+f.Field)
+	}
+	if got[2] != "note nulls note" {
+		t.Errorf("expected a note on nulls, got %v", got)
+	}
+	if f := (&Report{}).Findings(0); len(f) != 1 || f[0].Rule != "no-rows" {
+		t.Errorf("unexpected findings of an empty file: %v", f)
+	}
+}
+
+func TestReportJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := findingsReport(t).WriteJUnit(&buf, 80); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	// rows, 4 fields, unique id
+	if doc.Tests != 6 || doc.Failures != 3 {
+		t.Fatalf("unexpected junit output:\n%s", buf.String())
+	}
+	cases := doc.Suites[0].Cases
+	if cases[1].Name != "id" || cases[1].Failure != nil || cases[3].Failure == nil || cases[3].Failure.Type != "empty-field" {
+		t.Errorf("unexpected test cases: %+v", cases)
+	}
+	if !strings.Contains(cases[3].Failure.Text, "ALL NULL") && !strings.Contains(cases[3].Failure.Text, "EMPTY") {
+		t.Errorf("stats missing in the failure: %s", cases[3].Failure.Text)
+	}
+	if cases[5].Name != "unique id" || cases[5].Failure == nil {
+		t.Errorf("unexpected unique test case: %+v", cases[5])
+	}
+}
+
+func TestReportSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := findingsReport(t).WriteSARIF(&buf, 80); err != nil {
+		t.Fatal(err)
+	}
+	var doc sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "2.1.0" || len(doc.Runs) != 1 || len(doc.Runs[0].Results) != 4 {
+		t.Fatalf("unexpected sarif output:\n%s", buf.String())
+	}
+	res := doc.Runs[0].Results[1]
+	if res.RuleId != "empty-field" || res.Level != "error" || res.Locations[0].LogicalLocations[0].Name != "empty" ||
+		res.Locations[0].PhysicalLocation.ArtifactLocation.Uri != "memory" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,351 @@
+package fcheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FIXED_ERROR_LINES max number of line numbers kept for records of a wrong length
+const FIXED_ERROR_LINES = 10
+
+// FixedField is a field of a fixed-width record
+type FixedField struct {
+	Name   string
+	Start  int // 1-based position of the first byte, as in copybooks
+	Length int
+	Type   DataType // DT_int or DT_float are converted, blank and invalid numbers are nulls
+	// Decimals is the number of implied decimal places of numbers, e.g. with 2 "12345" is 123.45 (the type is float)
+	Decimals int
+	// Overpunch is set for signed numbers with the sign in the last digit (COBOL zoned decimal):
+	// {, A-I are +0..+9 and }, J-R are -0..-9
+	Overpunch bool
+}
+
+// FixedLayout is the layout of fixed-width records
+type FixedLayout struct {
+	Fields []FixedField
+	// RecordLength is the expected length of records (without the line end), records of a different length are counted,
+	// if 0 it's the end of the last field
+	RecordLength int
+}
+
+// Validate checks the field positions, lengths and names
+func (l *FixedLayout) Validate() error {
+	if len(l.Fields) == 0 {
+		return fmt.Errorf("fixed-width layout has no fields")
+	}
+	names := map[string]bool{}
+	for _, f := range l.Fields {
+		if f.Name == "" || names[f.Name] {
+			return fmt.Errorf("fixed-width layout: empty or duplicate field name: %q", f.Name)
+		}
+		names[f.Name] = true
+		if f.Start < 1 || f.Length < 1 || f.Decimals < 0 || f.Decimals > f.Length {
+			return fmt.Errorf("fixed-width layout: %s: invalid position %d, length %d or decimals %d", f.Name, f.Start, f.Length, f.Decimals)
+		}
+		if l.RecordLength > 0 && f.Start+f.Length-1 > l.RecordLength {
+			return fmt.Errorf("fixed-width layout: %s ends after the record length %d", f.Name, l.RecordLength)
+		}
+	}
+	return nil
+}
+
+func (l *FixedLayout) recordLength() int {
+	if l.RecordLength > 0 {
+		return l.RecordLength
+	}
+	n := 0
+	for _, f := range l.Fields {
+		if end := f.Start + f.Length - 1; end > n {
+			n = end
+		}
+	}
+	return n
+}
+
+// FixedTrim tells which spaces are removed from string fields (numbers are always trimmed)
+type FixedTrim uint
+
+const (
+	TR_both FixedTrim = iota
+	TR_right
+	TR_left
+	TR_none
+)
+
+// ParseFixedTrim parses both, right, left or none
+func ParseFixedTrim(s string) (FixedTrim, error) {
+	switch s {
+	case "", "both":
+		return TR_both, nil
+	case "right":
+		return TR_right, nil
+	case "left":
+		return TR_left, nil
+	case "none":
+		return TR_none, nil
+	}
+	return TR_both, fmt.Errorf("invalid trim option: %s (expected both, right, left or none)", s)
+}
+
+// FixedWidthOptions are the options of the fixed-width reader (ReaderOptions{"fixed": FixedWidthOptions{...}}),
+// the format is used only if a layout is given
+type FixedWidthOptions struct {
+	Layout FixedLayout
+	Trim   FixedTrim
+}
+
+type FixedWidthReader struct {
+	fileName   string
+	layout     FixedLayout
+	trim       FixedTrim
+	fields     []FixedField // selected fields
+	names      []string
+	types      []DataType
+	length     int // expected record length
+	badLength  int // records of a wrong length
+	badLines   []int
+	badNumbers int // numbers that can't be converted (read as nulls)
+	readStop
+}
+
+// NewFixedWidthReader creates a reader of fixed-width text records (one per line) with the layout
+func NewFixedWidthReader(fileName string, layout FixedLayout, trim FixedTrim) (*FixedWidthReader, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return &FixedWidthReader{fileName: fileName, layout: layout, trim: trim}, nil
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name: "fixed",
+		Type: FT_fixed,
+		// only if the layout is given
+		Sniff: func(fileName string, head []byte, opts any) bool {
+			o, ok := opts.(FixedWidthOptions)
+			return ok && len(o.Layout.Fields) > 0
+		},
+		New: func(fileName string, opts any) (FileReader, error) {
+			o, ok := opts.(FixedWidthOptions)
+			if !ok {
+				return nil, fmt.Errorf("fixed-width files need a layout")
+			}
+			return NewFixedWidthReader(fileName, o.Layout, o.Trim)
+		},
+	})
+}
+
+func (fr *FixedWidthReader) FileName() string {
+	return fr.fileName
+}
+
+func (fr *FixedWidthReader) Init() {
+	fr.fields = fr.layout.Fields
+	fr.names = make([]string, len(fr.fields))
+	fr.types = make([]DataType, len(fr.fields))
+	for i, f := range fr.fields {
+		fr.names[i] = f.Name
+		fr.types[i] = f.Type
+		if f.Decimals > 0 {
+			fr.types[i] = DT_float
+		} else if f.Type == DT_unknown {
+			fr.types[i] = DT_string
+		}
+	}
+	fr.length = fr.layout.recordLength()
+}
+
+// Project implements Projector, unselected fields are not extracted
+func (fr *FixedWidthReader) Project(fields []string) error {
+	selected := make([]FixedField, len(fields))
+	types := make([]DataType, len(fields))
+	for i, name := range fields {
+		j := indexof(fr.names, name)
+		if j < 0 {
+			return fmt.Errorf("unknown field: %s", name)
+		}
+		selected[i] = fr.fields[j]
+		types[i] = fr.types[j]
+	}
+	fr.fields = selected
+	fr.names = fields
+	fr.types = types
+	return nil
+}
+
+func (fr *FixedWidthReader) GetFields() []string {
+	return fr.names
+}
+
+func (fr *FixedWidthReader) GetTypes() []DataType {
+	return fr.types
+}
+
+func (fr *FixedWidthReader) GetFileInfo() string {
+	info := fmt.Sprintf("fixed-width, %d fields, record length %d", len(fr.layout.Fields), fr.length)
+	if fr.badLength > 0 {
+		lines := make([]string, len(fr.badLines))
+		for i, l := range fr.badLines {
+			lines[i] = strconv.Itoa(l)
+		}
+		if fr.badLength > len(lines) {
+			lines = append(lines, "...")
+		}
+		info += fmt.Sprintf(", %d records of a wrong length (lines %s)", fr.badLength, strings.Join(lines, ", "))
+	}
+	if fr.badNumbers > 0 {
+		info += fmt.Sprintf(", %d invalid numbers", fr.badNumbers)
+	}
+	return info
+}
+
+// overpunch maps the last character of a signed zoned decimal to the digit and the sign
+func overpunch(c byte) (digit byte, negative bool, ok bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c, false, true
+	case c == '{':
+		return '0', false, true
+	case c >= 'A' && c <= 'I':
+		return '1' + c - 'A', false, true
+	case c == '}':
+		return '0', true, true
+	case c >= 'J' && c <= 'R':
+		return '1' + c - 'J', true, true
+	}
+	return 0, false, false
+}
+
+// parseNumber converts the trimmed value of a numeric field, ok is false if it's not a number
+func parseNumber(f *FixedField, s string) (any, bool) {
+	negative := false
+	if f.Overpunch {
+		digit, neg, ok := overpunch(s[len(s)-1])
+		if !ok {
+			return nil, false
+		}
+		s = s[:len(s)-1] + string(digit)
+		negative = neg
+	}
+	if f.Decimals == 0 && f.Type != DT_float {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		if negative {
+			v = -v
+		}
+		return v, true
+	}
+	if f.Decimals > 0 && !strings.Contains(s, ".") {
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, false
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, false
+	}
+	if f.Decimals > 0 && !strings.Contains(s, ".") {
+		v /= math.Pow10(f.Decimals)
+	}
+	if negative {
+		v = -v
+	}
+	return v, true
+}
+
+// value extracts the field from the record, missing, blank and invalid numbers are nulls (invalid ones are counted)
+func (fr *FixedWidthReader) value(f *FixedField, rec []byte, typ DataType) any {
+	start := f.Start - 1
+	if start >= len(rec) {
+		return nil
+	}
+	end := start + f.Length
+	if end > len(rec) {
+		end = len(rec)
+	}
+	s := string(rec[start:end])
+	if typ == DT_string {
+		switch fr.trim {
+		case TR_both:
+			s = strings.TrimSpace(s)
+		case TR_right:
+			s = strings.TrimRight(s, " \t")
+		case TR_left:
+			s = strings.TrimLeft(s, " \t")
+		}
+		return s
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if v, ok := parseNumber(f, s); ok {
+		return v
+	}
+	fr.badNumbers++
+	return nil
+}
+
+func (fr *FixedWidthReader) toList(rec []byte) []any {
+	row := make([]any, len(fr.fields))
+	for i := range fr.fields {
+		row[i] = fr.value(&fr.fields[i], rec, fr.types[i])
+	}
+	return row
+}
+
+func (fr *FixedWidthReader) Read() chan []any {
+	out := make(chan []any)
+	done := fr.begin()
+	go func() {
+		f, err := os.Open(fr.fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r := bufio.NewReaderSize(f, 64*1024)
+		for line := 1; ; line++ {
+			rec, err := r.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				log.Fatal(err)
+			}
+			if len(rec) == 0 && err == io.EOF {
+				break
+			}
+			rec = bytes.TrimRight(rec, "\r\n")
+			if len(rec) == 0 {
+				if err == io.EOF {
+					break
+				}
+				continue
+			}
+			if len(rec) != fr.length {
+				fr.badLength++
+				if len(fr.badLines) < FIXED_ERROR_LINES {
+					fr.badLines = append(fr.badLines, line)
+				}
+			}
+			select {
+			case out <- fr.toList(rec):
+			case <-done:
+				close(out)
+				return
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+		close(out)
+	}()
+	return out
+}
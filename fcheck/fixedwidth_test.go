@@ -0,0 +1,147 @@
+package fcheck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCopybook = `      * customer extract
+000100 01 CUSTOMER.
+000200    05 CUST-ID       PIC 9(6).
+000300    05 NAME          PIC X(12).
+000400    05 BALANCE       PIC S9(5)V99.
+000500    05 STATUS        PIC X.
+000600       88 ACTIVE     VALUE 'A'.
+000700    05 FILLER        PIC X(2).
+000800    05 PHONE         PIC X(5) OCCURS 2 TIMES.
+`
+
+func TestParseCopybook(t *testing.T) {
+	layout, err := ParseCopybook(strings.NewReader(testCopybook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FixedField{
+		{"CUST-ID", 1, 6, DT_int, 0, false},
+		{"NAME", 7, 12, DT_string, 0, false},
+		{"BALANCE", 19, 7, DT_float, 2, true},
+		{"STATUS", 26, 1, DT_string, 0, false},
+		{"PHONE_1", 29, 5, DT_string, 0, false},
+		{"PHONE_2", 34, 5, DT_string, 0, false},
+	}
+	if len(layout.Fields) != len(expected) || layout.RecordLength != 38 {
+		t.Fatalf("unexpected layout: %+v", layout)
+	}
+	for i, f := range expected {
+		if layout.Fields[i] != f {
+			t.Errorf("expected %+v, got %+v", f, layout.Fields[i])
+		}
+	}
+	for _, bad := range []string{
+		"01 R.\n 05 A PIC S9(5) COMP-3.",
+		"01 R.\n 05 A PIC X(2).\n 05 B REDEFINES A PIC 99.",
+		"01 R.\n 05 G OCCURS 2.\n 10 A PIC X.",
+		"01 R.\n 05 A PIC X(.",
+		"01 R.\n05 A PIC X.\n01 S.\n05 B PIC X.",
+		"01 R.\n 05 A PIC X.\n 05 A PIC X.",
+	} {
+		if _, err := ParseCopybook(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	for _, tt := range []struct {
+		f        FixedField
+		s        string
+		expected any
+	}{
+		{FixedField{Type: DT_int}, "00123", int64(123)},
+		{FixedField{Type: DT_int, Overpunch: true}, "0012J", int64(-121)},
+		{FixedField{Type: DT_int, Overpunch: true}, "0012{", int64(120)},
+		{FixedField{Type: DT_float, Decimals: 2}, "12345", 123.45},
+		{FixedField{Type: DT_float, Decimals: 2, Overpunch: true}, "1234}", -123.4},
+		{FixedField{Type: DT_float}, "1.5", 1.5},
+		{FixedField{Type: DT_int}, "12a", nil},
+		{FixedField{Type: DT_int, Overpunch: true}, "12a", nil},
+	} {
+		v, ok := parseNumber(&tt.f, tt.s)
+		if v != tt.expected || ok != (tt.expected != nil) {
+			t.Errorf("%s: expected %v, got %v", tt.s, tt.expected, v)
+		}
+	}
+}
+
+func TestFixedWidthReader(t *testing.T) {
+	layout, err := ParseCopybook(strings.NewReader(testCopybook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "customers.dat")
+	data := "000001John Smith  000123{A  12345abcde\r\n" +
+		"000002Anna        000050JA  11111     \r\n" +
+		"\r\n" +
+		"000003Bad\r\n" +
+		"000004Zed         00001aBX  22222xxxxx\r\n"
+	if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFileReader(fileName, ReaderOptions{"fixed": FixedWidthOptions{Layout: layout}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fr.(*FixedWidthReader); !ok {
+		t.Fatalf("expected a fixed-width reader, got %T", fr)
+	}
+	fr.Init()
+	if types := fr.GetTypes(); types[0] != DT_int || types[2] != DT_float || types[1] != DT_string {
+		t.Errorf("unexpected types: %v", types)
+	}
+	rows := 0
+	for range fr.Read() {
+		rows++
+	}
+	info := fr.GetFileInfo()
+	if rows != 4 || !strings.Contains(info, "1 records of a wrong length (lines 4)") || !strings.Contains(info, "1 invalid numbers") {
+		t.Errorf("unexpected rows %d or info: %s", rows, info)
+	}
+	if _, err := NewFileReader(fileName, nil); err == nil {
+		t.Error("expected an error without a layout")
+	}
+}
+
+func TestFixedWidthTrim(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "one.dat")
+	if err := os.WriteFile(fileName, []byte("  ab  00042  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	layout := FixedLayout{Fields: []FixedField{{Name: "s", Start: 1, Length: 6}, {Name: "n", Start: 7, Length: 7, Type: DT_int}}}
+	for trim, expected := range map[FixedTrim]string{TR_both: "ab", TR_right: "  ab", TR_left: "ab  ", TR_none: "  ab  "} {
+		fr, err := NewFixedWidthReader(fileName, layout, trim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.Init()
+		for row := range fr.Read() {
+			if row[0] != expected || row[1] != int64(42) {
+				t.Errorf("trim %d: unexpected row %q", trim, row)
+			}
+		}
+	}
+	fr, _ := NewFixedWidthReader(fileName, layout, TR_both)
+	fr.Init()
+	if err := fr.Project([]string{"n"}); err != nil {
+		t.Fatal(err)
+	}
+	for row := range fr.Read() {
+		if len(row) != 1 || row[0] != int64(42) {
+			t.Errorf("unexpected projected row %v", row)
+		}
+	}
+	if _, err := NewFixedWidthReader(fileName, FixedLayout{Fields: []FixedField{{Name: "a", Start: 0, Length: 1}}}, TR_both); err == nil {
+		t.Error("expected an invalid layout error")
+	}
+}
@@ -0,0 +1,150 @@
+package fcheck
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// svg bar chart of a histogram, bars are scaled to the highest one
+func histogramSVG(h *HistogramStats) template.HTML {
+	const width, height = 320, 120
+	maxCount := 1
+	for _, c := range h.Counts {
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" role="img">`, width, height+16, width, height+16)
+	bw := float64(width) / float64(len(h.Counts))
+	for i, c := range h.Counts {
+		bh := float64(height) * float64(c) / float64(maxCount)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" class="bar"><title>[%s, %s): %d</title></rect>`,
+			float64(i)*bw+1, float64(height)-bh, bw-2, bh,
+			template.HTMLEscapeString(fmt.Sprintf("%.4g", h.Edges[i])), template.HTMLEscapeString(fmt.Sprintf("%.4g", h.Edges[i+1])), c)
+	}
+	fmt.Fprintf(&b, `<text x="0" y="%d" class="axis">%.4g</text>`, height+12, h.Edges[0])
+	fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis" text-anchor="end">%.4g</text>`, width, height+12, h.Edges[len(h.Edges)-1])
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// svg horizontal bar chart of the most (least) frequent values
+func valuesSVG(values []ValueCount) template.HTML {
+	const width, rowHeight, labelWidth = 320, 18, 130
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" role="img">`, width, rowHeight*len(values), width, rowHeight*len(values))
+	for i, v := range values {
+		label := v.Value
+		if len([]rune(label)) > 20 {
+			label = string([]rune(label)[:19]) + "…"
+		}
+		y := i * rowHeight
+		bw := float64(width-labelWidth-50) * v.Percent / 100
+		fmt.Fprintf(&b, `<text x="0" y="%d" class="label">%s<title>%s</title></text>`, y+13, template.HTMLEscapeString(label), template.HTMLEscapeString(v.Value))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" class="bar"/>`, labelWidth, y+3, bw, rowHeight-6)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="axis">%.1f%%</text>`, float64(labelWidth)+bw+4, y+13, v.Percent)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// background of a heatmap cell: white (no nulls) to red (all nulls)
+func nullColor(share float64) template.CSS {
+	return template.CSS(fmt.Sprintf("background: rgba(220, 50, 47, %.2f)", share))
+}
+
+func coverageColor(coverage float64) template.CSS {
+	return nullColor(1 - coverage/100)
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"histogram": histogramSVG,
+	"values":    valuesSVG,
+	"nullColor": nullColor,
+	"covColor":  coverageColor,
+	"pct":       func(share float64) string { return fmt.Sprintf("%.1f%%", 100*share) },
+	"chunk": func(i, size int) string {
+		return fmt.Sprintf("rows %d-%d", i*size+1, (i+1)*size)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gcf report: {{.FileName}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; } h2 { font-size: 1.2em; margin-top: 2em; }
+.summary td { padding: 2px 12px 2px 0; }
+table.fields { border-collapse: collapse; }
+table.fields th, table.fields td { border-bottom: 1px solid #ddd; padding: 4px 10px; text-align: left; }
+table.fields th { cursor: pointer; background: #f4f4f4; user-select: none; }
+table.fields th:after { content: " \2195"; color: #aaa; }
+td.num { text-align: right; }
+.cards { display: flex; flex-wrap: wrap; gap: 16px; }
+.card { border: 1px solid #ddd; border-radius: 4px; padding: 8px 12px; }
+.card h3 { font-size: 1em; margin: 0 0 6px 0; }
+.bar { fill: #268bd2; } .axis, .label { font-size: 11px; fill: #555; }
+table.heatmap { border-collapse: collapse; font-size: 12px; }
+table.heatmap td { border: 1px solid #eee; min-width: 10px; height: 16px; padding: 0; }
+table.heatmap td.name { padding: 0 8px 0 0; border: none; white-space: nowrap; }
+</style>
+</head>
+<body>
+<h1>{{.FileName}}</h1>
+<table class="summary">
+<tr><td>Info</td><td>{{.FileInfo}}</td></tr>
+<tr><td>Rows</td><td>{{.RowCount}}</td></tr>
+<tr><td>Fields</td><td>{{len .Fields}}</td></tr>
+<tr><td>Started</td><td>{{.Start.Format "2006-01-02 15:04:05"}}</td></tr>
+<tr><td>Duration</td><td>{{printf "%.3f" .Duration.Seconds}} s</td></tr>
+</table>
+{{if eq .RowCount 0}}<p>No data found</p>{{else}}
+<h2>Fields</h2>
+<table class="fields" id="fields">
+<thead><tr><th>field</th><th>type</th><th>count</th><th>coverage %</th><th>nulls</th><th>comment</th></tr></thead>
+<tbody>
+{{range .Fields}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td class="num">{{.Count}}</td><td class="num" style="{{covColor .Coverage}}">{{printf "%.2f" .Coverage}}</td><td class="num">{{.Nulls}}</td><td>{{.Comment}}</td></tr>
+{{end}}</tbody>
+</table>
+<h2>Distributions</h2>
+<div class="cards">
+{{range .Fields}}{{if .Histogram}}<div class="card"><h3>{{.Name}}</h3>{{histogram .Histogram}}</div>
+{{else if .Strings}}{{if .Strings.Values}}<div class="card"><h3>{{.Name}}</h3>{{values .Strings.Values}}</div>
+{{end}}{{end}}{{end}}</div>
+{{if .NullMapChunk}}{{$chunk := .NullMapChunk}}
+<h2>Nulls by row position</h2>
+<p>Each cell is a chunk of {{.NullMapChunk}} rows, the darker the more null or empty values.</p>
+<table class="heatmap">
+{{range .Fields}}{{$name := .Name}}<tr><td class="name">{{.Name}}</td>{{range $i, $s := .NullMap}}<td style="{{nullColor $s}}" title="{{$name}}, {{chunk $i $chunk}}: {{pct $s}} null"></td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{end}}
+<script>
+// sort the field table by the clicked column, numbers numerically, click again to reverse
+document.querySelectorAll("#fields th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#fields tbody");
+    var rows = Array.prototype.slice.call(tbody.rows);
+    var dir = th.dataset.dir === "asc" ? -1 : 1;
+    th.dataset.dir = dir === 1 ? "asc" : "desc";
+    rows.sort(function (a, b) {
+      var x = a.cells[col].textContent, y = b.cells[col].textContent;
+      var nx = parseFloat(x), ny = parseFloat(y);
+      if (!isNaN(nx) && !isNaN(ny)) { return dir * (nx - ny); }
+      return dir * x.localeCompare(y);
+    });
+    rows.forEach(function (r) { tbody.appendChild(r); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// WriteHTML renders the report as a self-contained HTML page (no external assets): summary, sortable
+// field table, histograms (CL_histogram), top string values and a null heatmap (CL_nullmap)
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,42 @@
+package fcheck
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReportHTML(t *testing.T) {
+	sr := numberedRows(100)
+	sr.fields = append(sr.fields, "name")
+	sr.types = append(sr.types, DT_string)
+	for i, row := range sr.rows {
+		name := any("<b>&co")
+		if i%2 == 0 {
+			name = nil
+		}
+		sr.rows[i] = append(row, name)
+	}
+	r := newTestReport(t, sr, ReportOptions{NoOfSamples: 3, Collectors: CL_default | CL_histogram | CL_nullmap})
+	if h := r.Field("i").Histogram; h == nil || len(h.Counts) != N_HISTOGRAM_BINS || h.Counts[0] != 5 {
+		t.Errorf("unexpected histogram: %+v", h)
+	}
+	if m := r.Field("name").NullMap; len(m) != 50 || m[0] != 0.5 || r.NullMapChunk != 2 {
+		t.Errorf("unexpected null map: %v, chunk %d", m, r.NullMapChunk)
+	}
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{"<svg", "<td>i</td>", "&lt;b&gt;&amp;co", "Nulls by row position", "rows 1-2: 50.0% null", "<script>"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("%q not in the html", s)
+		}
+	}
+	for _, s := range []string{"<b>&co", "http://", "https://", "src="} {
+		if strings.Contains(html, s) {
+			t.Errorf("%q in the html", s)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package fcheck
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+	"\t", "&#9;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// escapeMarkdown makes the value safe in a GitHub flavoured Markdown table cell
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// WriteMarkdown renders the coverage and frequent values sections as GitHub flavoured Markdown tables
+func (r *Report) WriteMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "## %s\n\n", escapeMarkdown(r.FileName))
+	fmt.Fprintf(w, "%s, %d rows, done in %.3f seconds\n\n", escapeMarkdown(r.FileInfo), r.RowCount, r.Duration.Seconds())
+	if r.RowCount < 1 {
+		fmt.Fprintln(w, "No data found")
+		return
+	}
+
+	fmt.Fprintln(w, "### Coverage")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| field | count | % | type | comment |")
+	fmt.Fprintln(w, "|---|--:|--:|---|---|")
+	anyValues := false
+	for _, f := range r.Fields {
+		fmt.Fprintf(w, "| %s | %d | %.2f | %s | %s |\n", escapeMarkdown(f.Name), f.Count, f.Coverage, f.Type, escapeMarkdown(f.Comment))
+		anyValues = anyValues || f.Strings != nil
+	}
+	fmt.Fprintln(w)
+
+	if anyValues {
+		if r.LeastFrequent {
+			fmt.Fprintf(w, "### %d least frequent string values\n\n", r.NoOfSamples)
+		} else {
+			fmt.Fprintf(w, "### %d most frequent string values\n\n", r.NoOfSamples)
+		}
+		fmt.Fprintln(w, "| field | count | % | value |")
+		fmt.Fprintln(w, "|---|--:|--:|---|")
+		for _, f := range r.Fields {
+			if f.Strings == nil {
+				continue
+			}
+			if len(f.Strings.Values) == 0 {
+				fmt.Fprintf(w, "| %s | | | *not available* |\n", escapeMarkdown(f.Name))
+			}
+			for i, v := range f.Strings.Values {
+				name := ""
+				if i == 0 {
+					name = escapeMarkdown(f.Name)
+				}
+				fmt.Fprintf(w, "| %s | %d | %.2f | %s |\n", name, v.Count, v.Percent, escapeMarkdown(v.Value))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,55 @@
+package fcheck
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := map[string]string{
+		"plain":       "plain",
+		"a|b":         `a\|b`,
+		"line\nbreak": "line<br>break",
+		"crlf\r\n":    "crlf<br>",
+		"tab\there":   "tab&#9;here",
+		"<script>":    "&lt;script&gt;",
+		`back\|slash`: `back\\\|slash`,
+	}
+	for value, expected := range tests {
+		if s := escapeMarkdown(value); s != expected {
+			t.Errorf("%q: expected %q, got %q", value, expected, s)
+		}
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"a_very_long_field_name_exceeding_the_fixed_width_layout", "n"},
+		types:  []DataType{DT_string, DT_int},
+		rows:   [][]any{{"x|y", int64(1)}, {"multi\nline", int64(2)}, {"x|y", nil}},
+	}
+	r := newTestReport(t, sr, ReportOptions{NoOfSamples: 5})
+	var buf bytes.Buffer
+	r.WriteMarkdown(&buf)
+	md := buf.String()
+	for _, s := range []string{
+		"| a_very_long_field_name_exceeding_the_fixed_width_layout | 3 | 100.00 | string |",
+		"| n | 2 | 66.67 | int |",
+		"| a_very_long_field_name_exceeding_the_fixed_width_layout | 2 | 66.67 | x\\|y |",
+		"|  | 1 | 33.33 | multi<br>line |",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("%q not in:\n%s", s, md)
+		}
+	}
+	// every table row has the same number of unescaped pipes
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "| field | count | % | value |") {
+			continue
+		}
+		if strings.HasPrefix(line, "|") && strings.Count(strings.ReplaceAll(line, `\|`, ""), "|") != 5 && strings.Count(strings.ReplaceAll(line, `\|`, ""), "|") != 6 {
+			t.Errorf("broken table row: %s", line)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package fcheck
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Enum MaskAction specifies how values of a field are scrubbed by MaskedReader
+type MaskAction uint
+
+const (
+	MA_none  MaskAction = iota
+	MA_mask             // letters and digits replaced with *, except the last 4 (the first char of an email)
+	MA_hash             // hex HMAC-SHA256 of the value, needs a key
+	MA_token            // TOK_1, TOK_2, ... the same value always gets the same token (within one run)
+	MA_drop             // the field is removed
+)
+
+func (a MaskAction) String() string {
+	switch a {
+	case MA_mask:
+		return "mask"
+	case MA_hash:
+		return "hash"
+	case MA_token:
+		return "token"
+	case MA_drop:
+		return "drop"
+	}
+	return "none"
+}
+
+func parseMaskAction(s string) (MaskAction, error) {
+	for a := MA_mask; a <= MA_drop; a++ {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return MA_none, fmt.Errorf("unknown mask action: %s (expected mask, hash, token or drop)", s)
+}
+
+// MaskRule applies the action to the fields matching the spec (see SelectFields)
+type MaskRule struct {
+	Fields string
+	Action MaskAction
+}
+
+// ParseMaskRules parses rules like "email,phone_*=hash;card=mask;/^ssn/=drop",
+// if a field matches more than one rule the first one is used
+func ParseMaskRules(spec string) ([]MaskRule, error) {
+	var rules []MaskRule
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.LastIndexByte(part, '=')
+		if i < 1 {
+			return nil, fmt.Errorf("invalid mask rule %q, expected fields=action", part)
+		}
+		action, err := parseMaskAction(strings.TrimSpace(part[i+1:]))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, MaskRule{Fields: strings.TrimSpace(part[:i]), Action: action})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no mask rules in %q", spec)
+	}
+	return rules, nil
+}
+
+// MaskedReader wraps a FileReader and scrubs values of the selected fields, nulls stay null.
+// Masked, hashed and tokenized fields become strings.
+type MaskedReader struct {
+	FileReader
+	rules   []MaskRule
+	key     []byte
+	actions []MaskAction // per field of the wrapped reader
+	fields  []string
+	types   []DataType
+	tokens  map[string]string
+}
+
+// NewMaskedReader checks the rules, field names are checked by Init()
+func NewMaskedReader(fr FileReader, rules []MaskRule, key []byte) (*MaskedReader, error) {
+	for _, r := range rules {
+		if r.Action == MA_hash && len(key) == 0 {
+			return nil, fmt.Errorf("hashing %s needs a key", r.Fields)
+		}
+	}
+	return &MaskedReader{FileReader: fr, rules: rules, key: key, tokens: map[string]string{}}, nil
+}
+
+func (mr *MaskedReader) Init() {
+	mr.FileReader.Init()
+	fields := mr.FileReader.GetFields()
+	types := mr.FileReader.GetTypes()
+	mr.actions = make([]MaskAction, len(fields))
+	for _, r := range mr.rules {
+		selected, err := SelectFields(fields, r.Fields)
+		if err != nil {
+			log.Fatal("invalid mask rule: ", err)
+		}
+		for _, f := range selected {
+			if i := indexof(fields, f); mr.actions[i] == MA_none {
+				mr.actions[i] = r.Action
+			}
+		}
+	}
+	mr.fields, mr.types = nil, nil
+	for i, f := range fields {
+		switch mr.actions[i] {
+		case MA_drop:
+			continue
+		case MA_none:
+			mr.types = append(mr.types, types[i])
+		default:
+			mr.types = append(mr.types, DT_string)
+		}
+		mr.fields = append(mr.fields, f)
+	}
+}
+
+func (mr *MaskedReader) GetFields() []string {
+	return mr.fields
+}
+func (mr *MaskedReader) GetTypes() []DataType {
+	return mr.types
+}
+
+func (mr *MaskedReader) GetFileInfo() string {
+	var masked []string
+	fields := mr.FileReader.GetFields()
+	for i, a := range mr.actions {
+		if a != MA_none {
+			masked = append(masked, fields[i]+"="+a.String())
+		}
+	}
+	return fmt.Sprintf("%s, masked: %s", mr.FileReader.GetFileInfo(), strings.Join(masked, ","))
+}
+
+// maskString keeps separators and the last 4 letters/digits (at most half of them, so short values are
+// always masked), emails keep the first char of the local part (if it's longer than 1) and the domain
+func maskString(s string) string {
+	if at := strings.LastIndexByte(s, '@'); at > 0 {
+		local := []rune(s[:at])
+		keep := 1
+		if len(local) < 2 {
+			keep = 0
+		}
+		return string(local[:keep]) + strings.Repeat("*", len(local)-keep) + s[at:]
+	}
+	runes := []rune(s)
+	n := 0
+	for _, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	keep := 4
+	if n/2 < keep {
+		keep = n / 2
+	}
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) {
+			if keep > 0 {
+				keep--
+			} else {
+				runes[i] = '*'
+			}
+		}
+	}
+	return string(runes)
+}
+
+func (mr *MaskedReader) apply(action MaskAction, value any) any {
+	if value == nil {
+		return nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		s = fmt.Sprintf("%v", value)
+	}
+	switch action {
+	case MA_mask:
+		return maskString(s)
+	case MA_hash:
+		h := hmac.New(sha256.New, mr.key)
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	case MA_token:
+		t, ok := mr.tokens[s]
+		if !ok {
+			t = fmt.Sprintf("TOK_%d", len(mr.tokens)+1)
+			mr.tokens[s] = t
+		}
+		return t
+	}
+	return value
+}
+
+func (mr *MaskedReader) Stop() {
+	stopReader(mr.FileReader)
+}
+
+func (mr *MaskedReader) Read() chan []any {
+	out := make(chan []any)
+	go func(in chan []any) {
+		for row := range in {
+			masked := make([]any, 0, len(mr.fields))
+			for i, v := range row {
+				switch mr.actions[i] {
+				case MA_drop:
+				case MA_none:
+					masked = append(masked, v)
+				default:
+					masked = append(masked, mr.apply(mr.actions[i], v))
+				}
+			}
+			out <- masked
+		}
+		close(out)
+	}(mr.FileReader.Read())
+	return out
+}
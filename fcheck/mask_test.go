@@ -0,0 +1,112 @@
+package fcheck
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func piiRows() *sliceReader {
+	return &sliceReader{
+		fields: []string{"id", "email", "card", "phone", "pesel"},
+		types:  []DataType{DT_int, DT_string, DT_string, DT_string, DT_int},
+		rows: [][]any{
+			{int64(1), "john@example.com", "4111 1111 1111 1111", "+48 601 234 567", int64(44051401359)},
+			{int64(2), "ann@example.com", "5500-0000-0000-0004", "+48 601 234 568", int64(44051401359)},
+			{int64(3), nil, "4111 1111 1111 1111", "n/a", nil},
+		},
+	}
+}
+
+func TestParseMaskRules(t *testing.T) {
+	rules, err := ParseMaskRules("email, phone=hash; card=mask;/^pes/=drop ;id=token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []MaskRule{{"email, phone", MA_hash}, {"card", MA_mask}, {"/^pes/", MA_drop}, {"id", MA_token}}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, rules)
+	}
+	for i := range rules {
+		if rules[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], rules[i])
+		}
+	}
+	for _, spec := range []string{"", "email", "=hash", "email=encrypt"} {
+		if _, err := ParseMaskRules(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+	if _, err := NewMaskedReader(piiRows(), rules, nil); err == nil {
+		t.Error("hash without a key accepted")
+	}
+}
+
+func TestMaskedReader(t *testing.T) {
+	rules, _ := ParseMaskRules("email=hash;card=mask;pesel=drop;phone=token")
+	mr, err := NewMaskedReader(piiRows(), rules, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.Init()
+	if f := strings.Join(mr.GetFields(), ","); f != "id,email,card,phone" {
+		t.Errorf("unexpected fields: %s", f)
+	}
+	if types := mr.GetTypes(); types[0] != DT_int || types[1] != DT_string || types[3] != DT_string {
+		t.Errorf("unexpected types: %v", types)
+	}
+	var rows [][]any
+	for row := range mr.Read() {
+		rows = append(rows, row)
+	}
+	// echo -n john@example.com | openssl dgst -sha256 -hmac secret
+	if rows[0][1] != "62f6d956c6a553410a5571d75aaf18a7ceaf78addce3d9999da2e289164e8598" {
+		t.Errorf("unexpected hash: %v", rows[0][1])
+	}
+	if rows[0][1] == rows[1][1] || rows[2][1] != nil {
+		t.Errorf("unexpected hashes: %v, %v, %v", rows[0][1], rows[1][1], rows[2][1])
+	}
+	if rows[0][2] != "**** **** **** 1111" || rows[1][2] != "****-****-****-0004" {
+		t.Errorf("unexpected masks: %v, %v", rows[0][2], rows[1][2])
+	}
+	if rows[0][3] != "TOK_1" || rows[1][3] != "TOK_2" || rows[2][3] != "TOK_3" {
+		t.Errorf("unexpected tokens: %v, %v, %v", rows[0][3], rows[1][3], rows[2][3])
+	}
+	if rows[0][0] != int64(1) {
+		t.Errorf("unmasked value changed: %v", rows[0][0])
+	}
+	if !strings.Contains(mr.GetFileInfo(), "masked: email=hash,card=mask,phone=token,pesel=drop") {
+		t.Errorf("unexpected info: %s", mr.GetFileInfo())
+	}
+	for value, expected := range map[string]string{
+		"john@example.com":  "j***@example.com",
+		"łukasz@example.pl": "ł*****@example.pl",
+		"a@example.com":     "*@example.com",
+		"1234":              "**34",
+		"7":                 "*",
+		"AB-1234567":        "**-***4567",
+		"zażółć":            "***ółć",
+	} {
+		if s := maskString(value); s != expected {
+			t.Errorf("%s: expected %s, got %s", value, expected, s)
+		}
+	}
+}
+
+func TestReportPII(t *testing.T) {
+	r := newTestReport(t, piiRows(), ReportOptions{Collectors: CL_default | CL_pii})
+	expected := map[string]string{"id": "", "email": "email", "card": "credit_card", "phone": "phone", "pesel": "pesel"}
+	for f, pii := range expected {
+		if r.Field(f).PII != pii {
+			t.Errorf("%s: expected %q, got %q", f, pii, r.Field(f).PII)
+		}
+	}
+	if r.Field("email").Patterns != nil {
+		t.Error("patterns reported without CL_patterns")
+	}
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	if !strings.Contains(buf.String(), "PII: CREDIT_CARD") {
+		t.Errorf("PII not in the report:\n%s", buf.String())
+	}
+}
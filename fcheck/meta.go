@@ -0,0 +1,24 @@
+package fcheck
+
+import (
+	"bytes"
+	"io"
+)
+
+// FileMeta is the structure of a file read without profiling the data (see ReadParquetMeta and ReadAvroMeta)
+type FileMeta interface {
+	WriteText(w io.Writer)
+	IssueCount() int // missing or inconsistent statistics, corrupted blocks
+}
+
+// ReadFileMeta reads the metadata of an Avro or Parquet file, Avro is recognized by the magic bytes
+func ReadFileMeta(fileName string) (FileMeta, error) {
+	head, err := readHead(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(head, MAGIC_AVRO) {
+		return ReadAvroMeta(fileName)
+	}
+	return ReadParquetMeta(fileName)
+}
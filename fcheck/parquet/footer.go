@@ -0,0 +1,313 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// ReadFooter reads the file metadata from the end of the file, row groups are not read
+func ReadFooter(r io.ReaderAt, size int64) (*FileMetaData, error) {
+	if size < 12 {
+		return nil, errors.New("parquet: file too short")
+	}
+	var tail [8]byte
+	if _, err := r.ReadAt(tail[:], size-8); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(tail[4:], MAGIC) {
+		return nil, errors.New("parquet: no magic bytes at the end of the file (truncated or encrypted file)")
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerSize > size-12 {
+		return nil, fmt.Errorf("parquet: invalid footer size %d", footerSize)
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-8-footerSize); err != nil {
+		return nil, err
+	}
+	return ParseFileMetaData(footer)
+}
+
+// ParseFileMetaData decodes the thrift encoded FileMetaData
+func ParseFileMetaData(b []byte) (*FileMetaData, error) {
+	s, _, err := ReadStruct(b)
+	if err != nil {
+		return nil, err
+	}
+	m := &FileMetaData{Version: int32(s.Int(1, 0)), NumRows: s.Int(3, 0), CreatedBy: s.Str(6)}
+	for _, v := range s.List(2) {
+		e, _ := v.(Struct)
+		se := SchemaElement{Type: Type(e.Int(1, -1)), TypeLength: int32(e.Int(2, 0)), Repetition: Repetition(e.Int(3, -1)),
+			Name: e.Str(4), NumChildren: int32(e.Int(5, 0)), ConvertedType: ConvertedType(e.Int(6, -1)),
+			Scale: int32(e.Int(7, 0)), Precision: int32(e.Int(8, 0))}
+		for _, f := range e.Struct(10) {
+			se.LogicalType = logicalTypeNames[f.ID]
+		}
+		m.Schema = append(m.Schema, se)
+	}
+	for _, v := range s.List(4) {
+		rs, _ := v.(Struct)
+		rg := RowGroup{TotalByteSize: rs.Int(2, 0), NumRows: rs.Int(3, 0), FileOffset: rs.Int(5, 0),
+			TotalCompressedSize: rs.Int(6, 0), Ordinal: int16(rs.Int(7, int64(len(m.RowGroups))))}
+		for _, c := range rs.List(1) {
+			cs, _ := c.(Struct)
+			rg.Columns = append(rg.Columns, ColumnChunk{FileOffset: cs.Int(2, 0), Meta: parseColumnMetaData(cs.Struct(3))})
+		}
+		m.RowGroups = append(m.RowGroups, rg)
+	}
+	for _, v := range s.List(5) {
+		kv, _ := v.(Struct)
+		m.KeyValue = append(m.KeyValue, KeyValue{kv.Str(1), kv.Str(2)})
+	}
+	return m, nil
+}
+
+func parseColumnMetaData(s Struct) ColumnMetaData {
+	m := ColumnMetaData{Type: Type(s.Int(1, -1)), Codec: Codec(s.Int(4, -1)), NumValues: s.Int(5, 0),
+		TotalUncompressedSize: s.Int(6, 0), TotalCompressedSize: s.Int(7, 0), DataPageOffset: s.Int(9, 0),
+		DictionaryPageOffset: s.Int(11, 0)}
+	for _, e := range s.List(2) {
+		if x, ok := e.(int32); ok {
+			m.Encodings = append(m.Encodings, Encoding(x))
+		}
+	}
+	for _, p := range s.List(3) {
+		if x, ok := p.([]byte); ok {
+			m.Path = append(m.Path, string(x))
+		}
+	}
+	if s.Has(12) {
+		st := s.Struct(12)
+		m.Statistics = &Statistics{Max: st.Bytes(1), Min: st.Bytes(2), NullCount: st.Int(3, -1), DistinctCount: st.Int(4, -1),
+			MaxValue: st.Bytes(5), MinValue: st.Bytes(6)}
+	}
+	return m
+}
+
+// Leaf is a primitive column of the schema, column chunks of row groups are in the same order
+type Leaf struct {
+	Path     []string
+	Element  SchemaElement
+	Repeated bool // the column or any of its parents is repeated (num_values may differ from num_rows)
+}
+
+// Leaves returns the primitive columns of the schema, depth first
+func (m *FileMetaData) Leaves() []Leaf {
+	var leaves []Leaf
+	var walk func(i int, path []string, repeated bool) int
+	walk = func(i int, path []string, repeated bool) int {
+		e := m.Schema[i]
+		repeated = repeated || e.Repetition == R_repeated
+		if i > 0 {
+			path = append(append([]string(nil), path...), e.Name)
+		}
+		if e.NumChildren == 0 && i > 0 {
+			leaves = append(leaves, Leaf{path, e, repeated})
+			return i + 1
+		}
+		next := i + 1
+		for k := 0; k < int(e.NumChildren) && next < len(m.Schema); k++ {
+			next = walk(next, path, repeated)
+		}
+		return next
+	}
+	if len(m.Schema) > 0 {
+		walk(0, nil, false)
+	}
+	return leaves
+}
+
+// MinMax returns the min and max stats, deprecated is set when only the old (signed comparison) fields are present
+func (st *Statistics) MinMax() (min, max []byte, deprecated bool) {
+	if st.MinValue != nil || st.MaxValue != nil {
+		return st.MinValue, st.MaxValue, false
+	}
+	return st.Min, st.Max, st.Min != nil || st.Max != nil
+}
+
+func unsigned(e SchemaElement) bool {
+	return e.ConvertedType >= CT_uint_8 && e.ConvertedType <= CT_uint_64
+}
+
+// decodeStat decodes a plain encoded statistics value: int64, uint64, float64, bool or []byte
+func decodeStat(e SchemaElement, b []byte) (any, bool) {
+	switch e.Type {
+	case T_boolean:
+		if len(b) == 1 {
+			return b[0] != 0, true
+		}
+	case T_int32:
+		if len(b) == 4 {
+			if unsigned(e) {
+				return uint64(binary.LittleEndian.Uint32(b)), true
+			}
+			return int64(int32(binary.LittleEndian.Uint32(b))), true
+		}
+	case T_int64:
+		if len(b) == 8 {
+			if unsigned(e) {
+				return binary.LittleEndian.Uint64(b), true
+			}
+			return int64(binary.LittleEndian.Uint64(b)), true
+		}
+	case T_float:
+		if len(b) == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), true
+		}
+	case T_double:
+		if len(b) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b)), true
+		}
+	case T_byte_array, T_fixed_len_byte_array:
+		return b, true
+	}
+	return nil, false
+}
+
+// compareStats compares two statistics values, ok is false if they can't be compared (decimals, INT96)
+func compareStats(e SchemaElement, a, b []byte) (int, bool) {
+	if e.ConvertedType == CT_decimal || e.LogicalType == "DECIMAL" || e.Type == T_int96 {
+		return 0, false
+	}
+	x, ok1 := decodeStat(e, a)
+	y, ok2 := decodeStat(e, b)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	switch x := x.(type) {
+	case int64:
+		return cmp(x < y.(int64), x > y.(int64)), true
+	case uint64:
+		return cmp(x < y.(uint64), x > y.(uint64)), true
+	case float64:
+		return cmp(x < y.(float64), x > y.(float64)), true
+	case bool:
+		return cmp(!x && y.(bool), x && !y.(bool)), true
+	case []byte:
+		return bytes.Compare(x, y.([]byte)), true
+	}
+	return 0, false
+}
+
+func cmp(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// MAX_STAT_LEN max length of formatted string statistics
+const MAX_STAT_LEN = 40
+
+// FormatStat formats a statistics value of the column for display
+func FormatStat(e SchemaElement, b []byte) string {
+	v, ok := decodeStat(e, b)
+	if !ok {
+		return "0x" + hex.EncodeToString(b)
+	}
+	switch v := v.(type) {
+	case int64:
+		if e.ConvertedType == CT_date || e.LogicalType == "DATE" {
+			return time.Unix(v*86400, 0).UTC().Format("2006-01-02")
+		}
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []byte:
+		if utf8.Valid(v) && e.Type == T_byte_array {
+			s := []rune(string(v))
+			if len(s) > MAX_STAT_LEN {
+				return strconv.Quote(string(s[:MAX_STAT_LEN])) + "..."
+			}
+			return strconv.Quote(string(s))
+		}
+		if len(v) > MAX_STAT_LEN/2 {
+			return "0x" + hex.EncodeToString(v[:MAX_STAT_LEN/2]) + "..."
+		}
+		return "0x" + hex.EncodeToString(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// Issue is a problem found in the metadata, RowGroup and Column are -1 for the whole file / row group
+type Issue struct {
+	RowGroup int
+	Column   int
+	Message  string
+}
+
+// Check looks for missing or inconsistent statistics, row counts and chunk offsets
+func (m *FileMetaData) Check(fileSize int64) []Issue {
+	var issues []Issue
+	leaves := m.Leaves()
+	var rows int64
+	for i, rg := range m.RowGroups {
+		rows += rg.NumRows
+		if len(rg.Columns) != len(leaves) {
+			issues = append(issues, Issue{i, -1, fmt.Sprintf("%d column chunks, the schema has %d columns", len(rg.Columns), len(leaves))})
+		}
+		for k, c := range rg.Columns {
+			add := func(format string, args ...any) {
+				issues = append(issues, Issue{i, k, fmt.Sprintf(format, args...)})
+			}
+			md := c.Meta
+			// the chunk starts with the dictionary page, if any
+			start := md.DataPageOffset
+			if md.DictionaryPageOffset > 0 && md.DictionaryPageOffset < start {
+				start = md.DictionaryPageOffset
+			}
+			if start < int64(len(MAGIC)) || start+md.TotalCompressedSize > fileSize {
+				add("column chunk (offset %d, %d bytes) outside of the file (%d bytes)", start, md.TotalCompressedSize, fileSize)
+			}
+			if k >= len(leaves) {
+				continue
+			}
+			leaf := leaves[k]
+			if !leaf.Repeated && md.NumValues != rg.NumRows {
+				add("num_values %d != row group num_rows %d", md.NumValues, rg.NumRows)
+			}
+			st := md.Statistics
+			if st == nil {
+				add("no statistics")
+				continue
+			}
+			if st.NullCount < 0 {
+				add("no null_count")
+			} else if st.NullCount > md.NumValues {
+				add("null_count %d > num_values %d", st.NullCount, md.NumValues)
+			}
+			min, max, deprecated := st.MinMax()
+			switch {
+			case min == nil || max == nil:
+				if st.NullCount < 0 || st.NullCount < md.NumValues {
+					add("no min/max")
+				}
+			case deprecated && (leaf.Element.Type == T_byte_array || leaf.Element.Type == T_fixed_len_byte_array):
+				add("only deprecated min/max (signed byte comparison, unreliable for strings)")
+			default:
+				if c, ok := compareStats(leaf.Element, min, max); ok && c > 0 {
+					add("min %s > max %s", FormatStat(leaf.Element, min), FormatStat(leaf.Element, max))
+				}
+			}
+		}
+	}
+	if rows != m.NumRows {
+		issues = append(issues, Issue{-1, -1, fmt.Sprintf("row groups have %d rows, the file num_rows is %d", rows, m.NumRows)})
+	}
+	return issues
+}
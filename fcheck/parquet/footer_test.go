@@ -0,0 +1,138 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T) []byte {
+	var buf bytes.Buffer
+	cols := []Column{{"id", T_int64, true, false}, {"amount", T_double, false, false}, {"country", T_byte_array, false, true}}
+	w, err := NewWriter(&buf, cols, WriterOptions{RowGroupSize: 2000, KeyValue: []KeyValue{{"origin", "test"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		var amount any = float64(i)
+		if i%5 == 0 {
+			amount = nil
+		}
+		if err := w.Write([]any{int64(i), amount, []string{"PL", "DE", "FR"}[i%3]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadFooter(t *testing.T) {
+	b := writeTestFile(t)
+	m, err := ReadFooter(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NumRows != 500 || len(m.RowGroups) < 2 || len(m.KeyValue) != 1 || m.KeyValue[0].Value != "test" {
+		t.Fatalf("unexpected metadata: %+v", m)
+	}
+	leaves := m.Leaves()
+	if len(leaves) != 3 || leaves[2].Path[0] != "country" || leaves[2].Element.LogicalType != "STRING" || leaves[0].Element.Repetition != R_required {
+		t.Fatalf("unexpected leaves: %+v", leaves)
+	}
+	c := m.RowGroups[0].Columns[1].Meta
+	min, max, deprecated := c.Statistics.MinMax()
+	if c.Codec != C_uncompressed || c.Path[0] != "amount" || deprecated || FormatStat(leaves[1].Element, min) != "1" ||
+		c.Statistics.NullCount == 0 || FormatStat(leaves[1].Element, max) == "" {
+		t.Errorf("unexpected column metadata: %+v", c)
+	}
+	min, max, _ = m.RowGroups[0].Columns[2].Meta.Statistics.MinMax()
+	if FormatStat(leaves[2].Element, min) != `"DE"` || FormatStat(leaves[2].Element, max) != `"PL"` {
+		t.Errorf("unexpected country stats: %s %s", min, max)
+	}
+	if issues := m.Check(int64(len(b))); len(issues) != 0 {
+		t.Errorf("unexpected issues: %v", issues)
+	}
+	for _, size := range []int{len(b) - 1, 10} {
+		if _, err := ReadFooter(bytes.NewReader(b[:size]), int64(size)); err == nil {
+			t.Errorf("expected an error for %d bytes", size)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	b := writeTestFile(t)
+	m, _ := ReadFooter(bytes.NewReader(b), int64(len(b)))
+	m.NumRows++
+	rg := m.RowGroups[0]
+	rg.Columns[0].Meta.Statistics = nil
+	min := rg.Columns[1].Meta.Statistics.MinValue
+	rg.Columns[1].Meta.Statistics.MinValue = rg.Columns[1].Meta.Statistics.MaxValue
+	rg.Columns[1].Meta.Statistics.MaxValue = min
+	st := rg.Columns[2].Meta.Statistics
+	st.Min, st.Max, st.MinValue, st.MaxValue = st.MinValue, st.MaxValue, nil, nil
+	m.RowGroups[1].Columns[0].Meta.DataPageOffset = int64(len(b))
+	var msgs []string
+	for _, issue := range m.Check(int64(len(b))) {
+		msgs = append(msgs, issue.Message)
+	}
+	got := strings.Join(msgs, "\n")
+	for _, s := range []string{"no statistics", "> max 1", "only deprecated min/max", "outside of the file", "the file num_rows is 501"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("expected %q in\n%s", s, got)
+		}
+	}
+}
+
+func TestCheckDictionaryChunk(t *testing.T) {
+	// the last column is dictionary encoded, its chunk ends at the footer
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{{Name: "id", Type: T_int64}, {Name: "name", Type: T_byte_array, Dictionary: true}}, WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3000; i++ {
+		if err := w.Write([]any{int64(i), fmt.Sprintf("name %d", i%2000)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	m, err := ReadFooter(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.RowGroups[0].Columns[1].Meta.DictionaryPageOffset == 0 {
+		t.Fatal("expected a dictionary page")
+	}
+	if issues := m.Check(int64(len(b))); len(issues) != 0 {
+		t.Errorf("unexpected issues: %v", issues)
+	}
+}
+
+func TestFormatStat(t *testing.T) {
+	date := SchemaElement{Type: T_int32, ConvertedType: CT_date}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, 19000)
+	for _, tt := range []struct {
+		e        SchemaElement
+		b        []byte
+		expected string
+	}{
+		{date, b, "2022-01-08"},
+		{SchemaElement{Type: T_int32, ConvertedType: -1}, []byte{0xff, 0xff, 0xff, 0xff}, "-1"},
+		{SchemaElement{Type: T_int32, ConvertedType: CT_uint_64 - 1}, []byte{0xff, 0xff, 0xff, 0xff}, "4294967295"},
+		{SchemaElement{Type: T_byte_array}, []byte(strings.Repeat("x", 50)), `"` + strings.Repeat("x", MAX_STAT_LEN) + `"...`},
+		{SchemaElement{Type: T_byte_array}, []byte{0xff, 0x01}, "0xff01"},
+		{SchemaElement{Type: T_int64}, []byte{1}, "0x01"},
+	} {
+		if s := FormatStat(tt.e, tt.b); s != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, s)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package parquet
+
+// Parquet file metadata (parquet.thrift), only the parts used by gcf. Optional enum fields are -1 when not set.
+
+// MAGIC at the beginning and at the end of a Parquet file
+var MAGIC = []byte("PAR1")
+
+// Enum Type of values on disk (physical type)
+type Type int32
+
+const (
+	T_boolean Type = iota
+	T_int32
+	T_int64
+	T_int96
+	T_float
+	T_double
+	T_byte_array
+	T_fixed_len_byte_array
+)
+
+func (t Type) String() string {
+	names := []string{"BOOLEAN", "INT32", "INT64", "INT96", "FLOAT", "DOUBLE", "BYTE_ARRAY", "FIXED_LEN_BYTE_ARRAY"}
+	if t >= 0 && int(t) < len(names) {
+		return names[t]
+	}
+	return "UNKNOWN"
+}
+
+// Enum Repetition of a field
+type Repetition int32
+
+const (
+	R_required Repetition = iota
+	R_optional
+	R_repeated
+)
+
+func (r Repetition) String() string {
+	switch r {
+	case R_required:
+		return "required"
+	case R_optional:
+		return "optional"
+	case R_repeated:
+		return "repeated"
+	}
+	return "unknown"
+}
+
+// Enum ConvertedType (the old logical types)
+type ConvertedType int32
+
+const (
+	CT_utf8    ConvertedType = 0
+	CT_decimal ConvertedType = 5
+	CT_date    ConvertedType = 6
+	CT_uint_8  ConvertedType = 11
+	CT_uint_64 ConvertedType = 14
+)
+
+var convertedTypeNames = []string{"UTF8", "MAP", "MAP_KEY_VALUE", "LIST", "ENUM", "DECIMAL", "DATE", "TIME_MILLIS",
+	"TIME_MICROS", "TIMESTAMP_MILLIS", "TIMESTAMP_MICROS", "UINT_8", "UINT_16", "UINT_32", "UINT_64", "INT_8", "INT_16",
+	"INT_32", "INT_64", "JSON", "BSON", "INTERVAL"}
+
+func (t ConvertedType) String() string {
+	if t >= 0 && int(t) < len(convertedTypeNames) {
+		return convertedTypeNames[t]
+	}
+	return "UNKNOWN"
+}
+
+// logical type names by the LogicalType union field id
+var logicalTypeNames = map[int16]string{1: "STRING", 2: "MAP", 3: "LIST", 4: "ENUM", 5: "DECIMAL", 6: "DATE", 7: "TIME",
+	8: "TIMESTAMP", 10: "INTEGER", 11: "UNKNOWN", 12: "JSON", 13: "BSON", 14: "UUID", 15: "FLOAT16"}
+
+// Enum Encoding of values and levels in pages
+type Encoding int32
+
+const (
+	E_plain                   Encoding = 0
+	E_plain_dictionary        Encoding = 2
+	E_rle                     Encoding = 3
+	E_bit_packed              Encoding = 4
+	E_delta_binary_packed     Encoding = 5
+	E_delta_length_byte_array Encoding = 6
+	E_delta_byte_array        Encoding = 7
+	E_rle_dictionary          Encoding = 8
+	E_byte_stream_split       Encoding = 9
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case E_plain:
+		return "PLAIN"
+	case E_plain_dictionary:
+		return "PLAIN_DICTIONARY"
+	case E_rle:
+		return "RLE"
+	case E_bit_packed:
+		return "BIT_PACKED"
+	case E_delta_binary_packed:
+		return "DELTA_BINARY_PACKED"
+	case E_delta_length_byte_array:
+		return "DELTA_LENGTH_BYTE_ARRAY"
+	case E_delta_byte_array:
+		return "DELTA_BYTE_ARRAY"
+	case E_rle_dictionary:
+		return "RLE_DICTIONARY"
+	case E_byte_stream_split:
+		return "BYTE_STREAM_SPLIT"
+	}
+	return "UNKNOWN"
+}
+
+// Enum Codec compression of pages
+type Codec int32
+
+const (
+	C_uncompressed Codec = iota
+	C_snappy
+	C_gzip
+	C_lzo
+	C_brotli
+	C_lz4
+	C_zstd
+	C_lz4_raw
+)
+
+func (c Codec) String() string {
+	names := []string{"UNCOMPRESSED", "SNAPPY", "GZIP", "LZO", "BROTLI", "LZ4", "ZSTD", "LZ4_RAW"}
+	if c >= 0 && int(c) < len(names) {
+		return names[c]
+	}
+	return "UNKNOWN"
+}
+
+// Enum PageType
+type PageType int32
+
+const (
+	P_data PageType = iota
+	P_index
+	P_dictionary
+	P_data_v2
+)
+
+type FileMetaData struct {
+	Version   int32
+	Schema    []SchemaElement // the root (with NumChildren) followed by the fields, depth first
+	NumRows   int64
+	RowGroups []RowGroup
+	KeyValue  []KeyValue
+	CreatedBy string
+}
+
+type SchemaElement struct {
+	Type          Type // -1 for groups
+	TypeLength    int32
+	Repetition    Repetition
+	Name          string
+	NumChildren   int32
+	ConvertedType ConvertedType // -1 if none
+	Scale         int32
+	Precision     int32
+	LogicalType   string // STRING, DATE, TIMESTAMP, ... "" if none (only STRING is written)
+}
+
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+type RowGroup struct {
+	Columns             []ColumnChunk
+	TotalByteSize       int64 // uncompressed
+	NumRows             int64
+	FileOffset          int64
+	TotalCompressedSize int64
+	Ordinal             int16
+}
+
+type ColumnChunk struct {
+	FileOffset int64
+	Meta       ColumnMetaData
+}
+
+type ColumnMetaData struct {
+	Type                  Type
+	Encodings             []Encoding
+	Path                  []string
+	Codec                 Codec
+	NumValues             int64
+	TotalUncompressedSize int64
+	TotalCompressedSize   int64
+	DataPageOffset        int64
+	DictionaryPageOffset  int64 // 0 if there's no dictionary page
+	Statistics            *Statistics
+}
+
+// Statistics of a column chunk, Min and Max are the deprecated signed comparison ones
+type Statistics struct {
+	Max, Min           []byte
+	NullCount          int64 // -1 if not set
+	DistinctCount      int64 // -1 if not set
+	MaxValue, MinValue []byte
+}
+
+type PageHeader struct {
+	Type             PageType
+	UncompressedSize int32
+	CompressedSize   int32
+	DataPage         *DataPageHeader
+	DictionaryPage   *DictionaryPageHeader
+}
+
+type DataPageHeader struct {
+	NumValues               int32
+	Encoding                Encoding
+	DefinitionLevelEncoding Encoding
+	RepetitionLevelEncoding Encoding
+}
+
+type DictionaryPageHeader struct {
+	NumValues int32
+	Encoding  Encoding
+}
+
+func (m *FileMetaData) thrift() Struct {
+	schema := make([]Struct, len(m.Schema))
+	for i, e := range m.Schema {
+		schema[i] = e.thrift()
+	}
+	rowGroups := make([]Struct, len(m.RowGroups))
+	columnOrders := []Struct{}
+	for i, rg := range m.RowGroups {
+		rowGroups[i] = rg.thrift()
+	}
+	for i := 1; i < len(m.Schema); i++ {
+		// TYPE_ORDER (min_value/max_value are compared as the logical type)
+		columnOrders = append(columnOrders, Struct{{1, Struct{}}})
+	}
+	s := Struct{{1, m.Version}, {2, schema}, {3, m.NumRows}, {4, rowGroups}}
+	if len(m.KeyValue) > 0 {
+		kv := make([]Struct, len(m.KeyValue))
+		for i, p := range m.KeyValue {
+			kv[i] = Struct{{1, p.Key}, {2, p.Value}}
+		}
+		s = append(s, Field{5, kv})
+	}
+	if m.CreatedBy != "" {
+		s = append(s, Field{6, m.CreatedBy})
+	}
+	return append(s, Field{7, columnOrders})
+}
+
+func (e *SchemaElement) thrift() Struct {
+	var s Struct
+	if e.Type >= 0 {
+		s = append(s, Field{1, int32(e.Type)})
+	}
+	if e.NumChildren == 0 {
+		s = append(s, Field{3, int32(e.Repetition)})
+	}
+	s = append(s, Field{4, e.Name})
+	if e.NumChildren > 0 {
+		s = append(s, Field{5, e.NumChildren})
+	}
+	if e.ConvertedType >= 0 {
+		s = append(s, Field{6, int32(e.ConvertedType)})
+	}
+	if e.LogicalType == "STRING" {
+		s = append(s, Field{10, Struct{{1, Struct{}}}})
+	}
+	return s
+}
+
+func (rg *RowGroup) thrift() Struct {
+	columns := make([]Struct, len(rg.Columns))
+	for i, c := range rg.Columns {
+		columns[i] = Struct{{2, c.FileOffset}, {3, c.Meta.thrift()}}
+	}
+	return Struct{{1, columns}, {2, rg.TotalByteSize}, {3, rg.NumRows}, {5, rg.FileOffset}, {6, rg.TotalCompressedSize}, {7, rg.Ordinal}}
+}
+
+func (m *ColumnMetaData) thrift() Struct {
+	encodings := make([]int32, len(m.Encodings))
+	for i, e := range m.Encodings {
+		encodings[i] = int32(e)
+	}
+	s := Struct{{1, int32(m.Type)}, {2, encodings}, {3, m.Path}, {4, int32(m.Codec)}, {5, m.NumValues},
+		{6, m.TotalUncompressedSize}, {7, m.TotalCompressedSize}, {9, m.DataPageOffset}}
+	if m.DictionaryPageOffset > 0 {
+		s = append(s, Field{11, m.DictionaryPageOffset})
+	}
+	if st := m.Statistics; st != nil {
+		var ts Struct
+		if st.Max != nil {
+			ts = append(ts, Field{1, st.Max}, Field{2, st.Min})
+		}
+		if st.NullCount >= 0 {
+			ts = append(ts, Field{3, st.NullCount})
+		}
+		if st.DistinctCount >= 0 {
+			ts = append(ts, Field{4, st.DistinctCount})
+		}
+		if st.MaxValue != nil {
+			ts = append(ts, Field{5, st.MaxValue}, Field{6, st.MinValue})
+		}
+		s = append(s, Field{12, ts})
+	}
+	return s
+}
+
+func (h *PageHeader) thrift() Struct {
+	s := Struct{{1, int32(h.Type)}, {2, h.UncompressedSize}, {3, h.CompressedSize}}
+	if d := h.DataPage; d != nil {
+		s = append(s, Field{5, Struct{{1, d.NumValues}, {2, int32(d.Encoding)}, {3, int32(d.DefinitionLevelEncoding)}, {4, int32(d.RepetitionLevelEncoding)}}})
+	}
+	if d := h.DictionaryPage; d != nil {
+		s = append(s, Field{7, Struct{{1, d.NumValues}, {2, int32(d.Encoding)}}})
+	}
+	return s
+}
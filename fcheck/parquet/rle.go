@@ -0,0 +1,58 @@
+package parquet
+
+// RLE / bit-packing hybrid encoding of definition levels and dictionary indices:
+// runs of at least 8 equal values are RLE, everything else is bit-packed in groups of 8
+
+// bitWidth returns the number of bits needed for values up to max
+func bitWidth(max uint32) int {
+	n := 0
+	for ; max > 0; max >>= 1 {
+		n++
+	}
+	return n
+}
+
+func runLength(values []uint32, i int) int {
+	n := 1
+	for i+n < len(values) && values[i+n] == values[i] {
+		n++
+	}
+	return n
+}
+
+// appendHybrid appends the encoded values (without the length prefix)
+func appendHybrid(b []byte, values []uint32, width int) []byte {
+	valueBytes := (width + 7) / 8
+	for i := 0; i < len(values); {
+		if run := runLength(values, i); run >= 8 {
+			b = appendUvarint(b, uint64(run)<<1)
+			for k, v := 0, values[i]; k < valueBytes; k, v = k+1, v>>8 {
+				b = append(b, byte(v))
+			}
+			i += run
+			continue
+		}
+		// groups of 8 values until a run of 8 starts, the last group is padded with zeros
+		j := i
+		for j < len(values) && (j == i || runLength(values, j) < 8) {
+			j += 8
+		}
+		groups := (j - i) / 8
+		b = appendUvarint(b, uint64(groups)<<1|1)
+		var acc uint64
+		bits := 0
+		for k := i; k < j; k++ {
+			var v uint32
+			if k < len(values) {
+				v = values[k]
+			}
+			acc |= uint64(v) << bits
+			for bits += width; bits >= 8; bits -= 8 {
+				b = append(b, byte(acc))
+				acc >>= 8
+			}
+		}
+		i = j
+	}
+	return b
+}
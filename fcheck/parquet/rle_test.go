@@ -0,0 +1,89 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// decodeHybrid decodes n values, it's the reverse of appendHybrid
+func decodeHybrid(b []byte, width, n int) ([]uint32, int, bool) {
+	values := make([]uint32, 0, n)
+	valueBytes := (width + 7) / 8
+	pos := 0
+	for len(values) < n {
+		h, k := binary.Uvarint(b[pos:])
+		if k <= 0 {
+			return values, pos, false
+		}
+		pos += k
+		if h&1 == 0 {
+			if pos+valueBytes > len(b) {
+				return values, pos, false
+			}
+			var v uint32
+			for i := 0; i < valueBytes; i++ {
+				v |= uint32(b[pos+i]) << (8 * i)
+			}
+			pos += valueBytes
+			for i := uint64(0); i < h>>1 && len(values) < n; i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		count := int(h>>1) * 8
+		size := count * width / 8
+		if pos+size > len(b) {
+			return values, pos, false
+		}
+		var acc uint64
+		bits, next := 0, pos
+		for i := 0; i < count; i++ {
+			for bits < width {
+				acc |= uint64(b[next]) << bits
+				next++
+				bits += 8
+			}
+			if len(values) < n {
+				values = append(values, uint32(acc&(1<<width-1)))
+			}
+			acc >>= width
+			bits -= width
+		}
+		pos += size
+	}
+	return values, pos, true
+}
+
+func TestHybrid(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, width := range []int{0, 1, 3, 8, 13, 20} {
+		for _, n := range []int{0, 1, 7, 8, 9, 100, 1000} {
+			values := make([]uint32, n)
+			for i := range values {
+				if width > 0 && rnd.Intn(3) == 0 {
+					values[i] = uint32(rnd.Intn(1 << width))
+				} else if i > 0 {
+					values[i] = values[i-1]
+				}
+			}
+			b := appendHybrid(nil, values, width)
+			decoded, size, ok := decodeHybrid(b, width, n)
+			if !ok || size != len(b) || len(decoded) != n {
+				t.Fatalf("width %d, n %d: decoding failed, %d of %d bytes, %d values", width, n, size, len(b), len(decoded))
+			}
+			for i := range values {
+				if values[i] != decoded[i] {
+					t.Fatalf("width %d, n %d: value %d: expected %d, got %d", width, n, i, values[i], decoded[i])
+				}
+			}
+		}
+	}
+	// 8 equal values are a single RLE run: header (8 << 1) and the value
+	if b := appendHybrid(nil, []uint32{5, 5, 5, 5, 5, 5, 5, 5}, 3); len(b) != 2 || b[0] != 16 || b[1] != 5 {
+		t.Errorf("unexpected RLE run: %v", b)
+	}
+	if bitWidth(0) != 0 || bitWidth(1) != 1 || bitWidth(255) != 8 || bitWidth(256) != 9 {
+		t.Error("unexpected bit widths")
+	}
+}
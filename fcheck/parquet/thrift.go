@@ -0,0 +1,372 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Thrift compact protocol, just enough for the Parquet metadata (maps and sets are skipped when read).
+// Structs are written and read as lists of fields, see Struct.
+
+// compact protocol type ids
+const (
+	tStop        = 0
+	tBoolTrue    = 1
+	tBoolFalse   = 2
+	tByte        = 3
+	tI16         = 4
+	tI32         = 5
+	tI64         = 6
+	tDouble      = 7
+	tBinary      = 8
+	tList        = 9
+	tSet         = 10
+	tMap         = 11
+	tStruct      = 12
+	maxThriftLen = 1 << 28 // max length of a string or list, protects from corrupted lengths
+)
+
+// Field is a struct field, Value is one of: bool, int8, int16, int32, int64, float64, string, []byte,
+// Struct or a list: []int32, []int64, []string, []Struct ([]any when read)
+type Field struct {
+	ID    int16
+	Value any
+}
+
+// Struct is a thrift struct, fields must be in ascending ID order when written
+type Struct []Field
+
+// Get returns the value of the field or nil
+func (s Struct) Get(id int16) any {
+	for _, f := range s {
+		if f.ID == id {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// Int returns the integer field (any int type) or def if missing
+func (s Struct) Int(id int16, def int64) int64 {
+	switch v := s.Get(id).(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return def
+}
+
+func (s Struct) Str(id int16) string {
+	if b, ok := s.Get(id).([]byte); ok {
+		return string(b)
+	}
+	return ""
+}
+
+func (s Struct) Bytes(id int16) []byte {
+	b, _ := s.Get(id).([]byte)
+	return b
+}
+
+func (s Struct) Struct(id int16) Struct {
+	st, _ := s.Get(id).(Struct)
+	return st
+}
+
+func (s Struct) List(id int16) []any {
+	l, _ := s.Get(id).([]any)
+	return l
+}
+
+func (s Struct) Has(id int16) bool {
+	return s.Get(id) != nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+func thriftType(v any) byte {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return tBoolTrue
+		}
+		return tBoolFalse
+	case int8:
+		return tByte
+	case int16:
+		return tI16
+	case int32:
+		return tI32
+	case int64:
+		return tI64
+	case float64:
+		return tDouble
+	case string, []byte:
+		return tBinary
+	case Struct:
+		return tStruct
+	case []int32, []int64, []string, []Struct:
+		return tList
+	}
+	panic(fmt.Sprintf("parquet: unsupported thrift value %T", v))
+}
+
+// AppendStruct appends the compact encoding of the struct
+func AppendStruct(b []byte, s Struct) []byte {
+	var last int16
+	for _, f := range s {
+		t := thriftType(f.Value)
+		if delta := f.ID - last; delta > 0 && delta <= 15 {
+			b = append(b, byte(delta)<<4|t)
+		} else {
+			b = append(b, t)
+			b = appendVarint(b, int64(f.ID))
+		}
+		last = f.ID
+		if t != tBoolTrue && t != tBoolFalse {
+			b = appendValue(b, f.Value)
+		}
+	}
+	return append(b, tStop)
+}
+
+func appendListHeader(b []byte, n int, t byte) []byte {
+	if n < 15 {
+		return append(b, byte(n)<<4|t)
+	}
+	return appendUvarint(append(b, 0xf0|t), uint64(n))
+}
+
+func appendValue(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return append(b, 1)
+		}
+		return append(b, 2)
+	case int8:
+		return append(b, byte(v))
+	case int16:
+		return appendVarint(b, int64(v))
+	case int32:
+		return appendVarint(b, int64(v))
+	case int64:
+		return appendVarint(b, v)
+	case float64:
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+		return append(b, tmp[:]...)
+	case string:
+		return append(appendUvarint(b, uint64(len(v))), v...)
+	case []byte:
+		return append(appendUvarint(b, uint64(len(v))), v...)
+	case Struct:
+		return AppendStruct(b, v)
+	case []int32:
+		b = appendListHeader(b, len(v), tI32)
+		for _, x := range v {
+			b = appendVarint(b, int64(x))
+		}
+	case []int64:
+		b = appendListHeader(b, len(v), tI64)
+		for _, x := range v {
+			b = appendVarint(b, x)
+		}
+	case []string:
+		b = appendListHeader(b, len(v), tBinary)
+		for _, x := range v {
+			b = append(appendUvarint(b, uint64(len(x))), x...)
+		}
+	case []Struct:
+		b = appendListHeader(b, len(v), tStruct)
+		for _, x := range v {
+			b = AppendStruct(b, x)
+		}
+	}
+	return b
+}
+
+var errThriftEOF = errors.New("parquet: unexpected end of thrift data")
+
+// thriftReader decodes compact protocol data
+type thriftReader struct {
+	b     []byte
+	pos   int
+	depth int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, errThriftEOF
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, errThriftEOF
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	v, n := binary.Varint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, errThriftEOF
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) length() (int, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > maxThriftLen || int(n) > len(r.b)-r.pos {
+		return 0, fmt.Errorf("parquet: invalid thrift length %d", n)
+	}
+	return int(n), nil
+}
+
+// ReadStruct decodes a struct from the beginning of b and returns the number of bytes used
+func ReadStruct(b []byte) (Struct, int, error) {
+	r := &thriftReader{b: b}
+	s, err := r.readStruct()
+	return s, r.pos, err
+}
+
+func (r *thriftReader) readStruct() (Struct, error) {
+	if r.depth++; r.depth > 64 {
+		return nil, errors.New("parquet: thrift structs nested too deep")
+	}
+	defer func() { r.depth-- }()
+	var s Struct
+	var last int16
+	for {
+		h, err := r.byte()
+		if err != nil {
+			return s, err
+		}
+		if h == tStop {
+			return s, nil
+		}
+		t := h & 0x0f
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v, err := r.varint()
+			if err != nil {
+				return s, err
+			}
+			id = int16(v)
+		}
+		last = id
+		var v any
+		switch t {
+		case tBoolTrue:
+			v = true
+		case tBoolFalse:
+			v = false
+		default:
+			if v, err = r.readValue(t); err != nil {
+				return s, err
+			}
+		}
+		s = append(s, Field{id, v})
+	}
+}
+
+func (r *thriftReader) readValue(t byte) (any, error) {
+	switch t {
+	case tBoolTrue, tBoolFalse:
+		// in lists bools are a byte each
+		b, err := r.byte()
+		return b == 1, err
+	case tByte:
+		b, err := r.byte()
+		return int8(b), err
+	case tI16:
+		v, err := r.varint()
+		return int16(v), err
+	case tI32:
+		v, err := r.varint()
+		return int32(v), err
+	case tI64:
+		return r.varint()
+	case tDouble:
+		if r.pos+8 > len(r.b) {
+			return nil, errThriftEOF
+		}
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos-8:])), nil
+	case tBinary:
+		n, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		r.pos += n
+		return r.b[r.pos-n : r.pos], nil
+	case tStruct:
+		return r.readStruct()
+	case tList, tSet:
+		h, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		n := int(h >> 4)
+		if n == 15 {
+			if n, err = r.length(); err != nil {
+				return nil, err
+			}
+		}
+		l := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := r.readValue(h & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	case tMap:
+		n, err := r.length()
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		kv, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < 2*n; i++ {
+			t := kv >> 4
+			if i%2 == 1 {
+				t = kv & 0x0f
+			}
+			if _, err := r.readValue(t); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("parquet: invalid thrift type %d", t)
+}
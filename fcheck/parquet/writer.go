@@ -0,0 +1,462 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaults of WriterOptions
+const (
+	ROW_GROUP_SIZE   = 64 << 20 // approx. bytes of a row group (compressed pages)
+	PAGE_SIZE        = 1 << 20  // approx. bytes of a data page (before compression)
+	DICTIONARY_LIMIT = 10000    // max distinct values of a dictionary encoded column chunk
+	MAX_STATS_SIZE   = 4096     // string min/max longer than this are not stored
+)
+
+// Column of a flat schema, values passed to Writer.Write are nil, int64 (T_int64), float64 (T_double)
+// or string / []byte (T_byte_array, written as the STRING logical type)
+type Column struct {
+	Name       string
+	Type       Type
+	Required   bool // optional (nullable) by default
+	Dictionary bool // dictionary encoded (T_byte_array) in the column chunks where it's smaller than plain
+}
+
+type WriterOptions struct {
+	Codec        Codec // C_uncompressed, C_snappy, C_gzip or C_zstd
+	Level        int   // gzip (1-9) or zstd (1-22) level, 0 for the default
+	RowGroupSize int   // ROW_GROUP_SIZE if 0
+	PageSize     int   // PAGE_SIZE if 0
+	// DictionaryLimit is the max number of distinct strings in the dictionary of a column chunk, if a Dictionary
+	// column has more (or the dictionary gets bigger than PageSize) the rest of the chunk is plain encoded.
+	// The chunk is plain encoded also if the dictionary and the indices of its first page aren't smaller than
+	// the plain values. DICTIONARY_LIMIT if 0, negative disables dictionaries.
+	DictionaryLimit int
+	CreatedBy       string
+	KeyValue        []KeyValue
+}
+
+// Writer writes rows to a Parquet file, a row group is kept in memory (as compressed pages) until
+// it reaches RowGroupSize
+type Writer struct {
+	w         io.Writer
+	offset    int64
+	opts      WriterOptions
+	compress  func([]byte) ([]byte, error)
+	columns   []*columnWriter
+	rows      int64 // in the current row group
+	meta      FileMetaData
+	converted []any // Write buffer
+}
+
+// NewWriter writes the magic bytes and returns the writer, Close must be called to write the footer
+func NewWriter(w io.Writer, columns []Column, opts WriterOptions) (*Writer, error) {
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = ROW_GROUP_SIZE
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = PAGE_SIZE
+	}
+	if opts.DictionaryLimit == 0 {
+		opts.DictionaryLimit = DICTIONARY_LIMIT
+	}
+	pw := &Writer{w: w, opts: opts, converted: make([]any, len(columns))}
+	var err error
+	if pw.compress, err = compressor(opts.Codec, opts.Level); err != nil {
+		return nil, err
+	}
+	pw.meta = FileMetaData{Version: 1, CreatedBy: opts.CreatedBy, KeyValue: opts.KeyValue}
+	pw.meta.Schema = []SchemaElement{{Type: -1, Name: "schema", NumChildren: int32(len(columns)), ConvertedType: -1}}
+	for _, c := range columns {
+		e := SchemaElement{Type: c.Type, Repetition: R_optional, Name: c.Name, ConvertedType: -1}
+		if c.Required {
+			e.Repetition = R_required
+		}
+		switch c.Type {
+		case T_int64, T_double:
+		case T_byte_array:
+			e.ConvertedType, e.LogicalType = CT_utf8, "STRING"
+		default:
+			return nil, fmt.Errorf("parquet: column %s: type %s is not supported", c.Name, c.Type)
+		}
+		pw.meta.Schema = append(pw.meta.Schema, e)
+		pw.columns = append(pw.columns, newColumnWriter(c, pw))
+	}
+	return pw, pw.write(MAGIC)
+}
+
+func compressor(codec Codec, level int) (func([]byte) ([]byte, error), error) {
+	switch codec {
+	case C_uncompressed:
+		return func(b []byte) ([]byte, error) { return b, nil }, nil
+	case C_snappy:
+		return func(b []byte) ([]byte, error) { return snappy.Encode(nil, b), nil }, nil
+	case C_gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+			return nil, err
+		}
+		return func(b []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			gw, _ := gzip.NewWriterLevel(&buf, level)
+			if _, err := gw.Write(b); err != nil {
+				return nil, err
+			}
+			err := gw.Close()
+			return buf.Bytes(), err
+		}, nil
+	case C_zstd:
+		zlevel := zstd.SpeedDefault
+		if level != 0 {
+			zlevel = zstd.EncoderLevelFromZstd(level)
+		}
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zlevel))
+		if err != nil {
+			return nil, err
+		}
+		return func(b []byte) ([]byte, error) { return enc.EncodeAll(b, nil), nil }, nil
+	}
+	return nil, fmt.Errorf("parquet: codec %s is not supported", codec)
+}
+
+func (pw *Writer) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// Write adds a row, nothing is written if any value has the wrong type (or is null for a required column)
+func (pw *Writer) Write(row []any) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("parquet: %d values for %d columns", len(row), len(pw.columns))
+	}
+	for i, c := range pw.columns {
+		v := row[i]
+		switch x := v.(type) {
+		case nil:
+			if c.col.Required {
+				return fmt.Errorf("parquet: null value of required column %s", c.col.Name)
+			}
+		case int64:
+			if c.col.Type != T_int64 {
+				return fmt.Errorf("parquet: int64 value of %s column %s", c.col.Type, c.col.Name)
+			}
+		case float64:
+			if c.col.Type != T_double {
+				return fmt.Errorf("parquet: float64 value of %s column %s", c.col.Type, c.col.Name)
+			}
+		case string, []byte:
+			if c.col.Type != T_byte_array {
+				return fmt.Errorf("parquet: %T value of %s column %s", v, c.col.Type, c.col.Name)
+			}
+			if b, ok := x.([]byte); ok {
+				v = string(b)
+			}
+		default:
+			return fmt.Errorf("parquet: unsupported value %T of column %s", v, c.col.Name)
+		}
+		pw.converted[i] = v
+	}
+	size := 0
+	for i, c := range pw.columns {
+		if err := c.push(pw.converted[i]); err != nil {
+			return err
+		}
+		size += c.bufferedSize()
+	}
+	pw.rows++
+	if size >= pw.opts.RowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func (pw *Writer) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	rg := RowGroup{NumRows: pw.rows, FileOffset: pw.offset, Ordinal: int16(len(pw.meta.RowGroups))}
+	for _, c := range pw.columns {
+		chunk, err := c.writeChunk()
+		if err != nil {
+			return err
+		}
+		rg.Columns = append(rg.Columns, chunk)
+		rg.TotalByteSize += chunk.Meta.TotalUncompressedSize
+		rg.TotalCompressedSize += chunk.Meta.TotalCompressedSize
+	}
+	pw.meta.RowGroups = append(pw.meta.RowGroups, rg)
+	pw.meta.NumRows += pw.rows
+	pw.rows = 0
+	return nil
+}
+
+// Close writes the last row group and the footer (it doesn't close the underlying writer)
+func (pw *Writer) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	footer := AppendStruct(nil, pw.meta.thrift())
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+	if err := pw.write(size[:]); err != nil {
+		return err
+	}
+	return pw.write(MAGIC)
+}
+
+// columnWriter encodes the values of a column chunk into compressed data pages
+type columnWriter struct {
+	col Column
+	pw  *Writer
+	// current page
+	defs    []uint32 // definition levels: 0 null, 1 value
+	plain   []byte   // plain encoded values
+	indices []uint32 // dictionary indices
+	// dictionary of string columns
+	useDict   bool
+	dict      map[string]uint32
+	dictPlain []byte
+	dictRaw   int // plain size of the dictionary encoded values of the page
+	// chunk
+	pages        []byte
+	encodings    []Encoding
+	numValues    int64
+	nulls        int64
+	uncompressed int64
+	min, max     any
+}
+
+func newColumnWriter(col Column, pw *Writer) *columnWriter {
+	c := &columnWriter{col: col, pw: pw}
+	c.reset()
+	return c
+}
+
+func (c *columnWriter) reset() {
+	c.useDict = c.col.Dictionary && c.col.Type == T_byte_array && c.pw.opts.DictionaryLimit > 0
+	c.dict, c.dictPlain = map[string]uint32{}, c.dictPlain[:0]
+	c.pages, c.encodings = c.pages[:0], []Encoding{E_rle}
+	c.numValues, c.nulls, c.uncompressed = 0, 0, 0
+	c.min, c.max = nil, nil
+}
+
+func appendPlain(b []byte, v any) []byte {
+	var tmp [8]byte
+	switch x := v.(type) {
+	case int64:
+		binary.LittleEndian.PutUint64(tmp[:], uint64(x))
+		return append(b, tmp[:]...)
+	case float64:
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(x))
+		return append(b, tmp[:]...)
+	case string:
+		binary.LittleEndian.PutUint32(tmp[:4], uint32(len(x)))
+		return append(append(b, tmp[:4]...), x...)
+	}
+	return b
+}
+
+func less(a, b any) bool {
+	switch x := a.(type) {
+	case int64:
+		return x < b.(int64)
+	case float64:
+		return x < b.(float64)
+	case string:
+		return x < b.(string)
+	}
+	return false
+}
+
+func (c *columnWriter) push(v any) error {
+	c.numValues++
+	if v == nil {
+		c.nulls++
+		c.defs = append(c.defs, 0)
+	} else {
+		c.defs = append(c.defs, 1)
+		if f, ok := v.(float64); !ok || !math.IsNaN(f) {
+			if c.min == nil || less(v, c.min) {
+				c.min = v
+			}
+			if c.max == nil || less(c.max, v) {
+				c.max = v
+			}
+		}
+		if c.useDict {
+			s := v.(string)
+			idx, ok := c.dict[s]
+			if !ok && (len(c.dict) >= c.pw.opts.DictionaryLimit || len(c.dictPlain)+4+len(s) > c.pw.opts.PageSize) {
+				// too many distinct values, the rest of the chunk is plain encoded (also the current page
+				// if no page with the dictionary was written)
+				if len(c.pages) == 0 {
+					c.fallback()
+				} else {
+					c.defs = c.defs[:len(c.defs)-1]
+					if err := c.flushPage(); err != nil {
+						return err
+					}
+					c.useDict = false
+					c.defs = append(c.defs, 1)
+				}
+			} else if !ok {
+				idx = uint32(len(c.dict))
+				c.dict[s] = idx
+				c.dictPlain = appendPlain(c.dictPlain, s)
+			}
+			if c.useDict {
+				c.indices = append(c.indices, idx)
+				c.dictRaw += 4 + len(s)
+			}
+		}
+		if !c.useDict {
+			c.plain = appendPlain(c.plain, v)
+		}
+	}
+	if c.pageSize() >= c.pw.opts.PageSize {
+		return c.flushPage()
+	}
+	return nil
+}
+
+// pageSize estimates the encoded size of the current page
+func (c *columnWriter) pageSize() int {
+	return len(c.defs)/8 + len(c.plain) + len(c.indices)*bitWidth(uint32(len(c.dict)))/8
+}
+
+// bufferedSize is the size of the chunk so far
+func (c *columnWriter) bufferedSize() int {
+	return len(c.pages) + len(c.dictPlain) + c.pageSize()
+}
+
+func (c *columnWriter) addEncoding(e Encoding) {
+	for _, x := range c.encodings {
+		if x == e {
+			return
+		}
+	}
+	c.encodings = append(c.encodings, e)
+}
+
+// appendPage compresses the page body and appends the page with its header
+func (c *columnWriter) appendPage(dst []byte, h PageHeader, body []byte) ([]byte, error) {
+	compressed, err := c.pw.compress(body)
+	if err != nil {
+		return dst, err
+	}
+	h.UncompressedSize, h.CompressedSize = int32(len(body)), int32(len(compressed))
+	header := AppendStruct(nil, h.thrift())
+	c.uncompressed += int64(len(header) + len(body))
+	return append(append(dst, header...), compressed...), nil
+}
+
+// fallback plain encodes the values of the current page and drops the dictionary,
+// the rest of the chunk is plain encoded
+func (c *columnWriter) fallback() {
+	values := make([]string, len(c.dict))
+	for s, i := range c.dict {
+		values[i] = s
+	}
+	for _, i := range c.indices {
+		c.plain = appendPlain(c.plain, values[i])
+	}
+	c.useDict = false
+	c.dict, c.dictPlain, c.indices, c.dictRaw = map[string]uint32{}, c.dictPlain[:0], c.indices[:0], 0
+}
+
+func (c *columnWriter) flushPage() error {
+	if len(c.defs) == 0 {
+		return nil
+	}
+	if c.useDict && len(c.pages) == 0 && len(c.dictPlain)+len(c.indices)*bitWidth(uint32(len(c.dict)))/8 >= c.dictRaw {
+		// the first page decides the encoding of the chunk
+		c.fallback()
+	}
+	var body []byte
+	if !c.col.Required {
+		// v1 data pages: definition levels prefixed with their length
+		levels := appendHybrid(nil, c.defs, 1)
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(levels)))
+		body = append(append(body, size[:]...), levels...)
+	}
+	encoding := E_plain
+	if c.useDict {
+		encoding = E_plain_dictionary
+		width := bitWidth(uint32(len(c.dict) - 1))
+		if len(c.dict) == 0 {
+			width = 0
+		}
+		body = appendHybrid(append(body, byte(width)), c.indices, width)
+	} else {
+		body = append(body, c.plain...)
+	}
+	c.addEncoding(encoding)
+	h := PageHeader{Type: P_data, DataPage: &DataPageHeader{NumValues: int32(len(c.defs)), Encoding: encoding,
+		DefinitionLevelEncoding: E_rle, RepetitionLevelEncoding: E_rle}}
+	var err error
+	c.pages, err = c.appendPage(c.pages, h, body)
+	c.defs, c.plain, c.indices, c.dictRaw = c.defs[:0], c.plain[:0], c.indices[:0], 0
+	return err
+}
+
+func (c *columnWriter) stats() *Statistics {
+	st := &Statistics{NullCount: c.nulls, DistinctCount: -1}
+	if c.min == nil {
+		return st
+	}
+	min, max := appendPlain(nil, c.min), appendPlain(nil, c.max)
+	if c.col.Type == T_byte_array {
+		min, max = []byte(c.min.(string)), []byte(c.max.(string))
+		if len(min) > MAX_STATS_SIZE || len(max) > MAX_STATS_SIZE {
+			return st
+		}
+	} else {
+		// signed comparison, the deprecated fields are valid too
+		st.Min, st.Max = min, max
+	}
+	st.MinValue, st.MaxValue = min, max
+	return st
+}
+
+// writeChunk writes the dictionary page (if any) and the data pages, and resets the column for the next row group
+func (c *columnWriter) writeChunk() (ColumnChunk, error) {
+	if err := c.flushPage(); err != nil {
+		return ColumnChunk{}, err
+	}
+	start := c.pw.offset
+	meta := ColumnMetaData{Type: c.col.Type, Path: []string{c.col.Name}, Codec: c.pw.opts.Codec, NumValues: c.numValues,
+		DataPageOffset: start, Statistics: c.stats()}
+	if len(c.dict) > 0 {
+		h := PageHeader{Type: P_dictionary, DictionaryPage: &DictionaryPageHeader{NumValues: int32(len(c.dict)), Encoding: E_plain_dictionary}}
+		page, err := c.appendPage(nil, h, c.dictPlain)
+		if err != nil {
+			return ColumnChunk{}, err
+		}
+		if err = c.pw.write(page); err != nil {
+			return ColumnChunk{}, err
+		}
+		meta.DictionaryPageOffset, meta.DataPageOffset = start, c.pw.offset
+	}
+	if err := c.pw.write(c.pages); err != nil {
+		return ColumnChunk{}, err
+	}
+	meta.Encodings = c.encodings
+	meta.TotalUncompressedSize = c.uncompressed
+	meta.TotalCompressedSize = c.pw.offset - start
+	c.reset()
+	return ColumnChunk{FileOffset: start, Meta: meta}, nil
+}
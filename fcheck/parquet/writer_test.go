@@ -0,0 +1,275 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+func decompress(codec Codec, b []byte) ([]byte, error) {
+	switch codec {
+	case C_snappy:
+		return snappy.Decode(nil, b)
+	case C_gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(gr)
+	case C_zstd:
+		dec, _ := zstd.NewReader(nil)
+		return dec.DecodeAll(b, nil)
+	}
+	return b, nil
+}
+
+func plainValues(t Type, b []byte, n int) ([]any, int) {
+	var values []any
+	pos := 0
+	for i := 0; i < n; i++ {
+		switch t {
+		case T_int64:
+			values = append(values, int64(binary.LittleEndian.Uint64(b[pos:])))
+			pos += 8
+		case T_double:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(b[pos:])))
+			pos += 8
+		case T_byte_array:
+			size := int(binary.LittleEndian.Uint32(b[pos:]))
+			values = append(values, string(b[pos+4:pos+4+size]))
+			pos += 4 + size
+		}
+	}
+	return values, pos
+}
+
+// readFile decodes a flat file written by Writer: the footer and all values by column
+func readFile(t *testing.T, b []byte) (Struct, [][]any) {
+	if !bytes.Equal(b[:4], MAGIC) || !bytes.Equal(b[len(b)-4:], MAGIC) {
+		t.Fatal("no magic bytes")
+	}
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta, n, err := ReadStruct(b[len(b)-8-size : len(b)-8])
+	if err != nil || n != size {
+		t.Fatalf("invalid footer: %v, %d of %d bytes", err, n, size)
+	}
+	schema := meta.List(2)
+	columns := make([][]any, len(schema)-1)
+	for _, rg := range meta.List(4) {
+		for i, cc := range rg.(Struct).List(1) {
+			cm := cc.(Struct).Struct(3)
+			typ, codec, required := Type(cm.Int(1, -1)), Codec(cm.Int(4, 0)), schema[i+1].(Struct).Int(3, 1) == 0
+			pos := int(cm.Int(9, 0))
+			if cm.Has(11) {
+				pos = int(cm.Int(11, 0))
+			}
+			var dict []any
+			for values := int64(0); values < cm.Int(5, 0); {
+				h, n, err := ReadStruct(b[pos:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				pos += n
+				body, err := decompress(codec, b[pos:pos+int(h.Int(3, 0))])
+				if err != nil || len(body) != int(h.Int(2, 0)) {
+					t.Fatalf("page decompression failed: %v", err)
+				}
+				pos += int(h.Int(3, 0))
+				if PageType(h.Int(1, 0)) == P_dictionary {
+					dict, _ = plainValues(typ, body, int(h.Struct(7).Int(1, 0)))
+					continue
+				}
+				dp := h.Struct(5)
+				count := int(dp.Int(1, 0))
+				defs := make([]uint32, count)
+				if !required {
+					levelSize := int(binary.LittleEndian.Uint32(body))
+					if defs, _, _ = decodeHybrid(body[4:4+levelSize], 1, count); len(defs) != count {
+						t.Fatal("invalid definition levels")
+					}
+					body = body[4+levelSize:]
+				} else {
+					for k := range defs {
+						defs[k] = 1
+					}
+				}
+				notNull := 0
+				for _, d := range defs {
+					notNull += int(d)
+				}
+				var page []any
+				if Encoding(dp.Int(2, 0)) == E_plain_dictionary {
+					indices, _, ok := decodeHybrid(body[1:], int(body[0]), notNull)
+					if !ok {
+						t.Fatal("invalid dictionary indices")
+					}
+					for _, k := range indices {
+						page = append(page, dict[k])
+					}
+				} else {
+					page, _ = plainValues(typ, body, notNull)
+				}
+				for _, d := range defs {
+					if d == 0 {
+						columns[i] = append(columns[i], nil)
+					} else {
+						columns[i] = append(columns[i], page[0])
+						page = page[1:]
+					}
+				}
+				values += int64(count)
+			}
+		}
+	}
+	return meta, columns
+}
+
+func TestWriter(t *testing.T) {
+	cols := []Column{{"id", T_int64, true, false}, {"amount", T_double, false, false}, {"country", T_byte_array, false, true},
+		{"name", T_byte_array, false, true}, {"code", T_byte_array, false, false}}
+	var rows [][]any
+	for i := 0; i < 1000; i++ {
+		var amount any = float64(i) / 4
+		if i%10 == 0 {
+			amount = nil
+		}
+		rows = append(rows, []any{int64(i), amount, []string{"PL", "DE", "FR"}[i%3], fmt.Sprintf("name %d", i), fmt.Sprintf("c%d", i%2)})
+	}
+	for _, codec := range []Codec{C_uncompressed, C_snappy, C_gzip, C_zstd} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, cols, WriterOptions{Codec: codec, PageSize: 1000, RowGroupSize: 8000, DictionaryLimit: 100, CreatedBy: "gcf test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err := w.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		meta, columns := readFile(t, buf.Bytes())
+		if meta.Int(3, 0) != 1000 || len(meta.List(4)) < 2 || meta.Str(6) != "gcf test" {
+			t.Fatalf("%s: unexpected metadata: %d rows, %d row groups", codec, meta.Int(3, 0), len(meta.List(4)))
+		}
+		for i, row := range rows {
+			for k, v := range row {
+				if columns[k][i] != v {
+					t.Fatalf("%s: row %d column %d: expected %v, got %v", codec, i, k, v, columns[k][i])
+				}
+			}
+		}
+		// country is dictionary encoded, name has more than 100 values in the first page (plain encoded),
+		// code is plain encoded
+		rg := meta.List(4)[0].(Struct)
+		encodings := func(col int) []any { return rg.List(1)[col].(Struct).Struct(3).List(2) }
+		if e := encodings(2); len(e) != 2 || Encoding(e[1].(int32)) != E_plain_dictionary {
+			t.Errorf("%s: unexpected country encodings: %v", codec, e)
+		}
+		if e := encodings(3); len(e) != 2 || Encoding(e[1].(int32)) != E_plain {
+			t.Errorf("%s: unexpected name encodings: %v", codec, e)
+		}
+		if e := encodings(4); len(e) != 2 || Encoding(e[1].(int32)) != E_plain {
+			t.Errorf("%s: unexpected code encodings: %v", codec, e)
+		}
+		stats := rg.List(1)[1].(Struct).Struct(3).Struct(12)
+		if stats.Int(3, -1) == 0 || math.Float64frombits(binary.LittleEndian.Uint64(stats.Bytes(6))) != 0.25 {
+			t.Errorf("%s: unexpected amount stats: %v", codec, stats)
+		}
+	}
+}
+
+func TestWriterDictionaryFallback(t *testing.T) {
+	cols := []Column{{"s", T_byte_array, false, true}}
+	for _, tt := range []struct {
+		name      string
+		value     func(i int) string
+		encodings []Encoding
+	}{
+		{"few values", func(i int) string { return []string{"yes", "no"}[i%2] }, []Encoding{E_rle, E_plain_dictionary}},
+		{"unique values", func(i int) string { return fmt.Sprintf("value %d", i) }, []Encoding{E_rle, E_plain}},
+		// pages with the dictionary are written before the limit is reached
+		{"more values later", func(i int) string {
+			if i < 500 {
+				return "yes"
+			}
+			return fmt.Sprintf("value %d", i)
+		}, []Encoding{E_rle, E_plain_dictionary, E_plain}},
+	} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, cols, WriterOptions{PageSize: 20, DictionaryLimit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			if err := w.Write([]any{tt.value(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		meta, columns := readFile(t, buf.Bytes())
+		for i, v := range columns[0] {
+			if v != tt.value(i) {
+				t.Fatalf("%s: row %d: expected %s, got %v", tt.name, i, tt.value(i), v)
+			}
+		}
+		var e []Encoding
+		for _, x := range meta.List(4)[0].(Struct).List(1)[0].(Struct).Struct(3).List(2) {
+			e = append(e, Encoding(x.(int32)))
+		}
+		if fmt.Sprint(e) != fmt.Sprint(tt.encodings) {
+			t.Errorf("%s: expected encodings %v, got %v", tt.name, tt.encodings, e)
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, []Column{{Name: "b", Type: T_boolean}}, WriterOptions{}); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+	if _, err := NewWriter(&buf, nil, WriterOptions{Codec: C_lzo}); err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+	w, _ := NewWriter(&buf, []Column{{Name: "id", Type: T_int64, Required: true}, {Name: "s", Type: T_byte_array}}, WriterOptions{})
+	for _, row := range [][]any{{nil, "a"}, {int64(1), 2.0}, {"1", "a"}, {int64(1)}} {
+		if err := w.Write(row); err == nil {
+			t.Errorf("expected an error for %v", row)
+		}
+	}
+	if err := w.Write([]any{int64(1), nil}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	meta, columns := readFile(t, buf.Bytes())
+	if meta.Int(3, 0) != 1 || columns[0][0] != int64(1) || columns[1][0] != nil {
+		t.Errorf("unexpected file: %v", columns)
+	}
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	s := Struct{{1, int32(-5)}, {2, []string{"a", "b"}}, {3, int64(1) << 40}, {4, true}, {20, Struct{{1, 1.5}}},
+		{21, []Struct{{{1, "x"}}}}, {22, []int32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}}}
+	b := AppendStruct(nil, s)
+	r, n, err := ReadStruct(b)
+	if err != nil || n != len(b) {
+		t.Fatal(err)
+	}
+	if r.Int(1, 0) != -5 || len(r.List(2)) != 2 || r.Int(3, 0) != 1<<40 || r.Get(4) != true ||
+		r.Struct(20).Get(1) != 1.5 || r.List(21)[0].(Struct).Str(1) != "x" || len(r.List(22)) != 16 {
+		t.Errorf("unexpected struct: %v", r)
+	}
+	if _, _, err := ReadStruct(b[:len(b)-3]); err == nil {
+		t.Error("expected an error for truncated data")
+	}
+}
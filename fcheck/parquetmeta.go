@@ -0,0 +1,154 @@
+package fcheck
+
+import (
+	"fmt"
+	"gocf/fcheck/parquet"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ParquetMeta is the footer of a Parquet file with the issues found in it, the row groups are not read
+type ParquetMeta struct {
+	FileName string
+	Size     int64
+	Meta     *parquet.FileMetaData
+	Issues   []parquet.Issue
+}
+
+// ReadParquetMeta reads and checks the footer of the Parquet file
+func ReadParquetMeta(fileName string) (*ParquetMeta, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	m, err := parquet.ReadFooter(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return &ParquetMeta{FileName: fileName, Size: fi.Size(), Meta: m, Issues: m.Check(fi.Size())}, nil
+}
+
+// IssueCount returns the number of issues found in the metadata
+func (pm *ParquetMeta) IssueCount() int {
+	return len(pm.Issues)
+}
+
+func formatSize(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	f := float64(n)
+	i := 0
+	for ; f >= 1024 && i < len(units)-1; i++ {
+		f /= 1024
+	}
+	if i == 0 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64) + " " + units[i]
+}
+
+func schemaType(e parquet.SchemaElement) string {
+	s := e.Type.String()
+	if e.Type == parquet.T_fixed_len_byte_array {
+		s += "(" + strconv.Itoa(int(e.TypeLength)) + ")"
+	}
+	if e.LogicalType != "" {
+		s += " " + e.LogicalType
+	}
+	if e.ConvertedType >= 0 && e.ConvertedType.String() != e.LogicalType {
+		s += " (" + e.ConvertedType.String() + ")"
+	}
+	if e.ConvertedType == parquet.CT_decimal || e.LogicalType == "DECIMAL" {
+		s += fmt.Sprintf(" precision %d scale %d", e.Precision, e.Scale)
+	}
+	return s
+}
+
+// WriteText writes the metadata: schema, key-value metadata, row groups with per column statistics, and the issues
+func (pm *ParquetMeta) WriteText(w io.Writer) {
+	m := pm.Meta
+	fmt.Fprintln(w, "File:", pm.FileName)
+	info := fmt.Sprintf("parquet version %d, %s", m.Version, formatSize(pm.Size))
+	if m.CreatedBy != "" {
+		info += ", created by " + m.CreatedBy
+	}
+	fmt.Fprintln(w, "Info:", info)
+	fmt.Fprintf(w, "Rows: %d in %d row groups\n", m.NumRows, len(m.RowGroups))
+
+	leaves := m.Leaves()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Schema:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, l := range leaves {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", strings.Join(l.Path, "."), l.Element.Repetition, schemaType(l.Element))
+	}
+	tw.Flush()
+
+	if len(m.KeyValue) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Key-value metadata:")
+		for _, kv := range m.KeyValue {
+			fmt.Fprintf(w, "  %s: %s\n", kv.Key, kv.Value)
+		}
+	}
+
+	issues := map[[2]int][]string{}
+	for _, issue := range pm.Issues {
+		key := [2]int{issue.RowGroup, issue.Column}
+		issues[key] = append(issues[key], issue.Message)
+	}
+	for i, rg := range m.RowGroups {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Row group %d: %d rows, offset %d, %s compressed, %s uncompressed\n", i, rg.NumRows, rg.FileOffset,
+			formatSize(rg.TotalCompressedSize), formatSize(rg.TotalByteSize))
+		for _, msg := range issues[[2]int{i, -1}] {
+			fmt.Fprintln(w, "  ! "+msg)
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  column\tcodec\tencodings\tcompressed\tuncompressed\tvalues\tnulls\tmin\tmax")
+		for k, c := range rg.Columns {
+			md := c.Meta
+			name := strings.Join(md.Path, ".")
+			encodings := make([]string, len(md.Encodings))
+			for i, e := range md.Encodings {
+				encodings[i] = e.String()
+			}
+			nulls, min, max := "-", "-", "-"
+			if st := md.Statistics; st != nil {
+				if st.NullCount >= 0 {
+					nulls = strconv.FormatInt(st.NullCount, 10)
+				}
+				lmin, lmax, _ := st.MinMax()
+				if k < len(leaves) {
+					if lmin != nil {
+						min = parquet.FormatStat(leaves[k].Element, lmin)
+					}
+					if lmax != nil {
+						max = parquet.FormatStat(leaves[k].Element, lmax)
+					}
+				}
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", name, md.Codec, strings.Join(encodings, ","),
+				formatSize(md.TotalCompressedSize), formatSize(md.TotalUncompressedSize), md.NumValues, nulls, min, max)
+		}
+		tw.Flush()
+		for k := range rg.Columns {
+			for _, msg := range issues[[2]int{i, k}] {
+				fmt.Fprintf(w, "  ! %s: %s\n", strings.Join(rg.Columns[k].Meta.Path, "."), msg)
+			}
+		}
+	}
+	for _, msg := range issues[[2]int{-1, -1}] {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "! "+msg)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%d issues found\n", len(pm.Issues))
+}
@@ -0,0 +1,42 @@
+package fcheck
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParquetMeta(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := ToParquet(testRows(), &buf, ParquetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "test.parquet")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pm, err := ReadParquetMeta(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	pm.WriteText(&out)
+	for _, s := range []string{"created by gcf", "Rows: 5 in 1 row groups", "country  optional  BYTE_ARRAY STRING (UTF8)", "SNAPPY", "0 issues found"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected %q in\n%s", s, out.String())
+		}
+	}
+	if _, err := ReadParquetMeta("csv_test.go"); err == nil {
+		t.Error("expected an error for a non parquet file")
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, expected := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 3 << 30: "3.0 GB"} {
+		if s := formatSize(n); s != expected {
+			t.Errorf("%d: expected %s, got %s", n, expected, s)
+		}
+	}
+}
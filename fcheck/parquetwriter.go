@@ -0,0 +1,134 @@
+package fcheck
+
+import (
+	"fmt"
+	"gocf/fcheck/parquet"
+	"io"
+)
+
+// PARQUET_CODECS compression codecs supported by ToParquet
+var PARQUET_CODECS = []string{"uncompressed", "snappy", "gzip", "zstd"}
+
+// ParquetOptions controls ToParquet, zero values are the parquet.Writer defaults
+type ParquetOptions struct {
+	Codec        string // one of PARQUET_CODECS, snappy if empty
+	Level        int    // gzip (1-9) or zstd (1-22) level
+	RowGroupSize int    // approx. row group size in bytes
+	PageSize     int    // approx. page size in bytes
+	// DictionaryLimit is the max number of distinct values of dictionary encoded string columns,
+	// negative disables dictionaries
+	DictionaryLimit int
+	// Distinct is the number of distinct values of string fields, e.g. from a profile of the file
+	// (StringFreq.Distinct of the report fields). Fields with more than DictionaryLimit values are not dictionary
+	// encoded, for the others (and if nil) the writer decides by the first page of each row group.
+	Distinct map[string]int
+	// Rejects gets the rows that can't be converted to the column types as csv, with an error column, dropped if nil
+	Rejects io.Writer
+}
+
+func parquetCodec(name string) (parquet.Codec, error) {
+	switch name {
+	case "", "snappy":
+		return parquet.C_snappy, nil
+	case "uncompressed", "none", "null":
+		return parquet.C_uncompressed, nil
+	case "gzip":
+		return parquet.C_gzip, nil
+	case "zstd":
+		return parquet.C_zstd, nil
+	}
+	return 0, fmt.Errorf("unknown parquet codec: %s (supported: uncompressed, snappy, gzip, zstd)", name)
+}
+
+// ToParquet writes all rows (Init() is called here) as a Parquet file, the schema is derived from GetTypes:
+// int -> INT64, float -> DOUBLE, string -> BYTE_ARRAY (STRING), all columns optional.
+// String columns are dictionary encoded in the row groups where it's smaller (see ParquetOptions.Distinct).
+func ToParquet(fr FileReader, w io.Writer, opts ParquetOptions) (ConvertResult, error) {
+	var res ConvertResult
+	fr.Init()
+	fields := fr.GetFields()
+	types := fr.GetTypes()
+	codec, err := parquetCodec(opts.Codec)
+	if err != nil {
+		return res, err
+	}
+	columns := make([]parquet.Column, len(fields))
+	for i, f := range fields {
+		columns[i] = parquet.Column{Name: f, Type: parquet.T_byte_array}
+		switch types[i] {
+		case DT_int:
+			columns[i].Type = parquet.T_int64
+		case DT_float:
+			columns[i].Type = parquet.T_double
+		}
+	}
+	limit := opts.DictionaryLimit
+	if limit == 0 {
+		limit = parquet.DICTIONARY_LIMIT
+	}
+	if limit > 0 {
+		for i := range columns {
+			if n, ok := opts.Distinct[fields[i]]; (!ok || n <= limit) && columns[i].Type == parquet.T_byte_array {
+				columns[i].Dictionary = true
+			}
+		}
+	}
+	pw, err := parquet.NewWriter(w, columns, parquet.WriterOptions{Codec: codec, Level: opts.Level, RowGroupSize: opts.RowGroupSize,
+		PageSize: opts.PageSize, DictionaryLimit: opts.DictionaryLimit, CreatedBy: "gcf"})
+	if err != nil {
+		return res, err
+	}
+	rejects := newRejectWriter(opts.Rejects, fields)
+	values := make([]any, len(fields))
+	rows := fr.Read()
+	for row := range rows {
+		var err error
+		for i, v := range row {
+			if values[i], err = parquetValue(columns[i], v); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = pw.Write(values)
+		}
+		if err != nil {
+			res.Rejected++
+			if err = rejects.write(row, err); err != nil {
+				drain(rows)
+				return res, err
+			}
+			continue
+		}
+		res.Rows++
+	}
+	if err := rejects.flush(); err != nil {
+		return res, err
+	}
+	return res, pw.Close()
+}
+
+// parquetValue converts the value to the column type, empty strings are nulls for numeric columns
+func parquetValue(col parquet.Column, value any) (any, error) {
+	if s, ok := value.(string); value == nil || ok && s == "" && col.Type != parquet.T_byte_array {
+		return nil, nil
+	}
+	switch col.Type {
+	case parquet.T_int64:
+		v, err := toInt64(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", col.Name, err)
+		}
+		return v, nil
+	case parquet.T_double:
+		v, err := toFloat64(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", col.Name, err)
+		}
+		return v, nil
+	}
+	if b, ok := value.([]byte); ok {
+		return b, nil
+	}
+	s, _ := formatValue(value)
+	return s, nil
+}
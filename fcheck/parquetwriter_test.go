@@ -0,0 +1,116 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gocf/fcheck/parquet"
+	"os"
+	"testing"
+)
+
+func TestToParquet(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"id", "amount", "name"},
+		types:  []DataType{DT_int, DT_float, DT_string},
+		rows: [][]any{
+			{int64(1), 10.5, "a"},
+			{"2", "", nil},
+			{"x", 1.0, "c"},
+			{int64(4), "y", "d"},
+			{int64(5), int64(3), int64(7)},
+		},
+	}
+	var buf, rejects bytes.Buffer
+	res, err := ToParquet(sr, &buf, ParquetOptions{Codec: "zstd", Rejects: &rejects})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 3 || res.Rejected != 2 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	expected := `id,amount,name,error
+x,1,c,"id: ""x"" is not an integer"
+4,y,d,"amount: ""y"" is not a number"
+`
+	if rejects.String() != expected {
+		t.Errorf("unexpected rejects:\n%s", rejects.String())
+	}
+	b := buf.Bytes()
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta, _, err := parquet.ReadStruct(b[len(b)-8-size : len(b)-8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Int(3, 0) != 3 || meta.Str(6) != "gcf" {
+		t.Errorf("unexpected metadata: %v", meta)
+	}
+	schema := meta.List(2)
+	for i, typ := range []parquet.Type{parquet.T_int64, parquet.T_double, parquet.T_byte_array} {
+		if e := schema[i+1].(parquet.Struct); parquet.Type(e.Int(1, -1)) != typ || e.Str(4) != sr.fields[i] {
+			t.Errorf("unexpected schema element: %v", e)
+		}
+	}
+	if codec := meta.List(4)[0].(parquet.Struct).List(1)[0].(parquet.Struct).Struct(3).Int(4, -1); parquet.Codec(codec) != parquet.C_zstd {
+		t.Errorf("unexpected codec: %d", codec)
+	}
+	if _, err := ToParquet(sr, &bytes.Buffer{}, ParquetOptions{Codec: "lzo"}); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+}
+
+// parquetFixtureRows are the rows of PARQUET_GCF_PATH
+func parquetFixtureRows() *sliceReader {
+	sr := &sliceReader{
+		fields: []string{"id", "amount", "country", "name"},
+		types:  []DataType{DT_int, DT_float, DT_string, DT_string},
+	}
+	for i := 0; i < 300; i++ {
+		var amount, country any = float64(i) / 4, []string{"PL", "DE", "FR", "żółw"}[i%4]
+		if i%7 == 0 {
+			amount, country = nil, nil
+		}
+		sr.rows = append(sr.rows, []any{int64(i), amount, country, fmt.Sprintf("name %d", i)})
+	}
+	return sr
+}
+
+// PARQUET_GCF_PATH was written by ToParquet from parquetFixtureRows and read back with the Apache Arrow
+// Go parquet reader (5 row groups, country dictionary encoded, nulls in amount and country)
+const PARQUET_GCF_PATH = "../test/data/parquet_gcf"
+
+func TestToParquetFixture(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := ToParquet(parquetFixtureRows(), &buf, ParquetOptions{RowGroupSize: 2000, DictionaryLimit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile(PARQUET_GCF_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("the file differs from %s", PARQUET_GCF_PATH)
+	}
+	m, err := parquet.ReadFooter(bytes.NewReader(expected), int64(len(expected)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only country has at most 50 distinct values
+	for _, c := range m.RowGroups[0].Columns {
+		if dict := c.Meta.DictionaryPageOffset > 0; dict != (c.Meta.Path[0] == "country") {
+			t.Errorf("%s: unexpected dictionary encoding: %v", c.Meta.Path[0], c.Meta.Encodings)
+		}
+	}
+	// the distinct values are given, no dictionaries
+	buf.Reset()
+	if _, err := ToParquet(parquetFixtureRows(), &buf, ParquetOptions{Distinct: map[string]int{"country": 100}, DictionaryLimit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if m, err = parquet.ReadFooter(bytes.NewReader(b), int64(len(b))); err != nil {
+		t.Fatal(err)
+	}
+	if c := m.RowGroups[0].Columns[2]; c.Meta.DictionaryPageOffset != 0 {
+		t.Errorf("unexpected dictionary of %s", c.Meta.Path[0])
+	}
+}
@@ -0,0 +1,258 @@
+package fcheck
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Projector is implemented by readers that can skip decoding of unselected fields.
+// Project is called after Init() and before Read(), afterwards GetFields, GetTypes
+// and rows returned by Read() contain only the selected fields (in the original order).
+type Projector interface {
+	Project(fields []string) error
+}
+
+type fieldPattern struct {
+	exclude bool
+	name    string         // plain name
+	glob    string         // *, ? or [...] pattern
+	re      *regexp.Regexp // /regexp/
+}
+
+func (p fieldPattern) match(field string) bool {
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(field)
+	case p.glob != "":
+		ok, _ := path.Match(p.glob, field)
+		return ok
+	}
+	return p.name == field
+}
+
+// split the selection spec by commas, except for commas inside /regexps/
+func splitFieldSpec(spec string) ([]string, error) {
+	var parts []string
+	for len(spec) > 0 {
+		s := strings.TrimLeft(spec, " ")
+		start := strings.TrimPrefix(s, "!")
+		if strings.HasPrefix(start, "/") {
+			// find closing, not escaped slash
+			end := -1
+			for i := 1; i < len(start); i++ {
+				if start[i] == '\\' {
+					i++
+				} else if start[i] == '/' {
+					end = i
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated regular expression: %s", s)
+			}
+			n := len(s) - len(start) + end + 1
+			parts = append(parts, s[:n])
+			rest := strings.TrimLeft(s[n:], " ")
+			if len(rest) > 0 && rest[0] != ',' {
+				return nil, fmt.Errorf("expected , after %s", s[:n])
+			}
+			spec = strings.TrimPrefix(rest, ",")
+			continue
+		}
+		i := strings.IndexByte(s, ',')
+		if i < 0 {
+			parts = append(parts, s)
+			break
+		}
+		parts = append(parts, s[:i])
+		spec = s[i+1:]
+	}
+	return parts, nil
+}
+
+// SelectFields returns the fields matching the selection spec: a comma separated list of names,
+// globs (e.g. amount_*) and regular expressions in slashes (e.g. /^dt_\d+$/). Patterns prefixed
+// with ! exclude fields, if there are only exclusions all other fields are selected.
+// The original order of the fields is kept.
+func SelectFields(fields []string, spec string) ([]string, error) {
+	parts, err := splitFieldSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []fieldPattern
+	anyInclude := false
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var p fieldPattern
+		if strings.HasPrefix(part, "!") {
+			p.exclude = true
+			part = strings.TrimSpace(part[1:])
+		} else {
+			anyInclude = true
+		}
+		switch {
+		case len(part) > 1 && strings.HasPrefix(part, "/") && strings.HasSuffix(part, "/"):
+			if p.re, err = regexp.Compile(part[1 : len(part)-1]); err != nil {
+				return nil, fmt.Errorf("invalid field pattern %s: %w", part, err)
+			}
+		case strings.ContainsAny(part, "*?["):
+			if _, err = path.Match(part, ""); err != nil {
+				return nil, fmt.Errorf("invalid field pattern %s: %w", part, err)
+			}
+			p.glob = part
+		default:
+			if indexof(fields, part) < 0 {
+				return nil, fmt.Errorf("unknown field: %s", part)
+			}
+			p.name = part
+		}
+		patterns = append(patterns, p)
+	}
+	var selected []string
+	for _, field := range fields {
+		include := !anyInclude
+		for _, p := range patterns {
+			if p.match(field) {
+				if p.exclude {
+					include = false
+					break
+				}
+				include = true
+			}
+		}
+		if include {
+			selected = append(selected, field)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no fields match: %s", spec)
+	}
+	return selected, nil
+}
+
+// projection maps rows with all fields to rows with the selected ones
+type projection struct {
+	fields []string
+	types  []DataType
+	index  []int // position of the selected field in the source row
+}
+
+func newProjection(fields []string, types []DataType, selected []string) (*projection, error) {
+	p := &projection{fields: selected, types: make([]DataType, len(selected)), index: make([]int, len(selected))}
+	for i, name := range selected {
+		j := indexof(fields, name)
+		if j < 0 {
+			return nil, fmt.Errorf("unknown field: %s", name)
+		}
+		p.index[i] = j
+		p.types[i] = types[j]
+	}
+	return p, nil
+}
+
+// apply returns a new row with the selected values
+func (p *projection) apply(row []any) []any {
+	selected := make([]any, len(p.index))
+	for i, j := range p.index {
+		selected[i] = row[j]
+	}
+	return selected
+}
+
+// ProjectedReader wraps a FileReader and passes only the selected fields (see SelectFields).
+// If the wrapped reader is a Projector the selection is pushed down to it,
+// so unselected fields are not decoded at all.
+type ProjectedReader struct {
+	FileReader
+	spec    string
+	nFields int // number of fields before the projection
+	proj    *projection
+}
+
+func NewProjectedReader(fr FileReader, spec string) (*ProjectedReader, error) {
+	if _, err := splitFieldSpec(spec); err != nil {
+		return nil, err
+	}
+	return &ProjectedReader{FileReader: fr, spec: spec}, nil
+}
+
+func (pr *ProjectedReader) Init() {
+	pr.FileReader.Init()
+	fields := pr.FileReader.GetFields()
+	pr.nFields = len(fields)
+	selected, err := SelectFields(fields, pr.spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if p, ok := pr.FileReader.(Projector); ok {
+		err = p.Project(selected)
+	} else {
+		pr.proj, err = newProjection(fields, pr.FileReader.GetTypes(), selected)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (pr *ProjectedReader) GetFields() []string {
+	if pr.proj != nil {
+		return pr.proj.fields
+	}
+	return pr.FileReader.GetFields()
+}
+func (pr *ProjectedReader) GetTypes() []DataType {
+	if pr.proj != nil {
+		return pr.proj.types
+	}
+	return pr.FileReader.GetTypes()
+}
+func (pr *ProjectedReader) GetFileInfo() string {
+	return fmt.Sprintf("%s, %d of %d fields selected", pr.FileReader.GetFileInfo(), len(pr.GetFields()), pr.nFields)
+}
+
+func (pr *ProjectedReader) Stop() {
+	stopReader(pr.FileReader)
+}
+
+func (pr *ProjectedReader) Read() chan []any {
+	if pr.proj == nil {
+		return pr.FileReader.Read()
+	}
+	out := make(chan []any)
+	go func(in chan []any) {
+		for row := range in {
+			out <- pr.proj.apply(row)
+		}
+		close(out)
+	}(pr.FileReader.Read())
+	return out
+}
+
+// ReadBatches implements BatchReader, the selected vectors are taken from the batches of the wrapped reader
+// if it's a BatchReader, otherwise the selected fields of its rows are collected into batches
+func (pr *ProjectedReader) ReadBatches(size int) chan *ColumnBatch {
+	if _, ok := pr.FileReader.(BatchReader); !ok {
+		return rowBatches(pr, size)
+	}
+	if pr.proj == nil {
+		return ReadBatches(pr.FileReader, size)
+	}
+	out := make(chan *ColumnBatch)
+	go func(in chan *ColumnBatch) {
+		for b := range in {
+			columns := make([]*Vector, len(pr.proj.index))
+			for i, j := range pr.proj.index {
+				columns[i] = b.Columns[j]
+			}
+			out <- &ColumnBatch{Rows: b.Rows, Columns: columns}
+		}
+		close(out)
+	}(ReadBatches(pr.FileReader, size))
+	return out
+}
@@ -0,0 +1,92 @@
+package fcheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectFields(t *testing.T) {
+	fields := []string{"id", "amount_net", "amount_gross", "amount_tmp", "dt_1", "dt_22", "name"}
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"name,id", "id,name"},
+		{"amount_*", "amount_net,amount_gross,amount_tmp"},
+		{"amount_*,!amount_tmp", "amount_net,amount_gross"},
+		{`/^dt_\d{1,2}$/, id`, "id,dt_1,dt_22"},
+		{`!/^dt_/,!amount_?????`, "id,amount_net,amount_tmp,name"},
+		{"!name", "id,amount_net,amount_gross,amount_tmp,dt_1,dt_22"},
+		{"*,!*_*", "id,name"},
+	}
+	for _, tc := range tests {
+		selected, err := SelectFields(fields, tc.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tc.spec, err)
+		} else if strings.Join(selected, ",") != tc.expected {
+			t.Errorf("%s: expected %s, got %v", tc.spec, tc.expected, selected)
+		}
+	}
+	for _, spec := range []string{"nope", "/[/", "/abc", "x*,!*", "[a"} {
+		if _, err := SelectFields(fields, spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestProjectedReader(t *testing.T) {
+	pr, err := NewProjectedReader(testRows(), "name,country")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr.Init()
+	if f := strings.Join(pr.GetFields(), ","); f != "country,name" {
+		t.Errorf("unexpected fields: %s", f)
+	}
+	if pr.GetTypes()[1] != DT_string {
+		t.Errorf("unexpected types: %v", pr.GetTypes())
+	}
+	n := 0
+	for row := range pr.Read() {
+		if len(row) != 2 {
+			t.Fatalf("unexpected row: %v", row)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("Expected 5 rows, got %d", n)
+	}
+}
+
+func TestProjectionPushdown(t *testing.T) {
+	cr := NewCsvReader("../test/data/simple.csv", ',')
+	fr, _ := NewFilteredReader(&cr, "INTEGER <= 500")
+	pr, _ := NewProjectedReader(fr, "STRING,BOOLEAN")
+	r := newTestReport(t, pr, ReportOptions{})
+	if r.RowCount != 500 {
+		t.Errorf("Expected 500 rows, got %d", r.RowCount)
+	}
+	if len(r.Fields) != 2 || r.Fields[0].Name != "BOOLEAN" || r.Fields[1].Name != "STRING" {
+		t.Errorf("unexpected fields: %+v", r.Fields)
+	}
+	// the filter needs INTEGER, so it's the only extra column converted by the csv reader
+	if f := strings.Join(cr.GetFields(), ","); f != "BOOLEAN,INTEGER,STRING" {
+		t.Errorf("unexpected csv fields: %s", f)
+	}
+	if !strings.Contains(r.FileInfo, "CSV, 10 columns") || !strings.Contains(r.FileInfo, "2 of 10 fields") {
+		t.Errorf("unexpected file info: %s", r.FileInfo)
+	}
+
+	ar := NewAvroReader(AVRO_SNAPPY_PATH)
+	pr, _ = NewProjectedReader(&ar, "!*_null")
+	r = newTestReport(t, pr, ReportOptions{})
+	if r.RowCount != AVRO_SNAPPY_ROWS || len(r.Fields) != 8 {
+		t.Errorf("unexpected report: %d rows, %d fields", r.RowCount, len(r.Fields))
+	}
+	if len(ar.GetFields()) != 8 || ar.GetTypes()[1] != DT_int {
+		t.Errorf("projection not pushed down: %v %v", ar.GetFields(), ar.GetTypes())
+	}
+	if s := r.Field("long").Numeric; s == nil || r.Field("long").Count != AVRO_SNAPPY_ROWS {
+		t.Errorf("unexpected long stats: %+v", r.Field("long"))
+	}
+}
@@ -0,0 +1,144 @@
+package fcheck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Format describes a file format: how to recognize it and how to create a FileReader for it.
+// Built-in formats register themselves in init(), other packages can add their own with RegisterFormat.
+// Detection tries, in that order: magic bytes, content sniffing, file name extensions.
+// If more than one format matches at the same step, the one registered first wins.
+type Format struct {
+	// Name is a short unique name of the format (e.g. "csv"), it's also the key in ReaderOptions
+	Name string
+	// Type is one of the built-in FileType values, FT_unknown for formats defined outside fcheck
+	Type FileType
+	// Magic bytes at the very beginning of the file (optional)
+	Magic []byte
+	// Extensions of the file name, including the dot, e.g. ".csv" (optional)
+	Extensions []string
+	// Sniff checks the first bytes of the file (up to HEAD_SIZE) and the options passed for this format (optional)
+	Sniff func(fileName string, head []byte, opts any) bool
+	// New creates a reader, opts is whatever was passed in ReaderOptions for this format (may be nil)
+	New func(fileName string, opts any) (FileReader, error)
+}
+
+// ReaderOptions holds per-format reader options keyed by the format name, e.g. {"csv": CsvOptions{...}}
+type ReaderOptions map[string]any
+
+// number of bytes read from the beginning of a file to detect its format
+const HEAD_SIZE = 512
+
+var (
+	formatsMu sync.RWMutex
+	formats   []*Format
+)
+
+// RegisterFormat adds a new file format. It panics if the name is empty, already taken or New is missing.
+func RegisterFormat(f Format) {
+	if f.Name == "" || f.New == nil {
+		panic("fcheck: format must have a name and a reader constructor")
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for _, rf := range formats {
+		if rf.Name == f.Name {
+			panic("fcheck: format " + f.Name + " registered twice")
+		}
+	}
+	formats = append(formats, &f)
+}
+
+// Formats returns names of all registered formats in registration order
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// LookupFormat returns a registered format by its name
+func LookupFormat(name string) (*Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func readHead(fileName string) ([]byte, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, HEAD_SIZE)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+	return head[:n], nil
+}
+
+// detectFormat finds the format of the file using magic bytes, sniffers and extensions (in that order)
+func detectFormat(fileName string, opts ReaderOptions) (*Format, error) {
+	head, err := readHead(fileName)
+	if err != nil {
+		return nil, err
+	}
+	// sniffers are called without the lock, they may look up or register formats
+	formatsMu.RLock()
+	formats := append([]*Format(nil), formats...)
+	formatsMu.RUnlock()
+	for _, f := range formats {
+		if len(f.Magic) > 0 && bytes.HasPrefix(head, f.Magic) {
+			return f, nil
+		}
+	}
+	for _, f := range formats {
+		if f.Sniff != nil && f.Sniff(fileName, head, opts[f.Name]) {
+			return f, nil
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if ext != "" && ext == strings.ToLower(e) {
+				return f, nil
+			}
+		}
+	}
+	return nil, errors.New("unknown file format")
+}
+
+// NewFileReader detects the format of the file and creates a reader for it.
+// opts may be nil, then every reader uses its defaults.
+func NewFileReader(fileName string, opts ReaderOptions) (FileReader, error) {
+	f, err := detectFormat(fileName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.New(fileName, opts[f.Name])
+}
+
+// NewFileReaderOf creates a reader for the given format without trying to detect it
+func NewFileReaderOf(format string, fileName string, opts ReaderOptions) (FileReader, error) {
+	f, ok := LookupFormat(format)
+	if !ok {
+		return nil, fmt.Errorf("unknown file format: %s (known formats: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return f.New(fileName, opts[f.Name])
+}
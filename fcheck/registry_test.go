@@ -0,0 +1,102 @@
+package fcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeReader struct {
+	CsvReader
+	opts any
+}
+
+// sniffedLocked is set if the fake sniffer is called while the formats are locked
+var sniffedLocked bool
+
+func init() {
+	RegisterFormat(Format{
+		Name:  "fake",
+		Magic: []byte("FAKE"),
+		Sniff: func(fileName string, head []byte, opts any) bool {
+			if formatsMu.TryLock() {
+				formatsMu.Unlock()
+			} else {
+				sniffedLocked = true
+			}
+			return false
+		},
+		New: func(fileName string, opts any) (FileReader, error) {
+			return &fakeReader{CsvReader: NewCsvReader(fileName, ','), opts: opts}, nil
+		},
+	})
+}
+
+func TestDetectFormat(t *testing.T) {
+	dir := t.TempDir()
+	fakeFile := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(fakeFile, []byte("FAKE,1,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	noExt := filepath.Join(dir, "data")
+	if err := os.WriteFile(noExt, []byte("a;b\n1;2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		fileName string
+		opts     ReaderOptions
+		expected string
+	}{
+		{"../test/data/simple.csv", nil, "csv"},
+		{AVRO_NULL_PATH, nil, "avro"},
+		{AVRO_SNAPPY_PATH, ReaderOptions{"csv": CsvOptions{Delimiter: ';'}}, "avro"}, // magic bytes first
+		{fakeFile, nil, "fake"},
+		{noExt, ReaderOptions{"csv": CsvOptions{Delimiter: ';'}}, "csv"},
+		{noExt, nil, ""},
+		{"../test/data/parquet_snappy", nil, ""},
+	}
+	for _, tc := range tests {
+		f, err := detectFormat(tc.fileName, tc.opts)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected unknown format, got %s", tc.fileName, f.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.fileName, err)
+		} else if f.Name != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.fileName, tc.expected, f.Name)
+		}
+	}
+	if sniffedLocked {
+		t.Error("sniffers are called with the formats locked")
+	}
+}
+
+func TestNewFileReaderOptions(t *testing.T) {
+	fr, err := NewFileReader("../test/data/quoted_sc_delim.csv", ReaderOptions{"csv": CsvOptions{Delimiter: ';'}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, ok := fr.(*CsvReader)
+	if !ok {
+		t.Fatalf("expected *CsvReader, got %T", fr)
+	}
+	if cr.delimiter != ';' {
+		t.Errorf("expected ';' delimiter, got '%c'", cr.delimiter)
+	}
+	if _, err := NewFileReader("../test/data/simple.csv", ReaderOptions{"csv": "x"}); err == nil {
+		t.Error("invalid options accepted")
+	}
+	fr, err = NewFileReaderOf("fake", "../test/data/simple.csv", ReaderOptions{"fake": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.(*fakeReader).opts != 42 {
+		t.Error("options not passed to the reader")
+	}
+	if _, err := NewFileReaderOf("nope", "../test/data/simple.csv", nil); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,430 @@
+package fcheck
+
+import (
+	"fmt"
+	"gocf/fcheck/stats"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Enum Collector selects stat collectors used by NewReport (can be combined with |)
+type Collector uint
+
+const (
+	CL_stats     Collector = 1 << iota // min, max, mean and std. deviation of numeric fields
+	CL_freq                            // value frequencies and length range of string fields
+	CL_patterns                        // value shapes (AB-1234 -> AA-9999) and semantic types (email, url, ...) of all fields
+	CL_pii                             // fields with likely PII (emails, phones, IBANs, cards, PESELs, IPs), see FieldReport.PII
+	CL_histogram                       // histograms of numeric fields
+	CL_nullmap                         // share of nulls in consecutive chunks of rows
+
+	CL_default = CL_stats | CL_freq
+)
+
+// ReportOptions controls what NewReport collects and how fields are ordered
+type ReportOptions struct {
+	Sorted        bool      // sort fields alphabetically (default: the original file order)
+	NoOfSamples   int       // number of most/least frequent values to keep for string fields
+	LeastFrequent bool      // keep least frequent values instead of most frequent ones
+	Collectors    Collector // collectors to enable, CL_default if 0
+	// duplicate keys and rows detection, disabled if nil
+	Duplicates *DuplicateOptions
+	// correlations, dependencies and redundant fields, disabled if nil
+	Correlations *CorrelationOptions
+}
+
+// Report is the result of profiling a file with NewReport
+type Report struct {
+	FileName      string             `json:"file_name"`
+	FileInfo      string             `json:"file_info"`
+	RowCount      int                `json:"row_count"`
+	Start         time.Time          `json:"start"`
+	Duration      time.Duration      `json:"duration"`
+	NoOfSamples   int                `json:"no_of_samples"`
+	LeastFrequent bool               `json:"least_frequent"` // Values of the fields are the least frequent ones
+	Fields        []FieldReport      `json:"fields"`         // in the report order, see ReportOptions.Sorted
+	Duplicates    *DuplicateReport   `json:"duplicates,omitempty"`
+	Correlations  *CorrelationReport `json:"correlations,omitempty"`
+	NullMapChunk  int                `json:"null_map_chunk,omitempty"` // rows per chunk of FieldReport.NullMap
+}
+
+// FieldReport holds stats of a single field
+type FieldReport struct {
+	Name      string          `json:"name"`
+	Index     int             `json:"index"` // position of the field in the file
+	Type      DataType        `json:"type"`
+	Count     int             `json:"count"` // not null (and not empty for strings)
+	Nulls     int             `json:"nulls"`
+	Coverage  float64         `json:"coverage"` // Count / RowCount in %
+	Comment   string          `json:"comment"`
+	Numeric   *NumericStats   `json:"numeric,omitempty"`
+	Strings   *StringStats    `json:"strings,omitempty"`
+	Patterns  *PatternStats   `json:"patterns,omitempty"`
+	PII       string          `json:"pii,omitempty"` // semantic type of likely PII, "" if none
+	Histogram *HistogramStats `json:"histogram,omitempty"`
+	NullMap   []float64       `json:"null_map,omitempty"` // share (0-1) of nulls and empty values per chunk of rows
+	// raw collector, for stats not exposed above
+	Collector stats.StatCollector `json:"-"`
+}
+
+type NumericStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+}
+
+// N_HISTOGRAM_BINS number of bins of numeric histograms
+const N_HISTOGRAM_BINS = 20
+
+type HistogramStats struct {
+	Edges  []float64 `json:"edges"`  // len(Counts)+1 bin edges
+	Counts []int     `json:"counts"` // estimated from a sample for big files
+}
+
+type StringStats struct {
+	MinLength int          `json:"min_length"`
+	MaxLength int          `json:"max_length"`
+	Values    []ValueCount `json:"values"` // most (or least) frequent values
+}
+
+// PII_MIN_SHARE share of non empty values of a PII semantic type needed to report the field as PII
+const PII_MIN_SHARE = 0.5
+
+type PatternStats struct {
+	Values       []ValueCount `json:"values"`             // most (or least) frequent patterns
+	Semantic     []ValueCount `json:"semantic,omitempty"` // all detected semantic types
+	SemanticType string       `json:"semantic_type"`      // matching at least 90% of values, "" if none
+}
+
+type ValueCount struct {
+	Value   string  `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"` // of all rows
+}
+
+func (s DataType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func getStatCollectors(types []DataType, collectors Collector) []stats.StatCollector {
+	statCollectors := make([]stats.StatCollector, len(types))
+	for i, t := range types {
+		switch {
+		case (t == DT_float || t == DT_int) && collectors&CL_stats != 0:
+			statCollectors[i] = &stats.RunningStats{}
+		case t != DT_float && t != DT_int && collectors&CL_freq != 0:
+			statCollectors[i] = stats.NewStringFreq()
+		default:
+			statCollectors[i] = &stats.Counter{}
+		}
+	}
+	return statCollectors
+}
+
+// NewReport reads all rows from the reader (Init() is called here) and collects stats of every field,
+// readers that decode column batches natively (see BatchReader) are read batch by batch.
+// An error is returned for invalid duplicate options or if the duplicates can't be counted.
+func NewReport(fr FileReader, opts ReportOptions) (*Report, error) {
+	if opts.Collectors == 0 {
+		opts.Collectors = CL_default
+	}
+	fr.Init()
+	fields := fr.GetFields()
+	types := fr.GetTypes()
+	statCollectors := getStatCollectors(types, opts.Collectors)
+	var patterns []*stats.PatternFreq
+	if opts.Collectors&(CL_patterns|CL_pii) != 0 {
+		patterns = make([]*stats.PatternFreq, len(types))
+		for i := range patterns {
+			patterns[i] = stats.NewPatternFreq()
+		}
+	}
+	var dups *duplicateDetector
+	if opts.Duplicates != nil {
+		var err error
+		if dups, err = newDuplicateDetector(*opts.Duplicates, fields); err != nil {
+			return nil, err
+		}
+	}
+	var corr *correlationCollector
+	if opts.Correlations != nil {
+		corr = newCorrelationCollector(*opts.Correlations, fields, types)
+	}
+	var histograms []*stats.Histogram
+	if opts.Collectors&CL_histogram != 0 {
+		histograms = make([]*stats.Histogram, len(types))
+		for i, t := range types {
+			if t == DT_int || t == DT_float {
+				histograms[i] = stats.NewHistogram()
+			}
+		}
+	}
+	var nullMaps []*stats.NullMap
+	if opts.Collectors&CL_nullmap != 0 {
+		nullMaps = make([]*stats.NullMap, len(types))
+		for i := range nullMaps {
+			nullMaps[i] = stats.NewNullMap()
+		}
+	}
+	noOffields := len(fields)
+	// collectors that take single values
+	pushRow := func(row []any) {
+		for i, p := range patterns {
+			p.Push(row[i])
+		}
+		for i, h := range histograms {
+			if h != nil {
+				h.Push(row[i])
+			}
+		}
+		for i, m := range nullMaps {
+			m.Push(row[i])
+		}
+		if dups != nil {
+			dups.Push(row)
+		}
+		if corr != nil {
+			corr.Push(row)
+		}
+	}
+	perRow := patterns != nil || histograms != nil || nullMaps != nil || dups != nil || corr != nil
+	rowCount := 0
+	start := time.Now()
+	if nativeBatches(fr) {
+		var row []any
+		for b := range ReadBatches(fr, BATCH_SIZE) {
+			for i := 0; i < noOffields; i++ {
+				b.Columns[i].push(statCollectors[i])
+			}
+			rowCount += b.Rows
+			for k := 0; perRow && k < b.Rows; k++ {
+				row = b.Row(k, row)
+				pushRow(row)
+			}
+		}
+	} else {
+		for row := range fr.Read() {
+			rowCount++
+			for i := 0; i < noOffields; i++ {
+				statCollectors[i].Push(row[i])
+			}
+			pushRow(row)
+		}
+	}
+	r := &Report{
+		FileName:      fr.FileName(),
+		FileInfo:      fr.GetFileInfo(),
+		RowCount:      rowCount,
+		Start:         start,
+		Duration:      time.Since(start),
+		NoOfSamples:   opts.NoOfSamples,
+		LeastFrequent: opts.LeastFrequent,
+		Fields:        make([]FieldReport, noOffields),
+	}
+	for i, field := range fields {
+		s := statCollectors[i]
+		f := FieldReport{Name: field, Index: i, Type: types[i], Count: s.Count(), Comment: s.Info(), Collector: s}
+		if rowCount > 0 {
+			f.Coverage = float64(100*s.Count()) / float64(rowCount)
+		}
+		switch c := s.(type) {
+		case *stats.RunningStats:
+			f.Nulls = c.Nulls()
+			if c.Count() > 0 {
+				f.Numeric = &NumericStats{Min: c.Min(), Max: c.Max(), Mean: c.Mean(), StdDev: c.StdDev()}
+			}
+		case *stats.StringFreq:
+			f.Nulls = c.Nulls()
+			f.Strings = &StringStats{MinLength: c.MinLen(), MaxLength: c.MaxLen()}
+			vals, counts := c.Freq(opts.NoOfSamples, opts.LeastFrequent)
+			for k := range vals {
+				f.Strings.Values = append(f.Strings.Values,
+					ValueCount{Value: vals[k], Count: counts[k], Percent: float64(100*counts[k]) / float64(rowCount)})
+			}
+		case *stats.Counter:
+			f.Nulls = c.Nulls()
+		}
+		if opts.Collectors&CL_patterns != 0 {
+			f.Patterns = newPatternStats(patterns[i], opts.NoOfSamples, opts.LeastFrequent, rowCount)
+		}
+		if histograms != nil && histograms[i] != nil && histograms[i].Count() > 0 {
+			edges, counts := histograms[i].Bins(N_HISTOGRAM_BINS)
+			f.Histogram = &HistogramStats{Edges: edges, Counts: counts}
+		}
+		if nullMaps != nil {
+			f.NullMap = nullMaps[i].Shares()
+			r.NullMapChunk = nullMaps[i].ChunkSize()
+		}
+		if opts.Collectors&CL_pii != 0 {
+			f.PII = likelyPII(patterns[i])
+			if f.PII != "" {
+				f.Comment = strings.TrimSpace(f.Comment + " PII: " + strings.ToUpper(f.PII))
+			}
+		}
+		r.Fields[i] = f
+	}
+	if dups != nil {
+		var err error
+		if r.Duplicates, err = dups.Report(); err != nil {
+			return nil, err
+		}
+	}
+	if corr != nil {
+		r.Correlations = corr.Report()
+	}
+	if opts.Sorted {
+		sort.SliceStable(r.Fields, func(i, j int) bool { return r.Fields[i].Name < r.Fields[j].Name })
+	}
+	return r, nil
+}
+
+func newPatternStats(p *stats.PatternFreq, n int, least bool, rowCount int) *PatternStats {
+	ps := &PatternStats{SemanticType: p.Dominant(0.9)}
+	percent := func(count int) float64 { return float64(100*count) / float64(rowCount) }
+	vals, counts := p.Freq(n, least)
+	for k := range vals {
+		ps.Values = append(ps.Values, ValueCount{Value: vals[k], Count: counts[k], Percent: percent(counts[k])})
+	}
+	vals, counts = p.Semantic()
+	for k := range vals {
+		ps.Semantic = append(ps.Semantic, ValueCount{Value: vals[k], Count: counts[k], Percent: percent(counts[k])})
+	}
+	return ps
+}
+
+func likelyPII(p *stats.PatternFreq) string {
+	types, counts := p.Semantic()
+	for i, t := range types {
+		if stats.IsPII(t) && float64(counts[i]) >= PII_MIN_SHARE*float64(p.Count()) {
+			return t
+		}
+	}
+	return ""
+}
+
+// Field returns stats of the field with the given name (nil if there's no such field)
+func (r *Report) Field(name string) *FieldReport {
+	for i := range r.Fields {
+		if r.Fields[i].Name == name {
+			return &r.Fields[i]
+		}
+	}
+	return nil
+}
+
+// WriteText renders the report in the fixed-width text format used by gcf
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "File:", r.FileName)
+	fmt.Fprintln(w, "Info:", r.FileInfo)
+
+	if r.RowCount < 1 {
+		fmt.Fprintln(w, "No data found")
+		return
+	}
+
+	fmt.Fprintln(w, "=================")
+	fmt.Fprintln(w, " coverage report ")
+	fmt.Fprintln(w, "=================")
+
+	maxFieldLen := 30
+	for _, field := range r.Fields {
+		if len(field.Name) > maxFieldLen {
+			maxFieldLen = len(field.Name)
+		}
+	}
+	smaxFieldLen := strconv.Itoa(maxFieldLen)
+	// print header
+	headerTemplate := "%-" + smaxFieldLen + "s : %-8s : %-6s : %-16s : %s"
+	template := "%-" + smaxFieldLen + "s : %-8d : %-6.2f : %-16s : %s\n"
+	h1 := fmt.Sprintf(headerTemplate, "field", "count", "%", "type", "comment")
+	fmt.Fprintln(w, h1)
+	fmt.Fprintln(w, strings.Repeat("-", len(h1)))
+
+	anyValues := false
+	for _, field := range r.Fields {
+		fmt.Fprintf(w, template, field.Name, field.Count, field.Coverage, field.Type, field.Comment)
+		anyValues = anyValues || field.Strings != nil
+	}
+	fmt.Fprintln(w)
+
+	// n most/least frequent values for categorical
+	if anyValues {
+		var title2 string
+		if r.LeastFrequent {
+			title2 = fmt.Sprintf("%d least frequent string values", r.NoOfSamples)
+		} else {
+			title2 = fmt.Sprintf("%d most frequent string values", r.NoOfSamples)
+		}
+		fmt.Fprintln(w, title2)
+		fmt.Fprintln(w, strings.Repeat("=", len(title2)))
+
+		header2Template := "%-" + smaxFieldLen + "s : %-8s : %-6s : %-16s"
+		h2 := fmt.Sprintf(header2Template, "field", "count", "%", "value")
+		fmt.Fprintln(w, h2)
+		fmt.Fprintln(w, strings.Repeat("-", len(h2)))
+		template2 := "%-" + smaxFieldLen + "s : %-8d : %-6.2f : "
+
+		for _, field := range r.Fields {
+			if field.Strings == nil {
+				continue
+			}
+			fmt.Fprintln(w, field.Name)
+			if field.Count == 0 {
+				fmt.Fprintf(w, "%-"+smaxFieldLen+"s : %s\n", "", "--- NOT AVAILABLE ---")
+			} else {
+				for _, v := range field.Strings.Values {
+					fmt.Fprintf(w, template2, "", v.Count, v.Percent)
+					fmt.Fprintln(w, v.Value)
+				}
+			}
+		}
+	}
+	r.writePatterns(w, smaxFieldLen)
+	if r.Duplicates != nil {
+		r.Duplicates.writeText(w)
+	}
+	if r.Correlations != nil {
+		r.Correlations.writeText(w)
+	}
+	fmt.Fprintf(w, "Done in %.3f seconds.\n", r.Duration.Seconds())
+}
+
+func (r *Report) writePatterns(w io.Writer, smaxFieldLen string) {
+	if len(r.Fields) == 0 || r.Fields[0].Patterns == nil {
+		return
+	}
+	var title string
+	if r.LeastFrequent {
+		title = fmt.Sprintf("%d least frequent patterns", r.NoOfSamples)
+	} else {
+		title = fmt.Sprintf("%d most frequent patterns", r.NoOfSamples)
+	}
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w, strings.Repeat("=", len(title)))
+	h := fmt.Sprintf("%-"+smaxFieldLen+"s : %-8s : %-6s : %-16s", "field", "count", "%", "pattern")
+	fmt.Fprintln(w, h)
+	fmt.Fprintln(w, strings.Repeat("-", len(h)))
+	template := "%-" + smaxFieldLen + "s : %-8d : %-6.2f : %s\n"
+	for _, field := range r.Fields {
+		p := field.Patterns
+		fmt.Fprint(w, field.Name)
+		if p.SemanticType != "" {
+			fmt.Fprintf(w, " (%s)", strings.ToUpper(p.SemanticType))
+		}
+		fmt.Fprintln(w)
+		if len(p.Values) == 0 {
+			fmt.Fprintf(w, "%-"+smaxFieldLen+"s : %s\n", "", "--- NOT AVAILABLE ---")
+		}
+		for _, v := range p.Values {
+			fmt.Fprintf(w, template, "", v.Count, v.Percent, v.Value)
+		}
+		for _, v := range p.Semantic {
+			fmt.Fprintf(w, template, "", v.Count, v.Percent, "type: "+strings.ToUpper(v.Value))
+		}
+	}
+	fmt.Fprintln(w)
+}
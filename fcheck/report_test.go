@@ -0,0 +1,123 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// newTestReport returns the report of the reader, the test fails on an error
+func newTestReport(t *testing.T, fr FileReader, opts ReportOptions) *Report {
+	t.Helper()
+	r, err := NewReport(fr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestNewReport(t *testing.T) {
+	cr := NewCsvReader("../test/data/simple.csv", ',')
+	r := newTestReport(t, &cr, ReportOptions{Sorted: true, NoOfSamples: 3})
+	if r.RowCount != CSV_SIMPLE_ROWS {
+		t.Errorf("Expected %d rows, got %d", CSV_SIMPLE_ROWS, r.RowCount)
+	}
+	if len(r.Fields) != 10 {
+		t.Fatalf("Expected 10 fields, got %d", len(r.Fields))
+	}
+	for i := 1; i < len(r.Fields); i++ {
+		if r.Fields[i-1].Name > r.Fields[i].Name {
+			t.Errorf("fields not sorted: %s > %s", r.Fields[i-1].Name, r.Fields[i].Name)
+		}
+	}
+	long := r.Field("LONG")
+	if long == nil || long.Type != DT_int || long.Numeric == nil || long.Strings != nil {
+		t.Errorf("invalid LONG stats: %+v", long)
+	} else if long.Index != 2 || long.Coverage != 100 {
+		t.Errorf("invalid LONG index/coverage: %d, %f", long.Index, long.Coverage)
+	}
+	vnull := r.Field("V_NULL")
+	if vnull == nil || vnull.Count != 0 || vnull.Comment != "EMPTY" {
+		t.Errorf("invalid V_NULL stats: %+v", vnull)
+	}
+	vsnull := r.Field("VS_NULL")
+	if vsnull == nil || vsnull.Strings == nil || len(vsnull.Strings.Values) != 1 || vsnull.Strings.Values[0].Value != "Null" {
+		t.Errorf("invalid VS_NULL stats: %+v", vsnull)
+	} else if vsnull.Strings.MinLength != 4 || vsnull.Strings.MaxLength != 4 {
+		t.Errorf("invalid VS_NULL length: %+v", vsnull.Strings)
+	}
+	if r.Field("nope") != nil {
+		t.Error("unexpected field")
+	}
+	js, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(js), `"type":"int"`) {
+		t.Errorf("type not marshalled as string: %s", js)
+	}
+}
+
+func TestNewReportCollectors(t *testing.T) {
+	cr := NewCsvReader("../test/data/simple.csv", ',')
+	r := newTestReport(t, &cr, ReportOptions{Collectors: CL_stats})
+	for _, f := range r.Fields {
+		if f.Strings != nil {
+			t.Errorf("%s: string stats collected", f.Name)
+		}
+		if f.Type == DT_int && f.Numeric == nil {
+			t.Errorf("%s: numeric stats not collected", f.Name)
+		}
+	}
+	if r.Fields[0].Name != "BOOLEAN" || r.Fields[0].Count != CSV_SIMPLE_ROWS {
+		t.Errorf("unexpected first field: %+v", r.Fields[0])
+	}
+}
+
+func TestReportWriteText(t *testing.T) {
+	fr := NewAvroReader(AVRO_NULL_PATH)
+	r := newTestReport(t, &fr, ReportOptions{Sorted: true, NoOfSamples: 2, LeastFrequent: true})
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+	for _, s := range []string{"File: " + AVRO_NULL_PATH, " coverage report ", "2 least frequent string values", "Done in"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%q not found in the report:\n%s", s, out)
+		}
+	}
+}
+
+func TestReportPatterns(t *testing.T) {
+	sr := &sliceReader{
+		fields: []string{"code", "email", "n"},
+		types:  []DataType{DT_string, DT_string, DT_int},
+		rows: [][]any{
+			{"AB-1234", "a@example.com", int64(1)},
+			{"XY-0001", "b@example.com", int64(22)},
+			{"ab-12", "c@example.com", nil},
+		},
+	}
+	r := newTestReport(t, sr, ReportOptions{NoOfSamples: 5, Collectors: CL_default | CL_patterns})
+	code := r.Field("code").Patterns
+	if code == nil || len(code.Values) != 2 || code.Values[0].Value != "AA-9999" || code.Values[0].Count != 2 || code.SemanticType != "" {
+		t.Errorf("invalid code patterns: %+v", code)
+	}
+	email := r.Field("email").Patterns
+	if email == nil || email.SemanticType != "email" || len(email.Semantic) != 1 || email.Semantic[0].Count != 3 {
+		t.Errorf("invalid email patterns: %+v", email)
+	}
+	if n := r.Field("n").Patterns; n == nil || len(n.Values) != 2 {
+		t.Errorf("invalid n patterns: %+v", n)
+	}
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	for _, s := range []string{"5 most frequent patterns", "email (EMAIL)", "AA-9999", "type: EMAIL"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("%q not in the report:\n%s", s, buf.String())
+		}
+	}
+	if newTestReport(t, sr, ReportOptions{}).Field("code").Patterns != nil {
+		t.Error("patterns collected by default")
+	}
+}
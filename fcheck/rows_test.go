@@ -0,0 +1,45 @@
+package fcheck
+
+import (
+	"testing"
+)
+
+// readers must not change rows after sending them, consumers may keep all of them
+func TestRowOwnership(t *testing.T) {
+	readers := map[string]func() FileReader{
+		"csv": func() FileReader {
+			cr := NewCsvReader("../test/data/simple.csv", ',')
+			return &cr
+		},
+		"filtered and projected": func() FileReader {
+			cr := NewCsvReader("../test/data/simple.csv", ',')
+			fr, _ := NewFilteredReader(&cr, "INTEGER > 100")
+			pr, _ := NewProjectedReader(fr, "INTEGER,STRING")
+			return pr
+		},
+		"reservoir": func() FileReader {
+			cr := NewCsvReader("../test/data/simple.csv", ',')
+			return NewSampledReader(&cr, Sampling{Mode: SM_reservoir, N: 300, Seed: 1})
+		},
+	}
+	expected := map[string]int{"csv": 1000, "filtered and projected": 900, "reservoir": 300}
+	for name, newReader := range readers {
+		fr := newReader()
+		fr.Init()
+		k := indexof(fr.GetFields(), "INTEGER")
+		var rows [][]any
+		for row := range fr.Read() {
+			rows = append(rows, row)
+		}
+		if len(rows) != expected[name] {
+			t.Errorf("%s: expected %d rows, got %d", name, expected[name], len(rows))
+		}
+		// the rows are in the file order, INTEGER is the line number
+		for i := 1; i < len(rows); i++ {
+			if prev, v := rows[i-1][k].(int64), rows[i][k].(int64); v <= prev {
+				t.Errorf("%s: row %d changed after it was sent: %d after %d", name, i, v, prev)
+				break
+			}
+		}
+	}
+}
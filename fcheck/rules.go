@@ -0,0 +1,391 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gocf/fcheck/stats"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules are data quality expectations loaded from a YAML file, e.g.:
+//
+//	row_count: {min: 1, max: 1000000}
+//	fields:
+//	  - name: id
+//	    not_null: 100
+//	    unique: true
+//	  - name: country
+//	    allowed: [PL, DE, CZ]
+//	  - name: amount
+//	    range: {min: 0}
+//	  - name: email
+//	    regex: '^[^@ ]+@[^@ ]+$'
+//	    length: {max: 100}
+//	  - name: updated_at
+//	    fresh: 2d
+type Rules struct {
+	RowCount *Bounds      `yaml:"row_count"`
+	Fields   []FieldRules `yaml:"fields"`
+}
+
+// Bounds is an inclusive range, a nil bound is not checked
+type Bounds struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// FieldRules are expectations of a single field, nulls are skipped by all rules except not_null
+type FieldRules struct {
+	Name    string   `yaml:"name"`
+	NotNull *float64 `yaml:"not_null"` // min % of not null (and not empty) values
+	Unique  bool     `yaml:"unique"`
+	Allowed []string `yaml:"allowed"` // values compared as text
+	Regex   string   `yaml:"regex"`   // must match the whole value as text
+	Range   *Bounds  `yaml:"range"`   // numeric values
+	Length  *Bounds  `yaml:"length"`  // length of values as text (in characters)
+	Fresh   string   `yaml:"fresh"`   // max age of the newest timestamp, e.g. 90m, 24h, 7d
+}
+
+// layouts tried when parsing timestamps for the fresh rule (numbers are unix seconds or milliseconds)
+var TIMESTAMP_LAYOUTS = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05", "2006-01-02"}
+
+func (b *Bounds) String() string {
+	switch {
+	case b.Min != nil && b.Max != nil:
+		return fmt.Sprintf("between %g and %g", *b.Min, *b.Max)
+	case b.Min != nil:
+		return fmt.Sprintf(">= %g", *b.Min)
+	case b.Max != nil:
+		return fmt.Sprintf("<= %g", *b.Max)
+	}
+	return "any"
+}
+
+func (b *Bounds) contains(x float64) bool {
+	return (b.Min == nil || x >= *b.Min) && (b.Max == nil || x <= *b.Max)
+}
+
+// parseAge is time.ParseDuration with days (d) added
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func parseTimestamp(value any) (time.Time, bool) {
+	if x, ok := stats.ToFloat(value); ok {
+		if x > 1e11 {
+			// milliseconds
+			return time.UnixMilli(int64(x)), true
+		}
+		return time.Unix(int64(x), 0), true
+	}
+	s, _ := formatValue(value)
+	for _, layout := range TIMESTAMP_LAYOUTS {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseRules parses and validates rules, unknown keys are errors (to catch typos)
+func ParseRules(data []byte) (*Rules, error) {
+	var r Rules
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	for _, f := range r.Fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("invalid rules: field without a name")
+		}
+		if f.Regex != "" {
+			if _, err := regexp.Compile(f.Regex); err != nil {
+				return nil, fmt.Errorf("invalid regex of %s: %w", f.Name, err)
+			}
+		}
+		if f.Fresh != "" {
+			if _, err := parseAge(f.Fresh); err != nil {
+				return nil, fmt.Errorf("invalid fresh of %s: %w", f.Name, err)
+			}
+		}
+	}
+	return &r, nil
+}
+
+func LoadRules(fileName string) (*Rules, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data)
+}
+
+// RuleResult is the outcome of a single rule
+type RuleResult struct {
+	Field    string `json:"field,omitempty"` // "" for file level rules
+	Rule     string `json:"rule"`
+	Expected string `json:"expected"`
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message"` // observed stats, offending values
+}
+
+// CheckResult holds results of all rules
+type CheckResult struct {
+	FileName string        `json:"file_name"`
+	FileInfo string        `json:"file_info"`
+	RowCount int           `json:"row_count"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Failed   int           `json:"failed"`
+	Results  []RuleResult  `json:"results"`
+}
+
+// rowCheck counts values violating a rule, with a few examples
+type rowCheck struct {
+	field    int
+	rule     string
+	expected string
+	test     func(value any) bool
+	checked  int
+	failed   int
+	examples []string
+}
+
+const N_RULE_EXAMPLES = 5
+
+func (c *rowCheck) push(value any) {
+	if value == nil {
+		return
+	}
+	c.checked++
+	if !c.test(value) {
+		c.failed++
+		if len(c.examples) < N_RULE_EXAMPLES {
+			s, _ := formatValue(value)
+			c.examples = append(c.examples, strconv.Quote(s))
+		}
+	}
+}
+
+func (c *rowCheck) result(field string) RuleResult {
+	r := RuleResult{Field: field, Rule: c.rule, Expected: c.expected, Passed: c.failed == 0}
+	if c.failed == 0 {
+		r.Message = fmt.Sprintf("%d values checked", c.checked)
+	} else {
+		r.Message = fmt.Sprintf("%d of %d values failed, e.g. %s", c.failed, c.checked, strings.Join(c.examples, ", "))
+	}
+	return r
+}
+
+// Check reads all rows (Init() is called here) and evaluates the rules
+func (r *Rules) Check(fr FileReader) (*CheckResult, error) {
+	fr.Init()
+	fields := fr.GetFields()
+	index := make([]int, len(r.Fields))
+	for i, f := range r.Fields {
+		if index[i] = indexof(fields, f.Name); index[i] < 0 {
+			return nil, fmt.Errorf("unknown field in rules: %s", f.Name)
+		}
+	}
+
+	counters := make([]stats.Counter, len(r.Fields))
+	uniques := make([]*spillCounter, len(r.Fields))
+	newest := make([]*time.Time, len(r.Fields))
+	var checks []*rowCheck
+	for i, f := range r.Fields {
+		if f.Unique {
+			uniques[i] = newSpillCounter(1000000, "")
+		}
+		if len(f.Allowed) > 0 {
+			allowed := map[string]bool{}
+			for _, a := range f.Allowed {
+				allowed[a] = true
+			}
+			checks = append(checks, &rowCheck{field: i, rule: "allowed", expected: "one of " + strings.Join(f.Allowed, ", "),
+				test: func(v any) bool { s, _ := formatValue(v); return allowed[s] }})
+		}
+		if f.Regex != "" {
+			re := regexp.MustCompile("^(?:" + f.Regex + ")$")
+			checks = append(checks, &rowCheck{field: i, rule: "regex", expected: "matches " + f.Regex,
+				test: func(v any) bool { s, _ := formatValue(v); return re.MatchString(s) }})
+		}
+		if f.Range != nil {
+			b := f.Range
+			checks = append(checks, &rowCheck{field: i, rule: "range", expected: b.String(),
+				test: func(v any) bool { x, ok := stats.ToFloat(v); return ok && b.contains(x) }})
+		}
+		if f.Length != nil {
+			b := f.Length
+			checks = append(checks, &rowCheck{field: i, rule: "length", expected: "length " + b.String(),
+				test: func(v any) bool { s, _ := formatValue(v); return b.contains(float64(utf8.RuneCountInString(s))) }})
+		}
+		if f.Fresh != "" {
+			n := i
+			checks = append(checks, &rowCheck{field: i, rule: "timestamp", expected: "valid timestamp",
+				test: func(v any) bool {
+					t, ok := parseTimestamp(v)
+					if ok && (newest[n] == nil || t.After(*newest[n])) {
+						newest[n] = &t
+					}
+					return ok
+				}})
+		}
+	}
+
+	res := &CheckResult{FileName: fr.FileName(), FileInfo: fr.GetFileInfo(), Start: time.Now()}
+	var buf []byte
+	var err error
+	for row := range fr.Read() {
+		res.RowCount++
+		for i, j := range index {
+			v := row[j]
+			counters[i].Push(v)
+			if uniques[i] != nil && v != nil && err == nil {
+				buf = appendValue(buf[:0], v)
+				err = uniques[i].add(buf)
+			}
+		}
+		for _, c := range checks {
+			c.push(row[index[c.field]])
+		}
+	}
+	if err != nil {
+		for _, u := range uniques {
+			if u != nil {
+				u.cleanup()
+			}
+		}
+		return nil, err
+	}
+
+	add := func(rr RuleResult) {
+		if !rr.Passed {
+			res.Failed++
+		}
+		res.Results = append(res.Results, rr)
+	}
+	if r.RowCount != nil {
+		add(RuleResult{Rule: "row_count", Expected: r.RowCount.String(), Passed: r.RowCount.contains(float64(res.RowCount)),
+			Message: fmt.Sprintf("%d rows", res.RowCount)})
+	}
+	for i, f := range r.Fields {
+		c := &counters[i]
+		if f.NotNull != nil {
+			pct := 100.0
+			if res.RowCount > 0 {
+				pct = float64(100*c.Count()) / float64(res.RowCount)
+			}
+			add(RuleResult{Field: f.Name, Rule: "not_null", Expected: fmt.Sprintf(">= %g%%", *f.NotNull), Passed: pct >= *f.NotNull,
+				Message: fmt.Sprintf("%.2f%% not null, %d nulls, %d empty", pct, c.Nulls(), res.RowCount-c.Count()-c.Nulls())})
+		}
+		if uniques[i] != nil {
+			dups, duplicated, examples, err := uniques[i].result(N_RULE_EXAMPLES)
+			if err != nil {
+				return nil, err
+			}
+			rr := RuleResult{Field: f.Name, Rule: "unique", Expected: "no duplicates", Passed: dups == 0, Message: "no duplicates"}
+			if dups > 0 {
+				var e []string
+				for _, k := range examples {
+					e = append(e, fmt.Sprintf("%s (%d)", strings.Join(k.Key, ", "), k.Count))
+				}
+				rr.Message = fmt.Sprintf("%d duplicate rows, %d duplicated values, e.g. %s", dups, duplicated, strings.Join(e, ", "))
+			}
+			add(rr)
+		}
+		for _, ch := range checks {
+			if ch.field == i {
+				add(ch.result(f.Name))
+			}
+		}
+		if f.Fresh != "" {
+			age, _ := parseAge(f.Fresh)
+			rr := RuleResult{Field: f.Name, Rule: "fresh", Expected: "newest value not older than " + f.Fresh}
+			if newest[i] == nil {
+				rr.Message = "no timestamps"
+			} else {
+				rr.Passed = res.Start.Sub(*newest[i]) <= age
+				rr.Message = fmt.Sprintf("newest: %s (%s ago)", newest[i].Format(time.RFC3339), res.Start.Sub(*newest[i]).Round(time.Second))
+			}
+			add(rr)
+		}
+	}
+	res.Duration = time.Since(res.Start)
+	return res, nil
+}
+
+// WriteText prints one line per rule and a summary
+func (c *CheckResult) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "File:", c.FileName)
+	fmt.Fprintln(w, "Info:", c.FileInfo)
+	fmt.Fprintln(w, "Rows:", c.RowCount)
+	for _, r := range c.Results {
+		status := "PASS"
+		if !r.Passed {
+			status = "FAIL"
+		}
+		name := r.Rule
+		if r.Field != "" {
+			name = r.Field + ": " + r.Rule
+		}
+		fmt.Fprintf(w, "%s %s (%s): %s\n", status, name, r.Expected, r.Message)
+	}
+	fmt.Fprintf(w, "%d rules, %d failed. Done in %.3f seconds.\n", len(c.Results), c.Failed, c.Duration.Seconds())
+}
+
+func (c *CheckResult) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// WriteJUnit writes a test suite for the file with a test case per rule
+func (c *CheckResult) WriteJUnit(w io.Writer) error {
+	s := newJUnitSuite(c.FileName, c.Start, c.Duration)
+	for _, r := range c.Results {
+		tc := junitCase{Name: r.Rule + " " + r.Expected, Classname: c.FileName, SystemOut: r.Message}
+		if r.Field != "" {
+			tc.Name = r.Field + ": " + tc.Name
+			tc.Classname += "." + r.Field
+		}
+		if !r.Passed {
+			tc.Failure = &junitFailure{Message: r.Message, Type: r.Rule, Text: r.Message}
+		}
+		s.add(tc)
+	}
+	return writeJUnit(w, s)
+}
+
+// WriteSARIF writes failed rules as SARIF errors
+func (c *CheckResult) WriteSARIF(w io.Writer) error {
+	var rules [][2]string
+	seen := map[string]bool{}
+	var results []sarifResult
+	for _, r := range c.Results {
+		if !seen[r.Rule] {
+			seen[r.Rule] = true
+			rules = append(rules, [2]string{r.Rule, "gcf check rule: " + r.Rule})
+		}
+		if !r.Passed {
+			results = append(results, newSarifResult(c.FileName, r.Field, r.Rule, FL_error, r.Expected+": "+r.Message))
+		}
+	}
+	return writeSARIF(w, rules, results)
+}
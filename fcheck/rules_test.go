@@ -0,0 +1,139 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRules = `
+row_count: {min: 1, max: 4}
+fields:
+  - name: id
+    not_null: 100
+    unique: true
+  - name: country
+    not_null: 50
+    allowed: [PL, DE]
+  - name: amount
+    range: {min: 0, max: 100}
+  - name: email
+    regex: '[^@ ]+@[^@ ]+'
+    length: {max: 12}
+  - name: updated
+    fresh: 2d
+`
+
+func rulesRows() *sliceReader {
+	now := time.Now()
+	return &sliceReader{
+		fields: []string{"id", "country", "amount", "email", "updated"},
+		types:  []DataType{DT_int, DT_string, DT_float, DT_string, DT_string},
+		rows: [][]any{
+			{int64(1), "PL", 10.0, "a@b.pl", now.Add(-72 * time.Hour).Format(time.RFC3339)},
+			{int64(2), "CZ", -1.0, "not an email", now.Add(-time.Hour).Format("2006-01-02 15:04:05")},
+			{int64(2), "", nil, nil, "yesterday"},
+			{nil, "DE", 101.0, "x@y.com", nil},
+		},
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	r, err := ParseRules([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Fields) != 5 || *r.RowCount.Max != 4 || !r.Fields[0].Unique || r.Fields[4].Fresh != "2d" {
+		t.Errorf("unexpected rules: %+v", r)
+	}
+	for _, bad := range []string{"fields:\n  - name: x\n    not_nul: 10", "fields:\n  - regex: x", "fields:\n  - name: x\n    regex: '('", "fields:\n  - name: x\n    fresh: 2 days"} {
+		if _, err := ParseRules([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	r, _ := ParseRules([]byte(testRules))
+	res, err := r.Check(rulesRows())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name   string
+		passed bool
+	}{
+		{"row_count", true},
+		{"id: not_null", false},
+		{"id: unique", false},
+		{"country: not_null", true},
+		{"country: allowed", false},
+		{"amount: range", false},
+		{"email: regex", false},
+		{"email: length", true},
+		{"updated: timestamp", false},
+		{"updated: fresh", true},
+	}
+	if len(res.Results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), res.Results)
+	}
+	for i, e := range expected {
+		rr := res.Results[i]
+		name := rr.Rule
+		if rr.Field != "" {
+			name = rr.Field + ": " + rr.Rule
+		}
+		if name != e.name || rr.Passed != e.passed {
+			t.Errorf("expected %s passed=%v, got %+v", e.name, e.passed, rr)
+		}
+	}
+	if res.Failed != 6 {
+		t.Errorf("expected 6 failures, got %d", res.Failed)
+	}
+	if m := res.Results[5].Message; m != `2 of 3 values failed, e.g. "-1", "101"` {
+		t.Errorf("unexpected range message: %s", m)
+	}
+	if m := res.Results[2].Message; !strings.HasPrefix(m, "1 duplicate rows, 1 duplicated values, e.g. 2 (2)") {
+		t.Errorf("unexpected unique message: %s", m)
+	}
+
+	if _, err := (&Rules{Fields: []FieldRules{{Name: "nope"}}}).Check(rulesRows()); err == nil {
+		t.Error("unknown field not reported")
+	}
+}
+
+func TestCheckResultOutput(t *testing.T) {
+	r, _ := ParseRules([]byte(testRules))
+	res, _ := r.Check(rulesRows())
+	var buf bytes.Buffer
+	res.WriteText(&buf)
+	if !strings.Contains(buf.String(), "FAIL amount: range (between 0 and 100)") || !strings.Contains(buf.String(), "10 rules, 6 failed") {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+	buf.Reset()
+	if err := res.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Tests != 10 || doc.Failures != 6 || len(doc.Suites) != 1 || doc.Suites[0].Cases[5].Failure == nil {
+		t.Errorf("unexpected junit output:\n%s", buf.String())
+	}
+	buf.Reset()
+	if err := res.WriteSARIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var sarif sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &sarif); err != nil || len(sarif.Runs[0].Results) != 6 || len(sarif.Runs[0].Tool.Driver.Rules) != 9 {
+		t.Errorf("unexpected sarif output: %v\n%s", err, buf.String())
+	}
+	buf.Reset()
+	if err := res.WriteJSON(&buf); err != nil || !strings.Contains(buf.String(), `"failed": 6`) {
+		t.Errorf("unexpected json output: %v\n%s", err, buf.String())
+	}
+}
@@ -0,0 +1,241 @@
+package fcheck
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Enum SampleMode specifies how rows are sampled by SampledReader
+type SampleMode uint
+
+const (
+	SM_head      SampleMode = iota // first N rows
+	SM_tail                        // last N rows
+	SM_reservoir                   // uniform random sample of N rows
+	SM_fraction                    // each row with the probability Fraction (Bernoulli sampling)
+	SM_every                       // every N-th row, starting with the first one
+)
+
+func (m SampleMode) String() string {
+	switch m {
+	case SM_head:
+		return "head"
+	case SM_tail:
+		return "tail"
+	case SM_reservoir:
+		return "reservoir"
+	case SM_fraction:
+		return "fraction"
+	case SM_every:
+		return "every"
+	}
+	return "unknown"
+}
+
+// Sampling describes a sample of rows
+type Sampling struct {
+	Mode     SampleMode
+	N        int     // number of rows (head, tail, reservoir) or the step (every)
+	Fraction float64 // 0..1 (fraction)
+	Seed     int64   // seed of the random generator (reservoir, fraction), 0 picks a random seed
+}
+
+// ParseSampling parses the sampling spec: head:N, tail:N, reservoir:N, fraction:F (0.01 or 1%) or every:N
+func ParseSampling(spec string) (Sampling, error) {
+	var s Sampling
+	mode, arg, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return s, fmt.Errorf("invalid sampling %q, expected mode:value", spec)
+	}
+	switch mode {
+	case "head":
+		s.Mode = SM_head
+	case "tail":
+		s.Mode = SM_tail
+	case "reservoir":
+		s.Mode = SM_reservoir
+	case "fraction":
+		s.Mode = SM_fraction
+	case "every":
+		s.Mode = SM_every
+	default:
+		return s, fmt.Errorf("unknown sampling mode: %s (expected head, tail, reservoir, fraction or every)", mode)
+	}
+	if s.Mode == SM_fraction {
+		var err error
+		if strings.HasSuffix(arg, "%") {
+			s.Fraction, err = strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+			s.Fraction /= 100
+		} else {
+			s.Fraction, err = strconv.ParseFloat(arg, 64)
+		}
+		if err != nil || s.Fraction < 0 || s.Fraction > 1 {
+			return s, fmt.Errorf("invalid fraction: %s", arg)
+		}
+		return s, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return s, fmt.Errorf("invalid number of rows: %s", arg)
+	}
+	s.N = n
+	return s, nil
+}
+
+func (s Sampling) String() string {
+	switch s.Mode {
+	case SM_reservoir:
+		return fmt.Sprintf("random %d rows (seed %d)", s.N, s.Seed)
+	case SM_fraction:
+		return fmt.Sprintf("random %g%% of rows (seed %d)", 100*s.Fraction, s.Seed)
+	case SM_every:
+		return fmt.Sprintf("every %d. row", s.N)
+	}
+	return fmt.Sprintf("%s %d rows", s.Mode, s.N)
+}
+
+// Stopper is implemented by readers that can stop reading before the end of the file. Stop is called while
+// receiving the rows of Read() (or the batches of ReadBatches), the reader then closes the file and the channel
+// without reading the rest. The receiver still receives till the channel is closed.
+type Stopper interface {
+	Stop()
+}
+
+// errStopped is returned by the decode functions of readers to stop reading
+var errStopped = errors.New("stopped")
+
+// stopReader stops the reader if it's a Stopper
+func stopReader(fr FileReader) {
+	if s, ok := fr.(Stopper); ok {
+		s.Stop()
+	}
+}
+
+// readStop implements Stopper, each read gets a new channel that Stop closes
+type readStop struct {
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// begin returns the channel of a new read, it's called before the reading goroutine is started
+func (rs *readStop) begin() chan struct{} {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.done = make(chan struct{})
+	return rs.done
+}
+
+func (rs *readStop) Stop() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.done != nil {
+		close(rs.done)
+		rs.done = nil
+	}
+}
+
+// SampledReader wraps a FileReader and passes only a sample of its rows.
+// Rows are passed in the original order, also for the random modes.
+// For the head mode the wrapped reader is stopped after the first N rows if it's a Stopper, otherwise
+// the rest of its rows are skipped. Either way the channel is closed after the wrapped reader finished.
+type SampledReader struct {
+	FileReader
+	sampling Sampling
+}
+
+func NewSampledReader(fr FileReader, s Sampling) *SampledReader {
+	if s.Seed == 0 && (s.Mode == SM_reservoir || s.Mode == SM_fraction) {
+		s.Seed = time.Now().UnixNano()
+	}
+	return &SampledReader{FileReader: fr, sampling: s}
+}
+
+func (sr *SampledReader) GetFileInfo() string {
+	return fmt.Sprintf("%s, sample: %s", sr.FileReader.GetFileInfo(), sr.sampling)
+}
+
+func (sr *SampledReader) Stop() {
+	stopReader(sr.FileReader)
+}
+
+func (sr *SampledReader) Read() chan []any {
+	out := make(chan []any)
+	go func(in chan []any, s Sampling) {
+		rnd := rand.New(rand.NewSource(s.Seed))
+		switch s.Mode {
+		case SM_head:
+			for i := 0; i < s.N; i++ {
+				row, ok := <-in
+				if !ok {
+					break
+				}
+				out <- row
+			}
+			// the rows sent till the reader stops are skipped, out is closed after in so that the reader
+			// doesn't change its counts (see GetFileInfo) after the last row was received
+			stopReader(sr.FileReader)
+			for range in {
+			}
+		case SM_every:
+			i := 0
+			for row := range in {
+				if i%s.N == 0 {
+					out <- row
+				}
+				i++
+			}
+		case SM_fraction:
+			for row := range in {
+				if rnd.Float64() < s.Fraction {
+					out <- row
+				}
+			}
+		case SM_tail:
+			// ring buffer with the last N rows
+			ring := make([][]any, 0, s.N)
+			i := 0
+			for row := range in {
+				if len(ring) < s.N {
+					ring = append(ring, row)
+				} else {
+					ring[i%s.N] = row
+				}
+				i++
+			}
+			if len(ring) < s.N {
+				i = 0
+			}
+			for k := range ring {
+				out <- ring[(i+k)%len(ring)]
+			}
+		case SM_reservoir:
+			// Algorithm R, the row number is kept to restore the original order
+			type sampledRow struct {
+				n   int
+				row []any
+			}
+			reservoir := make([]sampledRow, 0, s.N)
+			i := 0
+			for row := range in {
+				if len(reservoir) < s.N {
+					reservoir = append(reservoir, sampledRow{i, row})
+				} else if j := rnd.Intn(i + 1); j < s.N {
+					reservoir[j] = sampledRow{i, row}
+				}
+				i++
+			}
+			sort.Slice(reservoir, func(a, b int) bool { return reservoir[a].n < reservoir[b].n })
+			for _, r := range reservoir {
+				out <- r.row
+			}
+		}
+		close(out)
+	}(sr.FileReader.Read(), sr.sampling)
+	return out
+}
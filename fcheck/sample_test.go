@@ -0,0 +1,187 @@
+package fcheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func numberedRows(n int) *sliceReader {
+	sr := &sliceReader{fields: []string{"i"}, types: []DataType{DT_int}}
+	for i := 0; i < n; i++ {
+		sr.rows = append(sr.rows, []any{int64(i)})
+	}
+	return sr
+}
+
+func sampledValues(sr *SampledReader) []int64 {
+	sr.Init()
+	var values []int64
+	for row := range sr.Read() {
+		values = append(values, row[0].(int64))
+	}
+	return values
+}
+
+func TestParseSampling(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Sampling
+	}{
+		{"head:10", Sampling{Mode: SM_head, N: 10}},
+		{"tail:1", Sampling{Mode: SM_tail, N: 1}},
+		{"reservoir:100", Sampling{Mode: SM_reservoir, N: 100}},
+		{"fraction:0.25", Sampling{Mode: SM_fraction, Fraction: 0.25}},
+		{"fraction:1%", Sampling{Mode: SM_fraction, Fraction: 0.01}},
+		{"every:7", Sampling{Mode: SM_every, N: 7}},
+	}
+	for _, tc := range tests {
+		s, err := ParseSampling(tc.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tc.spec, err)
+		} else if s != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", tc.spec, tc.expected, s)
+		}
+	}
+	for _, spec := range []string{"head", "head:0", "tail:x", "fraction:2", "fraction:-1%", "random:5"} {
+		if _, err := ParseSampling(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestSampledReader(t *testing.T) {
+	equal := func(a []int64, b ...int64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	if v := sampledValues(NewSampledReader(numberedRows(10), Sampling{Mode: SM_head, N: 3})); !equal(v, 0, 1, 2) {
+		t.Errorf("head: %v", v)
+	}
+	if v := sampledValues(NewSampledReader(numberedRows(10), Sampling{Mode: SM_tail, N: 3})); !equal(v, 7, 8, 9) {
+		t.Errorf("tail: %v", v)
+	}
+	if v := sampledValues(NewSampledReader(numberedRows(2), Sampling{Mode: SM_tail, N: 3})); !equal(v, 0, 1) {
+		t.Errorf("short tail: %v", v)
+	}
+	if v := sampledValues(NewSampledReader(numberedRows(10), Sampling{Mode: SM_every, N: 4})); !equal(v, 0, 4, 8) {
+		t.Errorf("every: %v", v)
+	}
+	if v := sampledValues(NewSampledReader(numberedRows(2), Sampling{Mode: SM_reservoir, N: 5})); !equal(v, 0, 1) {
+		t.Errorf("short reservoir: %v", v)
+	}
+}
+
+// finishedReader tells when all its rows were read
+type finishedReader struct {
+	*sliceReader
+	done chan bool
+}
+
+func (fr *finishedReader) Read() chan []any {
+	out := make(chan []any)
+	go func(in chan []any) {
+		for row := range in {
+			out <- row
+		}
+		close(out)
+		close(fr.done)
+	}(fr.sliceReader.Read())
+	return out
+}
+
+func TestHeadDrainsReader(t *testing.T) {
+	for _, n := range []int{0, 3, 20} {
+		fr := &finishedReader{numberedRows(10), make(chan bool)}
+		sr := NewSampledReader(fr, Sampling{Mode: SM_head, N: n})
+		sr.Init()
+		rows := 0
+		for range sr.Read() {
+			rows++
+		}
+		if expected := map[int]int{0: 0, 3: 3, 20: 10}[n]; rows != expected {
+			t.Errorf("head:%d: expected %d rows, got %d", n, expected, rows)
+		}
+		select {
+		case <-fr.done:
+		case <-time.After(time.Second):
+			t.Errorf("head:%d: the reader was not read to the end", n)
+		}
+	}
+}
+
+func TestHeadStopsReader(t *testing.T) {
+	// the numbers after the first 100 rows are invalid, they are counted only if the reader isn't stopped
+	var sb strings.Builder
+	sb.WriteString("id,n\n")
+	for i := 0; i < 10000; i++ {
+		n := "1"
+		if i >= 100 {
+			n = "x"
+		}
+		fmt.Fprintf(&sb, "%d,%s\n", i, n)
+	}
+	fileName := filepath.Join(t.TempDir(), "head.csv")
+	if err := os.WriteFile(fileName, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, where := range []string{"", "id >= 0"} {
+		fr, err := NewFileReader(fileName, ReaderOptions{"csv": CsvOptions{Types: map[string]DataType{"n": DT_int}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.Init()
+		if where != "" {
+			if fr, err = NewFilteredReader(fr, where); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r := newTestReport(t, NewSampledReader(fr, Sampling{Mode: SM_head, N: 10}), ReportOptions{})
+		if r.RowCount != 10 {
+			t.Errorf("%q: expected 10 rows, got %d", where, r.RowCount)
+		}
+		if strings.Contains(r.FileInfo, "invalid numbers") {
+			t.Errorf("%q: the reader was not stopped: %s", where, r.FileInfo)
+		}
+	}
+}
+
+func TestRandomSampling(t *testing.T) {
+	s := Sampling{Mode: SM_reservoir, N: 50, Seed: 42}
+	v1 := sampledValues(NewSampledReader(numberedRows(1000), s))
+	v2 := sampledValues(NewSampledReader(numberedRows(1000), s))
+	if len(v1) != 50 {
+		t.Fatalf("Expected 50 rows, got %d", len(v1))
+	}
+	for i := range v1 {
+		if v1[i] != v2[i] {
+			t.Fatal("same seed, different samples")
+		}
+		if i > 0 && v1[i-1] >= v1[i] {
+			t.Fatalf("sample not in the original order: %v", v1)
+		}
+	}
+	if v1[len(v1)-1] < 500 {
+		t.Errorf("sample not uniform: %v", v1)
+	}
+
+	s = Sampling{Mode: SM_fraction, Fraction: 0.1, Seed: 42}
+	v1 = sampledValues(NewSampledReader(numberedRows(10000), s))
+	v2 = sampledValues(NewSampledReader(numberedRows(10000), s))
+	if len(v1) < 800 || len(v1) > 1200 || len(v1) != len(v2) {
+		t.Errorf("unexpected fraction sample size: %d, %d", len(v1), len(v2))
+	}
+	if sr := NewSampledReader(numberedRows(1), Sampling{Mode: SM_fraction, Fraction: 0.1}); sr.sampling.Seed == 0 {
+		t.Error("random seed not set")
+	}
+}
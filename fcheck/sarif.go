@@ -0,0 +1,83 @@
+package fcheck
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SARIF 2.1.0 (static analysis results format) subset, shown as annotations by GitHub code scanning and others
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"` // error, warning or note
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysical  `json:"physicalLocation"`
+	LogicalLocations []sarifLogical `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+}
+
+type sarifArtifact struct {
+	Uri string `json:"uri"`
+}
+
+type sarifLogical struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+func newSarifResult(fileName, field, rule, level, message string) sarifResult {
+	loc := sarifLocation{PhysicalLocation: sarifPhysical{ArtifactLocation: sarifArtifact{Uri: fileName}}}
+	if field != "" {
+		loc.LogicalLocations = []sarifLogical{{Name: field, Kind: "member"}}
+	}
+	return sarifResult{RuleId: rule, Level: level, Message: sarifMessage{Text: message}, Locations: []sarifLocation{loc}}
+}
+
+// writeSARIF writes a single run of gcf, rules are the ids with descriptions of all results
+func writeSARIF(w io.Writer, rules [][2]string, results []sarifResult) error {
+	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: "gcf", Rules: []sarifRule{}}}, Results: results}
+	for _, r := range rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{Id: r[0], ShortDescription: sarifMessage{Text: r[1]}})
+	}
+	if run.Results == nil {
+		run.Results = []sarifResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: "https://json.schemastore.org/sarif-2.1.0.json", Version: "2.1.0", Runs: []sarifRun{run}})
+}
@@ -0,0 +1,315 @@
+package fcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaField is a field with the type and nullability observed in the data
+type SchemaField struct {
+	Name      string
+	Type      DataType
+	Nullable  bool // any null (or empty string) value seen, or no rows at all
+	MaxLength int  // max length in bytes of string values, 0 if unknown
+}
+
+// Schema of a file inferred by NewReport, fields in the original file order
+type Schema struct {
+	Name   string // table/record name, the file name without extension by default
+	Fields []SchemaField
+}
+
+// NewSchema builds the schema from the report (it needs CL_freq for string lengths)
+func NewSchema(r *Report) *Schema {
+	base := filepath.Base(r.FileName)
+	s := &Schema{Name: strings.TrimSuffix(base, filepath.Ext(base))}
+	fields := append([]FieldReport(nil), r.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Index < fields[j].Index })
+	for _, f := range fields {
+		sf := SchemaField{Name: f.Name, Type: f.Type, Nullable: r.RowCount == 0 || f.Count < r.RowCount}
+		if sf.Type == DT_unknown {
+			sf.Type = DT_string
+		}
+		if f.Strings != nil {
+			sf.MaxLength = f.Strings.MaxLength
+		}
+		s.Fields = append(s.Fields, sf)
+	}
+	return s
+}
+
+// SchemaFormats returns the formats supported by Schema.Write
+func SchemaFormats() []string {
+	return []string{"avsc", "jsonschema", "parquet", "postgres", "bigquery", "hive", "snowflake"}
+}
+
+// Write writes the schema as Avro .avsc, JSON Schema, Parquet message or CREATE TABLE DDL (see SchemaFormats)
+func (s *Schema) Write(w io.Writer, format string) error {
+	switch format {
+	case "avsc", "avro":
+		return s.writeAvro(w)
+	case "jsonschema", "json":
+		return s.writeJSONSchema(w)
+	case "parquet":
+		return s.writeParquet(w)
+	case "postgres", "bigquery", "hive", "snowflake":
+		return s.writeDDL(w, format)
+	}
+	return fmt.Errorf("unknown schema format: %s (supported: %s)", format, strings.Join(SchemaFormats(), ", "))
+}
+
+// identifier changes the name to [A-Za-z_][A-Za-z0-9_]* (Avro and Parquet names)
+func identifier(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// identifiers returns unique identifiers of the fields, duplicates get _2, _3, ... suffixes
+func (s *Schema) identifiers() []string {
+	names := make([]string, len(s.Fields))
+	used := map[string]bool{}
+	for i, f := range s.Fields {
+		name := identifier(f.Name)
+		for k := 2; used[name]; k++ {
+			name = identifier(f.Name) + "_" + strconv.Itoa(k)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+type avroField struct {
+	Name string `json:"name"`
+	Type any    `json:"type"`
+	Doc  string `json:"doc,omitempty"` // the original name if it's not a valid Avro name
+	null bool
+}
+
+// MarshalJSON adds "default": null to nullable fields
+func (f avroField) MarshalJSON() ([]byte, error) {
+	type plain avroField
+	if !f.null {
+		return json.Marshal(plain(f))
+	}
+	return json.Marshal(struct {
+		plain
+		Default *struct{} `json:"default"`
+	}{plain: plain(f)})
+}
+
+func (s *Schema) writeAvro(w io.Writer) error {
+	type record struct {
+		Type   string      `json:"type"`
+		Name   string      `json:"name"`
+		Fields []avroField `json:"fields"`
+	}
+	rec := record{Type: "record", Name: identifier(s.Name), Fields: []avroField{}}
+	for i, name := range s.identifiers() {
+		f := s.Fields[i]
+		t := "string"
+		switch f.Type {
+		case DT_int:
+			t = "long"
+		case DT_float:
+			t = "double"
+		}
+		af := avroField{Name: name, Type: t}
+		if name != f.Name {
+			af.Doc = f.Name
+		}
+		if f.Nullable {
+			af.Type, af.null = []string{"null", t}, true
+		}
+		rec.Fields = append(rec.Fields, af)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rec)
+}
+
+func (s *Schema) writeJSONSchema(w io.Writer) error {
+	type property struct {
+		Type      any `json:"type"`
+		MaxLength int `json:"maxLength,omitempty"`
+	}
+	// properties are written by hand to keep the field order
+	var b strings.Builder
+	required := []string{}
+	b.WriteString("{\n")
+	b.WriteString("  \"$schema\": \"https://json-schema.org/draft/2020-12/schema\",\n")
+	fmt.Fprintf(&b, "  \"title\": %s,\n", jsonString(s.Name))
+	b.WriteString("  \"type\": \"object\",\n")
+	b.WriteString("  \"properties\": {")
+	for i, f := range s.Fields {
+		p := property{Type: "string", MaxLength: f.MaxLength}
+		switch f.Type {
+		case DT_int:
+			p = property{Type: "integer"}
+		case DT_float:
+			p = property{Type: "number"}
+		}
+		if f.Nullable {
+			p.Type = []string{p.Type.(string), "null"}
+		} else {
+			required = append(required, f.Name)
+		}
+		js, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n    %s: %s", jsonString(f.Name), js)
+	}
+	if len(s.Fields) > 0 {
+		b.WriteString("\n  ")
+	}
+	b.WriteString("},\n")
+	js, _ := json.Marshal(required)
+	fmt.Fprintf(&b, "  \"required\": %s\n}\n", js)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func jsonString(s string) string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
+
+func (s *Schema) writeParquet(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "message %s {\n", identifier(s.Name))
+	for i, name := range s.identifiers() {
+		f := s.Fields[i]
+		repetition := "required"
+		if f.Nullable {
+			repetition = "optional"
+		}
+		t := "binary"
+		switch f.Type {
+		case DT_int:
+			t = "int64"
+		case DT_float:
+			t = "double"
+		}
+		fmt.Fprintf(&b, "  %s %s %s", repetition, t, name)
+		if f.Type == DT_string {
+			b.WriteString(" (STRING)")
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// max VARCHAR lengths, longer strings get the unbounded type
+const (
+	HIVE_MAX_VARCHAR      = 65535
+	SNOWFLAKE_MAX_VARCHAR = 16777216
+)
+
+// sqlType returns the column type for the dialect, string lengths are in bytes so they are an upper
+// bound of the number of characters
+func sqlType(f SchemaField, dialect string) string {
+	switch dialect {
+	case "postgres":
+		switch f.Type {
+		case DT_int:
+			return "BIGINT"
+		case DT_float:
+			return "DOUBLE PRECISION"
+		}
+		if f.MaxLength > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", f.MaxLength)
+		}
+		return "TEXT"
+	case "bigquery":
+		switch f.Type {
+		case DT_int:
+			return "INT64"
+		case DT_float:
+			return "FLOAT64"
+		}
+		if f.MaxLength > 0 {
+			return fmt.Sprintf("STRING(%d)", f.MaxLength)
+		}
+		return "STRING"
+	case "hive":
+		switch f.Type {
+		case DT_int:
+			return "BIGINT"
+		case DT_float:
+			return "DOUBLE"
+		}
+		if f.MaxLength > 0 && f.MaxLength <= HIVE_MAX_VARCHAR {
+			return fmt.Sprintf("VARCHAR(%d)", f.MaxLength)
+		}
+		return "STRING"
+	case "snowflake":
+		switch f.Type {
+		case DT_int:
+			return "NUMBER(38,0)"
+		case DT_float:
+			return "FLOAT"
+		}
+		if f.MaxLength > 0 && f.MaxLength <= SNOWFLAKE_MAX_VARCHAR {
+			return fmt.Sprintf("VARCHAR(%d)", f.MaxLength)
+		}
+		return "VARCHAR"
+	}
+	return ""
+}
+
+// quoteIdentifier quotes the name for the dialect: "name" (Postgres, Snowflake) or `name` (BigQuery, Hive),
+// backticks are escaped with a backslash in BigQuery and doubled in Hive
+func quoteIdentifier(name, dialect string) string {
+	switch dialect {
+	case "bigquery":
+		return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+	case "hive":
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// writeDDL writes CREATE TABLE, Hive gets no NOT NULL (not supported before Hive 3 and never enforced)
+func (s *Schema) writeDDL(w io.Writer, dialect string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (", quoteIdentifier(s.Name, dialect))
+	for i, f := range s.Fields {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n  %s %s", quoteIdentifier(f.Name, dialect), sqlType(f, dialect))
+		if !f.Nullable && dialect != "hive" {
+			b.WriteString(" NOT NULL")
+		}
+	}
+	b.WriteString("\n);\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
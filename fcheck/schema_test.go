@@ -0,0 +1,100 @@
+package fcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func schemaRows() *sliceReader {
+	return &sliceReader{
+		fields: []string{"id", "amount", "first name", "1st"},
+		types:  []DataType{DT_int, DT_float, DT_string, DT_string},
+		rows: [][]any{
+			{int64(1), 1.5, "Anna", "x"},
+			{int64(2), nil, "Bartholomew", "y"},
+			{int64(3), 2.0, "", "z"},
+		},
+	}
+}
+
+func TestNewSchema(t *testing.T) {
+	r := newTestReport(t, schemaRows(), ReportOptions{Sorted: true, NoOfSamples: 1})
+	s := NewSchema(r)
+	expected := []SchemaField{
+		{"id", DT_int, false, 0},
+		{"amount", DT_float, true, 0},
+		{"first name", DT_string, true, 11},
+		{"1st", DT_string, false, 1},
+	}
+	if s.Name != "memory" || len(s.Fields) != len(expected) {
+		t.Fatalf("unexpected schema: %+v", s)
+	}
+	for i, f := range expected {
+		if s.Fields[i] != f {
+			t.Errorf("field %d: expected %+v, got %+v", i, f, s.Fields[i])
+		}
+	}
+}
+
+func TestSchemaWrite(t *testing.T) {
+	s := NewSchema(newTestReport(t, schemaRows(), ReportOptions{NoOfSamples: 1}))
+	tests := map[string][]string{
+		"avsc": {`"name": "memory"`, `"name": "id",
+      "type": "long"`, `"type": [
+        "null",
+        "double"
+      ],
+      "default": null`, `"name": "first_name"`, `"doc": "first name"`, `"name": "_1st"`},
+		"jsonschema": {`"id": {"type":"integer"}`, `"amount": {"type":["number","null"]}`,
+			`"first name": {"type":["string","null"],"maxLength":11}`, `"required": ["id","1st"]`},
+		"parquet": {"message memory {\n", "  required int64 id;\n", "  optional double amount;\n",
+			"  optional binary first_name (STRING);\n", "  required binary _1st (STRING);\n"},
+		"postgres":  {`CREATE TABLE "memory" (`, `"id" BIGINT NOT NULL,`, `"amount" DOUBLE PRECISION,`, `"first name" VARCHAR(11),`, `"1st" VARCHAR(1) NOT NULL`},
+		"bigquery":  {"`id` INT64 NOT NULL,", "`amount` FLOAT64,", "`first name` STRING(11),"},
+		"hive":      {"`id` BIGINT,", "`amount` DOUBLE,", "`1st` VARCHAR(1)\n);"},
+		"snowflake": {`"id" NUMBER(38,0) NOT NULL,`, `"amount" FLOAT,`, `"first name" VARCHAR(11),`},
+	}
+	for format, parts := range tests {
+		var buf bytes.Buffer
+		if err := s.Write(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		for _, p := range parts {
+			if !strings.Contains(out, p) {
+				t.Errorf("%s: %q not in:\n%s", format, p, out)
+			}
+		}
+		if format == "avsc" || format == "jsonschema" {
+			var v any
+			if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+				t.Errorf("%s: invalid json: %v", format, err)
+			}
+		}
+	}
+	if err := s.Write(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	for dialect, expected := range map[string]string{
+		"postgres":  `"a""b` + "`" + `c"`,
+		"snowflake": `"a""b` + "`" + `c"`,
+		"bigquery":  "`a\"b\\`c`",
+		"hive":      "`a\"b``c`",
+	} {
+		if q := quoteIdentifier("a\"b`c", dialect); q != expected {
+			t.Errorf("%s: expected %s, got %s", dialect, expected, q)
+		}
+	}
+}
+
+func TestSchemaIdentifiers(t *testing.T) {
+	s := &Schema{Fields: []SchemaField{{Name: "a-b"}, {Name: "a b"}, {Name: "a_b"}, {Name: ""}}}
+	if ids := strings.Join(s.identifiers(), ","); ids != "a_b,a_b_2,a_b_3,_" {
+		t.Errorf("unexpected identifiers: %s", ids)
+	}
+}
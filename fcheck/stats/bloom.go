@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// BloomFilter is a set membership test with false positives but no false negatives.
+// Used for approximate duplicate detection when keeping all values in memory is not an option.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// NewBloomFilter creates a filter sized for n items with the false positive rate p
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{bits: make([]uint64, m/64), m: m, k: k}
+}
+
+// two hashes for double hashing: h1 + i*h2
+func bloomHashes(item []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(item)
+	h1 := h.Sum64()
+	h.Write([]byte{0x9e})
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
+
+// Add adds the item and returns true if it was (probably) added before
+func (bf *BloomFilter) Add(item []byte) bool {
+	h1, h2 := bloomHashes(item)
+	present := true
+	for i := uint64(0); i < bf.k; i++ {
+		bit := (h1 + i*h2) % bf.m
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			present = false
+			bf.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return present
+}
+
+// Test returns true if the item was (probably) added before
+func (bf *BloomFilter) Test(item []byte) bool {
+	h1, h2 := bloomHashes(item)
+	for i := uint64(0); i < bf.k; i++ {
+		bit := (h1 + i*h2) % bf.m
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeBytes returns the memory used by the filter bits
+func (bf *BloomFilter) SizeBytes() int {
+	return len(bf.bits) * 8
+}
@@ -0,0 +1,31 @@
+package stats
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	n := 10000
+	bf := NewBloomFilter(n, 0.01)
+	for i := 0; i < n; i++ {
+		if bf.Add([]byte(strconv.Itoa(i))) && i < 100 {
+			// false positives are possible but unlikely when the filter is almost empty
+			t.Errorf("%d reported as present", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !bf.Test([]byte(strconv.Itoa(i))) {
+			t.Fatalf("false negative: %d", i)
+		}
+	}
+	fp := 0
+	for i := n; i < 2*n; i++ {
+		if bf.Test([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / float64(n); rate > 0.02 {
+		t.Errorf("false positive rate too high: %f", rate)
+	}
+}
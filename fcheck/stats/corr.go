@@ -0,0 +1,153 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// ToFloat converts numeric values to float64, ok is false for nil and non numeric values
+func ToFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Covariance is a running co-moment of two variables (pairs with a missing value are skipped by the caller),
+// same online algorithm as RunningStats extended to two variables
+type Covariance struct {
+	n, meanX, meanY, m2X, m2Y, cXY float64
+}
+
+func (c *Covariance) Push(x, y float64) {
+	c.n++
+	dx := x - c.meanX
+	c.meanX += dx / c.n
+	dy := y - c.meanY
+	c.meanY += dy / c.n
+	c.m2X += dx * (x - c.meanX)
+	c.m2Y += dy * (y - c.meanY)
+	c.cXY += dx * (y - c.meanY)
+}
+
+func (c *Covariance) Count() int {
+	return int(c.n)
+}
+
+// Pearson returns the correlation coefficient, NaN if it's undefined (less than 2 values or a constant variable)
+func (c *Covariance) Pearson() float64 {
+	if c.n < 2 || c.m2X == 0 || c.m2Y == 0 {
+		return math.NaN()
+	}
+	r := c.cXY / math.Sqrt(c.m2X*c.m2Y)
+	// rounding errors
+	return math.Max(-1, math.Min(1, r))
+}
+
+// ranks returns ranks of values (1 based), ties get the average rank
+func ranks(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return values[idx[i]] < values[idx[j]] })
+	r := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i + 1
+		for j < len(idx) && values[idx[j]] == values[idx[i]] {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			r[idx[k]] = rank
+		}
+		i = j
+	}
+	return r
+}
+
+// Spearman returns the rank correlation coefficient of x and y (pairs with NaN are skipped),
+// NaN if it's undefined
+func Spearman(x, y []float64) float64 {
+	var xs, ys []float64
+	for i := range x {
+		if !math.IsNaN(x[i]) && !math.IsNaN(y[i]) {
+			xs = append(xs, x[i])
+			ys = append(ys, y[i])
+		}
+	}
+	rx, ry := ranks(xs), ranks(ys)
+	var c Covariance
+	for i := range rx {
+		c.Push(rx[i], ry[i])
+	}
+	return c.Pearson()
+}
+
+// Contingency is a frequency table of two categorical variables
+type Contingency struct {
+	n     int
+	cells map[[2]string]int
+	rows  map[string]int
+	cols  map[string]int
+}
+
+func NewContingency() *Contingency {
+	return &Contingency{cells: map[[2]string]int{}, rows: map[string]int{}, cols: map[string]int{}}
+}
+
+func (c *Contingency) Push(x, y string) {
+	c.n++
+	c.cells[[2]string{x, y}]++
+	c.rows[x]++
+	c.cols[y]++
+}
+
+func (c *Contingency) Count() int {
+	return c.n
+}
+
+// CramersV returns the Cramér's V association (0 - none, 1 - one variable determines the other),
+// NaN if it's undefined (one of the variables is constant)
+func (c *Contingency) CramersV() float64 {
+	k := len(c.rows)
+	if len(c.cols) < k {
+		k = len(c.cols)
+	}
+	if c.n == 0 || k < 2 {
+		return math.NaN()
+	}
+	n := float64(c.n)
+	chi2 := 0.0
+	for x, nx := range c.rows {
+		for y, ny := range c.cols {
+			expected := float64(nx) * float64(ny) / n
+			d := float64(c.cells[[2]string{x, y}]) - expected
+			chi2 += d * d / expected
+		}
+	}
+	return math.Min(1, math.Sqrt(chi2/(n*float64(k-1))))
+}
@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPearson(t *testing.T) {
+	var c Covariance
+	for i := 0; i < 10; i++ {
+		c.Push(float64(i), float64(2*i+1))
+	}
+	if p := c.Pearson(); !near(p, 1) {
+		t.Errorf("expected 1, got %f", p)
+	}
+	c = Covariance{}
+	for _, xy := range [][2]float64{{1, 2}, {2, 4}, {3, 5}, {4, 4}, {5, 5}} {
+		c.Push(xy[0], xy[1])
+	}
+	// numpy.corrcoef
+	if p := c.Pearson(); !near(p, 0.7745966692414834) {
+		t.Errorf("expected 0.7746, got %f", p)
+	}
+	c = Covariance{}
+	c.Push(1, 1)
+	c.Push(2, 1)
+	if p := c.Pearson(); !math.IsNaN(p) {
+		t.Errorf("expected NaN for a constant variable, got %f", p)
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, math.NaN()}
+	y := []float64{1, 8, 27, 64, 125, 0}
+	if s := Spearman(x, y); !near(s, 1) {
+		t.Errorf("expected 1, got %f", s)
+	}
+	// ties, scipy.stats.spearmanr
+	x = []float64{1, 2, 2, 3, 4}
+	y = []float64{5, 3, 4, 1, 2}
+	if s := Spearman(x, y); !near(s, -0.8720815992723809) {
+		t.Errorf("expected -0.8721, got %f", s)
+	}
+}
+
+func TestCramersV(t *testing.T) {
+	c := NewContingency()
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			c.Push("a", "x")
+		} else {
+			c.Push("b", "y")
+		}
+	}
+	if v := c.CramersV(); !near(v, 1) {
+		t.Errorf("expected 1, got %f", v)
+	}
+	c = NewContingency()
+	for i := 0; i < 100; i++ {
+		c.Push([]string{"a", "b"}[i%2], []string{"x", "y"}[i/2%2])
+	}
+	if v := c.CramersV(); !near(v, 0) {
+		t.Errorf("expected 0, got %f", v)
+	}
+}
@@ -0,0 +1,139 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// N_HISTOGRAM_SAMPLE values kept by Histogram, bins are computed from this sample and scaled to all values
+const N_HISTOGRAM_SAMPLE = 10000
+
+// Histogram collects a uniform sample of numeric values (reservoir sampling with a fixed seed,
+// so reports are repeatable) and the exact range
+type Histogram struct {
+	sample   []float64
+	n        int
+	min, max float64
+	rnd      *rand.Rand
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{rnd: rand.New(rand.NewSource(1))}
+}
+
+// Push adds a value, nil and non numeric values are skipped
+func (h *Histogram) Push(value any) {
+	x, ok := ToFloat(value)
+	if !ok || math.IsNaN(x) || math.IsInf(x, 0) {
+		return
+	}
+	h.n++
+	if h.n == 1 || x < h.min {
+		h.min = x
+	}
+	if h.n == 1 || x > h.max {
+		h.max = x
+	}
+	if len(h.sample) < N_HISTOGRAM_SAMPLE {
+		h.sample = append(h.sample, x)
+	} else if k := h.rnd.Intn(h.n); k < N_HISTOGRAM_SAMPLE {
+		h.sample[k] = x
+	}
+}
+
+func (h *Histogram) Count() int {
+	return h.n
+}
+
+// Bins returns n+1 bin edges and n (estimated) counts of values in [edge[i], edge[i+1]),
+// the last bin includes the max. Integers with a range smaller than n get a bin per value.
+func (h *Histogram) Bins(n int) ([]float64, []int) {
+	if h.n == 0 || n < 1 {
+		return nil, nil
+	}
+	width := (h.max - h.min) / float64(n)
+	integers := true
+	for _, x := range h.sample {
+		if x != math.Trunc(x) {
+			integers = false
+			break
+		}
+	}
+	if integers && h.max-h.min+1 <= float64(n) {
+		n = int(h.max-h.min) + 1
+		width = 1
+	} else if width == 0 {
+		n, width = 1, 1
+	}
+	edges := make([]float64, n+1)
+	for i := range edges {
+		edges[i] = h.min + float64(i)*width
+	}
+	sorted := append([]float64(nil), h.sample...)
+	sort.Float64s(sorted)
+	counts := make([]int, n)
+	scale := float64(h.n) / float64(len(h.sample))
+	prev := 0
+	for i := 0; i < n; i++ {
+		end := len(sorted)
+		if i < n-1 {
+			end = sort.SearchFloat64s(sorted, edges[i+1])
+		}
+		counts[i] = int(math.Round(float64(end-prev) * scale))
+		prev = end
+	}
+	return edges, counts
+}
+
+// N_NULL_CHUNKS max number of row chunks of NullMap
+const N_NULL_CHUNKS = 50
+
+// NullMap counts nulls (and empty strings) in consecutive chunks of rows, when there are more than
+// N_NULL_CHUNKS chunks the adjacent ones are merged, so the chunk size is a power of 2
+type NullMap struct {
+	chunkSize int
+	rows      int
+	nulls     []int
+}
+
+func NewNullMap() *NullMap {
+	return &NullMap{chunkSize: 1}
+}
+
+func (m *NullMap) Push(value any) {
+	if m.rows == m.chunkSize*len(m.nulls) {
+		if len(m.nulls) == N_NULL_CHUNKS {
+			for i := 0; i < N_NULL_CHUNKS/2; i++ {
+				m.nulls[i] = m.nulls[2*i] + m.nulls[2*i+1]
+			}
+			m.nulls = m.nulls[:N_NULL_CHUNKS/2]
+			m.chunkSize *= 2
+		}
+		if m.rows == m.chunkSize*len(m.nulls) {
+			m.nulls = append(m.nulls, 0)
+		}
+	}
+	m.rows++
+	if s, ok := value.(string); value == nil || ok && len(s) == 0 {
+		m.nulls[len(m.nulls)-1]++
+	}
+}
+
+// ChunkSize returns the number of rows per chunk (the last one may be smaller)
+func (m *NullMap) ChunkSize() int {
+	return m.chunkSize
+}
+
+// Shares returns the share (0-1) of nulls in every chunk
+func (m *NullMap) Shares() []float64 {
+	shares := make([]float64, len(m.nulls))
+	for i, n := range m.nulls {
+		size := m.chunkSize
+		if i == len(m.nulls)-1 {
+			size = m.rows - i*m.chunkSize
+		}
+		shares[i] = float64(n) / float64(size)
+	}
+	return shares
+}
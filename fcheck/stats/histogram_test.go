@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 100; i++ {
+		h.Push(float64(i) / 10)
+	}
+	h.Push(nil)
+	h.Push("x")
+	assert(t, h.Count(), 100, "Count")
+	edges, counts := h.Bins(10)
+	assert(t, len(edges), 11, "edges")
+	assert(t, edges[10], 9.9, "last edge")
+	for _, c := range counts {
+		assert(t, c, 10, "bin count")
+	}
+
+	h = NewHistogram()
+	for i := 0; i < 2*N_HISTOGRAM_SAMPLE; i++ {
+		h.Push(int64(i % 3))
+	}
+	edges, counts = h.Bins(20)
+	assert(t, len(counts), 3, "integer bins")
+	assert(t, edges[1], 1.0, "integer edge")
+	total := counts[0] + counts[1] + counts[2]
+	assert(t, total, 2*N_HISTOGRAM_SAMPLE, "scaled total")
+
+	h = NewHistogram()
+	h.Push(5.5)
+	edges, counts = h.Bins(10)
+	assert(t, len(counts), 1, "constant bins")
+	assert(t, counts[0], 1, "constant count")
+	edges, _ = NewHistogram().Bins(10)
+	assert(t, len(edges), 0, "empty")
+}
+
+func TestNullMap(t *testing.T) {
+	m := NewNullMap()
+	for i := 0; i < 10; i++ {
+		m.Push(nil)
+	}
+	assert(t, m.ChunkSize(), 1, "chunk size")
+	assert(t, len(m.Shares()), 10, "chunks")
+	for i := 0; i < 2*N_NULL_CHUNKS; i++ {
+		if i < N_NULL_CHUNKS-10 {
+			m.Push("")
+		} else {
+			m.Push("x")
+		}
+	}
+	// 110 rows, chunks of 4
+	assert(t, m.ChunkSize(), 4, "merged chunk size")
+	shares := m.Shares()
+	assert(t, len(shares), 28, "merged chunks")
+	assert(t, shares[0], 1.0, "first chunk")
+	assert(t, shares[len(shares)-1], 0.0, "last chunk")
+	assert(t, shares[12], 0.5, "chunk with the last nulls")
+	assert(t, shares[13], 0.0, "chunk after the nulls")
+}
@@ -0,0 +1,294 @@
+package stats
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Enum semantic types detected by SemanticType
+const (
+	ST_email      = "email"
+	ST_url        = "url"
+	ST_ipv4       = "ipv4"
+	ST_ipv6       = "ipv6"
+	ST_uuid       = "uuid"
+	ST_phone      = "phone"
+	ST_country    = "country_code" // ISO 3166-1 alpha-2
+	ST_creditCard = "credit_card"  // 13-19 digits passing the Luhn check
+	ST_iban       = "iban"         // passing the mod 97 check
+	ST_pesel      = "pesel"        // Polish national ID, with a valid birth date and check digit
+)
+
+// IsPII returns true for semantic types identifying a person
+func IsPII(semanticType string) bool {
+	switch semanticType {
+	case ST_email, ST_phone, ST_iban, ST_creditCard, ST_pesel, ST_ipv4, ST_ipv6:
+		return true
+	}
+	return false
+}
+
+// MAX_PATTERN_LEN longer patterns are truncated (and end with ...)
+const MAX_PATTERN_LEN = 50
+
+// MAX_PATTERNS distinct patterns kept by PatternFreq, the rest is counted as OTHER_PATTERN
+const MAX_PATTERNS = 10000
+const OTHER_PATTERN = "(other)"
+
+var (
+	reEmail = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}$`)
+	reUrl   = regexp.MustCompile(`^(?i)(https?|ftp)://[^\s/$.?#][^\s]*$`)
+	reUuid  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	reCard  = regexp.MustCompile(`^[0-9]{4}([ \-]?[0-9]{2,4}){2,4}$`)
+	rePhone = regexp.MustCompile(`^\+?\(?[0-9]{1,4}\)?([ \-.]?\(?[0-9]{1,4}\)?){1,6}$`)
+	reIban  = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	rePesel = regexp.MustCompile(`^[0-9]{11}$`)
+	reDate  = regexp.MustCompile(`^[0-9]{4}[\-./][0-9]{1,2}[\-./][0-9]{1,2}$|^[0-9]{1,2}[\-./][0-9]{1,2}[\-./][0-9]{4}$`)
+)
+
+var countryCodes = map[string]bool{}
+
+func init() {
+	for _, c := range strings.Fields(`AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ
+		BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET
+		FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO IQ IR
+		IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN
+		MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY
+		QA RE RO RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR
+		TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`) {
+		countryCodes[c] = true
+	}
+}
+
+// Pattern maps a value to its shape: upper case letters become A, other letters a, digits 9,
+// everything else is kept, e.g. "AB-1234" -> "AA-9999"
+func Pattern(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		if n == MAX_PATTERN_LEN {
+			b.WriteString("...")
+			break
+		}
+		switch {
+		case unicode.IsUpper(r):
+			b.WriteByte('A')
+		case unicode.IsLetter(r):
+			b.WriteByte('a')
+		case unicode.IsDigit(r):
+			b.WriteByte('9')
+		default:
+			b.WriteRune(r)
+		}
+		n++
+	}
+	return b.String()
+}
+
+func digits(s string) []int {
+	var d []int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			d = append(d, int(r-'0'))
+		}
+	}
+	return d
+}
+
+func luhn(d []int) bool {
+	sum := 0
+	for i := range d {
+		x := d[len(d)-1-i]
+		if i%2 == 1 {
+			if x *= 2; x > 9 {
+				x -= 9
+			}
+		}
+		sum += x
+	}
+	return sum%10 == 0
+}
+
+func iban(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if !reIban.MatchString(s) {
+		return false
+	}
+	// move the first 4 chars to the end, letters become 10..35, the number mod 97 must be 1
+	mod := 0
+	for _, r := range s[4:] + s[:4] {
+		if r >= 'A' {
+			mod = (mod*100 + int(r-'A'+10)) % 97
+		} else {
+			mod = (mod*10 + int(r-'0')) % 97
+		}
+	}
+	return mod == 1
+}
+
+func pesel(s string) bool {
+	if !rePesel.MatchString(s) {
+		return false
+	}
+	d := digits(s)
+	// month + 20 * century offset (80 - 1800s, 0 - 1900s, 20 - 2000s, ...)
+	month := (d[2]*10 + d[3]) % 20
+	day := d[4]*10 + d[5]
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return false
+	}
+	sum := 0
+	for i, w := range []int{1, 3, 7, 9, 1, 3, 7, 9, 1, 3} {
+		sum += w * d[i]
+	}
+	return (10-sum%10)%10 == d[10]
+}
+
+// SemanticType returns the detected type of the value (ST_*), "" if none matched.
+// Phone numbers need separators or a + prefix, and a + prefix or at least 9 digits (to skip numbers and dates).
+func SemanticType(s string) string {
+	s = strings.TrimSpace(s)
+	switch {
+	case len(s) < 2:
+		return ""
+	case reUuid.MatchString(s):
+		return ST_uuid
+	case reEmail.MatchString(s):
+		return ST_email
+	case reUrl.MatchString(s):
+		return ST_url
+	case len(s) == 2 && countryCodes[s]:
+		return ST_country
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		if strings.Contains(s, ":") {
+			return ST_ipv6
+		}
+		return ST_ipv4
+	}
+	if iban(s) {
+		return ST_iban
+	}
+	if pesel(s) {
+		return ST_pesel
+	}
+	if reDate.MatchString(s) {
+		return ""
+	}
+	d := digits(s)
+	if reCard.MatchString(s) && len(d) >= 13 && len(d) <= 19 && luhn(d) {
+		return ST_creditCard
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil || !strings.ContainsAny(s, " -.()+") {
+		return ""
+	}
+	if rePhone.MatchString(s) && len(d) <= 15 && (len(d) >= 9 || s[0] == '+' && len(d) >= 7) {
+		return ST_phone
+	}
+	return ""
+}
+
+// Stats collector for value shapes and semantic types (values casted to string)
+type PatternFreq struct {
+	patterns map[string]int
+	semantic map[string]int
+	n        int // non null or empty
+	cnt      int
+	nullCnt  int
+}
+
+func NewPatternFreq() *PatternFreq {
+	return &PatternFreq{patterns: map[string]int{}, semantic: map[string]int{}}
+}
+
+func (pf *PatternFreq) Push(value any) {
+	pf.cnt++
+	if value == nil {
+		pf.nullCnt++
+		return
+	}
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	if len(s) == 0 {
+		return
+	}
+	pf.n++
+	p := Pattern(s)
+	if _, ok := pf.patterns[p]; !ok && len(pf.patterns) >= MAX_PATTERNS {
+		p = OTHER_PATTERN
+	}
+	pf.patterns[p]++
+	if t := SemanticType(s); t != "" {
+		pf.semantic[t]++
+	}
+}
+
+func (pf *PatternFreq) Count() int {
+	return pf.n
+}
+
+func (pf *PatternFreq) Nulls() int {
+	return pf.nullCnt
+}
+
+func sortedCounts(counts map[string]int) ([]string, []int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	vals := make([]int, len(keys))
+	for i, k := range keys {
+		vals[i] = counts[k]
+	}
+	return keys, vals
+}
+
+// Freq returns n most (or least) frequent patterns
+func (pf *PatternFreq) Freq(n int, least bool) ([]string, []int) {
+	keys, vals := sortedCounts(pf.patterns)
+	if least {
+		return keys[Max(0, len(keys)-n):], vals[Max(0, len(vals)-n):]
+	}
+	return keys[:Min(n, len(keys))], vals[:Min(n, len(vals))]
+}
+
+// Semantic returns detected semantic types with the number of matching values, most frequent first
+func (pf *PatternFreq) Semantic() ([]string, []int) {
+	return sortedCounts(pf.semantic)
+}
+
+// Dominant returns the semantic type matching at least the given share (0-1) of non empty values, "" if none
+func (pf *PatternFreq) Dominant(share float64) string {
+	types, counts := pf.Semantic()
+	if len(types) > 0 && float64(counts[0]) >= share*float64(pf.n) {
+		return types[0]
+	}
+	return ""
+}
+
+func (pf *PatternFreq) Info() string {
+	if pf.n == 0 {
+		return ""
+	}
+	ret := fmt.Sprintf("%d patterns", len(pf.patterns))
+	if t := pf.Dominant(0.9); t != "" {
+		ret += ", " + strings.ToUpper(t)
+	}
+	return ret
+}
@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"testing"
+)
+
+func TestPattern(t *testing.T) {
+	tests := map[string]string{
+		"AB-1234":       "AA-9999",
+		"Warszawa 01":   "Aaaaaaaa 99",
+		"żółw@ŁÓDŹ.pl":  "aaaa@AAAA.aa",
+		"":              "",
+		"(555) 123-456": "(999) 999-999",
+	}
+	for value, expected := range tests {
+		assert(t, Pattern(value), expected, value)
+	}
+	long := Pattern("01234567890123456789012345678901234567890123456789012345")
+	assert(t, len(long), MAX_PATTERN_LEN+3, "long pattern length")
+}
+
+func TestSemanticType(t *testing.T) {
+	tests := map[string]string{
+		"john.doe@example.com":                 ST_email,
+		"https://example.com/a?b=c":            ST_url,
+		"192.168.0.1":                          ST_ipv4,
+		"2001:db8::ff00:42:8329":               ST_ipv6,
+		"123e4567-e89b-12d3-a456-426614174000": ST_uuid,
+		"+48 601 234 567":                      ST_phone,
+		"(555) 123-4567":                       ST_phone,
+		"PL":                                   ST_country,
+		"4111 1111 1111 1111":                  ST_creditCard,
+		"4111111111111111":                     ST_creditCard,
+		"4111111111111112":                     "", // Luhn check fails
+		"PL61109010140000071219812874":         ST_iban,
+		"DE89 3704 0044 0532 0130 00":          ST_iban,
+		"DE89 3704 0044 0532 0130 01":          "",
+		"44051401359":                          ST_pesel,
+		"02270803624":                          ST_pesel,
+		"44051401358":                          "", // wrong check digit
+		"44151401351":                          "", // month 15
+		"2024-01-15":                           "",
+		"123456789":                            "",
+		"3.14159265":                           "",
+		"XX":                                   "",
+		"hello world":                          "",
+	}
+	for value, expected := range tests {
+		assert(t, SemanticType(value), expected, value)
+	}
+}
+
+func TestPatternFreq(t *testing.T) {
+	pf := NewPatternFreq()
+	for _, v := range []any{"a@b.com", "c@d.org", "AB-1", "AB-2", "AB-3", nil, ""} {
+		pf.Push(v)
+	}
+	assert(t, pf.Count(), 5, "Count")
+	assert(t, pf.Nulls(), 1, "Nulls")
+	patterns, counts := pf.Freq(1, false)
+	assert(t, patterns[0], "AA-9", "top pattern")
+	assert(t, counts[0], 3, "top pattern count")
+	types, counts := pf.Semantic()
+	assert(t, len(types), 1, "semantic types")
+	assert(t, counts[0], 2, "emails")
+	assert(t, pf.Dominant(0.9), "", "dominant")
+	assert(t, pf.Dominant(0.4), ST_email, "dominant")
+}
+
+func TestIsPII(t *testing.T) {
+	assert(t, IsPII(ST_email), true, "email")
+	assert(t, IsPII(ST_pesel), true, "pesel")
+	assert(t, IsPII(ST_country), false, "country")
+	assert(t, IsPII(""), false, "none")
+}
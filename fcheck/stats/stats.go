@@ -5,6 +5,7 @@ import (
 	"log"
 	"math"
 	"sort"
+	"strconv"
 )
 
 type StatCollector interface {
@@ -15,12 +16,21 @@ type StatCollector interface {
 
 }
 
+// BatchCollector is implemented by collectors that take vectors of values without boxing them (batch versions
+// of Push), valid is the validity bitmap of the values (see IsValid), nil if there are no nulls
+type BatchCollector interface {
+	PushInts(values []int64, valid []byte)
+	PushFloats(values []float64, valid []byte)
+	PushStrings(values []string, valid []byte)
+}
+
 // Stat collector for numerical types
 // based on https://www.johndcook.com/blog/standard_deviation/
 type RunningStats struct {
 	m_n, m_M, m_S, min, max float64
 	cnt int
 	nullCnt int
+	invalidCnt int // not numeric values of PushStrings
 }
 // TODO: add counting nulls etc.
 /*
@@ -85,6 +95,41 @@ func (rs *RunningStats) Push(value any) {
 	default:
 		log.Panic(fmt.Printf("unexpected type: %T is not numeric\n", v))
 	}
+	rs.add(x)
+}
+// PushInts, PushFloats and PushStrings push vectors of values without boxing them
+func (rs *RunningStats) PushInts(values []int64, valid []byte) {
+	rs.cnt += len(values)
+	for i, v := range values {
+		if !IsValid(valid, i) {
+			rs.nullCnt++
+			continue
+		}
+		rs.add(float64(v))
+	}
+}
+func (rs *RunningStats) PushFloats(values []float64, valid []byte) {
+	rs.cnt += len(values)
+	for i, v := range values {
+		if !IsValid(valid, i) {
+			rs.nullCnt++
+			continue
+		}
+		rs.add(v)
+	}
+}
+// PushStrings counts the values, strings are not numeric so the ones that are not null are invalid
+func (rs *RunningStats) PushStrings(values []string, valid []byte) {
+	rs.cnt += len(values)
+	for i := range values {
+		if IsValid(valid, i) {
+			rs.invalidCnt++
+		} else {
+			rs.nullCnt++
+		}
+	}
+}
+func (rs *RunningStats) add(x float64) {
 	rs.m_n++
 	if rs.m_n == 1.0 {
 		rs.m_M = x
@@ -105,6 +150,18 @@ func (rs *RunningStats) Push(value any) {
 func (rs *RunningStats) Count() int {
 	return int(rs.m_n)
 }
+func (rs *RunningStats) Nulls() int {
+	return rs.nullCnt
+}
+func (rs *RunningStats) Invalid() int {
+	return rs.invalidCnt
+}
+func (rs *RunningStats) Min() float64 {
+	return rs.min
+}
+func (rs *RunningStats) Max() float64 {
+	return rs.max
+}
 
 func (rs *RunningStats) Mean() float64 {
 	return rs.m_M
@@ -131,6 +188,9 @@ func (rs *RunningStats) Info() string {
 			ret = fmt.Sprintf("%d NULL ", rs.nullCnt)
 		}
 	}
+	if rs.invalidCnt > 0 {
+		ret += fmt.Sprintf("%d INVALID ", rs.invalidCnt)
+	}
 	ret += fmt.Sprintf("min: %.3g, max: %.3g, mean: %.3g, std: %.3g", rs.min, rs.max, rs.m_M, rs.StdDev())
 	return ret
 }
@@ -163,13 +223,47 @@ func (sf *StringFreq) Push(value any) {
 	default:
 		s = fmt.Sprintf("%v", v)
 	}
+	sf.add(s)
+}
+func (sf *StringFreq) PushInts(values []int64, valid []byte) {
+	for i, v := range values {
+		sf.cnt++
+		if !IsValid(valid, i) {
+			sf.nullCnt++
+			continue
+		}
+		sf.add(strconv.FormatInt(v, 10))
+	}
+}
+func (sf *StringFreq) PushFloats(values []float64, valid []byte) {
+	for i, v := range values {
+		sf.cnt++
+		if !IsValid(valid, i) {
+			sf.nullCnt++
+			continue
+		}
+		sf.add(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+}
+func (sf *StringFreq) PushStrings(values []string, valid []byte) {
+	for i, v := range values {
+		sf.cnt++
+		if !IsValid(valid, i) {
+			sf.nullCnt++
+			continue
+		}
+		sf.add(v)
+	}
+}
+func (sf *StringFreq) add(s string) {
 	l := len(s)
 	if l > 0 {
 		sf.counts[s]++
 		sf.n++
 		if l > sf.maxl {
 			sf.maxl = l
-		} else if l < sf.minl {
+		}
+		if l < sf.minl {
 			sf.minl = l
 		}
 	}
@@ -177,6 +271,26 @@ func (sf *StringFreq) Push(value any) {
 func (sf *StringFreq) Count() int {
 	return sf.n
 }
+// Distinct returns the number of distinct (not empty) values
+func (sf *StringFreq) Distinct() int {
+	return len(sf.counts)
+}
+func (sf *StringFreq) Nulls() int {
+	return sf.nullCnt
+}
+// MinLen and MaxLen return the length range of non empty values (0 if there were none)
+func (sf *StringFreq) MinLen() int {
+	if sf.n == 0 {
+		return 0
+	}
+	return sf.minl
+}
+func (sf *StringFreq) MaxLen() int {
+	if sf.n == 0 {
+		return 0
+	}
+	return sf.maxl
+}
 func (sf *StringFreq) Freq(n int, least bool) ([]string, []int) {
 	keys := make([]string, len(sf.counts))
 	var i int
@@ -213,6 +327,77 @@ func (sf *StringFreq) Info() string {
 	return ret + "EMPTY"
 }
 
+//
+// Stats collector that only counts values, used when other collectors are disabled
+//
+type Counter struct {
+	n       int // non null or empty
+	cnt     int
+	nullCnt int
+}
+func (c *Counter) Push(value any) {
+	c.cnt++
+	if value == nil {
+		c.nullCnt++
+		return
+	}
+	if s, ok := value.(string); ok && len(s) == 0 {
+		return
+	}
+	c.n++
+}
+func (c *Counter) PushInts(values []int64, valid []byte) {
+	c.pushNulls(len(values), valid)
+}
+func (c *Counter) PushFloats(values []float64, valid []byte) {
+	c.pushNulls(len(values), valid)
+}
+func (c *Counter) PushStrings(values []string, valid []byte) {
+	c.cnt += len(values)
+	for i, v := range values {
+		if !IsValid(valid, i) {
+			c.nullCnt++
+		} else if len(v) > 0 {
+			c.n++
+		}
+	}
+}
+// pushNulls counts n values with the validity bitmap
+func (c *Counter) pushNulls(n int, valid []byte) {
+	c.cnt += n
+	for i := 0; i < n; i++ {
+		if IsValid(valid, i) {
+			c.n++
+		} else {
+			c.nullCnt++
+		}
+	}
+}
+func (c *Counter) Count() int {
+	return c.n
+}
+func (c *Counter) Nulls() int {
+	return c.nullCnt
+}
+func (c *Counter) Freq(n int, least bool) ([]string, []int) {
+	return nil, nil
+}
+func (c *Counter) Info() string {
+	if c.nullCnt > 0 {
+		if c.cnt == c.nullCnt {
+			return "ALL NULL"
+		}
+		return fmt.Sprintf("%d NULL", c.nullCnt)
+	}
+	return ""
+}
+
+// IsValid tells if the i-th value of a vector with the validity bitmap is not null:
+// bit i of the bitmap is set (least significant bit first), all values are valid if the bitmap is nil
+func IsValid(valid []byte, i int) bool {
+	return valid == nil || valid[i/8]&(1<<(i%8)) != 0
+}
+
 // helper functions
 func Max(a int, b int) int {
 	if a > b {
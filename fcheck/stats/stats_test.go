@@ -19,6 +19,17 @@ func TestRunningStats(t *testing.T) {
 	assert(t, s.StdDev(), 29.011491975882016, "StdDev")
 }
 
+func TestStringFreqLengths(t *testing.T) {
+	// the min length was only updated by values not longer than the max, so it stayed unset for growing lengths
+	sf := NewStringFreq()
+	for _, s := range []string{"a", "abc", "abcde"} {
+		sf.Push(s)
+	}
+	assert(t, sf.MinLen(), 1, "MinLen")
+	assert(t, sf.MaxLen(), 5, "MaxLen")
+	assert(t, sf.Info(), "length min: 1, max: 5", "Info")
+}
+
 
 func BenchmarkRunningStatsPush(b *testing.B) {
 	var x any = float32(1.123)
@@ -30,3 +41,67 @@ func BenchmarkRunningStatsPush(b *testing.B) {
 
 
 
+func TestPushVectors(t *testing.T) {
+	// 0, nil, 2, 3, nil, 5, 6, 7, 8, nil
+	valid := []byte{0b11101101, 0b00000001}
+	ints := []int64{0, 0, 2, 3, 0, 5, 6, 7, 8, 0}
+	floats := []float64{0, 0, 2, 3, 0, 5, 6, 7, 8, 0}
+	strs := []string{"0", "", "2", "3", "", "5", "6", "7", "8", ""}
+	var rows []any
+	for i, v := range ints {
+		if IsValid(valid, i) {
+			rows = append(rows, v)
+		} else {
+			rows = append(rows, nil)
+		}
+	}
+	for _, newCollector := range []func() StatCollector{
+		func() StatCollector { return &RunningStats{} },
+		func() StatCollector { return NewStringFreq() },
+		func() StatCollector { return &Counter{} },
+	} {
+		exp := newCollector()
+		for _, v := range rows {
+			exp.Push(v)
+		}
+		for name, push := range map[string]func(BatchCollector){
+			"ints":   func(s BatchCollector) { s.PushInts(ints, valid) },
+			"floats": func(s BatchCollector) { s.PushFloats(floats, valid) },
+			"strings": func(s BatchCollector) {
+				if _, ok := s.(*RunningStats); !ok {
+					s.PushStrings(strs, valid)
+				} else {
+					s.PushInts(ints, valid)
+				}
+			},
+		} {
+			s := newCollector()
+			push(s.(BatchCollector))
+			assert(t, s.Count(), exp.Count(), name+" Count")
+			assert(t, s.Info(), exp.Info(), name+" Info")
+		}
+	}
+	s := RunningStats{}
+	s.PushInts([]int64{1, 2, 3}, nil)
+	s.PushStrings([]string{"", ""}, []byte{0})
+	assert(t, s.Count(), 3, "Count")
+	assert(t, s.Nulls(), 2, "Nulls")
+	assert(t, s.Mean(), 2.0, "Mean")
+	// strings in a numeric field are counted as invalid
+	s.PushStrings([]string{"a", "", "b"}, []byte{5})
+	assert(t, s.Nulls(), 3, "Nulls")
+	assert(t, s.Invalid(), 2, "Invalid")
+	assert(t, s.Count(), 3, "Count")
+	assert(t, s.Info(), "3 NULL 2 INVALID min: 1, max: 3, mean: 2, std: 1", "Info")
+}
+
+func BenchmarkRunningStatsPushFloats(b *testing.B) {
+	values := make([]float64, 1024)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	s := RunningStats{}
+	for i := 0; i < b.N; i += len(values) {
+		s.PushFloats(values, nil)
+	}
+}
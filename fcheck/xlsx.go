@@ -0,0 +1,596 @@
+package fcheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XlsxOptions are the options of the Excel reader (ReaderOptions{"xlsx": XlsxOptions{...}})
+type XlsxOptions struct {
+	// Sheet is the name or the 1-based index of the sheet, the first one if empty
+	Sheet string
+	// HeaderRow is the 1-based number of the row with the column names, rows above it are skipped,
+	// 0 is the first non-empty row, negative - no header (columns are named A, B, C ...)
+	HeaderRow int
+}
+
+// xlsxCell is a cell value: string, int64, float64 or nil (empty and error cells)
+type xlsxCell struct {
+	col   int
+	value any
+}
+
+type XlsxReader struct {
+	fileName  string
+	sheet     string
+	headerRow int
+	sheetName string
+	sheetPath string
+	strings   []string // shared strings
+	dateStyle []bool   // cellXfs with a date or time number format
+	date1904  bool
+	sheets    int
+	rows      int // data rows (non-empty rows after the header)
+	errors    int // error cells (#N/A, #DIV/0! ...) read as nulls
+	fields    []string
+	types     []DataType
+	columns   []int // column of each (selected) field
+	readStop
+}
+
+func NewXlsxReader(fileName string, opts XlsxOptions) *XlsxReader {
+	return &XlsxReader{fileName: fileName, sheet: opts.Sheet, headerRow: opts.HeaderRow}
+}
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "xlsx",
+		Type:       FT_xlsx,
+		Extensions: []string{".xlsx", ".xlsm"},
+		// a zip with [Content_Types].xml and a workbook
+		Sniff: func(fileName string, head []byte, opts any) bool {
+			return bytes.HasPrefix(head, MAGIC_ZIP) && isXlsx(fileName)
+		},
+		New: func(fileName string, opts any) (FileReader, error) {
+			o, ok := opts.(XlsxOptions)
+			if !ok && opts != nil {
+				return nil, fmt.Errorf("invalid xlsx options: %T", opts)
+			}
+			return NewXlsxReader(fileName, o), nil
+		},
+	})
+}
+
+func isXlsx(fileName string) bool {
+	z, err := zip.OpenReader(fileName)
+	if err != nil {
+		return false
+	}
+	defer z.Close()
+	found := 0
+	for _, f := range z.File {
+		if f.Name == "[Content_Types].xml" || f.Name == "xl/workbook.xml" {
+			found++
+		}
+	}
+	return found == 2
+}
+
+func zipFile(z *zip.ReadCloser, name string) *zip.File {
+	for _, f := range z.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func openZipFile(z *zip.ReadCloser, name string) (io.ReadCloser, error) {
+	if f := zipFile(z, name); f != nil {
+		return f.Open()
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+func decodeZipFile(z *zip.ReadCloser, name string, v any) error {
+	r, err := openZipFile(z, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// richText is the text of a shared or inline string, plain (t) or made of formatted runs (r)
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt *richText) String() string {
+	if len(rt.Runs) == 0 {
+		return rt.T
+	}
+	var sb strings.Builder
+	sb.WriteString(rt.T)
+	for _, r := range rt.Runs {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+// findSheet returns the name and the zip path of the sheet by its name or 1-based index
+func (xr *XlsxReader) findSheet(z *zip.ReadCloser) error {
+	var wb struct {
+		Pr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			Id   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeZipFile(z, "xl/workbook.xml", &wb); err != nil {
+		return err
+	}
+	xr.date1904 = wb.Pr.Date1904 == "1" || wb.Pr.Date1904 == "true"
+	xr.sheets = len(wb.Sheets)
+	if len(wb.Sheets) == 0 {
+		return fmt.Errorf("the workbook has no sheets")
+	}
+	k := -1
+	if xr.sheet == "" {
+		k = 0
+	}
+	for i, s := range wb.Sheets {
+		if s.Name == xr.sheet {
+			k = i
+		}
+	}
+	if n, err := strconv.Atoi(xr.sheet); k < 0 && err == nil && n >= 1 && n <= len(wb.Sheets) {
+		k = n - 1
+	}
+	if k < 0 {
+		names := make([]string, len(wb.Sheets))
+		for i, s := range wb.Sheets {
+			names[i] = s.Name
+		}
+		return fmt.Errorf("sheet %s not found (sheets: %s)", xr.sheet, strings.Join(names, ", "))
+	}
+	var rels struct {
+		Rels []struct {
+			Id     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeZipFile(z, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return err
+	}
+	xr.sheetName = wb.Sheets[k].Name
+	for _, r := range rels.Rels {
+		if r.Id == wb.Sheets[k].Id {
+			if strings.HasPrefix(r.Target, "/") {
+				xr.sheetPath = r.Target[1:]
+			} else {
+				xr.sheetPath = path.Join("xl", r.Target)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("sheet %s: relationship %s not found", xr.sheetName, wb.Sheets[k].Id)
+}
+
+func (xr *XlsxReader) readSharedStrings(z *zip.ReadCloser) error {
+	if zipFile(z, "xl/sharedStrings.xml") == nil {
+		// workbooks with inline strings only
+		return nil
+	}
+	r, err := openZipFile(z, "xl/sharedStrings.xml")
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d := xml.NewDecoder(r)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("xl/sharedStrings.xml: %v", err)
+		}
+		if se, ok := t.(xml.StartElement); ok && se.Name.Local == "si" {
+			var si richText
+			if err := d.DecodeElement(&si, &se); err != nil {
+				return fmt.Errorf("xl/sharedStrings.xml: %v", err)
+			}
+			xr.strings = append(xr.strings, si.String())
+		}
+	}
+}
+
+// built-in number formats of dates and times
+var xlsxDateFormats = map[int]bool{14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 30: true, 36: true, 45: true, 46: true, 47: true, 50: true, 57: true}
+
+// literals, colors and conditions ("...", \x, [Red]) are removed before looking for date parts, [h] [mm] [ss] are kept
+var xlsxFormatLiterals = regexp.MustCompile(`"[^"]*"|\\.|_.|\*.|\[[^\]hms]*\]`)
+
+// isDateFormat tells if the custom number format code is a date or a time (contains d, m, y, h or s)
+func isDateFormat(code string) bool {
+	code = strings.ToLower(xlsxFormatLiterals.ReplaceAllString(code, ""))
+	if strings.Contains(code, "general") {
+		return false
+	}
+	return strings.ContainsAny(code, "dmyhs")
+}
+
+func (xr *XlsxReader) readStyles(z *zip.ReadCloser) error {
+	var styles struct {
+		NumFmts []struct {
+			Id   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtId int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if zipFile(z, "xl/styles.xml") == nil {
+		return nil
+	}
+	if err := decodeZipFile(z, "xl/styles.xml", &styles); err != nil {
+		return err
+	}
+	dates := map[int]bool{}
+	for id := range xlsxDateFormats {
+		dates[id] = true
+	}
+	for _, f := range styles.NumFmts {
+		dates[f.Id] = isDateFormat(f.Code)
+	}
+	xr.dateStyle = make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		xr.dateStyle[i] = dates[xf.NumFmtId]
+	}
+	return nil
+}
+
+// columnIndex returns the 0-based column of a cell reference like AB12, -1 if it's invalid
+func columnIndex(ref string) int {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A') + 1
+	}
+	if i == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// columnName returns the letters of the 0-based column: A, B ... Z, AA ...
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxDate converts a date serial number to a date (2006-01-02), a date and time or a time (serials below 1)
+func xlsxDate(serial float64, date1904 bool) string {
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if serial < 61 {
+		// Lotus 1-2-3 bug kept by Excel: 1900 is a leap year (serial 60 is 1900-02-29)
+		base = base.AddDate(0, 0, 1)
+	}
+	days := math.Floor(serial)
+	secs := math.Round((serial - days) * 86400)
+	t := base.AddDate(0, 0, int(days)).Add(time.Duration(secs) * time.Second)
+	switch {
+	case serial < 1 && !date1904:
+		return t.Format("15:04:05")
+	case secs == 0:
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// cellValue converts the cell by its type (t) and style (s) attributes
+func (xr *XlsxReader) cellValue(t string, s int, v string, is *richText) any {
+	switch t {
+	case "s":
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(xr.strings) {
+			xr.errors++
+			return nil
+		}
+		return xr.strings[i]
+	case "inlineStr":
+		if is == nil {
+			return nil
+		}
+		return is.String()
+	case "str", "d":
+		return v
+	case "b":
+		if v == "1" {
+			return "true"
+		}
+		return "false"
+	case "e":
+		xr.errors++
+		return nil
+	}
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		xr.errors++
+		return nil
+	}
+	if s >= 0 && s < len(xr.dateStyle) && xr.dateStyle[s] {
+		return xlsxDate(f, xr.date1904)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return f
+}
+
+// scan reads the sheet calling fn for each non-empty row (1-based row number and the cells), stops if fn returns false
+func (xr *XlsxReader) scan(fn func(row int, cells []xlsxCell) bool) error {
+	z, err := zip.OpenReader(xr.fileName)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	r, err := openZipFile(z, xr.sheetPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d := xml.NewDecoder(r)
+	row, col := 0, 0
+	var cells []xlsxCell
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", xr.sheetPath, err)
+		}
+		switch e := t.(type) {
+		case xml.StartElement:
+			switch e.Name.Local {
+			case "row":
+				row++
+				for _, a := range e.Attr {
+					if a.Name.Local == "r" {
+						if n, err := strconv.Atoi(a.Value); err == nil {
+							row = n
+						}
+					}
+				}
+				col = 0
+				cells = cells[:0]
+			case "c":
+				var c struct {
+					R  string    `xml:"r,attr"`
+					T  string    `xml:"t,attr"`
+					S  int       `xml:"s,attr"`
+					V  string    `xml:"v"`
+					Is *richText `xml:"is"`
+				}
+				if err := d.DecodeElement(&c, &e); err != nil {
+					return fmt.Errorf("%s: %v", xr.sheetPath, err)
+				}
+				if c.R != "" {
+					if k := columnIndex(c.R); k >= 0 {
+						col = k
+					}
+				}
+				if v := xr.cellValue(c.T, c.S, c.V, c.Is); v != nil && v != "" {
+					cells = append(cells, xlsxCell{col, v})
+				}
+				col++
+			}
+		case xml.EndElement:
+			if e.Name.Local == "row" && len(cells) > 0 {
+				if !fn(row, cells) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (xr *XlsxReader) FileName() string {
+	return xr.fileName
+}
+
+// Init finds the sheet and reads it once to get the columns and their types: a column with numbers only is int
+// (float if any number has a fraction), columns with any text, boolean or date are strings
+func (xr *XlsxReader) Init() {
+	z, err := zip.OpenReader(xr.fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = xr.findSheet(z)
+	if err == nil {
+		err = xr.readSharedStrings(z)
+	}
+	if err == nil {
+		err = xr.readStyles(z)
+	}
+	z.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", xr.fileName, err)
+	}
+	var header []xlsxCell
+	var types []DataType
+	xr.rows = 0
+	err = xr.scan(func(row int, cells []xlsxCell) bool {
+		if xr.headerRow >= 0 && header == nil {
+			if row < xr.headerRow {
+				return true
+			}
+			header = append([]xlsxCell{}, cells...)
+			return true
+		}
+		xr.rows++
+		for _, c := range cells {
+			for len(types) <= c.col {
+				types = append(types, DT_unknown)
+			}
+			var t DataType
+			switch c.value.(type) {
+			case int64:
+				t = DT_int
+			case float64:
+				t = DT_float
+			default:
+				t = DT_string
+			}
+			switch {
+			case types[c.col] == DT_unknown || types[c.col] == DT_int && t == DT_float:
+				types[c.col] = t
+			case t == DT_string || types[c.col] == DT_string:
+				types[c.col] = DT_string
+			}
+		}
+		return true
+	})
+	if err != nil {
+		log.Fatalf("%s: %v", xr.fileName, err)
+	}
+	names := map[int]string{}
+	for _, c := range header {
+		names[c.col] = fmt.Sprintf("%v", c.value)
+		for len(types) <= c.col {
+			types = append(types, DT_unknown)
+		}
+	}
+	if len(types) == 0 {
+		log.Fatalf("%s: sheet %s is empty", xr.fileName, xr.sheetName)
+	}
+	xr.fields, xr.types, xr.columns = nil, nil, nil
+	for col, t := range types {
+		name, ok := names[col]
+		if !ok {
+			if t == DT_unknown {
+				// empty column without a name
+				continue
+			}
+			name = columnName(col)
+		}
+		if t == DT_unknown {
+			t = DT_string
+		}
+		xr.fields = append(xr.fields, name)
+		xr.types = append(xr.types, t)
+		xr.columns = append(xr.columns, col)
+	}
+}
+
+// Project implements Projector
+func (xr *XlsxReader) Project(fields []string) error {
+	var columns []int
+	var types []DataType
+	for _, name := range fields {
+		i := indexof(xr.fields, name)
+		if i < 0 {
+			return fmt.Errorf("unknown field: %s", name)
+		}
+		columns = append(columns, xr.columns[i])
+		types = append(types, xr.types[i])
+	}
+	xr.columns = columns
+	xr.fields = fields
+	xr.types = types
+	return nil
+}
+
+func (xr *XlsxReader) GetFields() []string {
+	return xr.fields
+}
+
+func (xr *XlsxReader) GetTypes() []DataType {
+	return xr.types
+}
+
+func (xr *XlsxReader) GetFileInfo() string {
+	info := fmt.Sprintf("xlsx, sheet %s (%d sheets), %d rows", xr.sheetName, xr.sheets, xr.rows)
+	if xr.errors > 0 {
+		info += fmt.Sprintf(", %d error cells", xr.errors)
+	}
+	return info
+}
+
+// toList puts the cells into the row, ints in float columns are converted
+func (xr *XlsxReader) toList(cells []xlsxCell, byColumn map[int]int) []any {
+	row := make([]any, len(xr.fields))
+	for _, c := range cells {
+		i, ok := byColumn[c.col]
+		if !ok {
+			continue
+		}
+		if v, isInt := c.value.(int64); isInt && xr.types[i] == DT_float {
+			row[i] = float64(v)
+		} else {
+			row[i] = c.value
+		}
+	}
+	return row
+}
+
+func (xr *XlsxReader) Read() chan []any {
+	// position of each (selected) column in the row
+	byColumn := map[int]int{}
+	for i, col := range xr.columns {
+		byColumn[col] = i
+	}
+	out := make(chan []any)
+	done := xr.begin()
+	go func() {
+		// errors are counted again
+		xr.errors = 0
+		header := xr.headerRow < 0
+		err := xr.scan(func(row int, cells []xlsxCell) bool {
+			if !header {
+				header = row >= xr.headerRow
+				return true
+			}
+			select {
+			case out <- xr.toList(cells, byColumn):
+				return true
+			case <-done:
+				return false
+			}
+		})
+		if err != nil {
+			log.Fatalf("%s: %v", xr.fileName, err)
+		}
+		close(out)
+	}()
+	return out
+}
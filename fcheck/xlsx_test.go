@@ -0,0 +1,151 @@
+package fcheck
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeXlsx writes a minimal workbook with the sheets (name -> sheetData rows xml)
+func writeXlsx(t *testing.T, sheets [][2]string) string {
+	fileName := filepath.Join(t.TempDir(), "test.xlsx")
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	files := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"></Types>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>country</t></si><si><t>amount</t></si><si><t>PL</t></si><si><r><t>D</t></r><r><t>E</t></r></si><si><t>when</t></si></sst>`,
+		"xl/styles.xml": `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd hh:mm"/><numFmt numFmtId="165" formatCode="&quot;days&quot; 0.00"/></numFmts>` +
+			`<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+	}
+	var wb, rels strings.Builder
+	wb.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, s := range sheets {
+		id := "rId" + string(rune('1'+i))
+		wb.WriteString(`<sheet name="` + s[0] + `" sheetId="` + string(rune('1'+i)) + `" r:id="` + id + `"/>`)
+		rels.WriteString(`<Relationship Id="` + id + `" Target="worksheets/sheet` + string(rune('1'+i)) + `.xml"/>`)
+		files["xl/worksheets/sheet"+string(rune('1'+i))+".xml"] = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			s[1] + `</sheetData></worksheet>`
+	}
+	wb.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+	files["xl/workbook.xml"] = wb.String()
+	files["xl/_rels/workbook.xml.rels"] = rels.String()
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+const testSheet = `<row r="1"><c r="A1" t="inlineStr"><is><t>Sales report</t></is></c></row>` +
+	`<row r="3"><c r="A3" t="s"><v>0</v></c><c r="B3" t="s"><v>1</v></c><c r="C3" t="s"><v>4</v></c><c r="E3" t="inlineStr"><is><t>ok</t></is></c></row>` +
+	`<row r="4"><c r="A4" t="s"><v>2</v></c><c r="B4"><v>10</v></c><c r="C4" s="1"><v>44927</v></c><c r="E4" t="b"><v>1</v></c></row>` +
+	`<row r="6"><c r="A6" t="s"><v>3</v></c><c r="B6"><v>2.5</v></c><c r="C6" s="2"><v>44927.5</v></c><c r="D6" s="3"><v>7</v></c><c r="E6" t="e"><v>#N/A</v></c></row>`
+
+func TestXlsxReader(t *testing.T) {
+	fileName := writeXlsx(t, [][2]string{{"Notes", `<row r="1"><c r="A1" t="inlineStr"><is><t>x</t></is></c></row>`}, {"Data", testSheet}})
+	fr, err := NewFileReader(fileName, ReaderOptions{"xlsx": XlsxOptions{Sheet: "Data", HeaderRow: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fr.(*XlsxReader); !ok {
+		t.Fatalf("expected an xlsx reader, got %T", fr)
+	}
+	fr.Init()
+	fields, types := fr.GetFields(), fr.GetTypes()
+	if strings.Join(fields, ",") != "country,amount,when,D,ok" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+	if types[0] != DT_string || types[1] != DT_float || types[2] != DT_string || types[3] != DT_int || types[4] != DT_string {
+		t.Errorf("unexpected types: %v", types)
+	}
+	n := 0
+	for range fr.Read() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 rows, got %d", n)
+	}
+	if info := fr.GetFileInfo(); info != "xlsx, sheet Data (2 sheets), 2 rows, 1 error cells" {
+		t.Errorf("unexpected info: %s", info)
+	}
+	// values are checked on the cells, the row sent by Read is reused
+	var rows []string
+	fr.(*XlsxReader).scan(func(row int, cells []xlsxCell) bool {
+		rows = append(rows, fmt.Sprint(row, cells))
+		return true
+	})
+	expected := []string{"1 [{0 Sales report}]", "3 [{0 country} {1 amount} {2 when} {4 ok}]", "4 [{0 PL} {1 10} {2 2023-01-01} {4 true}]",
+		"6 [{0 DE} {1 2.5} {2 2023-01-01 12:00:00} {3 7}]"}
+	if strings.Join(rows, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %q, got %q", expected, rows)
+	}
+
+	// by index, without a header, projected
+	xr := NewXlsxReader(fileName, XlsxOptions{Sheet: "2", HeaderRow: -1})
+	xr.Init()
+	if strings.Join(xr.GetFields(), ",") != "A,B,C,D,E" || xr.GetTypes()[1] != DT_string {
+		t.Errorf("unexpected fields %v or types %v", xr.GetFields(), xr.GetTypes())
+	}
+	if err := xr.Project([]string{"B"}); err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	for row := range xr.Read() {
+		if len(row) != 1 {
+			t.Fatalf("unexpected row: %v", row)
+		}
+		n++
+	}
+	if n != 4 {
+		t.Errorf("expected 4 rows, got %d", n)
+	}
+}
+
+func TestXlsxHelpers(t *testing.T) {
+	for _, tt := range []struct {
+		serial   float64
+		date1904 bool
+		expected string
+	}{
+		{44927, false, "2023-01-01"},
+		{44927.75, false, "2023-01-01 18:00:00"},
+		{0.5, false, "12:00:00"},
+		{59, false, "1900-02-28"},
+		{61, false, "1900-03-01"},
+		{0, true, "1904-01-01"},
+	} {
+		if s := xlsxDate(tt.serial, tt.date1904); s != tt.expected {
+			t.Errorf("%v: expected %s, got %s", tt.serial, tt.expected, s)
+		}
+	}
+	for code, expected := range map[string]bool{"yyyy-mm-dd": true, "[h]:mm:ss": true, `"days" 0.00`: false, "[Red]0.00": false, "General": false, `0.00\ "m"`: false} {
+		if isDateFormat(code) != expected {
+			t.Errorf("%s: expected %v", code, expected)
+		}
+	}
+	for _, col := range []int{0, 25, 26, 701, 702, 16383} {
+		if k := columnIndex(columnName(col) + "1"); k != col {
+			t.Errorf("expected column %d, got %d (%s)", col, k, columnName(col))
+		}
+	}
+	if columnName(27) != "AB" || columnIndex("1") != -1 {
+		t.Error("unexpected column names")
+	}
+}
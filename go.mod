@@ -2,10 +2,15 @@ module gocf
 
 go 1.18
 
-require github.com/hamba/avro v1.7.0
+require (
+	github.com/golang/snappy v0.0.4
+	github.com/hamba/avro v1.7.0
+	github.com/klauspost/compress v1.17.0
+	github.com/pierrec/lz4/v4 v4.1.21
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/golang/snappy v0.0.4 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect